@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func cat(args []string) error {
+	set := flag.NewFlagSet("cat", flag.ContinueOnError)
+	number := set.Bool("n", false, "Number output lines")
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	line := 0
+	return eachInput("cat", set.Args(), func(_ string, r io.Reader) error {
+		if !*number {
+			_, err := io.Copy(out, r)
+			return err
+		}
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			line++
+			fmt.Fprintf(out, "%6d\t%s\n", line, scanner.Text())
+		}
+		return scanner.Err()
+	})
+}
+
+func tee(args []string) error {
+	set := flag.NewFlagSet("tee", flag.ContinueOnError)
+	appendFiles := set.Bool("a", false, "Append to files instead of overwriting them")
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if *appendFiles {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	writers := []io.Writer{os.Stdout}
+	for _, name := range set.Args() {
+		f, err := os.OpenFile(name, flags, 0644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		writers = append(writers, f)
+	}
+	_, err := io.Copy(io.MultiWriter(writers...), os.Stdin)
+	return err
+}
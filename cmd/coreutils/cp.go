@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+func cp(args []string) error {
+	set := flag.NewFlagSet("cp", flag.ContinueOnError)
+	recursive := set.Bool("r", false, "Copy directories recursively")
+	set.BoolVar(recursive, "R", false, "Copy directories recursively")
+	if err := set.Parse(splitBundledFlags(args)); err != nil {
+		return err
+	}
+	if set.NArg() < 2 {
+		return errors.New("usage: cp [-r] SOURCE... DEST")
+	}
+	sources, dest := set.Args()[:set.NArg()-1], set.Arg(set.NArg()-1)
+	destInfo, err := os.Stat(dest)
+	destIsDir := err == nil && destInfo.IsDir()
+	if len(sources) > 1 && !destIsDir {
+		return fmt.Errorf("target %q is not a directory", dest)
+	}
+
+	for _, source := range sources {
+		target := dest
+		if destIsDir {
+			target = filepath.Join(dest, filepath.Base(source))
+		}
+		if err := copyPath(source, target, *recursive); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyPath(source, target string, recursive bool) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return copyFile(source, target, info.Mode())
+	}
+	if !recursive {
+		return fmt.Errorf("-r not specified; omitting directory %q", source)
+	}
+	return filepath.WalkDir(source, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		targetPath := filepath.Join(target, rel)
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return os.MkdirAll(targetPath, info.Mode().Perm()|0700)
+		}
+		return copyFile(path, targetPath, info.Mode())
+	})
+}
+
+func copyFile(source, target string, mode os.FileMode) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+)
+
+const blockSize = 1024
+
+func du(args []string) error {
+	set := flag.NewFlagSet("du", flag.ContinueOnError)
+	summarize := set.Bool("s", false, "Print only a total for each argument")
+	all := set.Bool("a", false, "Print sizes of files, not just directories")
+	human := set.Bool("h", false, "Print sizes in human readable format, like 1.5M")
+	if err := set.Parse(splitBundledFlags(args)); err != nil {
+		return err
+	}
+	roots := set.Args()
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+	format := func(size int64) string {
+		if *human {
+			return humanSize(size)
+		}
+		return fmt.Sprint((size + blockSize - 1) / blockSize)
+	}
+
+	for _, root := range roots {
+		root = filepath.Clean(root)
+		sizes := make(map[string]int64)
+		var dirs []string
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() {
+				dirs = append(dirs, path)
+				return nil
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			for dir := path; ; dir = filepath.Dir(dir) {
+				sizes[dir] += info.Size()
+				if dir == root || dir == filepath.Dir(dir) {
+					break
+				}
+			}
+			if *all && !*summarize {
+				fmt.Printf("%s\t%s\n", format(info.Size()), path)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if *summarize || len(dirs) == 0 {
+			fmt.Printf("%s\t%s\n", format(sizes[root]), root)
+			continue
+		}
+		// print directories after their contents
+		for i := len(dirs) - 1; i >= 0; i-- {
+			fmt.Printf("%s\t%s\n", format(sizes[dirs[i]]), dirs[i])
+		}
+	}
+	return nil
+}
+
+func humanSize(size int64) string {
+	const units = "KMGTPE"
+	if size < blockSize {
+		return fmt.Sprint(size)
+	}
+	value := float64(size)
+	unit := -1
+	for value >= blockSize && unit < len(units)-1 {
+		value /= blockSize
+		unit++
+	}
+	if value < 10 {
+		return fmt.Sprintf("%.1f%c", value, units[unit])
+	}
+	return fmt.Sprintf("%.0f%c", value, units[unit])
+}
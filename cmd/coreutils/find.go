@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// find supports a subset of find(1)'s expressions, all of which must match: -name, -iname, -path, -type, -maxdepth, and -mindepth
+func find(args []string) error {
+	var roots []string
+	for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		roots = append(roots, args[0])
+		args = args[1:]
+	}
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+
+	var matchers []func(path string, entry fs.DirEntry) (bool, error)
+	maxDepth, minDepth := -1, 0
+	for len(args) > 0 {
+		if len(args) < 2 {
+			return fmt.Errorf("missing argument to %q", args[0])
+		}
+		option, value := args[0], args[1]
+		args = args[2:]
+		switch option {
+		case "-name", "-iname":
+			pattern := value
+			if option == "-iname" {
+				pattern = strings.ToLower(pattern)
+			}
+			matchers = append(matchers, func(path string, _ fs.DirEntry) (bool, error) {
+				name := filepath.Base(path)
+				if option == "-iname" {
+					name = strings.ToLower(name)
+				}
+				return filepath.Match(pattern, name)
+			})
+		case "-path":
+			matchers = append(matchers, func(path string, _ fs.DirEntry) (bool, error) {
+				return filepath.Match(value, path)
+			})
+		case "-type":
+			if value != "f" && value != "d" {
+				return fmt.Errorf("unknown type %q, expected f or d", value)
+			}
+			matchers = append(matchers, func(_ string, entry fs.DirEntry) (bool, error) {
+				return entry.IsDir() == (value == "d"), nil
+			})
+		case "-maxdepth", "-mindepth":
+			depth, err := strconv.Atoi(value)
+			if err != nil || depth < 0 {
+				return fmt.Errorf("invalid depth %q", value)
+			}
+			if option == "-maxdepth" {
+				maxDepth = depth
+			} else {
+				minDepth = depth
+			}
+		default:
+			return fmt.Errorf("unknown predicate %q", option)
+		}
+	}
+
+	var walkErr error
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			depth := pathDepth(root, path)
+			if maxDepth >= 0 && depth > maxDepth {
+				if entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if depth < minDepth {
+				return nil
+			}
+			for _, match := range matchers {
+				matched, err := match(path, entry)
+				if err != nil || !matched {
+					return err
+				}
+			}
+			fmt.Println(path)
+			return nil
+		})
+		if err != nil {
+			walkErr = errors.Join(walkErr, err)
+		}
+	}
+	return walkErr
+}
+
+// pathDepth returns the number of directories 'path' is below 'root'
+func pathDepth(root, path string) int {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// grep searches with Go's regexp syntax, which is close to POSIX extended regular expressions
+func grep(args []string) error {
+	set := flag.NewFlagSet("grep", flag.ContinueOnError)
+	ignoreCase := set.Bool("i", false, "Ignore case")
+	invert := set.Bool("v", false, "Select non-matching lines")
+	lineNumbers := set.Bool("n", false, "Print line numbers")
+	count := set.Bool("c", false, "Print only a count of matching lines per file")
+	filesOnly := set.Bool("l", false, "Print only names of files with matches")
+	recursive := set.Bool("r", false, "Search directories recursively")
+	fixed := set.Bool("F", false, "Match the pattern as a fixed string")
+	set.Bool("E", false, "Use extended regular expressions (always on)")
+	noFilenames := set.Bool("h", false, "Never print file names")
+	if err := set.Parse(splitBundledFlags(args)); err != nil {
+		return err
+	}
+	if set.NArg() == 0 {
+		return errors.New("usage: grep [-icEFhlnrv] PATTERN [FILE...]")
+	}
+	pattern := set.Arg(0)
+	if *fixed {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if *ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	expr, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+
+	files := set.Args()[1:]
+	if *recursive {
+		if len(files) == 0 {
+			files = []string{"."}
+		}
+		files, err = walkFiles(files)
+		if err != nil {
+			return err
+		}
+	}
+	showNames := (len(files) > 1 || *recursive) && !*noFilenames
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	matched := false
+	err = eachInput("grep", files, func(name string, r io.Reader) error {
+		if name == "-" {
+			name = "(standard input)"
+		}
+		prefix := ""
+		if showNames {
+			prefix = name + ":"
+		}
+		matches := 0
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(nil, 1<<20)
+		for lineNumber := 1; scanner.Scan(); lineNumber++ {
+			line := scanner.Text()
+			if expr.MatchString(line) == *invert {
+				continue
+			}
+			matches++
+			if *count || *filesOnly {
+				continue
+			}
+			if *lineNumbers {
+				fmt.Fprintf(out, "%s%d:%s\n", prefix, lineNumber, line)
+			} else {
+				fmt.Fprintf(out, "%s%s\n", prefix, line)
+			}
+		}
+		switch {
+		case *filesOnly && matches > 0:
+			fmt.Fprintln(out, name)
+		case *count:
+			fmt.Fprintf(out, "%s%d\n", prefix, matches)
+		}
+		matched = matched || matches > 0
+		return scanner.Err()
+	})
+	if err != nil {
+		if _, isExit := err.(errExitCode); !isExit {
+			fmt.Fprintln(os.Stderr, "grep:", err)
+		}
+		return errExitCode(2)
+	}
+	if !matched {
+		return errExitCode(1)
+	}
+	return nil
+}
+
+// walkFiles expands directories in 'paths' to the regular files inside them
+func walkFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err == nil && entry.Type().IsRegular() {
+				files = append(files, path)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
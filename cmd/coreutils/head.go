@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func head(args []string) error {
+	set := flag.NewFlagSet("head", flag.ContinueOnError)
+	lines := set.Int("n", 10, "Number of lines to print")
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	files := set.Args()
+	return eachInput("head", files, func(name string, r io.Reader) error {
+		printHeader(out, files, name)
+		scanner := bufio.NewScanner(r)
+		for i := 0; i < *lines && scanner.Scan(); i++ {
+			fmt.Fprintln(out, scanner.Text())
+		}
+		return scanner.Err()
+	})
+}
+
+func tail(args []string) error {
+	set := flag.NewFlagSet("tail", flag.ContinueOnError)
+	lines := set.Int("n", 10, "Number of lines to print")
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+	if *lines < 0 {
+		return fmt.Errorf("invalid number of lines: %d", *lines)
+	}
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	files := set.Args()
+	return eachInput("tail", files, func(name string, r io.Reader) error {
+		printHeader(out, files, name)
+		ring := make([]string, 0, *lines) // the last lines read, oldest first
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			if *lines == 0 {
+				continue
+			}
+			if len(ring) == *lines {
+				ring = ring[1:]
+			}
+			ring = append(ring, scanner.Text())
+		}
+		for _, line := range ring {
+			fmt.Fprintln(out, line)
+		}
+		return scanner.Err()
+	})
+}
+
+// printHeader prints "==> name <==" before each file's output if there are several files
+func printHeader(w io.Writer, files []string, name string) {
+	if len(files) < 2 {
+		return
+	}
+	if name != files[0] {
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "==> %s <==\n", name)
+}
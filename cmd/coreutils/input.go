@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// eachInput calls 'fn' with each named file, or stdin if none are named or the name is "-".
+// Files that fail to open are reported and skipped, and the first error is returned after all are processed.
+func eachInput(tool string, names []string, fn func(name string, r io.Reader) error) error {
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+	var firstErr error
+	for _, name := range names {
+		err := withInput(name, fn)
+		if err != nil {
+			if len(names) > 1 {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", tool, err)
+				err = errExitCode(1)
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+func withInput(name string, fn func(name string, r io.Reader) error) error {
+	if name == "-" {
+		return fn(name, os.Stdin)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return fn(name, f)
+}
@@ -0,0 +1,97 @@
+// Command coreutils provides basic file and text tools for the shell: cat, cp, du, find, grep, head, tail, tar, wc and more.
+//
+// Like BusyBox, it runs the tool named by argv[0], or by its first argument if invoked as 'coreutils'.
+// The per-tool entries in /bin are "#!/bin/coreutils" scripts, so the tool's path arrives as the first argument.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var tools = map[string]func(args []string) error{
+	"basename": basename,
+	"cat":      cat,
+	"cp":       cp,
+	"dirname":  dirname,
+	"du":       du,
+	"find":     find,
+	"grep":     grep,
+	"head":     head,
+	"sort":     sortLines,
+	"tail":     tail,
+	"tar":      tarFiles,
+	"tee":      tee,
+	"uniq":     uniq,
+	"wc":       wc,
+}
+
+// errExitCode exits quietly with a status, for tools like grep whose status reports a result
+type errExitCode int
+
+func (e errExitCode) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+func main() {
+	args := os.Args
+	name := filepath.Base(args[0])
+	if _, ok := tools[name]; !ok && len(args) > 1 {
+		args = args[1:]
+		name = filepath.Base(args[0])
+	}
+	if name == "--list" {
+		// the package index lists each tool from here, so installs link every tool
+		fmt.Println(strings.Join(toolNames(), "\n"))
+		return
+	}
+	tool, ok := tools[name]
+	if !ok {
+		fmt.Fprintln(os.Stderr, usage())
+		os.Exit(1)
+	}
+	err := tool(args[1:])
+	if code, ok := err.(errExitCode); ok {
+		os.Exit(int(code))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+		os.Exit(1)
+	}
+}
+
+func usage() string {
+	return "Usage: coreutils [" + strings.Join(toolNames(), "|") + "] [args...] | coreutils --list"
+}
+
+// toolNames returns the sorted names of all tools
+func toolNames() []string {
+	names := make([]string, 0, len(tools))
+	for name := range tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// splitBundledFlags splits bundled single-letter flags like -xzf into -x -z -f, for tools used to them.
+// Stops at the first argument that isn't a flag, so file names aren't split.
+func splitBundledFlags(args []string) []string {
+	var result []string
+	for i, arg := range args {
+		if arg == "--" || len(arg) < 2 || arg[0] != '-' {
+			return append(result, args[i:]...)
+		}
+		if len(arg) == 2 || arg[1] == '-' {
+			result = append(result, arg)
+			continue
+		}
+		for _, flag := range arg[1:] {
+			result = append(result, "-"+string(flag))
+		}
+	}
+	return result
+}
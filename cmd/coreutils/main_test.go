@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testArgsEnv = "COREUTILS_TEST_ARGS"
+
+// TestMain runs coreutils itself when re-executed by runCoreutils, so each tool runs in its own process with real stdio and exit codes
+func TestMain(m *testing.M) {
+	if args := os.Getenv(testArgsEnv); args != "" {
+		if err := json.Unmarshal([]byte(args), &os.Args); err != nil {
+			panic(err)
+		}
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runCoreutils runs coreutils with 'args' as its argv in 'dir'
+func runCoreutils(t *testing.T, dir, stdin string, args ...string) (stdout, stderr string, exitCode int) {
+	t.Helper()
+	argsJSON, err := json.Marshal(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), testArgsEnv+"="+string(argsJSON))
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader(stdin)
+	var out, errOut bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &errOut
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		exitCode = exitErr.ExitCode()
+	case err != nil:
+		t.Fatal(err)
+	}
+	return out.String(), errOut.String(), exitCode
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestTools(t *testing.T) {
+	for _, tc := range []struct {
+		description string
+		args        []string
+		files       map[string]string
+		stdin       string
+		expect      string
+		exitCode    int
+	}{
+		{description: "basename", args: []string{"basename", "/a/b.txt", ".txt"}, expect: "b\n"},
+		{description: "dirname", args: []string{"dirname", "/a/b", "c"}, expect: "/a\n.\n"},
+		{description: "cat stdin", args: []string{"cat"}, stdin: "hello\n", expect: "hello\n"},
+		{description: "cat files", args: []string{"cat", "a", "-", "b"}, files: map[string]string{"a": "1\n", "b": "3\n"}, stdin: "2\n", expect: "1\n2\n3\n"},
+		{description: "cat numbered", args: []string{"cat", "-n"}, stdin: "a\nb\n", expect: "     1\ta\n     2\tb\n"},
+		{description: "cat missing file", args: []string{"cat", "a", "missing"}, files: map[string]string{"a": "1\n"}, expect: "1\n", exitCode: 1},
+		{description: "head", args: []string{"head", "-n", "2"}, stdin: "1\n2\n3\n", expect: "1\n2\n"},
+		{description: "tail", args: []string{"tail", "-n", "2"}, stdin: "1\n2\n3\n", expect: "2\n3\n"},
+		{description: "wc", args: []string{"wc"}, stdin: "a b\nc\n", expect: "       2       3       6\n"},
+		{description: "wc bundled flags", args: []string{"wc", "-lw", "a"}, files: map[string]string{"a": "a b\nc\n"}, expect: "       2       3 a\n"},
+		{description: "grep", args: []string{"grep", "-n", "b"}, stdin: "abc\nxyz\nb\n", expect: "1:abc\n3:b\n"},
+		{description: "grep invert count", args: []string{"grep", "-vc", "b"}, stdin: "abc\nxyz\nb\n", expect: "1\n"},
+		{description: "grep no match", args: []string{"grep", "q"}, stdin: "abc\n", exitCode: 1},
+		{description: "grep ignore case", args: []string{"grep", "-i", "ABC"}, stdin: "abc\nxyz\n", expect: "abc\n"},
+		{description: "sort", args: []string{"sort"}, stdin: "b\nc\na\n", expect: "a\nb\nc\n"},
+		{description: "sort reverse numeric", args: []string{"sort", "-rn"}, stdin: "2\n10\n1\n", expect: "10\n2\n1\n"},
+		{description: "sort unique", args: []string{"sort", "-u"}, stdin: "b\na\nb\n", expect: "a\nb\n"},
+		{description: "uniq", args: []string{"uniq"}, stdin: "a\na\nb\na\n", expect: "a\nb\na\n"},
+		{description: "uniq count", args: []string{"uniq", "-c"}, stdin: "a\na\nb\n", expect: "      2 a\n      1 b\n"},
+		{description: "find", args: []string{"find", "d", "-type", "f"}, files: map[string]string{"d/a": "", "d/e/b": ""}, expect: "d/a\nd/e/b\n"},
+		{description: "find name", args: []string{"find", "d", "-name", "*.go"}, files: map[string]string{"d/a.go": "", "d/b.txt": ""}, expect: "d/a.go\n"},
+		{description: "unknown tool", args: []string{"coreutils", "nope"}, exitCode: 1},
+	} {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+			stdout, stderr, exitCode := runCoreutils(t, dir, tc.stdin, tc.args...)
+			if stdout != tc.expect {
+				t.Errorf("Expected output %q, got %q", tc.expect, stdout)
+			}
+			if exitCode != tc.exitCode {
+				t.Errorf("Expected exit code %d, got %d. Stderr: %s", tc.exitCode, exitCode, stderr)
+			}
+		})
+	}
+}
+
+func TestToolNames(t *testing.T) {
+	for _, args := range [][]string{
+		{"/bin/wc", "-l"},              // linked as the tool
+		{"coreutils", "wc", "-l"},      // run by name
+		{"coreutils", "/bin/wc", "-l"}, // run by a "#!/bin/coreutils" script
+	} {
+		stdout, stderr, exitCode := runCoreutils(t, t.TempDir(), "a\nb\n", args...)
+		if expect := "       2\n"; stdout != expect || exitCode != 0 {
+			t.Errorf("%q: Expected output %q, got %q with exit code %d: %s", args, expect, stdout, exitCode, stderr)
+		}
+	}
+
+	stdout, _, exitCode := runCoreutils(t, t.TempDir(), "", "coreutils", "--list")
+	if exitCode != 0 {
+		t.Fatalf("Expected --list to succeed, got exit code %d", exitCode)
+	}
+	if list := strings.Fields(stdout); !reflect.DeepEqual(toolNames(), list) {
+		t.Errorf("Expected tools %q, got %q", toolNames(), list)
+	}
+}
+
+func TestTeeAndCopy(t *testing.T) {
+	dir := t.TempDir()
+	stdout, stderr, exitCode := runCoreutils(t, dir, "hello\n", "tee", "a")
+	if stdout != "hello\n" || exitCode != 0 {
+		t.Fatalf("tee: Unexpected output %q with exit code %d: %s", stdout, exitCode, stderr)
+	}
+	if _, stderr, exitCode := runCoreutils(t, dir, "", "cp", "a", "b"); exitCode != 0 {
+		t.Fatalf("cp: Exit code %d: %s", exitCode, stderr)
+	}
+	contents, err := os.ReadFile(filepath.Join(dir, "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != "hello\n" {
+		t.Errorf("Expected copied contents %q, got %q", "hello\n", contents)
+	}
+}
+
+func TestTar(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"src/a": "a", "src/d/b": "b"})
+	if _, stderr, exitCode := runCoreutils(t, dir, "", "tar", "-czf", "out.tar.gz", "src"); exitCode != 0 {
+		t.Fatalf("Create: Exit code %d: %s", exitCode, stderr)
+	}
+	stdout, stderr, exitCode := runCoreutils(t, dir, "", "tar", "-tzf", "out.tar.gz")
+	if exitCode != 0 {
+		t.Fatalf("List: Exit code %d: %s", exitCode, stderr)
+	}
+	for _, name := range []string{"src/a", "src/d/b"} {
+		if !strings.Contains(stdout, name) {
+			t.Errorf("Expected %q in listing %q", name, stdout)
+		}
+	}
+
+	extractDir := filepath.Join(dir, "extract")
+	if err := os.Mkdir(extractDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if _, stderr, exitCode := runCoreutils(t, dir, "", "tar", "-xzf", "out.tar.gz", "-C", extractDir); exitCode != 0 {
+		t.Fatalf("Extract: Exit code %d: %s", exitCode, stderr)
+	}
+	contents, err := os.ReadFile(filepath.Join(extractDir, "src", "d", "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != "b" {
+		t.Errorf("Expected extracted contents %q, got %q", "b", contents)
+	}
+}
+
+func TestSplitBundledFlags(t *testing.T) {
+	for _, tc := range []struct {
+		args   []string
+		expect []string
+	}{
+		{nil, nil},
+		{[]string{"-xzf", "file"}, []string{"-x", "-z", "-f", "file"}},
+		{[]string{"-l", "--long", "-", "-ab"}, []string{"-l", "--long", "-", "-ab"}},
+		{[]string{"-ab", "--", "-cd"}, []string{"-a", "-b", "--", "-cd"}},
+	} {
+		if result := splitBundledFlags(tc.args); !reflect.DeepEqual(tc.expect, result) {
+			t.Errorf("splitBundledFlags(%q): Expected %q, got %q", tc.args, tc.expect, result)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+func basename(args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return errors.New("usage: basename NAME [SUFFIX]")
+	}
+	name := path.Base(args[0])
+	if len(args) == 2 && name != args[1] {
+		name = strings.TrimSuffix(name, args[1])
+	}
+	fmt.Println(name)
+	return nil
+}
+
+func dirname(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: dirname NAME...")
+	}
+	for _, name := range args {
+		fmt.Println(path.Dir(name))
+	}
+	return nil
+}
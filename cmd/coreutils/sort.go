@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func sortLines(args []string) error {
+	set := flag.NewFlagSet("sort", flag.ContinueOnError)
+	reverse := set.Bool("r", false, "Reverse the result")
+	numeric := set.Bool("n", false, "Compare by leading numeric value")
+	unique := set.Bool("u", false, "Print only the first of equal lines")
+	if err := set.Parse(splitBundledFlags(args)); err != nil {
+		return err
+	}
+	var lines []string
+	err := eachInput("sort", set.Args(), func(_ string, r io.Reader) error {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		return scanner.Err()
+	})
+	if err != nil {
+		return err
+	}
+
+	compare := strings.Compare
+	if *numeric {
+		compare = func(a, b string) int {
+			x, y := leadingNumber(a), leadingNumber(b)
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			default:
+				return strings.Compare(a, b)
+			}
+		}
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		if *reverse {
+			return compare(lines[i], lines[j]) > 0
+		}
+		return compare(lines[i], lines[j]) < 0
+	})
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	for i, line := range lines {
+		if *unique && i > 0 && compare(line, lines[i-1]) == 0 {
+			continue
+		}
+		fmt.Fprintln(out, line)
+	}
+	return nil
+}
+
+// leadingNumber parses the number at the start of 's', or 0 if there isn't one
+func leadingNumber(s string) float64 {
+	s = strings.TrimSpace(s)
+	end := 0
+	for end < len(s) && (s[end] >= '0' && s[end] <= '9' || s[end] == '.' || end == 0 && s[end] == '-') {
+		end++
+	}
+	n, _ := strconv.ParseFloat(s[:end], 64)
+	return n
+}
+
+func uniq(args []string) error {
+	set := flag.NewFlagSet("uniq", flag.ContinueOnError)
+	count := set.Bool("c", false, "Prefix lines with their number of occurrences")
+	duplicates := set.Bool("d", false, "Print only repeated lines")
+	unique := set.Bool("u", false, "Print only lines that are not repeated")
+	if err := set.Parse(splitBundledFlags(args)); err != nil {
+		return err
+	}
+	if set.NArg() > 1 {
+		return fmt.Errorf("extra operand %q", set.Arg(1))
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	return eachInput("uniq", set.Args(), func(_ string, r io.Reader) error {
+		var previous string
+		occurrences := 0
+		flush := func() {
+			if occurrences == 0 || *duplicates && occurrences == 1 || *unique && occurrences > 1 {
+				return
+			}
+			if *count {
+				fmt.Fprintf(out, "%7d %s\n", occurrences, previous)
+			} else {
+				fmt.Fprintln(out, previous)
+			}
+		}
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if occurrences > 0 && line == previous {
+				occurrences++
+				continue
+			}
+			flush()
+			previous, occurrences = line, 1
+		}
+		flush()
+		return scanner.Err()
+	})
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func tarFiles(args []string) error {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		args[0] = "-" + args[0] // support bundled flags without a dash, like 'tar xzf'
+	}
+	args = splitBundledFlags(args)
+	set := flag.NewFlagSet("tar", flag.ContinueOnError)
+	create := set.Bool("c", false, "Create an archive")
+	extract := set.Bool("x", false, "Extract an archive")
+	list := set.Bool("t", false, "List an archive's contents")
+	gzipped := set.Bool("z", false, "Compress or decompress with gzip")
+	verbose := set.Bool("v", false, "Print file names as they're processed")
+	file := set.String("f", "-", "Archive file, or - for stdin or stdout")
+	dir := set.String("C", "", "Change to this directory after opening the archive")
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+	switch {
+	case *create && !*extract && !*list:
+		if set.NArg() == 0 {
+			return errors.New("refusing to create an empty archive")
+		}
+		out := io.WriteCloser(os.Stdout)
+		if *file != "-" {
+			f, err := os.Create(*file)
+			if err != nil {
+				return err
+			}
+			out = f
+		}
+		err := chdir(*dir)
+		if err == nil {
+			err = createTar(out, set.Args(), *gzipped, *verbose)
+		}
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	case (*extract || *list) && !*create && !(*extract && *list):
+		in := io.ReadCloser(os.Stdin)
+		if *file != "-" {
+			f, err := os.Open(*file)
+			if err != nil {
+				return err
+			}
+			in = f
+		}
+		defer in.Close()
+		if err := chdir(*dir); err != nil {
+			return err
+		}
+		return readTar(in, *gzipped, *extract, *verbose || *list)
+	default:
+		return errors.New("usage: tar -c|-x|-t [-zv] [-f FILE] [-C DIR] [FILE...]")
+	}
+}
+
+func chdir(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	return os.Chdir(dir)
+}
+
+func createTar(w io.Writer, paths []string, gzipped, verbose bool) error {
+	var gzipWriter *gzip.Writer
+	if gzipped {
+		gzipWriter = gzip.NewWriter(w)
+		w = gzipWriter
+	}
+	archive := tar.NewWriter(w)
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			header, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			header.Name = filepath.ToSlash(path)
+			if info.IsDir() {
+				header.Name += "/"
+			}
+			if verbose {
+				fmt.Fprintln(os.Stderr, header.Name)
+			}
+			if err := archive.WriteHeader(header); err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = io.Copy(archive, f)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	if gzipWriter != nil {
+		return gzipWriter.Close()
+	}
+	return nil
+}
+
+func readTar(r io.Reader, gzipped, extract, verbose bool) error {
+	if gzipped {
+		gzipReader, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gzipReader.Close()
+		r = gzipReader
+	}
+	archive := tar.NewReader(r)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if verbose {
+			fmt.Println(header.Name)
+		}
+		if !extract {
+			continue
+		}
+		if err := extractEntry(archive, header); err != nil {
+			return err
+		}
+	}
+}
+
+func extractEntry(r io.Reader, header *tar.Header) error {
+	path := filepath.Clean(filepath.FromSlash(header.Name))
+	if filepath.IsAbs(path) || path == ".." || strings.HasPrefix(path, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("refusing to extract outside the current directory: %q", header.Name)
+	}
+	mode := header.FileInfo().Mode()
+	switch header.Typeflag {
+	case tar.TypeDir:
+		return os.MkdirAll(path, mode.Perm()|0700)
+	case tar.TypeReg:
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, r); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	default:
+		fmt.Fprintf(os.Stderr, "tar: skipping unsupported entry %q\n", header.Name)
+		return nil
+	}
+}
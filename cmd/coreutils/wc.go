@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"unicode"
+	"unicode/utf8"
+)
+
+type wordCount struct {
+	lines, words, chars, bytes int64
+}
+
+func wc(args []string) error {
+	set := flag.NewFlagSet("wc", flag.ContinueOnError)
+	lines := set.Bool("l", false, "Print the line count")
+	words := set.Bool("w", false, "Print the word count")
+	chars := set.Bool("m", false, "Print the character count")
+	bytes := set.Bool("c", false, "Print the byte count")
+	if err := set.Parse(splitBundledFlags(args)); err != nil {
+		return err
+	}
+	if !*lines && !*words && !*chars && !*bytes {
+		*lines, *words, *bytes = true, true, true
+	}
+	printCount := func(count wordCount, name string) {
+		for _, field := range []struct {
+			enabled bool
+			value   int64
+		}{
+			{*lines, count.lines},
+			{*words, count.words},
+			{*chars, count.chars},
+			{*bytes, count.bytes},
+		} {
+			if field.enabled {
+				fmt.Printf("%8d", field.value)
+			}
+		}
+		if name != "" {
+			fmt.Print(" ", name)
+		}
+		fmt.Println()
+	}
+
+	var total wordCount
+	files := set.Args()
+	err := eachInput("wc", files, func(name string, r io.Reader) error {
+		count, err := countWords(r)
+		if err != nil {
+			return err
+		}
+		total.lines += count.lines
+		total.words += count.words
+		total.chars += count.chars
+		total.bytes += count.bytes
+		if name == "-" {
+			name = ""
+		}
+		printCount(count, name)
+		return nil
+	})
+	if len(files) > 1 {
+		printCount(total, "total")
+	}
+	return err
+}
+
+func countWords(r io.Reader) (wordCount, error) {
+	var count wordCount
+	reader := bufio.NewReader(r)
+	inWord := false
+	for {
+		char, size, err := reader.ReadRune()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+		count.bytes += int64(size)
+		if char != utf8.RuneError || size > 1 {
+			count.chars++
+		}
+		if char == '\n' {
+			count.lines++
+		}
+		if unicode.IsSpace(char) {
+			inWord = false
+		} else if !inWord {
+			inWord = true
+			count.words++
+		}
+	}
+}
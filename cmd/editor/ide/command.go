@@ -12,20 +12,10 @@ import (
 )
 
 const (
-	goBinaryPath      = "/usr/local/go/bin/js_wasm/go"
 	buildConsoleIndex = 0
 )
 
-// runGoProcess optimizes runProcess by skipping the wait time for listing PATH directories on startup
-func (w *window) runGoProcess(console TaskConsole, args ...string) promise.JS {
-	return w.runRawProcess(console, goBinaryPath, "go", args...)
-}
-
 func (w *window) runProcess(console TaskConsole, name string, args ...string) promise.JS {
-	return w.runRawProcess(console, name, name, args...)
-}
-
-func (w *window) runRawProcess(console TaskConsole, fullPath, name string, args ...string) promise.JS {
 	resolve, reject, prom := promise.New()
 	go func() {
 		var success bool
@@ -33,7 +23,7 @@ func (w *window) runRawProcess(console TaskConsole, fullPath, name string, args
 		defer func() {
 			log.Printf("Process [%s %s] finished: %6.2fs", name, strings.Join(args, " "), elapsed.Seconds())
 		}()
-		success, elapsed = w.startProcess(console, fullPath, name, args...)
+		success, elapsed = w.startProcess(console, name, args...)
 		if success {
 			resolve(nil)
 		} else {
@@ -43,7 +33,7 @@ func (w *window) runRawProcess(console TaskConsole, fullPath, name string, args
 	return prom
 }
 
-func (w *window) startProcess(console TaskConsole, rawPath, name string, args ...string) (success bool, elapsed time.Duration) {
+func (w *window) startProcess(console TaskConsole, name string, args ...string) (success bool, elapsed time.Duration) {
 	if !w.showLoading.CAS(false, true) {
 		return false, 0
 	}
@@ -54,7 +44,7 @@ func (w *window) startProcess(console TaskConsole, rawPath, name string, args ..
 		w.loadingElem.RemoveClass("loading")
 	}()
 
-	ctx, err := console.Start(rawPath, name, args...)
+	ctx, err := console.Start("", name, args...)
 	if err != nil {
 		log.Error("Failed to start process: " + err.Error() + "\n")
 		return false, 0
@@ -69,7 +59,7 @@ func (w *window) startProcess(console TaskConsole, rawPath, name string, args ..
 }
 
 func (w *window) runPlayground(console TaskConsole) {
-	w.runGoProcess(console, "build", "-v", ".").Then(func(_ interface{}) interface{} {
+	w.runProcess(console, "go", "build", "-v", ".").Then(func(_ interface{}) interface{} {
 		return w.runProcess(console, "./playground").JSValue()
 	})
 }
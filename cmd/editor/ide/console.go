@@ -5,6 +5,7 @@ package ide
 
 import (
 	"context"
+	"io"
 
 	"github.com/hack-pad/hackpad/cmd/editor/dom"
 )
@@ -13,6 +14,11 @@ type ConsoleBuilder interface {
 	New(elem *dom.Element, rawName, name string, args ...string) (Console, error)
 }
 
+// ReplayBuilder creates consoles which replay recorded terminal sessions
+type ReplayBuilder interface {
+	NewReplay(elem *dom.Element, path string, speed float64) (Console, error)
+}
+
 type Console interface {
 	Tabber
 }
@@ -28,4 +34,6 @@ type TaskConsoleBuilder interface {
 type TaskConsole interface {
 	Tabber
 	Start(rawName, name string, args ...string) (context.Context, error)
+	Stdout() io.Writer
+	Stderr() io.Writer
 }
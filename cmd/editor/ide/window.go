@@ -5,6 +5,7 @@ package ide
 
 import (
 	_ "embed"
+	"errors"
 	"go/format"
 	"os"
 	"strings"
@@ -27,6 +28,7 @@ var (
 type Window interface {
 	NewEditor() Editor
 	NewConsole() Console
+	Replay(path string, speed float64) (Console, error)
 }
 
 type window struct {
@@ -80,7 +82,7 @@ func New(elem *dom.Element, editorBuilder EditorBuilder, consoleBuilder ConsoleB
 	w.controlButtons[0].AddEventListener("click", func(event js.Value) {
 		w.consolesPane.Focus(buildConsoleIndex)
 		console := w.consoles[buildConsoleIndex]
-		w.runGoProcess(console.(TaskConsole), "build", "-v", ".")
+		w.runProcess(console.(TaskConsole), "go", "build", "-v", ".")
 	})
 	w.controlButtons[1].AddEventListener("click", func(event js.Value) {
 		w.consolesPane.Focus(buildConsoleIndex)
@@ -164,6 +166,25 @@ func (w *window) NewConsole() Console {
 	return w.consolesPane.NewDefaultTab(TabOptions{}).(Console)
 }
 
+// Replay opens a console tab playing the asciicast recording at 'path'
+func (w *window) Replay(path string, speed float64) (Console, error) {
+	replayBuilder, ok := w.consoleBuilder.(ReplayBuilder)
+	if !ok {
+		return nil, errors.New("Console does not support replays")
+	}
+	var err error
+	console := w.consolesPane.NewTab(TabOptions{}, func(_ int, _, contents *dom.Element) Tabber {
+		var console Console
+		console, err = replayBuilder.NewReplay(contents, path, speed)
+		w.consoles = append(w.consoles, console)
+		return console
+	})
+	if err != nil {
+		return nil, err
+	}
+	return console.(Console), nil
+}
+
 func isCompatibleBrowser() bool {
 	userAgentStr := js.Global().Get("navigator").Get("userAgent").String()
 	userAgent := uasurfer.Parse(userAgentStr)
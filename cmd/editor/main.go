@@ -4,7 +4,9 @@
 package main
 
 import (
+	"errors"
 	"flag"
+	"io"
 	"os"
 	"syscall/js"
 
@@ -13,14 +15,11 @@ import (
 	"github.com/hack-pad/hackpad/cmd/editor/plaineditor"
 	"github.com/hack-pad/hackpad/cmd/editor/taskconsole"
 	"github.com/hack-pad/hackpad/cmd/editor/terminal"
+	"github.com/hack-pad/hackpad/internal/global"
 	"github.com/hack-pad/hackpad/internal/interop"
 	"github.com/hack-pad/hackpad/internal/log"
 )
 
-const (
-	goBinaryPath = "/usr/local/go/bin/js_wasm/go"
-)
-
 func main() {
 	editorID := flag.String("editor", "", "Editor element ID to attach")
 	flag.Parse()
@@ -47,8 +46,12 @@ func main() {
 	consoleBuilder := terminal.New(newXTermFunc)
 	taskConsoleBuilder := taskconsole.New()
 	win, tasks := ide.New(app, editorBuilder, consoleBuilder, taskConsoleBuilder)
+	routeOutput(tasks)
+	globalEditorProps.Set("replay", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		return replay(win, args)
+	}))
 
-	if _, err := tasks.Start(goBinaryPath, "go", "version"); err != nil {
+	if _, err := tasks.Start("", "go", "version"); err != nil {
 		log.Error("Failed to start go version: ", err)
 		return
 	}
@@ -65,7 +68,7 @@ func main() {
 	_, err := os.Stat("go.mod")
 	makeNewModule := os.IsNotExist(err)
 	if makeNewModule {
-		_, err := tasks.Start(goBinaryPath, "go", "mod", "init", "playground")
+		_, err := tasks.Start("", "go", "mod", "init", "playground")
 		if err != nil {
 			log.Error("Failed to start module init: ", err)
 			return
@@ -93,7 +96,7 @@ func main() {
 	}
 
 	if makeNewModule {
-		_, err := tasks.Start(goBinaryPath, "go", "mod", "tidy")
+		_, err := tasks.Start("", "go", "mod", "tidy")
 		if err != nil {
 			log.Error("Failed to start go mod tidy: ", err)
 			return
@@ -109,3 +112,40 @@ func main() {
 
 	select {}
 }
+
+// routeOutput shows output from processes without a terminal in the task console, instead of only the browser's devtools
+func routeOutput(tasks ide.TaskConsole) {
+	setOutput := global.Get("setOutput")
+	for stream, writer := range map[string]io.Writer{
+		"stdout": tasks.Stdout(),
+		"stderr": tasks.Stderr(),
+	} {
+		writer := writer
+		callback := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			_, _ = io.WriteString(writer, args[0].String())
+			return nil
+		})
+		err := setOutput.Invoke(stream, map[string]interface{}{
+			"callback":  callback,
+			"prefixPID": true,
+		})
+		if err.Truthy() {
+			log.Error("Failed to route ", stream, " to task console: ", err)
+		}
+	}
+}
+
+// replay opens a console tab playing an asciicast recording: editor.replay(path[, speed])
+func replay(win ide.Window, args []js.Value) interface{} {
+	if len(args) == 0 || args[0].Type() != js.TypeString {
+		return interop.WrapAsJSError(errors.New("Expected a recording path"), "replay")
+	}
+	speed := 1.0
+	if len(args) > 1 && args[1].Type() == js.TypeNumber {
+		speed = args[1].Float()
+	}
+	if _, err := win.Replay(args[0].String(), speed); err != nil {
+		return interop.WrapAsJSError(err, "replay")
+	}
+	return nil
+}
@@ -54,8 +54,12 @@ func (c *console) Start(rawName, name string, args ...string) (context.Context,
 	if rawName == "" {
 		rawName = name
 	}
-	cmd := exec.Command(name, args...)
-	cmd.Path = rawName
+	// skip exec.Command's PATH lookup, which stats every PATH directory from inside this process.
+	// Bare names are resolved by the kernel's cached lookup instead.
+	cmd := &exec.Cmd{
+		Path: rawName,
+		Args: append([]string{name}, args...),
+	}
 	cmd.Stdout = c.stdout
 	cmd.Stderr = c.stderr
 	c.commands <- cmd
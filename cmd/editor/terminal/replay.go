@@ -0,0 +1,115 @@
+//go:build js
+// +build js
+
+package terminal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall/js"
+
+	"github.com/hack-pad/hackpad/cmd/editor/dom"
+	"github.com/hack-pad/hackpad/cmd/editor/ide"
+	"github.com/hack-pad/hackpad/internal/asciicast"
+	"github.com/hack-pad/hackpad/internal/common"
+	"github.com/hack-pad/hackpad/internal/log"
+	"github.com/hack-pad/hackpad/internal/xterm"
+)
+
+// replay plays an asciicast recording into an xterm
+type replay struct {
+	xterm     js.Value
+	output    *xterm.Writer
+	player    *asciicast.Player
+	cancel    context.CancelFunc
+	titleChan chan string
+}
+
+// NewReplay plays the asciicast recording at 'path' into a new xterm in 'elem' at 'speed'.
+// While playing, typing '+' or '-' doubles or halves the speed, and space pauses.
+func (b *terminalBuilder) NewReplay(elem *dom.Element, path string, speed float64) (ide.Console, error) {
+	xtermValue := b.newXTermFunc.Invoke(elem.JSValue())
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &replay{
+		xterm:     xtermValue,
+		output:    xterm.NewWriter(xtermValue),
+		player:    asciicast.NewPlayer(speed),
+		cancel:    cancel,
+		titleChan: make(chan string, 1),
+	}
+	r.titleChan <- "Replay " + filepath.Base(path)
+	go func() {
+		err := r.play(ctx, path)
+		if err != nil && err != context.Canceled {
+			log.Error("Failed to replay terminal:", err)
+			_, _ = fmt.Fprintf(r.output, "\r\n%s\r\n", err)
+		}
+	}()
+	return r, nil
+}
+
+func (r *replay) play(ctx context.Context, path string) (err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	header, events, err := asciicast.Decode(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	r.resize(header.Width, header.Height)
+
+	controls := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		switch args[0].String() {
+		case "+":
+			r.player.SetSpeed(r.player.Speed() * 2)
+		case "-":
+			r.player.SetSpeed(r.player.Speed() / 2)
+		case " ":
+			r.player.TogglePause()
+		}
+		return nil
+	})
+	defer controls.Release()
+	listener := r.xterm.Call("onData", controls)
+	defer func() {
+		defer common.CatchException(&err)
+		listener.Call("dispose")
+	}()
+
+	err = r.player.Play(ctx, events, header.IdleTimeLimit, func(event asciicast.Event) error {
+		switch event.Type {
+		case asciicast.EventOutput:
+			_, err := r.output.Write([]byte(event.Data))
+			return err
+		case asciicast.EventResize:
+			cols, rows, err := event.Resize()
+			if err == nil {
+				r.resize(cols, rows)
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		_, err = r.output.Write([]byte("\r\n\033[1;32m[replay finished]\033[0m\r\n"))
+	}
+	return err
+}
+
+func (r *replay) resize(cols, rows int) {
+	if cols > 0 && rows > 0 {
+		r.xterm.Call("resize", cols, rows)
+	}
+}
+
+func (r *replay) Titles() <-chan string {
+	return r.titleChan
+}
+
+func (r *replay) Close() error {
+	r.cancel()
+	return r.output.Close()
+}
@@ -4,7 +4,9 @@
 package terminal
 
 import (
+	"errors"
 	"io"
+	"os"
 	"os/exec"
 	"syscall/js"
 
@@ -12,8 +14,7 @@ import (
 	"github.com/hack-pad/hackpad/cmd/editor/ide"
 	"github.com/hack-pad/hackpad/internal/common"
 	"github.com/hack-pad/hackpad/internal/log"
-	"github.com/hack-pad/hackpadfs/indexeddb/idbblob"
-	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+	"github.com/hack-pad/hackpad/internal/xterm"
 )
 
 type terminalBuilder struct {
@@ -28,6 +29,7 @@ func New(xtermFunc js.Value) ide.ConsoleBuilder {
 
 type terminal struct {
 	xterm     js.Value
+	output    *xterm.Writer
 	closables []func() error
 	cmd       *exec.Cmd
 	titleChan chan string
@@ -35,8 +37,10 @@ type terminal struct {
 }
 
 func (b *terminalBuilder) New(elem *dom.Element, rawName, name string, args ...string) (ide.Console, error) {
+	xtermValue := b.newXTermFunc.Invoke(elem.JSValue())
 	term := &terminal{
-		xterm:     b.newXTermFunc.Invoke(elem.JSValue()),
+		xterm:     xtermValue,
+		output:    xterm.NewWriter(xtermValue),
 		titleChan: make(chan string, 1),
 	}
 	go func() {
@@ -56,28 +60,23 @@ func (t *terminal) start(rawName, name string, args ...string) error {
 	}
 	t.cmd = exec.Command(name, args...)
 	t.cmd.Path = rawName
-	stdin, err := t.cmd.StdinPipe()
+	master, slave, err := openPTY()
 	if err != nil {
 		return err
 	}
-	stdout, err := t.cmd.StdoutPipe()
-	if err != nil {
-		return err
-	}
-	stderr, err := t.cmd.StderrPipe()
-	if err != nil {
-		return err
-	}
-	t.closables = append(t.closables, stdin.Close, stdout.Close, stderr.Close)
+	t.closables = append(t.closables, master.Close)
+	t.resize(master)
+	t.cmd.Stdin, t.cmd.Stdout, t.cmd.Stderr = slave, slave, slave
 
 	err = t.cmd.Start()
+	slave.Close() // only the command keeps the slave open, so the master sees EOF once it exits
 	if err != nil {
 		return err
 	}
 
 	f := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
 		chunk := []byte(args[0].String())
-		_, err := stdin.Write(chunk)
+		_, err := master.Write(chunk)
 		if err == io.EOF {
 			err = t.Close()
 		}
@@ -86,23 +85,50 @@ func (t *terminal) start(rawName, name string, args ...string) error {
 		}
 		return nil
 	})
+	resizeFunc := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		t.resize(master)
+		return nil
+	})
 	go func() {
 		_ = t.cmd.Wait()
 		f.Release()
+		resizeFunc.Release()
 	}()
 	dataListener := t.xterm.Call("onData", f)
+	resizeListener := t.xterm.Call("onResize", resizeFunc)
 	t.closables = append(t.closables, func() (err error) {
 		defer common.CatchException(&err)
 		dataListener.Call("dispose")
+		resizeListener.Call("dispose")
 		log.Print("disposed of data listener")
 		return nil
 	})
 
-	go t.readOutputPipes(stdout)
-	go t.readOutputPipes(stderr)
+	go t.readOutputPipes(master)
 	return nil
 }
 
+// openPTY opens a pseudo-terminal with hackpad's fs.openpty
+func openPTY() (master, slave *os.File, err error) {
+	defer common.CatchException(&err)
+	fds := js.Global().Get("fs").Call("openptySync")
+	if fds.Type() != js.TypeObject {
+		return nil, nil, errors.New("failed to open pseudo-terminal")
+	}
+	master = os.NewFile(uintptr(fds.Index(0).Int()), "ptmx")
+	slave = os.NewFile(uintptr(fds.Index(1).Int()), "pts")
+	return master, slave, nil
+}
+
+// resize sets the pseudo-terminal's window size to xterm's
+func (t *terminal) resize(master *os.File) {
+	fs := js.Global().Get("fs")
+	fs.Call("ioctlSync", master.Fd(), fs.Get("constants").Get("TIOCSWINSZ"), map[string]interface{}{
+		"rows": t.xterm.Get("rows"),
+		"cols": t.xterm.Get("cols"),
+	})
+}
+
 func (t *terminal) Wait() error {
 	return t.cmd.Wait()
 }
@@ -113,7 +139,11 @@ func (t *terminal) Close() error {
 	}
 	t.closed = true
 	const colorRed = "\033[1;31m"
-	t.xterm.Call("write", idbblob.FromBlob(blob.NewBytes([]byte("\n\r"+colorRed+"[exited]\n\r"))).JSValue())
+	go func() {
+		// Write blocks while xterm catches up, so don't block the caller, which may be an xterm event handler
+		_, _ = t.output.Write([]byte("\n\r" + colorRed + "[exited]\n\r"))
+		_ = t.output.Close()
+	}()
 	var err error
 	for _, closer := range t.closables {
 		cErr := closer()
@@ -125,13 +155,18 @@ func (t *terminal) Close() error {
 	return err
 }
 
+// readOutputPipes copies output to xterm until 'r' closes.
+// Each read takes as much output as is available, and blocks while xterm catches up.
 func (t *terminal) readOutputPipes(r io.Reader) {
-	buf := make([]byte, 1)
+	const maxRead = 32 << 10 // 32KiB, a full pipe or terminal buffer
+	buf := make([]byte, maxRead)
 	for {
-		_, err := r.Read(buf)
+		n, err := r.Read(buf)
+		if n > 0 {
+			_, _ = t.output.Write(buf[:n])
+		}
 		switch err {
 		case nil:
-			t.xterm.Call("write", idbblob.FromBlob(blob.NewBytes(buf)).JSValue())
 		case io.EOF:
 			t.Close()
 			return
@@ -0,0 +1,16 @@
+package main
+
+// installedPackage is an installed package from the kernel's package manager
+type installedPackage struct {
+	Name       string
+	Version    string
+	SHA256     string
+	Dependency bool
+}
+
+// availablePackage is a package in the package index
+type availablePackage struct {
+	Name    string
+	Version string
+	SHA256  string
+}
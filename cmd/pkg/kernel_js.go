@@ -0,0 +1,111 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"fmt"
+	"syscall/js"
+)
+
+func install(names []string, force bool) error {
+	_, err := call("install", names, map[string]interface{}{"force": force})
+	return err
+}
+
+func uninstall(names []string) error {
+	_, err := call("uninstall", names, nil)
+	return err
+}
+
+func upgrade(names []string) ([]string, error) {
+	result, err := call("upgrade", names, nil)
+	if err != nil {
+		return nil, err
+	}
+	upgraded := make([]string, result.Length())
+	for i := range upgraded {
+		upgraded[i] = result.Index(i).String()
+	}
+	return upgraded, nil
+}
+
+func listInstalled() ([]installedPackage, error) {
+	result, err := call("list", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	installed := make([]installedPackage, result.Length())
+	for i := range installed {
+		value := result.Index(i)
+		installed[i] = installedPackage{
+			Name:       value.Get("name").String(),
+			Version:    value.Get("version").String(),
+			SHA256:     value.Get("sha256").String(),
+			Dependency: value.Get("dependency").Bool(),
+		}
+	}
+	return installed, nil
+}
+
+func listAvailable() ([]availablePackage, error) {
+	result, err := call("available", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	available := make([]availablePackage, result.Length())
+	for i := range available {
+		value := result.Index(i)
+		available[i] = availablePackage{
+			Name:    value.Get("name").String(),
+			Version: value.Get("version").String(),
+			SHA256:  value.Get("sha256").String(),
+		}
+	}
+	return available, nil
+}
+
+// call runs a package manager operation with the kernel, waiting for its callback. 'options' are passed if set.
+func call(method string, names []string, options map[string]interface{}) (result js.Value, err error) {
+	defer catch(&err)
+	type response struct {
+		result js.Value
+		err    error
+	}
+	responses := make(chan response, 1)
+	callback := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		var resp response
+		if errValue := args[0]; errValue.Truthy() {
+			resp.err = fmt.Errorf("%s", errValue.Get("message").String())
+		} else if len(args) > 1 {
+			resp.result = args[1]
+		}
+		responses <- resp
+		return nil
+	})
+	defer callback.Release()
+
+	args := make([]interface{}, 0, len(names)+2)
+	for _, name := range names {
+		args = append(args, name)
+	}
+	if options != nil {
+		args = append(args, options)
+	}
+	js.Global().Get("packages").Call(method, append(args, callback)...)
+	resp := <-responses
+	return resp.result, resp.err
+}
+
+// catch converts a thrown JS exception into an error
+func catch(err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if jsErr, ok := r.(js.Error); ok {
+		*err = fmt.Errorf("%s", jsErr.Value.Get("message").String())
+		return
+	}
+	panic(r)
+}
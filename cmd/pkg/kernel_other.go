@@ -0,0 +1,28 @@
+//go:build !js
+// +build !js
+
+package main
+
+import "errors"
+
+var errNotHackpad = errors.New("requires hackpad")
+
+func install(names []string, force bool) error {
+	return errNotHackpad
+}
+
+func uninstall(names []string) error {
+	return errNotHackpad
+}
+
+func upgrade(names []string) ([]string, error) {
+	return nil, errNotHackpad
+}
+
+func listInstalled() ([]installedPackage, error) {
+	return nil, errNotHackpad
+}
+
+func listAvailable() ([]availablePackage, error) {
+	return nil, errNotHackpad
+}
@@ -0,0 +1,103 @@
+// Command pkg installs, lists, upgrades, and removes hackpad packages.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+const usage = "Usage: pkg install [-f] NAME... | uninstall NAME... | list [-a] | upgrade [NAME...]"
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err := run(os.Args[1], os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "pkg:", err)
+		os.Exit(1)
+	}
+}
+
+func run(command string, args []string) error {
+	switch command {
+	case "install":
+		set := flag.NewFlagSet("install", flag.ContinueOnError)
+		force := set.Bool("f", false, "Replace existing files no package installed")
+		if err := set.Parse(args); err != nil {
+			return err
+		}
+		if set.NArg() == 0 {
+			return errors.New("install: package name required")
+		}
+		return install(set.Args(), *force)
+	case "uninstall", "remove":
+		if len(args) == 0 {
+			return errors.New("uninstall: package name required")
+		}
+		return uninstall(args)
+	case "list":
+		return list(args)
+	case "upgrade":
+		upgraded, err := upgrade(args)
+		for _, name := range upgraded {
+			fmt.Println("Upgraded", name)
+		}
+		if err == nil && len(upgraded) == 0 {
+			fmt.Println("All packages are up to date")
+		}
+		return err
+	default:
+		return errors.New(usage)
+	}
+}
+
+func list(args []string) error {
+	set := flag.NewFlagSet("list", flag.ContinueOnError)
+	all := set.Bool("a", false, "List all available packages")
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+	installed, err := listInstalled()
+	if err != nil {
+		return err
+	}
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer table.Flush()
+	if !*all {
+		fmt.Fprintln(table, "NAME\tVERSION\t")
+		for _, pkg := range installed {
+			note := ""
+			if pkg.Dependency {
+				note = "(dependency)"
+			}
+			fmt.Fprintf(table, "%s\t%s\t%s\n", pkg.Name, pkg.Version, note)
+		}
+		return nil
+	}
+
+	available, err := listAvailable()
+	if err != nil {
+		return err
+	}
+	versions := make(map[string]installedPackage, len(installed))
+	for _, pkg := range installed {
+		versions[pkg.Name] = pkg
+	}
+	fmt.Fprintln(table, "NAME\tVERSION\tINSTALLED\t")
+	for _, pkg := range available {
+		current, ok := versions[pkg.Name]
+		status := "no"
+		switch {
+		case ok && current.SHA256 == pkg.SHA256:
+			status = "yes"
+		case ok:
+			status = current.Version + " (upgradable)"
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t\n", pkg.Name, pkg.Version, status)
+	}
+	return nil
+}
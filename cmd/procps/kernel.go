@@ -0,0 +1,37 @@
+package main
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// processInfo is a process table entry from the kernel, mirroring process.Info
+type processInfo struct {
+	PID       int
+	ParentPID int
+	Args      []string
+	State     string
+	Cwd       string
+	Backend   string
+	StartTime time.Time
+	Elapsed   time.Duration
+	ExitCode  int
+	Done      bool
+	Err       string
+	Files     []openFile
+}
+
+type openFile struct {
+	FD          int
+	Name        string
+	CloseOnExec bool
+	Refs        int
+}
+
+// Command returns the name of the process's program
+func (p processInfo) Command() string {
+	if len(p.Args) == 0 {
+		return ""
+	}
+	return filepath.Base(p.Args[0])
+}
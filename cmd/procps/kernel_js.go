@@ -0,0 +1,76 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"fmt"
+	"syscall/js"
+	"time"
+)
+
+// processes returns a snapshot of hackpad's process table
+func processes() (infos []processInfo, err error) {
+	defer catch(&err)
+	values := js.Global().Get("child_process").Call("processes")
+	for i := 0; i < values.Length(); i++ {
+		infos = append(infos, parseProcessInfo(values.Index(i)))
+	}
+	return infos, nil
+}
+
+func parseProcessInfo(value js.Value) processInfo {
+	info := processInfo{
+		PID:       value.Get("pid").Int(),
+		ParentPID: value.Get("ppid").Int(),
+		State:     value.Get("state").String(),
+		Cwd:       value.Get("cwd").String(),
+		Backend:   value.Get("backend").String(),
+		Elapsed:   time.Duration(value.Get("elapsed").Float() * float64(time.Millisecond)),
+	}
+	argv := value.Get("argv")
+	for i := 0; i < argv.Length(); i++ {
+		info.Args = append(info.Args, argv.Index(i).String())
+	}
+	if startTime := value.Get("startTime"); startTime.Truthy() {
+		info.StartTime = time.Unix(0, int64(startTime.Float()*float64(time.Millisecond)))
+	}
+	if exitCode := value.Get("exitCode"); exitCode.Type() == js.TypeNumber {
+		info.ExitCode = exitCode.Int()
+		info.Done = true
+	}
+	if errValue := value.Get("error"); errValue.Truthy() {
+		info.Err = errValue.String()
+	}
+	files := value.Get("files")
+	for i := 0; i < files.Length(); i++ {
+		file := files.Index(i)
+		info.Files = append(info.Files, openFile{
+			FD:          file.Get("fd").Int(),
+			Name:        file.Get("name").String(),
+			CloseOnExec: file.Get("closeOnExec").Bool(),
+			Refs:        file.Get("refs").Int(),
+		})
+	}
+	return info
+}
+
+// signalProcess sends 'signal' to process 'pid'
+func signalProcess(pid, signal int) (err error) {
+	defer catch(&err)
+	js.Global().Get("process").Call("kill", pid, signal)
+	return nil
+}
+
+// catch converts a thrown JS exception into an error
+func catch(err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if jsErr, ok := r.(js.Error); ok {
+		*err = fmt.Errorf("%s", jsErr.Value.Get("message").String())
+		return
+	}
+	panic(r)
+}
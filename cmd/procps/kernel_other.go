@@ -0,0 +1,16 @@
+//go:build !js
+// +build !js
+
+package main
+
+import "errors"
+
+var errNotHackpad = errors.New("requires hackpad")
+
+func processes() ([]processInfo, error) {
+	return nil, errNotHackpad
+}
+
+func signalProcess(pid, signal int) error {
+	return errNotHackpad
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var signals = map[string]int{
+	"HUP":  1,
+	"INT":  2,
+	"QUIT": 3,
+	"KILL": 9,
+	"TERM": 15,
+	"TSTP": 20,
+}
+
+// parseSignal parses a signal name like "TERM" or "SIGTERM", or a signal number
+func parseSignal(s string) (int, error) {
+	if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+		return n, nil
+	}
+	if signal, ok := signals[strings.TrimPrefix(strings.ToUpper(s), "SIG")]; ok {
+		return signal, nil
+	}
+	return 0, fmt.Errorf("unknown signal: %s", s)
+}
+
+func signalList() string {
+	names := make([]string, 0, len(signals))
+	for name := range signals {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(a, b int) bool {
+		return signals[names[a]] < signals[names[b]]
+	})
+	for i, name := range names {
+		names[i] = fmt.Sprintf("%d) SIG%s", signals[name], name)
+	}
+	return strings.Join(names, "\n")
+}
+
+// parseSignalFlag parses a leading '-s SIG', '-SIG' or '-N' argument, returning the remaining args
+func parseSignalFlag(args []string) (signal int, rest []string, err error) {
+	signal = signals["TERM"]
+	if len(args) == 0 || !strings.HasPrefix(args[0], "-") {
+		return signal, args, nil
+	}
+	switch {
+	case args[0] == "-s":
+		if len(args) < 2 {
+			return 0, nil, errors.New("option requires an argument: -s")
+		}
+		signal, err = parseSignal(args[1])
+		return signal, args[2:], err
+	case args[0] == "--":
+		return signal, args[1:], nil
+	default:
+		signal, err = parseSignal(strings.TrimPrefix(args[0], "-"))
+		return signal, args[1:], err
+	}
+}
+
+func kill(args []string) error {
+	if len(args) > 0 && args[0] == "-l" {
+		fmt.Println(signalList())
+		return nil
+	}
+	signal, args, err := parseSignalFlag(args)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return errors.New("usage: kill [-s SIGNAL | -SIGNAL] pid...")
+	}
+	var failed bool
+	for _, arg := range args {
+		pid, err := strconv.Atoi(arg)
+		if err == nil {
+			err = signalProcess(pid, signal)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "kill: (%s): %s\n", arg, err)
+			failed = true
+		}
+	}
+	if failed {
+		return errors.New("failed to signal some processes")
+	}
+	return nil
+}
+
+func killall(args []string) error {
+	signal, args, err := parseSignalFlag(args)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return errors.New("usage: killall [-s SIGNAL | -SIGNAL] name...")
+	}
+	infos, err := processes()
+	if err != nil {
+		return err
+	}
+	self := os.Getpid()
+	for _, name := range args {
+		found := false
+		for _, info := range running(infos) {
+			if info.PID == self || info.Command() != name {
+				continue
+			}
+			found = true
+			if err := signalProcess(info.PID, signal); err != nil {
+				return fmt.Errorf("%s(%d): %w", name, info.PID, err)
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: no process found", name)
+		}
+	}
+	return nil
+}
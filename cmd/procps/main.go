@@ -0,0 +1,56 @@
+// Command procps provides the process management tools ps, kill, killall and top.
+//
+// Like BusyBox, it runs the tool named by argv[0], or by its first argument if invoked as 'procps'.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var tools = map[string]func(args []string) error{
+	"ps":      ps,
+	"kill":    kill,
+	"killall": killall,
+	"top":     top,
+}
+
+func main() {
+	args := os.Args
+	name := filepath.Base(args[0])
+	if _, ok := tools[name]; !ok && len(args) > 1 {
+		args = args[1:]
+		name = filepath.Base(args[0])
+	}
+	if name == "--list" {
+		// the package index lists each tool from here, so installs link every tool
+		fmt.Println(strings.Join(toolNames(), "\n"))
+		return
+	}
+	tool, ok := tools[name]
+	if !ok {
+		fmt.Fprintln(os.Stderr, usage())
+		os.Exit(1)
+	}
+	if err := tool(args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+		os.Exit(1)
+	}
+}
+
+func usage() string {
+	return "Usage: procps [" + strings.Join(toolNames(), "|") + "] [args...] | procps --list"
+}
+
+// toolNames returns the sorted names of all tools
+func toolNames() []string {
+	names := make([]string, 0, len(tools))
+	for name := range tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func ps(args []string) error {
+	set := flag.NewFlagSet("ps", flag.ContinueOnError)
+	all := set.Bool("a", false, "Include exited processes")
+	long := set.Bool("l", false, "Include working directories and open files")
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+	infos, err := processes()
+	if err != nil {
+		return err
+	}
+	if !*all {
+		infos = running(infos)
+	}
+	return printTree(os.Stdout, infos, *long)
+}
+
+// running filters out exited processes
+func running(infos []processInfo) []processInfo {
+	var result []processInfo
+	for _, info := range infos {
+		if !info.Done {
+			result = append(result, info)
+		}
+	}
+	return result
+}
+
+// printTree prints a table of processes, with children indented below their parents
+func printTree(w io.Writer, infos []processInfo, long bool) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := "PID\tPPID\tSTATE\tELAPSED\tFDS\t"
+	if long {
+		header += "CWD\t"
+	}
+	fmt.Fprintln(table, header+"COMMAND")
+
+	children := make(map[int][]processInfo)
+	pids := make(map[int]bool)
+	for _, info := range infos {
+		pids[info.PID] = true
+	}
+	var roots []processInfo
+	for _, info := range infos {
+		if pids[info.ParentPID] && info.ParentPID != info.PID {
+			children[info.ParentPID] = append(children[info.ParentPID], info)
+		} else {
+			roots = append(roots, info)
+		}
+	}
+
+	var printProcess func(info processInfo, depth int)
+	printProcess = func(info processInfo, depth int) {
+		command := strings.Join(info.Args, " ")
+		if command == "" {
+			command = "init"
+		}
+		if depth > 0 {
+			command = strings.Repeat("  ", depth-1) + "\\_ " + command
+		}
+		state := info.State
+		if info.Done {
+			state = fmt.Sprintf("%s(%d)", state, info.ExitCode)
+		}
+		fmt.Fprintf(table, "%d\t%d\t%s\t%s\t%d\t", info.PID, info.ParentPID, state, formatElapsed(info.Elapsed), len(info.Files))
+		if long {
+			fmt.Fprintf(table, "%s\t", info.Cwd)
+		}
+		fmt.Fprintln(table, command)
+		if long {
+			for _, file := range info.Files {
+				fmt.Fprintf(table, "\t\t\t\t%d\t\t%s\n", file.FD, file.Name)
+			}
+		}
+		for _, child := range children[info.PID] {
+			printProcess(child, depth+1)
+		}
+	}
+	for _, root := range roots {
+		printProcess(root, 0)
+	}
+	return table.Flush()
+}
+
+// formatElapsed formats a duration in a short, human-readable form, like "12.3s", "4m05s", or "2h03m"
+func formatElapsed(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%.1fs", d.Seconds())
+	case d < time.Hour:
+		return fmt.Sprintf("%dm%02ds", int(d/time.Minute), int(d%time.Minute/time.Second))
+	default:
+		return fmt.Sprintf("%dh%02dm", int(d/time.Hour), int(d%time.Hour/time.Minute))
+	}
+}
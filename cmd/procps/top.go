@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+const clearScreen = "\x1b[H\x1b[2J"
+
+func top(args []string) error {
+	set := flag.NewFlagSet("top", flag.ContinueOnError)
+	delay := set.Float64("d", 2, "Seconds between refreshes")
+	iterations := set.Int("n", 0, "Number of refreshes before exiting, or 0 to run until killed")
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+	if *delay <= 0 {
+		return fmt.Errorf("invalid delay: %v", *delay)
+	}
+
+	for i := 0; *iterations == 0 || i < *iterations; i++ {
+		if i > 0 {
+			time.Sleep(time.Duration(*delay * float64(time.Second)))
+		}
+		infos, err := processes()
+		if err != nil {
+			return err
+		}
+		var done int
+		for _, info := range infos {
+			if info.Done {
+				done++
+			}
+		}
+		fmt.Print(clearScreen)
+		fmt.Printf("top - %s  processes: %d total, %d running, %d exited\n\n",
+			time.Now().Format("15:04:05"), len(infos), len(infos)-done, done)
+		if err := printTree(os.Stdout, running(infos), false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
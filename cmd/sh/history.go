@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultHistorySize = 1000
+	lockRetryInterval  = 10 * time.Millisecond
+	lockTimeout        = 2 * time.Second
+	// staleLockAge is how old a lock file must be before it's considered left behind by a killed shell
+	staleLockAge = 10 * time.Second
+)
+
+// history persists commands across sessions in HISTFILE, or ~/.sh_history by default.
+// Each command is appended as soon as it runs, so concurrent shells all keep their commands and a killed shell loses nothing.
+// Writes hold a lock file next to HISTFILE, and the file is rewritten without duplicates when it grows past twice HISTSIZE.
+type history struct {
+	path string
+	size int
+	// lines is roughly how many lines are in the file, counting this shell's appends but not other shells'
+	lines int
+}
+
+func newHistory(home string) *history {
+	path := os.Getenv("HISTFILE")
+	if path == "" {
+		path = filepath.Join(home, ".sh_history")
+	}
+	return &history{
+		path: path,
+		size: historySize(),
+	}
+}
+
+// historySize returns HISTSIZE, the number of commands to keep, or the default if unset or invalid
+func historySize() int {
+	size, err := strconv.Atoi(os.Getenv("HISTSIZE"))
+	if err != nil || size < 0 {
+		return defaultHistorySize
+	}
+	return size
+}
+
+// Commands compacts the history, then returns the saved commands, oldest first
+func (h *history) Commands() ([]string, error) {
+	var commands []string
+	err := h.withLock(func() error {
+		var err error
+		commands, err = h.compact()
+		return err
+	})
+	return commands, err
+}
+
+// Push appends 'command' to the history
+func (h *history) Push(command string) error {
+	return h.withLock(func() error {
+		f, err := os.OpenFile(h.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return err
+		}
+		_, err = f.WriteString(command + "\n")
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+		h.lines++
+		if h.lines > 2*h.size {
+			_, err = h.compact()
+		}
+		return err
+	})
+}
+
+// compact removes duplicates and trims the history to HISTSIZE, rewriting the file if anything changed. Must hold the lock.
+func (h *history) compact() ([]string, error) {
+	lines, err := readLines(h.path)
+	if err != nil {
+		return nil, err
+	}
+	commands := dedupHistory(lines)
+	if len(commands) > h.size {
+		commands = commands[len(commands)-h.size:]
+	}
+	h.lines = len(commands)
+	if len(commands) == len(lines) {
+		return commands, nil
+	}
+	return commands, writeLines(h.path, commands)
+}
+
+// withLock runs 'fn' while holding the history's lock file, waiting for other shells to release it.
+// A lock file older than staleLockAge was left by a shell killed mid-write, so it's removed.
+func (h *history) withLock(fn func() error) error {
+	lockPath := h.path + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		lock, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			_ = lock.Close()
+			defer os.Remove(lockPath)
+			return fn()
+		}
+		if !os.IsExist(err) {
+			return err
+		}
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			_ = os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return errors.Errorf("Timed out waiting for history lock: %s", lockPath)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// Search returns commands containing 'pattern', most recent first
+func (h *history) Search(pattern string) ([]string, error) {
+	commands, err := h.Commands()
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	for i := len(commands) - 1; i >= 0; i-- {
+		if strings.Contains(commands[i], pattern) {
+			matches = append(matches, commands[i])
+		}
+	}
+	return matches, nil
+}
+
+// Clear removes all saved commands
+func (h *history) Clear() error {
+	return h.withLock(func() error {
+		h.lines = 0
+		err := os.Remove(h.path)
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return err
+	})
+}
+
+// dedupHistory removes blank commands and earlier duplicates, keeping each command's most recent position
+func dedupHistory(commands []string) []string {
+	seen := make(map[string]bool, len(commands))
+	deduped := make([]string, 0, len(commands))
+	for i := len(commands) - 1; i >= 0; i-- {
+		command := strings.TrimSpace(commands[i])
+		if command == "" || seen[command] {
+			continue
+		}
+		seen[command] = true
+		deduped = append(deduped, command)
+	}
+	for i, j := 0, len(deduped)-1; i < j; i, j = i+1, j-1 {
+		deduped[i], deduped[j] = deduped[j], deduped[i]
+	}
+	return deduped
+}
+
+func readLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+func writeLines(path string, lines []string) error {
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return os.WriteFile(path, []byte(b.String()), 0600)
+}
+
+// Builtin runs the 'history' builtin, which prints saved commands. With a pattern, prints matching commands most recent first.
+func (h *history) Builtin(stdin io.Reader, stdout, stderr io.Writer, args ...string) error {
+	set := flag.NewFlagSet("history", flag.ContinueOnError)
+	set.SetOutput(stderr)
+	clear := set.Bool("c", false, "Clear the history.")
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+	if *clear {
+		return h.Clear()
+	}
+
+	if set.NArg() > 0 {
+		matches, err := h.Search(strings.Join(set.Args(), " "))
+		if err != nil {
+			return err
+		}
+		for _, command := range matches {
+			fmt.Fprintln(stdout, command)
+		}
+		return nil
+	}
+
+	commands, err := h.Commands()
+	if err != nil {
+		return err
+	}
+	for i, command := range commands {
+		fmt.Fprintf(stdout, "%5d  %s\n", i+1, command)
+	}
+	return nil
+}
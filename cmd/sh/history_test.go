@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDedupHistory(t *testing.T) {
+	for _, tc := range []struct {
+		description string
+		commands    []string
+		expect      []string
+	}{
+		{"empty", nil, []string{}},
+		{"no duplicates", []string{"a", "b"}, []string{"a", "b"}},
+		{"keeps most recent", []string{"a", "b", "a", "c", "b"}, []string{"a", "c", "b"}},
+		{"blank lines", []string{"", "a", "  ", "b"}, []string{"a", "b"}},
+		{"trims whitespace", []string{"ls ", " ls"}, []string{"ls"}},
+	} {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			if deduped := dedupHistory(tc.commands); !reflect.DeepEqual(tc.expect, deduped) {
+				t.Errorf("Expected %q, got %q", tc.expect, deduped)
+			}
+		})
+	}
+}
+
+func TestHistorySize(t *testing.T) {
+	for _, tc := range []struct {
+		value  string
+		expect int
+	}{
+		{"", defaultHistorySize},
+		{"5", 5},
+		{"0", 0},
+		{"-1", defaultHistorySize},
+		{"many", defaultHistorySize},
+	} {
+		t.Setenv("HISTSIZE", tc.value)
+		if size := historySize(); size != tc.expect {
+			t.Errorf("HISTSIZE=%q: Expected %d, got %d", tc.value, tc.expect, size)
+		}
+	}
+}
+
+func newTestHistory(t *testing.T, size string) *history {
+	t.Helper()
+	t.Setenv("HISTFILE", "")
+	t.Setenv("HISTSIZE", size)
+	return newHistory(t.TempDir())
+}
+
+func readTestLines(t *testing.T, path string) []string {
+	t.Helper()
+	lines, err := readLines(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lines
+}
+
+func TestHistoryCommands(t *testing.T) {
+	h := newTestHistory(t, "3")
+	if err := writeLines(h.path, []string{"ls", "pwd", "ls", "echo hi", "cd /", "pwd"}); err != nil {
+		t.Fatal(err)
+	}
+	commands, err := h.Commands()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{"echo hi", "cd /", "pwd"}
+	if !reflect.DeepEqual(expect, commands) {
+		t.Errorf("Expected commands %q, got %q", expect, commands)
+	}
+	if lines := readTestLines(t, h.path); !reflect.DeepEqual(expect, lines) {
+		t.Errorf("Expected history file to be compacted to %q, got %q", expect, lines)
+	}
+}
+
+func TestHistoryPushConcurrentShells(t *testing.T) {
+	h1 := newTestHistory(t, "")
+	h2 := newHistory(t.TempDir())
+	h2.path = h1.path
+
+	const pushes = 20
+	var wg sync.WaitGroup
+	for _, h := range []*history{h1, h2} {
+		h := h
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < pushes; i++ {
+				if err := h.Push(fmt.Sprintf("echo %p %d", h, i)); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	lines := readTestLines(t, h1.path)
+	if len(lines) != 2*pushes {
+		t.Errorf("Expected %d commands from both shells, got %d: %q", 2*pushes, len(lines), lines)
+	}
+}
+
+func TestHistoryPushCompacts(t *testing.T) {
+	h := newTestHistory(t, "2")
+	for i := 0; i < 5; i++ {
+		if err := h.Push(fmt.Sprint("echo ", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lines := readTestLines(t, h.path)
+	if len(lines) > 2*h.size {
+		t.Errorf("Expected history to be compacted to at most %d lines, got %q", 2*h.size, lines)
+	}
+	commands, err := h.Commands()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := []string{"echo 3", "echo 4"}; !reflect.DeepEqual(expect, commands) {
+		t.Errorf("Expected commands %q, got %q", expect, commands)
+	}
+}
+
+func TestHistoryLock(t *testing.T) {
+	h := newTestHistory(t, "")
+	lockPath := h.path + ".lock"
+	if err := os.WriteFile(lockPath, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	pushed := make(chan error, 1)
+	go func() {
+		pushed <- h.Push("ls")
+	}()
+	select {
+	case err := <-pushed:
+		t.Fatalf("Push should wait for the lock, got: %v", err)
+	case <-time.After(5 * lockRetryInterval):
+	}
+	if err := os.Remove(lockPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-pushed; err != nil {
+		t.Fatal(err)
+	}
+
+	// a lock left behind by a killed shell is removed
+	if err := os.WriteFile(lockPath, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	stale := time.Now().Add(-2 * staleLockAge)
+	if err := os.Chtimes(lockPath, stale, stale); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Push("pwd"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Errorf("Expected lock to be released, got: %v", err)
+	}
+	if expect, lines := []string{"ls", "pwd"}, readTestLines(t, h.path); !reflect.DeepEqual(expect, lines) {
+		t.Errorf("Expected history %q, got %q", expect, lines)
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "custom_history")
+	t.Setenv("HISTFILE", path)
+	h := newHistory(t.TempDir())
+	if h.path != path {
+		t.Errorf("Expected path %q, got %q", path, h.path)
+	}
+}
+
+func TestHistorySearchAndClear(t *testing.T) {
+	h := newTestHistory(t, "")
+	if err := writeLines(h.path, []string{"go build", "ls", "go test"}); err != nil {
+		t.Fatal(err)
+	}
+	matches, err := h.Search("go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{"go test", "go build"}
+	if !reflect.DeepEqual(expect, matches) {
+		t.Errorf("Expected matches %q, got %q", expect, matches)
+	}
+
+	if err := h.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(h.path); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed, got: %v", h.path, err)
+	}
+}
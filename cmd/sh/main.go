@@ -1,15 +1,37 @@
 package main
 
 import (
+	"fmt"
 	"io"
 	"log"
 	"os"
 
-	"github.com/hack-pad/hush"
+	"github.com/hack-pad/hackpad/internal/hush"
 )
 
 func main() {
 	log.SetOutput(io.Discard)
-	exitCode := hush.Run()
+	home, err := os.UserHomeDir()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "sh:", err)
+		os.Exit(1)
+	}
+	if len(os.Args) > 1 {
+		os.Exit(hush.RunOptions(shellOptions(newHistory(home))))
+	}
+
+	sourceStartupFiles(home)
+	restore := rawTerminal()
+	exitCode := hush.RunOptions(shellOptions(newHistory(home)))
+	restore()
 	os.Exit(exitCode)
 }
+
+func shellOptions(history *history) hush.Options {
+	return hush.Options{
+		History: history,
+		Builtins: map[string]hush.Builtin{
+			"history": history.Builtin,
+		},
+	}
+}
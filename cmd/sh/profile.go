@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/hack-pad/hackpad/internal/hush"
+	"github.com/pkg/errors"
+	"mvdan.cc/sh/v3/expand"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// startupFiles returns the scripts sourced when an interactive shell starts, in order
+func startupFiles(home string) []string {
+	return []string{
+		"/etc/profile",
+		filepath.Join(home, ".profile"),
+		filepath.Join(home, ".hushrc"),
+	}
+}
+
+// sourceStartupFiles sources each startup file that exists, reporting errors to stderr without stopping
+func sourceStartupFiles(home string) {
+	for _, path := range startupFiles(home) {
+		err := source(path)
+		if err != nil && !os.IsNotExist(errors.Cause(err)) {
+			fmt.Fprintln(os.Stderr, "sh:", err)
+		}
+	}
+}
+
+// source runs the script at 'path' in this shell, so variables and the working directory carry over to the interactive session.
+// Scripts are parsed with the same parser as hush and support the same subset of it: commands, assignments, and lists joined by && or ||,
+// plus the export, unset, cd, source (or '.'), alias, and unalias builtins. Failed statements are reported with their line number and don't stop the script.
+func source(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	file, err := syntax.NewParser().Parse(f, path)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range file.Stmts {
+		if err := runStmt(stmt); err != nil {
+			fmt.Fprintf(os.Stderr, "sh: %s:%d: %s\n", path, stmt.Pos().Line(), err)
+		}
+	}
+	return nil
+}
+
+func runStmt(stmt *syntax.Stmt) error {
+	if stmt.Negated || stmt.Background || stmt.Coprocess || len(stmt.Redirs) > 0 {
+		return errors.New("Negation, background jobs, and redirects are not supported in startup scripts")
+	}
+	switch cmd := stmt.Cmd.(type) {
+	case *syntax.CallExpr:
+		return runCall(cmd)
+	case *syntax.DeclClause:
+		return runDecl(cmd)
+	case *syntax.BinaryCmd:
+		switch cmd.Op {
+		case syntax.AndStmt:
+			if err := runStmt(cmd.X); err != nil {
+				return err
+			}
+			return runStmt(cmd.Y)
+		case syntax.OrStmt:
+			if err := runStmt(cmd.X); err == nil {
+				return nil
+			}
+			return runStmt(cmd.Y)
+		}
+		return errors.Errorf("Unsupported operator in startup script: %s", cmd.Op)
+	default:
+		return errors.Errorf("Unsupported statement in startup script: %T", stmt.Cmd)
+	}
+}
+
+func expandConfig() *expand.Config {
+	return &expand.Config{Env: expand.FuncEnviron(os.Getenv)}
+}
+
+func runCall(call *syntax.CallExpr) error {
+	cfg := expandConfig()
+	if len(call.Args) == 0 {
+		// Commands only see environment variables, so every assignment is exported
+		for _, a := range call.Assigns {
+			name, value, err := assignment(cfg, a)
+			if err != nil {
+				return err
+			}
+			if err := os.Setenv(name, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var env []string
+	for _, a := range call.Assigns {
+		name, value, err := assignment(cfg, a)
+		if err != nil {
+			return err
+		}
+		env = append(env, name+"="+value)
+	}
+	args, err := expand.Fields(cfg, call.Args...)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return nil
+	}
+
+	switch args[0] {
+	case "unset":
+		for _, name := range args[1:] {
+			os.Unsetenv(name)
+		}
+		return nil
+	case "cd":
+		dir := os.Getenv("HOME")
+		if len(args) > 1 {
+			dir = args[1]
+		}
+		return os.Chdir(dir)
+	case "source", ".":
+		if len(args) < 2 {
+			return errors.Errorf("%s: filename argument required", args[0])
+		}
+		return source(args[1])
+	case "alias":
+		return hush.Alias(os.Stdout, args[1:]...)
+	case "unalias":
+		return hush.Unalias(args[1:]...)
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// runDecl runs 'export'. Other declarations, like 'local' or 'readonly', aren't supported.
+func runDecl(decl *syntax.DeclClause) error {
+	if decl.Variant.Value != "export" {
+		return errors.Errorf("%s: not supported in startup scripts", decl.Variant.Value)
+	}
+	cfg := expandConfig()
+	for _, a := range decl.Args {
+		if a.Naked {
+			if a.Name == nil {
+				return errors.New("export: options are not supported")
+			}
+			continue // every variable is already exported
+		}
+		name, value, err := assignment(cfg, a)
+		if err != nil {
+			return err
+		}
+		if err := os.Setenv(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assignment expands the value of a NAME=value or NAME+=value assignment
+func assignment(cfg *expand.Config, a *syntax.Assign) (name, value string, err error) {
+	if a.Index != nil || a.Array != nil {
+		return "", "", errors.Errorf("Arrays are not supported: %s", a.Name.Value)
+	}
+	name = a.Name.Value
+	if a.Value != nil {
+		value, err = expand.Literal(cfg, a.Value)
+		if err != nil {
+			return "", "", err
+		}
+	}
+	if a.Append {
+		value = os.Getenv(name) + value
+	}
+	return name, value, nil
+}
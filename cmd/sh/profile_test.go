@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hack-pad/hackpad/internal/hush"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+func writeScript(t *testing.T, dir, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSource(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	for _, name := range []string{"GREETING", "NAME", "QUOTED", "MESSAGE", "PATH_LIKE", "REMOVED", "NESTED", "AND", "OR", "AFTER_ERROR"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("REMOVED", "value")
+	t.Setenv("PATH_LIKE", "/bin")
+
+	writeScript(t, dir, "nested.sh", `NESTED=yes`)
+	script := writeScript(t, dir, "profile", `
+# comments and blank lines are skipped
+
+GREETING=hello NAME=world
+export QUOTED="a   b" MESSAGE="$GREETING, ${NAME}"
+export PATH_LIKE+=:/usr/bin
+unset REMOVED
+cd `+dir+`
+. ./nested.sh
+NESTED=yes && AND=ran
+NESTED=yes || OR=skipped
+alias ll='ls -l'
+AFTER_ERROR=ran
+`)
+	if err := source(script); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expect := range map[string]string{
+		"GREETING":    "hello",
+		"NAME":        "world",
+		"QUOTED":      "a   b",
+		"MESSAGE":     "hello, world",
+		"PATH_LIKE":   "/bin:/usr/bin",
+		"REMOVED":     "",
+		"NESTED":      "yes",
+		"AND":         "ran",
+		"OR":          "",
+		"AFTER_ERROR": "ran",
+	} {
+		if value := os.Getenv(name); value != expect {
+			t.Errorf("%s = %q, expected %q", name, value, expect)
+		}
+	}
+	if _, ok := os.LookupEnv("REMOVED"); ok {
+		t.Error("REMOVED should be unset")
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cwd != expectDir {
+		t.Errorf("Working directory = %q, expected %q", cwd, expectDir)
+	}
+
+	var aliases bytes.Buffer
+	if err := hush.Alias(&aliases, "ll"); err != nil {
+		t.Fatal(err)
+	}
+	if expect := "alias ll='ls -l'\n"; aliases.String() != expect {
+		t.Errorf("Alias = %q, expected %q", aliases.String(), expect)
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	dir := t.TempDir()
+	if err := source(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("Expected not exist error, got: %v", err)
+	}
+
+	script := writeScript(t, dir, "invalid", "if true; then\n")
+	if err := source(script); err == nil {
+		t.Error("Expected parse error")
+	}
+}
+
+func TestRunStmtUnsupported(t *testing.T) {
+	for _, tc := range []struct {
+		description string
+		script      string
+	}{
+		{"invalid alias", `alias 'l l=ls -l'`},
+		{"unknown alias", `unalias missing-alias`},
+		{"redirect", `FOO=bar > out`},
+		{"negation", `! FOO=bar`},
+		{"background", `FOO=bar &`},
+		{"if clause", `if true; then FOO=bar; fi`},
+		{"readonly", `readonly FOO=bar`},
+		{"array", `FOO=(a b)`},
+		{"source without file", `source`},
+	} {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			script := writeScript(t, t.TempDir(), "script", tc.script)
+			f, err := os.Open(script)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			file, err := syntax.NewParser().Parse(f, script)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(file.Stmts) != 1 {
+				t.Fatalf("Expected 1 statement, got %d", len(file.Stmts))
+			}
+			if err := runStmt(file.Stmts[0]); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"os"
+	"syscall/js"
+)
+
+// rawTerminal switches stdin to raw mode if it's a terminal, since hush does its own line editing and echo.
+// Unlike cfmakeraw, signals stay on so ^C still interrupts commands. Returns a func to restore the original mode.
+func rawTerminal() (restore func()) {
+	restore = func() {}
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return
+	}
+	defer func() {
+		_ = recover() // stdin isn't a hackpad terminal
+	}()
+	fs := js.Global().Get("fs")
+	constants := fs.Get("constants")
+	termios := fs.Call("ioctlSync", 0, constants.Get("TCGETS"))
+	if termios.Type() != js.TypeObject {
+		return
+	}
+	lflag := termios.Get("lflag").Int() &^ (constants.Get("ICANON").Int() | constants.Get("ECHO").Int() | constants.Get("ECHOE").Int() | constants.Get("ECHOCTL").Int())
+	iflag := termios.Get("iflag").Int() &^ constants.Get("ICRNL").Int()
+	fs.Call("ioctlSync", 0, constants.Get("TCSETS"), map[string]interface{}{
+		"lflag": lflag,
+		"iflag": iflag,
+	})
+	return func() {
+		defer func() {
+			_ = recover()
+		}()
+		fs.Call("ioctlSync", 0, constants.Get("TCSETS"), termios)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !js
+// +build !js
+
+package main
+
+// rawTerminal is a no-op, since hush sets up the terminal itself on other platforms
+func rawTerminal() (restore func()) {
+	return func() {}
+}
@@ -4,27 +4,27 @@ go 1.20
 
 require (
 	github.com/avct/uasurfer v0.0.0-20191028135549-26b5daa857f1
+	github.com/fatih/color v1.12.0
 	github.com/hack-pad/go-indexeddb v0.3.2
 	github.com/hack-pad/hackpadfs v0.2.1
-	github.com/hack-pad/hush v0.1.0
+	github.com/hack-pad/safejs v0.1.1
 	github.com/johnstarich/go/datasize v0.0.1
+	github.com/klauspost/compress v1.17.4
 	github.com/machinebox/progress v0.2.0
+	github.com/mattn/go-tty v0.0.3
 	github.com/pkg/errors v0.9.1
 	go.uber.org/atomic v1.6.0
+	mvdan.cc/sh/v3 v3.3.0
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/fatih/color v1.12.0 // indirect
-	github.com/hack-pad/safejs v0.1.1 // indirect
 	github.com/matryer/is v1.4.1 // indirect
 	github.com/mattn/go-colorable v0.1.8 // indirect
 	github.com/mattn/go-isatty v0.0.12 // indirect
-	github.com/mattn/go-tty v0.0.3 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/stretchr/objx v0.5.0 // indirect
 	github.com/stretchr/testify v1.8.2 // indirect
 	golang.org/x/sys v0.4.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
-	mvdan.cc/sh/v3 v3.3.0 // indirect
 )
@@ -0,0 +1,108 @@
+// Package asciicast records and replays terminal sessions in the asciicast v2 format.
+//
+// See https://github.com/asciinema/asciinema/blob/develop/doc/asciicast-v2.md
+package asciicast
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Version is the supported asciicast format version
+const Version = 2
+
+// EventType is the kind of an Event
+type EventType string
+
+const (
+	EventOutput EventType = "o"
+	EventInput  EventType = "i"
+	EventResize EventType = "r" // data is "COLSxROWS"
+)
+
+// Header is the first line of a recording
+type Header struct {
+	Version       int               `json:"version"`
+	Width         int               `json:"width"`
+	Height        int               `json:"height"`
+	Timestamp     int64             `json:"timestamp,omitempty"`
+	IdleTimeLimit float64           `json:"idle_time_limit,omitempty"`
+	Command       string            `json:"command,omitempty"`
+	Title         string            `json:"title,omitempty"`
+	Env           map[string]string `json:"env,omitempty"`
+}
+
+// Event is a timestamped change in the terminal
+type Event struct {
+	Time float64 // seconds since the recording started
+	Type EventType
+	Data string
+}
+
+// MarshalJSON encodes the event as an asciicast event line: [time, type, data]
+func (e Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{e.Time, e.Type, e.Data})
+}
+
+// UnmarshalJSON decodes an asciicast event line
+func (e *Event) UnmarshalJSON(b []byte) error {
+	var fields []json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	if len(fields) != 3 {
+		return errors.Errorf("Invalid event, expected 3 fields: %s", b)
+	}
+	if err := json.Unmarshal(fields[0], &e.Time); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(fields[1], &e.Type); err != nil {
+		return err
+	}
+	return json.Unmarshal(fields[2], &e.Data)
+}
+
+// Resize returns the new size of an EventResize
+func (e Event) Resize() (cols, rows int, err error) {
+	if e.Type != EventResize {
+		return 0, 0, errors.Errorf("Not a resize event: %q", e.Type)
+	}
+	_, err = fmt.Sscanf(e.Data, "%dx%d", &cols, &rows)
+	return
+}
+
+// Decode reads a recording's header and events
+func Decode(r io.Reader) (Header, []Event, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16<<20) // output events can be long
+	var header Header
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return Header{}, nil, err
+		}
+		return Header{}, nil, errors.New("Empty recording")
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return Header{}, nil, errors.Wrap(err, "Invalid header")
+	}
+	if header.Version != Version {
+		return Header{}, nil, errors.Errorf("Unsupported asciicast version: %d", header.Version)
+	}
+
+	var events []Event
+	for line := 2; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return Header{}, nil, errors.Wrapf(err, "Invalid event on line %d", line)
+		}
+		events = append(events, event)
+	}
+	return header, events, scanner.Err()
+}
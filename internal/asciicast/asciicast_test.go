@@ -0,0 +1,87 @@
+package asciicast
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRecordAndDecode(t *testing.T) {
+	var buf bytes.Buffer
+	now := time.Unix(1000, 0)
+	clock := func() time.Time { return now }
+	r, err := newRecorder(&buf, Header{Width: 80, Height: 24, Title: "demo"}, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if err := r.Input([]byte("ls\r")); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if err := r.Output([]byte("caf\xc3")); err != nil { // the incomplete 'é' is held for the next output
+		t.Fatal(err)
+	}
+	if err := r.Output([]byte("\xa9\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Second)
+	if err := r.Resize(100, 30); err != nil {
+		t.Fatal(err)
+	}
+
+	header, events, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectHeader := Header{Version: 2, Width: 80, Height: 24, Timestamp: 1000, Title: "demo"}
+	if !reflect.DeepEqual(header, expectHeader) {
+		t.Errorf("Expected header %+v, got %+v", expectHeader, header)
+	}
+	expectEvents := []Event{
+		{Time: 0.5, Type: EventInput, Data: "ls\r"},
+		{Time: 1, Type: EventOutput, Data: "caf"},
+		{Time: 1, Type: EventOutput, Data: "é\r\n"},
+		{Time: 2, Type: EventResize, Data: "100x30"},
+	}
+	if !reflect.DeepEqual(events, expectEvents) {
+		t.Errorf("Expected events %+v, got %+v", expectEvents, events)
+	}
+	cols, rows, err := events[3].Resize()
+	if err != nil || cols != 100 || rows != 30 {
+		t.Errorf("Expected 100x30, got %dx%d: %v", cols, rows, err)
+	}
+}
+
+func TestPlay(t *testing.T) {
+	events := []Event{
+		{Time: 0.01, Type: EventOutput, Data: "a"},
+		{Time: 0.02, Type: EventOutput, Data: "b"},
+		{Time: 3600, Type: EventOutput, Data: "c"}, // an hour idle is cut to the idle limit
+	}
+	player := NewPlayer(2)
+	var played []string
+	start := time.Now()
+	err := player.Play(context.Background(), events, 0.02, func(event Event) error {
+		played = append(played, event.Data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(played, []string{"a", "b", "c"}) {
+		t.Errorf("Unexpected events played: %v", played)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected playback to be quick, took %s", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	player.TogglePause()
+	cancel()
+	if err := player.Play(ctx, events, 0, func(Event) error { return nil }); err != context.Canceled {
+		t.Errorf("Expected canceled playback, got %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package asciicast
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Player replays events in real time, scaled by an adjustable speed. It is safe for concurrent use.
+type Player struct {
+	mu      sync.Mutex
+	speed   float64
+	paused  bool
+	changed chan struct{} // closed and replaced when the speed or pause state changes
+}
+
+// NewPlayer returns a Player at 'speed', where 1 is real time and 2 is twice as fast
+func NewPlayer(speed float64) *Player {
+	if speed <= 0 {
+		speed = 1
+	}
+	return &Player{
+		speed:   speed,
+		changed: make(chan struct{}),
+	}
+}
+
+// Speed returns the playback speed
+func (p *Player) Speed() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.speed
+}
+
+// SetSpeed changes the playback speed. Speeds of zero or less are ignored.
+func (p *Player) SetSpeed(speed float64) {
+	if speed <= 0 {
+		return
+	}
+	p.mu.Lock()
+	p.speed = speed
+	p.notifyChange()
+	p.mu.Unlock()
+}
+
+// TogglePause pauses or resumes playback, returning true if now paused
+func (p *Player) TogglePause() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.paused = !p.paused
+	p.notifyChange()
+	return p.paused
+}
+
+// notifyChange wakes Play to reschedule. Must be called with p.mu held.
+func (p *Player) notifyChange() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+func (p *Player) state() (speed float64, paused bool, changed <-chan struct{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.speed, p.paused, p.changed
+}
+
+// Play calls 'fn' with each event at its scaled time, until all events play or 'ctx' is canceled.
+// Pauses between events longer than 'idleTimeLimit' seconds are shortened to it, unless it's zero.
+func (p *Player) Play(ctx context.Context, events []Event, idleTimeLimit float64, fn func(Event) error) error {
+	var position float64 // seconds into the recording played so far
+	var skipped float64  // idle seconds cut from the recording so far
+	var previous float64
+	for _, event := range events {
+		if idle := event.Time - previous; idleTimeLimit > 0 && idle > idleTimeLimit {
+			skipped += idle - idleTimeLimit
+		}
+		previous = event.Time
+		eventTime := event.Time - skipped
+
+		for position < eventTime {
+			if err := p.wait(ctx, eventTime, &position); err != nil {
+				return err
+			}
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// wait waits until the recording reaches 'target' seconds at the current speed, or until the speed or pause state changes, advancing 'position' by the recording time played
+func (p *Player) wait(ctx context.Context, target float64, position *float64) error {
+	speed, paused, changed := p.state()
+	var timeout <-chan time.Time
+	if !paused {
+		timer := time.NewTimer(time.Duration((target - *position) / speed * float64(time.Second)))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	start := time.Now()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timeout:
+		*position = target
+	case <-changed:
+		if !paused {
+			*position += time.Since(start).Seconds() * speed
+		}
+	}
+	return nil
+}
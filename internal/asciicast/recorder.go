@@ -0,0 +1,84 @@
+package asciicast
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpad/internal/common"
+)
+
+// Recorder writes a recording as events happen. It is safe for concurrent use.
+type Recorder struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	start   time.Time
+	now     func() time.Time
+	output  []byte // incomplete UTF-8 sequence at the end of the last output
+	input   []byte // incomplete UTF-8 sequence at the end of the last input
+	err     error
+}
+
+// NewRecorder writes 'header' to 'w' and starts recording. The header's version and timestamp are set automatically.
+func NewRecorder(w io.Writer, header Header) (*Recorder, error) {
+	return newRecorder(w, header, time.Now)
+}
+
+func newRecorder(w io.Writer, header Header, now func() time.Time) (*Recorder, error) {
+	r := &Recorder{
+		encoder: json.NewEncoder(w),
+		start:   now(),
+		now:     now,
+	}
+	header.Version = Version
+	header.Timestamp = r.start.Unix()
+	if err := r.encoder.Encode(header); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Output records output written to the terminal
+func (r *Recorder) Output(p []byte) error {
+	return r.record(EventOutput, &r.output, p)
+}
+
+// Input records input typed into the terminal
+func (r *Recorder) Input(p []byte) error {
+	return r.record(EventInput, &r.input, p)
+}
+
+// Resize records the terminal changing size
+func (r *Recorder) Resize(cols, rows int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.write(EventResize, fmt.Sprintf("%dx%d", cols, rows))
+}
+
+// record writes 'p' as an event, holding back a trailing incomplete UTF-8 sequence until the next call, since event data must be valid UTF-8
+func (r *Recorder) record(eventType EventType, partial *[]byte, p []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data := append(*partial, p...)
+	n := common.CompleteUTF8(data)
+	*partial = append([]byte(nil), data[n:]...)
+	if n == 0 {
+		return r.err
+	}
+	return r.write(eventType, string(data[:n]))
+}
+
+// write encodes an event. After the first error, all writes fail. Must be called with r.mu held.
+func (r *Recorder) write(eventType EventType, data string) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.err = r.encoder.Encode(Event{
+		Time: r.now().Sub(r.start).Seconds(),
+		Type: eventType,
+		Data: data,
+	})
+	return r.err
+}
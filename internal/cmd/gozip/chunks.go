@@ -0,0 +1,106 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hack-pad/hackpad/internal/fs"
+	"github.com/hack-pad/hackpadfs"
+)
+
+const chunkIndexFile = "index.json"
+
+// chunkGo writes a chunked archive to 'dir': a compressed tar of each directory's files, named by its checksum, and an index listing every file.
+// Clients fetch the index up front, then each chunk when its files are first opened.
+func chunkGo(goRoot string, rules Rules, options archiveOptions, dir string) (Stats, error) {
+	extension, err := codecExtension(options.Codec)
+	if err != nil {
+		return Stats{}, err
+	}
+	entries, stats, err := collectGo(goRoot, rules)
+	fmt.Fprintf(os.Stderr, "Stats: %s\n", stats)
+	if err != nil {
+		return stats, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return stats, err
+	}
+
+	index := fs.ChunkIndex{
+		Codec:  options.Codec,
+		Chunks: make(map[string]fs.Chunk),
+	}
+	dirEntries := make(map[string][]entry)
+	for _, e := range entries {
+		name := strings.TrimPrefix(e.name, "/")
+		if name == "" {
+			continue // the root is implied
+		}
+		file := fs.ChunkFile{
+			Name: name,
+			Mode: hackpadfs.FileMode(normalizePerm(e.info.IsDir(), int64(e.info.Mode().Perm()))),
+		}
+		if e.info.IsDir() {
+			file.Mode |= hackpadfs.ModeDir
+		} else {
+			file.Size = e.info.Size()
+			dirEntries[path.Dir(name)] = append(dirEntries[path.Dir(name)], e)
+		}
+		index.Files = append(index.Files, file)
+	}
+	sort.Slice(index.Files, func(a, b int) bool {
+		return index.Files[a].Name < index.Files[b].Name
+	})
+
+	for chunkDir, chunkEntries := range dirEntries {
+		chunk, err := writeChunk(dir, chunkEntries, options, extension)
+		if err != nil {
+			return stats, err
+		}
+		index.Chunks[chunkDir] = chunk
+	}
+	// clients download the index on every load, so keep it compact
+	contents, err := json.Marshal(index)
+	if err != nil {
+		return stats, err
+	}
+	return stats, os.WriteFile(filepath.Join(dir, chunkIndexFile), contents, 0644)
+}
+
+// writeChunk writes a compressed tar of 'entries' to a file in 'dir' named by its checksum, so unchanged chunks keep their URL
+func writeChunk(dir string, entries []entry, options archiveOptions, extension string) (fs.Chunk, error) {
+	var buf bytes.Buffer
+	compressor, err := newCompressor(&buf, options.Codec, options.Level)
+	if err != nil {
+		return fs.Chunk{}, err
+	}
+	archive := tar.NewWriter(compressor)
+	for _, e := range entries {
+		if _, err := writeEntry(archive, e); err != nil {
+			return fs.Chunk{}, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return fs.Chunk{}, err
+	}
+	if err := compressor.Close(); err != nil {
+		return fs.Chunk{}, err
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	chunk := fs.Chunk{
+		URL:    hex.EncodeToString(sum[:8]) + extension,
+		SHA256: hex.EncodeToString(sum[:]),
+		Size:   int64(buf.Len()),
+	}
+	return chunk, os.WriteFile(filepath.Join(dir, chunk.URL), buf.Bytes(), 0644)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+const (
+	codecGzip = "gzip"
+	codecZstd = "zstd"
+)
+
+// newCompressor returns a writer compressing to 'w' with 'codec'. A zero 'level' uses the codec's default.
+// Both codecs write the same output for the same input, so archives are reproducible.
+func newCompressor(w io.Writer, codec string, level int) (io.WriteCloser, error) {
+	switch codec {
+	case codecGzip:
+		if level == 0 {
+			level = gzip.BestSpeed
+		}
+		return gzip.NewWriterLevel(w, level)
+	case codecZstd:
+		if level == 0 {
+			level = 3
+		}
+		if level < 1 || level > 22 {
+			return nil, errors.Errorf("zstd: invalid compression level: %d", level)
+		}
+		return zstd.NewWriter(w,
+			zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)),
+			zstd.WithEncoderConcurrency(1),
+		)
+	default:
+		return nil, errors.Errorf("Unsupported codec: %q", codec)
+	}
+}
+
+// codecExtension returns the file extension for tar archives compressed with 'codec'
+func codecExtension(codec string) (string, error) {
+	switch codec {
+	case codecGzip:
+		return ".tar.gz", nil
+	case codecZstd:
+		return ".tar.zst", nil
+	default:
+		return "", errors.Errorf("Unsupported codec: %q", codec)
+	}
+}
@@ -2,85 +2,222 @@ package main
 
 import (
 	"archive/tar"
-	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
+	"github.com/hack-pad/hackpad/internal/fs"
 	"github.com/johnstarich/go/datasize"
 )
 
 func main() {
-	if len(os.Args) != 2 {
+	set := flag.NewFlagSet("gozip", flag.ExitOnError)
+	set.Usage = func() {
+		fmt.Fprintln(set.Output(), "Usage: gozip [flags] GOROOT > go.tar.gz")
+		set.PrintDefaults()
+	}
+	rulesFile := set.String("rules", "", "Rules file in gitignore syntax, replacing the defaults. Excluded paths are left out of the archive.")
+	var flagRules Rules
+	set.Var(ruleFlag{rules: &flagRules}, "exclude", "Exclude paths matching a gitignore-style `pattern`, after the rules file. May be repeated.")
+	set.Var(ruleFlag{rules: &flagRules, negate: true}, "include", "Include paths matching a gitignore-style `pattern` again, after the rules file. May be repeated.")
+	list := set.Bool("list", false, "Print the paths that would be archived, without archiving them.")
+	statsFile := set.String("stats", "", "Write stats to `file` as JSON.")
+	var options archiveOptions
+	set.StringVar(&options.Codec, "codec", codecGzip, "Compression codec: gzip or zstd.")
+	set.IntVar(&options.Level, "level", 0, "Compression level. gzip accepts 1-9, defaulting to 1 for speed. zstd accepts 1-22, defaulting to 3.")
+	set.StringVar(&options.Manifest, "manifest", "", "Write a manifest of each file's SHA-256 checksum to `file` as JSON, for clients to verify the archive.")
+	chunksDir := set.String("chunks", "", "Write a chunked archive to `dir` instead: an index.json, plus a compressed tar of each directory's files for loading on demand.")
+	_ = set.Parse(os.Args[1:])
+	if set.NArg() != 1 {
 		fmt.Fprintln(os.Stderr, "Path to Go source is required")
 		os.Exit(1)
 		return
 	}
-	err := archiveGo(os.Args[1], os.Stdout)
+
+	rules := DefaultRules()
+	if *rulesFile != "" {
+		var err error
+		rules, err = ReadRules(*rulesFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	rules = append(rules, flagRules...)
+
+	var stats Stats
+	var err error
+	switch {
+	case *list:
+		stats, err = listGo(set.Arg(0), rules, os.Stdout)
+	case *chunksDir != "":
+		stats, err = chunkGo(set.Arg(0), rules, options, *chunksDir)
+	default:
+		stats, err = archiveGo(set.Arg(0), rules, options, os.Stdout)
+	}
+	if err == nil && *statsFile != "" {
+		err = writeJSON(*statsFile, stats)
+	}
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
 
-func archiveGo(goRoot string, w io.Writer) error {
-	compressor, err := gzip.NewWriterLevel(w, gzip.BestSpeed)
+type archiveOptions struct {
+	Codec    string
+	Level    int
+	Manifest string // manifest file path, if set
+}
+
+// entry is a path to archive, named by its path relative to GOROOT
+type entry struct {
+	name string
+	path string
+	info os.FileInfo
+}
+
+// collectGo returns the entries to archive in a stable order: the go binary first, so it's usable as early as possible, then the rest sorted by name.
+func collectGo(goRoot string, rules Rules) ([]entry, Stats, error) {
+	goRoot, err := filepath.Abs(goRoot)
 	if err != nil {
-		return err
+		return nil, Stats{}, err
+	}
+	newEntry := func(path string, info os.FileInfo) entry {
+		return entry{
+			name: filepath.ToSlash(strings.TrimPrefix(path, goRoot)),
+			path: path,
+			info: info,
+		}
 	}
-	archive := tar.NewWriter(compressor)
 
-	goRoot, err = filepath.Abs(goRoot)
+	goBinary := filepath.Join(goRoot, "bin", "js_wasm", "go")
+	goBinaryInfo, err := os.Stat(goBinary)
 	if err != nil {
-		return err
+		return nil, Stats{}, err
 	}
+	entries := []entry{newEntry(goBinary, goBinaryInfo)}
+	stats, err := walkGo(goRoot, rules, func(path string, info os.FileInfo) error {
+		entries = append(entries, newEntry(path, info))
+		return nil
+	})
+	rest := entries[1:]
+	sort.Slice(rest, func(a, b int) bool {
+		return rest[a].name < rest[b].name
+	})
+	return entries, stats, err
+}
 
-	doFile := func(path string, info os.FileInfo) error {
-		header, err := tar.FileInfoHeader(info, "")
+func archiveGo(goRoot string, rules Rules, options archiveOptions, w io.Writer) (Stats, error) {
+	compressor, err := newCompressor(w, options.Codec, options.Level)
+	if err != nil {
+		return Stats{}, err
+	}
+	entries, stats, err := collectGo(goRoot, rules)
+	fmt.Fprintf(os.Stderr, "Stats: %s\n", stats)
+	if err != nil {
+		return stats, err
+	}
+	archive := tar.NewWriter(compressor)
+	manifest := fs.Manifest{
+		Codec: options.Codec,
+		Files: make(map[string]string),
+	}
+	for _, e := range entries {
+		sum, err := writeEntry(archive, e)
 		if err != nil {
-			return err
+			return stats, err
 		}
-		header.Name = strings.TrimPrefix(path, goRoot)
-		err = archive.WriteHeader(header)
-		if err != nil {
-			return err
+		if sum != "" {
+			manifest.Files[e.name] = sum
 		}
-		if info.IsDir() {
-			return nil
-		}
-		f, err := os.Open(path)
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-		_, err = io.Copy(archive, f)
-		return err
 	}
 
-	goBinary := filepath.Join(goRoot, "bin", "js_wasm", "go")
-	goBinaryInfo, err := os.Stat(goBinary)
+	err = archive.Close()
 	if err != nil {
-		return err
+		return stats, err
 	}
-	err = doFile(goBinary, goBinaryInfo)
+	err = compressor.Close()
+	if err != nil || options.Manifest == "" {
+		return stats, err
+	}
+	return stats, writeJSON(options.Manifest, manifest)
+}
+
+// writeEntry writes 'e' to 'archive' with a normalized header, so the same files always produce the same archive.
+// Returns the SHA-256 checksum of regular files.
+func writeEntry(archive *tar.Writer, e entry) (string, error) {
+	header, err := tar.FileInfoHeader(e.info, "")
 	if err != nil {
-		return err
+		return "", err
+	}
+	normalizeHeader(header)
+	header.Name = e.name
+	err = archive.WriteHeader(header)
+	if err != nil {
+		return "", err
+	}
+	if header.Typeflag != tar.TypeReg {
+		return "", nil
+	}
+	f, err := os.Open(e.path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(archive, hash), f)
+	return hex.EncodeToString(hash.Sum(nil)), err
+}
+
+// normalizeHeader removes host-specific details from 'header': timestamps, owners, and permissions beyond executable or not
+func normalizeHeader(header *tar.Header) {
+	header.ModTime = time.Unix(0, 0)
+	header.AccessTime = time.Time{}
+	header.ChangeTime = time.Time{}
+	header.Uid, header.Gid = 0, 0
+	header.Uname, header.Gname = "", ""
+	header.PAXRecords = nil
+	header.Mode = normalizePerm(header.Typeflag == tar.TypeDir, header.Mode)
+}
+
+// normalizePerm returns 0755 for directories and executables, and 0644 otherwise
+func normalizePerm(isDir bool, perm int64) int64 {
+	if isDir || perm&0111 != 0 {
+		return 0755
 	}
+	return 0644
+}
 
-	stats, err := walkGo(goRoot, doFile)
+// listGo prints the archive paths archiveGo would write, one per line
+func listGo(goRoot string, rules Rules, w io.Writer) (Stats, error) {
+	entries, stats, err := collectGo(goRoot, rules)
 	fmt.Fprintf(os.Stderr, "Stats: %s\n", stats)
 	if err != nil {
-		return err
+		return stats, err
 	}
+	for _, e := range entries {
+		if _, err := fmt.Fprintln(w, e.name); err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
 
-	err = archive.Close()
+func writeJSON(filePath string, value interface{}) error {
+	contents, err := json.MarshalIndent(value, "", "  ")
 	if err != nil {
 		return err
 	}
-	return compressor.Close()
+	return os.WriteFile(filePath, append(contents, '\n'), 0644)
 }
 
 type Int64Slice []int64
@@ -94,10 +231,13 @@ type Stats struct {
 	SkippedDirs  int
 	IgnoredFiles int
 
-	sizes []int64
+	sizes []int64 // sizes of archived regular files
 }
 
 func (s Stats) SizeMetrics() (mean, median, max float64) {
+	if len(s.sizes) == 0 {
+		return 0, 0, 0
+	}
 	sort.Sort(Int64Slice(s.sizes))
 	var total float64
 	for _, num := range s.sizes {
@@ -109,66 +249,86 @@ func (s Stats) SizeMetrics() (mean, median, max float64) {
 	return total / float64(len(s.sizes)), float64(s.sizes[len(s.sizes)/2]), max
 }
 
+// percentile returns the size at percentile 'p', from 0 to 99. SizeMetrics must sort sizes first.
+func (s Stats) percentile(p int) int64 {
+	if len(s.sizes) == 0 {
+		return 0
+	}
+	return s.sizes[len(s.sizes)*p/100]
+}
+
+func (s Stats) totalSize() int64 {
+	var total int64
+	for _, size := range s.sizes {
+		total += size
+	}
+	return total
+}
+
 func (s Stats) String() string {
 	mean, median, max := s.SizeMetrics()
 	size := func(i int64) string {
 		return datasize.Bytes(int64(i)).String()
 	}
-	return fmt.Sprintf("mean=%v, median=%v, max=%v, 90th%%=%v, 99th%%=%v, visited=%v, skipped dirs=%v ignored files=%v", size(int64(mean)), size(int64(median)), size(int64(max)), size(s.sizes[len(s.sizes)*90/100]), size(s.sizes[len(s.sizes)*99/100]), s.Visited, s.SkippedDirs, s.IgnoredFiles)
+	return fmt.Sprintf("total=%v, mean=%v, median=%v, max=%v, 90th%%=%v, 99th%%=%v, visited=%v, skipped dirs=%v ignored files=%v", size(s.totalSize()), size(int64(mean)), size(int64(median)), size(int64(max)), size(s.percentile(90)), size(s.percentile(99)), s.Visited, s.SkippedDirs, s.IgnoredFiles)
 }
 
-// walkGo walks through a Go sources directory root and runs 'do' on files to archive.
-func walkGo(goRoot string, do func(string, os.FileInfo) error) (Stats, error) {
+// MarshalJSON implements json.Marshaler, including size metrics in bytes
+func (s Stats) MarshalJSON() ([]byte, error) {
+	mean, median, max := s.SizeMetrics()
+	return json.Marshal(struct {
+		Visited      int
+		SkippedDirs  int
+		IgnoredFiles int
+		TotalSize    int64
+		MeanSize     int64
+		MedianSize   int64
+		MaxSize      int64
+		P90Size      int64
+		P99Size      int64
+	}{
+		Visited:      s.Visited,
+		SkippedDirs:  s.SkippedDirs,
+		IgnoredFiles: s.IgnoredFiles,
+		TotalSize:    s.totalSize(),
+		MeanSize:     int64(mean),
+		MedianSize:   int64(median),
+		MaxSize:      int64(max),
+		P90Size:      s.percentile(90),
+		P99Size:      s.percentile(99),
+	})
+}
+
+// walkGo walks through a Go sources directory root and runs 'do' on files to archive. Paths excluded by 'rules' are skipped.
+func walkGo(goRoot string, rules Rules, do func(string, os.FileInfo) error) (Stats, error) {
 	var stats Stats
 	walkPath := goRoot + string(filepath.Separator) // ensures symlink dir is followed
+	goBinary := filepath.Join(goRoot, "bin", "js_wasm", "go")
 	return stats, filepath.Walk(walkPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-
-		stats.sizes = append(stats.sizes, info.Size())
+		relPath, err := filepath.Rel(goRoot, path)
+		if err != nil {
+			return err
+		}
 
 		switch {
-		case path == ".":
-			return nil // "skip" top-level dir, don't record in stats
-		case matchPath(path, goRoot, ".git"),
-			matchPath(path, goRoot, "api"),
-			matchPath(path, goRoot, "doc"),
-			matchPath(path, goRoot, "src", "cmd"),
-			matchPath(path, goRoot, "src", "runtime", "cgo"),
-			matchPath(path, goRoot, "src", "runtime", "race"),
-			strings.HasSuffix(path, string(filepath.Separator)+"testdata"),
-			matchPath(path, goRoot, "test"):
-			stats.SkippedDirs++
-			return filepath.SkipDir // explicitly skip all of these contents
-		case matchPath(path, goRoot, "pkg", "tool", "js_wasm", "cgo"),
-			matchPath(path, goRoot, "bin", "js_wasm", "go"), // handled specially above
-			strings.HasSuffix(path, ".a"),
-			strings.HasSuffix(path, "_test.go"):
-			return nil // skip specific files
-		case matchPathPrefix(path, goRoot, "bin", "js_wasm"),
-			matchPathPrefix(path, goRoot, "pkg", "js_wasm"),
-			matchPathPrefix(path, goRoot, "pkg", "include"),
-			matchPathPrefix(path, goRoot, "pkg", "tool", "js_wasm"):
-			stats.Visited++
-			return do(path, info)
-		case matchPathPrefix(path, goRoot, "bin"),
-			matchPathPrefix(path, goRoot, "pkg"):
+		case path == goBinary:
+			return nil // handled specially before walking
+		case relPath != "." && rules.Excluded(filepath.ToSlash(relPath), info.IsDir()):
+			if info.IsDir() {
+				stats.SkippedDirs++
+				return filepath.SkipDir // explicitly skip all of these contents
+			}
 			stats.IgnoredFiles++
-			return nil // skip things not explicitly matched here
+			return nil
 		default:
 			stats.Visited++
+			if info.Mode().IsRegular() {
+				stats.sizes = append(stats.sizes, info.Size())
+			}
 			return do(path, info)
 		}
 	})
 }
-
-func matchPath(match string, paths ...string) bool {
-	return match == filepath.Join(paths...)
-}
-
-// matchPathPrefix returns true if joining paths forms a prefix for or is equal to 'match'
-func matchPathPrefix(match string, paths ...string) bool {
-	path := filepath.Join(paths...)
-	return match == path || strings.HasPrefix(match, path+string(filepath.Separator))
-}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	_ "embed"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+//go:embed default.rules
+var defaultRules string
+
+// Rules decide which GOROOT paths to leave out of the archive, using gitignore syntax
+type Rules []rule
+
+type rule struct {
+	pattern  []string // slash-separated segments, where "**" matches any number of directories
+	negate   bool     // '!' includes matching paths again
+	dirOnly  bool     // a trailing slash only matches directories
+	anchored bool     // patterns containing a slash match from the root, otherwise they match a name at any depth
+}
+
+// ParseRules parses gitignore-style rules from 'r', one per line. Blank lines and lines starting with '#' are skipped.
+func ParseRules(r io.Reader) (Rules, error) {
+	var rules Rules
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule, err := parseRule(line)
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %d", lineNumber)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// ReadRules parses the rules file at 'filePath'
+func ReadRules(filePath string) (Rules, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rules, err := ParseRules(f)
+	return rules, errors.Wrap(err, filePath)
+}
+
+// DefaultRules returns the built-in rules, which keep only what a js/wasm Go toolchain needs to build
+func DefaultRules() Rules {
+	rules, err := ParseRules(strings.NewReader(defaultRules))
+	if err != nil {
+		panic(err)
+	}
+	return rules
+}
+
+func parseRule(line string) (rule, error) {
+	var r rule
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	r.anchored = strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return rule{}, errors.New("Empty pattern")
+	}
+	r.pattern = strings.Split(line, "/")
+	for _, segment := range r.pattern {
+		if _, err := path.Match(segment, ""); err != nil {
+			return rule{}, errors.Wrapf(err, "Invalid pattern %q", line)
+		}
+	}
+	return r, nil
+}
+
+// Excluded returns true if the slash-separated path 'relPath', relative to GOROOT, should be left out.
+// Callers skip excluded directories entirely, so their contents are never checked.
+func (rules Rules) Excluded(relPath string, isDir bool) bool {
+	segments := strings.Split(strings.Trim(relPath, "/"), "/")
+	excluded := false
+	for _, r := range rules {
+		if r.matches(segments, isDir) {
+			excluded = !r.negate
+		}
+	}
+	return excluded
+}
+
+func (r rule) matches(segments []string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if !r.anchored {
+		return len(r.pattern) == 1 && matchSegment(r.pattern[0], segments[len(segments)-1])
+	}
+	return matchSegments(r.pattern, segments)
+}
+
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	return len(segments) > 0 &&
+		matchSegment(pattern[0], segments[0]) &&
+		matchSegments(pattern[1:], segments[1:])
+}
+
+func matchSegment(pattern, name string) bool {
+	match, _ := path.Match(pattern, name)
+	return match
+}
+
+// ruleFlag adds a rule for each use of a flag, keeping the order of -include and -exclude flags
+type ruleFlag struct {
+	rules  *Rules
+	negate bool
+}
+
+func (f ruleFlag) String() string {
+	return ""
+}
+
+func (f ruleFlag) Set(pattern string) error {
+	if f.negate {
+		pattern = "!" + pattern
+	}
+	r, err := parseRule(pattern)
+	if err != nil {
+		return err
+	}
+	*f.rules = append(*f.rules, r)
+	return nil
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules(strings.NewReader("# comment\n\n/a/\n!b\n*.go\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := Rules{
+		{pattern: []string{"a"}, dirOnly: true, anchored: true},
+		{pattern: []string{"b"}, negate: true},
+		{pattern: []string{"*.go"}},
+	}
+	if !reflect.DeepEqual(expect, rules) {
+		t.Errorf("Expected rules %+v, got %+v", expect, rules)
+	}
+
+	for _, invalid := range []string{"/", "!", "[", "a/[b"} {
+		if _, err := ParseRules(strings.NewReader(invalid)); err == nil {
+			t.Errorf("Expected error parsing %q", invalid)
+		}
+	}
+}
+
+func TestRulesExcluded(t *testing.T) {
+	for _, tc := range []struct {
+		description string
+		rules       string
+		path        string
+		isDir       bool
+		expect      bool
+	}{
+		{"no rules", "", "a", false, false},
+		{"name at root", "a", "a", false, true},
+		{"name at any depth", "a", "b/c/a", false, true},
+		{"glob name", "*.go", "b/main.go", false, true},
+		{"glob doesn't cross segments", "*.go", "main.go/b", false, false},
+		{"anchored", "/a", "a", false, true},
+		{"anchored only at root", "/a", "b/a", false, false},
+		{"nested anchored", "a/b", "a/b", false, true},
+		{"nested anchored prefix", "a/b", "a/b/c", false, false},
+		{"dir only matches dir", "a/", "a", true, true},
+		{"dir only skips file", "a/", "a", false, false},
+		{"double star", "a/**/c", "a/b/b/c", false, true},
+		{"double star matches zero dirs", "a/**/c", "a/c", false, true},
+		{"leading double star", "**/c", "a/b/c", false, true},
+		{"negated", "a*\n!ab", "ab", false, false},
+		{"last rule wins", "!ab\na*", "ab", false, true},
+		{"trailing slash in path", "/a/", "a/", true, true},
+	} {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			rules, err := ParseRules(strings.NewReader(tc.rules))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if excluded := rules.Excluded(tc.path, tc.isDir); excluded != tc.expect {
+				t.Errorf("Excluded(%q, %t) = %t, expected %t", tc.path, tc.isDir, excluded, tc.expect)
+			}
+		})
+	}
+}
+
+func TestRuleFlags(t *testing.T) {
+	var rules Rules
+	exclude, include := ruleFlag{rules: &rules}, ruleFlag{rules: &rules, negate: true}
+	for _, err := range []error{exclude.Set("/pkg/*"), include.Set("/pkg/js_wasm/")} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !rules.Excluded("pkg/linux_amd64", true) {
+		t.Error("Expected pkg/linux_amd64 to be excluded")
+	}
+	if rules.Excluded("pkg/js_wasm", true) {
+		t.Error("Expected pkg/js_wasm to be included")
+	}
+}
+
+// goRootFiles is a sample of files in a GOROOT built for js/wasm
+var goRootFiles = []string{
+	".git/config",
+	"VERSION",
+	"api/go1.txt",
+	"bin/go",
+	"bin/gofmt",
+	"bin/js_wasm/go",
+	"bin/js_wasm/gofmt",
+	"doc/go_spec.html",
+	"lib/time/zoneinfo.zip",
+	"misc/wasm/wasm_exec.js",
+	"pkg/include/textflag.h",
+	"pkg/js_wasm/fmt.a",
+	"pkg/js_wasm/internal/README",
+	"pkg/linux_amd64/fmt.a",
+	"pkg/linux_amd64/README",
+	"pkg/tool/js_wasm/cgo",
+	"pkg/tool/js_wasm/compile",
+	"pkg/tool/js_wasm/link",
+	"pkg/tool/linux_amd64/compile",
+	"src/cmd/go/main.go",
+	"src/fmt/print.go",
+	"src/fmt/print_test.go",
+	"src/fmt/testdata/input.txt",
+	"src/runtime/cgo/cgo.go",
+	"src/runtime/internal/sys/arch.go",
+	"src/runtime/race/race.go",
+	"src/runtime/runtime.go",
+	"test/fixedbugs/bug.go",
+}
+
+func writeGoRoot(t *testing.T) string {
+	t.Helper()
+	goRoot := t.TempDir()
+	for _, name := range goRootFiles {
+		path := filepath.Join(goRoot, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return goRoot
+}
+
+// archivedFiles returns the sorted files walk passes to 'do', relative to goRoot
+func archivedFiles(t *testing.T, goRoot string, walk func(do func(string, os.FileInfo) error) error) []string {
+	t.Helper()
+	var files []string
+	err := walk(func(path string, info os.FileInfo) error {
+		if info.Mode().IsRegular() {
+			relPath, err := filepath.Rel(goRoot, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(relPath))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// legacyWalkGo is walkGo before rules were configurable, with its exclusions hard-coded
+func legacyWalkGo(goRoot string, do func(string, os.FileInfo) error) error {
+	walkPath := goRoot + string(filepath.Separator)
+	matchPath := func(match string, paths ...string) bool {
+		return match == filepath.Join(paths...)
+	}
+	matchPathPrefix := func(match string, paths ...string) bool {
+		path := filepath.Join(paths...)
+		return match == path || strings.HasPrefix(match, path+string(filepath.Separator))
+	}
+	return filepath.Walk(walkPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		switch {
+		case matchPath(path, goRoot, ".git"),
+			matchPath(path, goRoot, "api"),
+			matchPath(path, goRoot, "doc"),
+			matchPath(path, goRoot, "src", "cmd"),
+			matchPath(path, goRoot, "src", "runtime", "cgo"),
+			matchPath(path, goRoot, "src", "runtime", "race"),
+			strings.HasSuffix(path, string(filepath.Separator)+"testdata"),
+			matchPath(path, goRoot, "test"):
+			return filepath.SkipDir
+		case matchPath(path, goRoot, "pkg", "tool", "js_wasm", "cgo"),
+			matchPath(path, goRoot, "bin", "js_wasm", "go"),
+			strings.HasSuffix(path, ".a"),
+			strings.HasSuffix(path, "_test.go"):
+			return nil
+		case matchPathPrefix(path, goRoot, "bin", "js_wasm"),
+			matchPathPrefix(path, goRoot, "pkg", "js_wasm"),
+			matchPathPrefix(path, goRoot, "pkg", "include"),
+			matchPathPrefix(path, goRoot, "pkg", "tool", "js_wasm"):
+			return do(path, info)
+		case matchPathPrefix(path, goRoot, "bin"),
+			matchPathPrefix(path, goRoot, "pkg"):
+			return nil
+		default:
+			return do(path, info)
+		}
+	})
+}
+
+func TestDefaultRulesMatchLegacyWalk(t *testing.T) {
+	goRoot := writeGoRoot(t)
+	legacy := archivedFiles(t, goRoot, func(do func(string, os.FileInfo) error) error {
+		return legacyWalkGo(goRoot, do)
+	})
+	archived := archivedFiles(t, goRoot, func(do func(string, os.FileInfo) error) error {
+		_, err := walkGo(goRoot, DefaultRules(), do)
+		return err
+	})
+	if !reflect.DeepEqual(legacy, archived) {
+		t.Errorf("Expected default rules to archive the same files as before:\n%q\nGot:\n%q", legacy, archived)
+	}
+	expect := []string{
+		"VERSION",
+		"bin/js_wasm/gofmt",
+		"lib/time/zoneinfo.zip",
+		"misc/wasm/wasm_exec.js",
+		"pkg/include/textflag.h",
+		"pkg/js_wasm/internal/README",
+		"pkg/tool/js_wasm/compile",
+		"pkg/tool/js_wasm/link",
+		"src/fmt/print.go",
+		"src/runtime/internal/sys/arch.go",
+		"src/runtime/runtime.go",
+	}
+	if !reflect.DeepEqual(expect, archived) {
+		t.Errorf("Expected archived files:\n%q\nGot:\n%q", expect, archived)
+	}
+}
+
+func TestWalkGoStats(t *testing.T) {
+	goRoot := writeGoRoot(t)
+	var files int
+	var totalSize int64
+	stats, err := walkGo(goRoot, DefaultRules(), func(path string, info os.FileInfo) error {
+		if info.Mode().IsRegular() {
+			files++
+			totalSize += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.sizes) != files {
+		t.Errorf("Expected %d sizes, one per archived file, got %d", files, len(stats.sizes))
+	}
+	if stats.totalSize() != totalSize {
+		t.Errorf("Expected total size %d, got %d", totalSize, stats.totalSize())
+	}
+	if stats.SkippedDirs == 0 || stats.IgnoredFiles == 0 {
+		t.Errorf("Expected skipped dirs and ignored files, got %+v", stats)
+	}
+}
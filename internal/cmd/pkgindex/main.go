@@ -0,0 +1,119 @@
+// Command pkgindex writes the package index for the Wasm binaries in a directory
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hack-pad/hackpad/internal/packages"
+	"github.com/pkg/errors"
+)
+
+// metadata adds dependencies to packages built from cmd/
+var metadata = map[string]packages.Package{
+	"editor": {Dependencies: []string{"sh"}},
+	"sh":     {Dependencies: []string{"coreutils"}},
+}
+
+// multiCall are packages built from cmd/ which run several tools, like BusyBox. Each lists its own tools with --list.
+var multiCall = map[string]bool{
+	"coreutils": true,
+	"procps":    true,
+}
+
+// notPackages are Wasm binaries served next to packages that aren't installable
+var notPackages = map[string]bool{
+	"main": true, // hackpad itself
+}
+
+func main() {
+	version := flag.String("version", "dev", "Version of every package")
+	cmdDir := flag.String("cmd", "cmd", "Directory of the packages' Go commands, for listing multi-call tools")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: pkgindex [-version VERSION] [-cmd DIR] DIR")
+		os.Exit(1)
+	}
+	index, err := buildIndex(flag.Arg(0), *version, goRunList(*cmdDir))
+	if err == nil {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(index)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// toolLister returns the tools of the multi-call package 'name'
+type toolLister func(name string) ([]string, error)
+
+// goRunList lists tools by running the package's command from 'cmdDir' on the host with --list
+func goRunList(cmdDir string) toolLister {
+	return func(name string) ([]string, error) {
+		cmd := exec.Command("go", "run", "./"+filepath.ToSlash(filepath.Join(cmdDir, name)), "--list")
+		cmd.Stderr = os.Stderr
+		output, err := cmd.Output()
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to list tools for %s", name)
+		}
+		return strings.Fields(string(output)), nil
+	}
+}
+
+func buildIndex(dir, version string, listTools toolLister) (packages.Index, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.wasm"))
+	if err != nil {
+		return packages.Index{}, err
+	}
+	sort.Strings(paths)
+	var index packages.Index
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".wasm")
+		if notPackages[name] {
+			continue
+		}
+		sum, err := sha256File(path)
+		if err != nil {
+			return packages.Index{}, err
+		}
+		pkg := metadata[name]
+		if multiCall[name] {
+			pkg.Tools, err = listTools(name)
+			if err != nil {
+				return packages.Index{}, err
+			}
+			if len(pkg.Tools) == 0 {
+				return packages.Index{}, errors.Errorf("Multi-call package %s has no tools", name)
+			}
+		}
+		pkg.Name = name
+		pkg.Version = version
+		pkg.SHA256 = sum
+		index.Packages = append(index.Packages, pkg)
+	}
+	return index, nil
+}
+
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hack-pad/hackpad/internal/packages"
+)
+
+func TestBuildIndex(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"coreutils", "main", "sh"} {
+		if err := os.WriteFile(filepath.Join(dir, name+".wasm"), []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	index, err := buildIndex(dir, "v1", func(name string) ([]string, error) {
+		if name != "coreutils" {
+			t.Errorf("Unexpected tool list for %q", name)
+		}
+		return []string{"cat", "wc"}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := packages.Index{Packages: []packages.Package{
+		{
+			Name:    "coreutils",
+			Version: "v1",
+			SHA256:  sha256Hex("coreutils"),
+			Tools:   []string{"cat", "wc"},
+		},
+		{
+			Name:         "sh",
+			Version:      "v1",
+			SHA256:       sha256Hex("sh"),
+			Dependencies: []string{"coreutils"},
+		},
+	}}
+	if !reflect.DeepEqual(expect, index) {
+		t.Errorf("Expected index %+v, got %+v", expect, index)
+	}
+}
+
+func sha256Hex(contents string) string {
+	sum := sha256.Sum256([]byte(contents))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestBuildIndexNoTools(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "procps.wasm"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := buildIndex(dir, "v1", func(string) ([]string, error) {
+		return nil, nil
+	})
+	if err == nil {
+		t.Error("Expected an error for a multi-call package without tools")
+	}
+}
+
+func TestGoRunList(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Builds commands")
+	}
+	listTools := goRunList(filepath.Join("..", "..", "..", "cmd"))
+	for name := range multiCall {
+		tools, err := listTools(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tools) == 0 {
+			t.Errorf("Expected tools for %s", name)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package common
+
+import "unicode/utf8"
+
+// CompleteUTF8 returns the length of the longest prefix of 'p' which doesn't end in an incomplete UTF-8 sequence.
+// Invalid sequences count as complete, so they're passed along rather than held forever.
+func CompleteUTF8(p []byte) int {
+	// a sequence is at most utf8.UTFMax bytes, so only the last few bytes can start an incomplete one
+	for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax; i-- {
+		c := p[i]
+		if c < utf8.RuneSelf {
+			return len(p) // ASCII
+		}
+		if !utf8.RuneStart(c) {
+			continue // continuation byte
+		}
+		if utf8.FullRune(p[i:]) {
+			return len(p)
+		}
+		return i
+	}
+	return len(p)
+}
@@ -0,0 +1,24 @@
+package common
+
+import "testing"
+
+func TestCompleteUTF8(t *testing.T) {
+	for _, tc := range []struct {
+		input    string
+		complete int
+	}{
+		{"", 0},
+		{"abc", 3},
+		{"aé", 3},
+		{"a\xc3", 1},
+		{"a€", 4},
+		{"a\xe2\x82", 1},
+		{"\U0001f600", 4},
+		{"a\xf0\x9f\x98", 1},
+		{"a\x80\x80\x80\x80", 5}, // invalid continuation bytes are passed along
+	} {
+		if complete := CompleteUTF8([]byte(tc.input)); complete != tc.complete {
+			t.Errorf("CompleteUTF8(%q) = %d, expected %d", tc.input, complete, tc.complete)
+		}
+	}
+}
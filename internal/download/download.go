@@ -0,0 +1,240 @@
+// Package download fetches files in chunks, reporting progress and resuming interrupted downloads with Range requests.
+package download
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultRetries    = 3
+	defaultRetryDelay = 500 * time.Millisecond
+)
+
+// Transport starts a GET request for 'url', requesting bytes from 'offset' onward if it's greater than zero
+type Transport interface {
+	Get(ctx context.Context, url string, offset int64) (*Response, error)
+}
+
+// Response is a response to a GET request, with a body read in chunks
+type Response struct {
+	StatusCode int
+	Status     string
+	// AcceptRanges is set if the server supports byte Range requests
+	AcceptRanges bool
+	// ContentLength is the length of the body, or -1 if unknown
+	ContentLength int64
+	// ContentRange is the Content-Range header of partial responses, like "bytes 100-199/200"
+	ContentRange string
+	// Encoded is set if the body has a Content-Encoding, so lengths and ranges don't match the decoded body
+	Encoded bool
+	// Next returns the next chunk of the body, or io.EOF at the end
+	Next func() (blob.Blob, error)
+	// Close releases the body
+	Close func()
+}
+
+// Buffer receives downloaded data
+type Buffer interface {
+	blob.SetBlob
+	blob.GrowBlob
+	blob.TruncateBlob
+}
+
+// Options configure a download
+type Options struct {
+	// Progress is called with the percentage downloaded, from 0 to 100, if the length is known
+	Progress func(percentage float64)
+	// Retries is the number of times to retry transient failures. Defaults to 3. Negative disables retries.
+	Retries int
+	// RetryDelay is the delay before the first retry, doubling for each after. Defaults to 500ms.
+	RetryDelay time.Duration
+	// NewBuffer returns an empty buffer to download into. Defaults to a byte slice.
+	NewBuffer func() (Buffer, error)
+}
+
+// transientError is a failure worth retrying
+type transientError struct {
+	err error
+}
+
+func (t *transientError) Error() string {
+	return t.err.Error()
+}
+
+func (t *transientError) Unwrap() error {
+	return t.err
+}
+
+// Get downloads 'url' with 'transport' until complete, 'ctx' is canceled, or retries run out
+func Get(ctx context.Context, transport Transport, url string, options Options) (blob.Blob, error) {
+	if options.Retries == 0 {
+		options.Retries = defaultRetries
+	}
+	if options.RetryDelay == 0 {
+		options.RetryDelay = defaultRetryDelay
+	}
+	newBuffer := options.NewBuffer
+	if newBuffer == nil {
+		newBuffer = func() (Buffer, error) {
+			return blob.NewBytesLength(0), nil
+		}
+	}
+	buf, err := newBuffer()
+	if err != nil {
+		return nil, err
+	}
+
+	d := &downloader{
+		transport: transport,
+		url:       url,
+		buf:       buf,
+		total:     -1,
+		progress:  options.Progress,
+	}
+	delay := options.RetryDelay
+	for attempt := 0; ; attempt++ {
+		err := d.attempt(ctx)
+		if err == nil {
+			return buf, buf.Truncate(d.received)
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		var transient *transientError
+		if !errors.As(err, &transient) || attempt >= options.Retries {
+			return nil, errors.Wrapf(err, "Failed to download %s", url)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+type downloader struct {
+	transport Transport
+	url       string
+	buf       Buffer
+	received  int64 // bytes of buf filled so far
+	total     int64 // expected length, or -1 if unknown
+	resumable bool
+	progress  func(float64)
+}
+
+// attempt requests the rest of the download, resuming where the last attempt stopped if the server supports it
+func (d *downloader) attempt(ctx context.Context) error {
+	if !d.resumable {
+		d.received = 0
+	}
+	resp, err := d.transport.Get(ctx, d.url, d.received)
+	if err != nil {
+		return &transientError{err}
+	}
+	defer resp.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		d.received = 0 // a full response, even if a range was requested
+		d.total = -1
+		if !resp.Encoded {
+			d.total = resp.ContentLength
+		}
+	case resp.StatusCode == http.StatusPartialContent && d.received > 0:
+		start, total, err := parseContentRange(resp.ContentRange)
+		if err != nil {
+			return err
+		}
+		if start != d.received {
+			return errors.Errorf("Server resumed at byte %d, expected %d", start, d.received)
+		}
+		d.total = total
+	case isTransientStatus(resp.StatusCode):
+		return &transientError{statusError(resp)}
+	default:
+		return statusError(resp)
+	}
+	d.resumable = resp.AcceptRanges && !resp.Encoded
+	d.reportProgress()
+
+	for {
+		chunk, err := resp.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return &transientError{err}
+		}
+		if err := d.write(chunk); err != nil {
+			return err
+		}
+		d.reportProgress()
+	}
+	if d.total >= 0 && d.received < d.total {
+		return &transientError{errors.Errorf("Download ended early after %d of %d bytes", d.received, d.total)}
+	}
+	if d.progress != nil && d.total < 0 {
+		d.progress(100)
+	}
+	return nil
+}
+
+// write appends 'chunk', growing the buffer by at least double to avoid copying it for every chunk
+func (d *downloader) write(chunk blob.Blob) error {
+	end := d.received + int64(chunk.Len())
+	if capacity := int64(d.buf.Len()); end > capacity {
+		grow := end - capacity
+		if d.total > capacity && d.total >= end {
+			grow = d.total - capacity
+		} else if capacity > grow {
+			grow = capacity
+		}
+		if err := d.buf.Grow(grow); err != nil {
+			return err
+		}
+	}
+	if _, err := d.buf.Set(chunk, d.received); err != nil {
+		return err
+	}
+	d.received = end
+	return nil
+}
+
+func (d *downloader) reportProgress() {
+	if d.progress != nil && d.total > 0 {
+		d.progress(100 * float64(d.received) / float64(d.total))
+	}
+}
+
+func isTransientStatus(code int) bool {
+	return code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+}
+
+func statusError(resp *Response) error {
+	return errors.Errorf("Unexpected response: %d %s", resp.StatusCode, resp.Status)
+}
+
+// parseContentRange parses a Content-Range header like "bytes 100-199/200". The total is -1 if unknown.
+func parseContentRange(contentRange string) (start, total int64, err error) {
+	var end int64
+	if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/%d", &start, &end, &total); err == nil {
+		return start, total, nil
+	}
+	if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/*", &start, &end); err == nil {
+		return start, -1, nil
+	}
+	return 0, 0, errors.Errorf("Invalid Content-Range: %q", contentRange)
+}
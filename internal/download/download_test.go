@@ -0,0 +1,204 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+	"github.com/pkg/errors"
+)
+
+// testTransport serves 'content' in chunks, failing requests listed in 'failures' after sending 'failAfter' bytes
+type testTransport struct {
+	content      []byte
+	chunkSize    int
+	acceptRanges bool
+	failures     map[int]int // request number -> bytes sent before failing, or -1 to fail before responding
+	statuses     map[int]int // request number -> status code
+	offsets      []int64
+}
+
+func (tr *testTransport) Get(ctx context.Context, url string, offset int64) (*Response, error) {
+	request := len(tr.offsets)
+	tr.offsets = append(tr.offsets, offset)
+	failAfter, fail := tr.failures[request]
+	if fail && failAfter < 0 {
+		return nil, errors.New("connection refused")
+	}
+	if status, ok := tr.statuses[request]; ok {
+		return &Response{StatusCode: status, Status: http.StatusText(status), Next: func() (blob.Blob, error) { return nil, io.EOF }, Close: func() {}}, nil
+	}
+
+	resp := &Response{
+		StatusCode:    http.StatusOK,
+		AcceptRanges:  tr.acceptRanges,
+		ContentLength: int64(len(tr.content)),
+		Close:         func() {},
+	}
+	body := tr.content
+	if offset > 0 && tr.acceptRanges {
+		resp.StatusCode = http.StatusPartialContent
+		resp.ContentRange = fmt.Sprintf("bytes %d-%d/%d", offset, len(tr.content)-1, len(tr.content))
+		body = body[offset:]
+		resp.ContentLength = int64(len(body))
+	}
+	sent := 0
+	resp.Next = func() (blob.Blob, error) {
+		if fail && sent >= failAfter {
+			return nil, errors.New("connection reset")
+		}
+		if sent == len(body) {
+			return nil, io.EOF
+		}
+		end := sent + tr.chunkSize
+		if end > len(body) {
+			end = len(body)
+		}
+		chunk := body[sent:end]
+		sent = end
+		return blob.NewBytes(chunk), nil
+	}
+	return resp, nil
+}
+
+func testContent(size int) []byte {
+	content := make([]byte, size)
+	for i := range content {
+		content[i] = byte(i)
+	}
+	return content
+}
+
+func TestGet(t *testing.T) {
+	content := testContent(1000)
+	for _, tc := range []struct {
+		description   string
+		acceptRanges  bool
+		failures      map[int]int
+		statuses      map[int]int
+		expectOffsets []int64
+		expectErr     bool
+	}{
+		{
+			description:   "one request",
+			expectOffsets: []int64{0},
+		},
+		{
+			description:   "resume with range",
+			acceptRanges:  true,
+			failures:      map[int]int{0: 300},
+			expectOffsets: []int64{0, 300},
+		},
+		{
+			description:   "restart without range support",
+			failures:      map[int]int{0: 300},
+			expectOffsets: []int64{0, 0},
+		},
+		{
+			description:   "retry refused connection",
+			acceptRanges:  true,
+			failures:      map[int]int{0: -1},
+			expectOffsets: []int64{0, 0},
+		},
+		{
+			description:   "retry server error",
+			statuses:      map[int]int{0: http.StatusServiceUnavailable},
+			expectOffsets: []int64{0, 0},
+		},
+		{
+			description:   "not found",
+			statuses:      map[int]int{0: http.StatusNotFound},
+			expectOffsets: []int64{0},
+			expectErr:     true,
+		},
+		{
+			description:   "retries run out",
+			failures:      map[int]int{0: -1, 1: -1, 2: -1, 3: -1},
+			expectOffsets: []int64{0, 0, 0, 0},
+			expectErr:     true,
+		},
+	} {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			transport := &testTransport{
+				content:      content,
+				chunkSize:    100,
+				acceptRanges: tc.acceptRanges,
+				failures:     tc.failures,
+				statuses:     tc.statuses,
+			}
+			var lastProgress float64
+			b, err := Get(context.Background(), transport, "file", Options{
+				RetryDelay: time.Millisecond,
+				Progress: func(percentage float64) {
+					lastProgress = percentage
+				},
+			})
+			if fmt.Sprint(transport.offsets) != fmt.Sprint(tc.expectOffsets) {
+				t.Errorf("Expected requests at offsets %v, got %v", tc.expectOffsets, transport.offsets)
+			}
+			if tc.expectErr {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b.Bytes()) != string(content) {
+				t.Errorf("Expected %d bytes of content, got %d bytes", len(content), b.Len())
+			}
+			if lastProgress != 100 {
+				t.Errorf("Expected progress to end at 100, got %v", lastProgress)
+			}
+		})
+	}
+}
+
+func TestGetCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	transport := &testTransport{
+		content:   testContent(1000),
+		chunkSize: 100,
+		failures:  map[int]int{0: 300},
+	}
+	_, err := Get(ctx, transport, "file", Options{
+		Progress: func(float64) {
+			cancel()
+		},
+	})
+	if err != context.Canceled {
+		t.Errorf("Expected context canceled, got: %v", err)
+	}
+	if len(transport.offsets) != 1 {
+		t.Errorf("Expected no retries after cancel, got requests at offsets %v", transport.offsets)
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	for _, tc := range []struct {
+		contentRange string
+		start, total int64
+		expectErr    bool
+	}{
+		{contentRange: "bytes 100-199/200", start: 100, total: 200},
+		{contentRange: "bytes 100-199/*", start: 100, total: -1},
+		{contentRange: "bytes */200", expectErr: true},
+	} {
+		start, total, err := parseContentRange(tc.contentRange)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("parseContentRange(%q): Expected error", tc.contentRange)
+			}
+			continue
+		}
+		if err != nil || start != tc.start || total != tc.total {
+			t.Errorf("parseContentRange(%q) = %d, %d, %v; expected %d, %d", tc.contentRange, start, total, err, tc.start, tc.total)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+//go:build js
+// +build js
+
+package download
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"syscall/js"
+
+	"github.com/hack-pad/hackpad/internal/common"
+	"github.com/hack-pad/hackpad/internal/promise"
+	"github.com/hack-pad/hackpadfs/indexeddb/idbblob"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+var (
+	jsFetch           = js.Global().Get("fetch")
+	jsObject          = js.Global().Get("Object")
+	jsAbortController = js.Global().Get("AbortController")
+)
+
+// Fetch is a Transport using the fetch API. Chunks stay inside JS Uint8Arrays, so memory usage is lower than the "native" http package.
+type Fetch struct{}
+
+// NewBuffer returns an empty buffer backed by a JS Uint8Array
+func NewBuffer() (Buffer, error) {
+	return idbblob.NewLength(0)
+}
+
+// Get implements Transport
+func (Fetch) Get(ctx context.Context, url string, offset int64) (_ *Response, err error) {
+	defer common.CatchException(&err)
+	controller := jsAbortController.New()
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			controller.Call("abort")
+		case <-stop:
+		}
+	}()
+	closeOnce := func() {
+		select {
+		case <-stop:
+		default:
+			close(stop)
+		}
+	}
+
+	init := jsObject.New()
+	init.Set("signal", controller.Get("signal"))
+	if offset > 0 {
+		headers := jsObject.New()
+		headers.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+		init.Set("headers", headers)
+	}
+	resultInt, err := promise.From(jsFetch.Invoke(url, init)).Await()
+	if err != nil {
+		closeOnce()
+		return nil, err
+	}
+	result := resultInt.(js.Value)
+	headers := result.Get("headers")
+	header := func(name string) string {
+		value := headers.Call("get", name)
+		if value.IsNull() {
+			return ""
+		}
+		return value.String()
+	}
+	contentLength, err := strconv.ParseInt(header("Content-Length"), 10, 64)
+	if err != nil {
+		contentLength = -1
+	}
+
+	var reader js.Value
+	if body := result.Get("body"); body.Truthy() {
+		reader = body.Call("getReader")
+	}
+	return &Response{
+		StatusCode:    result.Get("status").Int(),
+		Status:        result.Get("statusText").String(),
+		AcceptRanges:  header("Accept-Ranges") == "bytes",
+		ContentLength: contentLength,
+		ContentRange:  header("Content-Range"),
+		Encoded:       header("Content-Encoding") != "",
+		Next: func() (_ blob.Blob, err error) {
+			defer common.CatchException(&err)
+			if reader.IsUndefined() {
+				return nil, io.EOF
+			}
+			chunkInt, err := promise.From(reader.Call("read")).Await()
+			if err != nil {
+				return nil, err
+			}
+			chunk := chunkInt.(js.Value)
+			if chunk.Get("done").Bool() {
+				return nil, io.EOF
+			}
+			return idbblob.New(chunk.Get("value"))
+		},
+		Close: func() {
+			closeOnce()
+			if !reader.IsUndefined() {
+				reader.Call("cancel")
+			}
+		},
+	}, nil
+}
@@ -0,0 +1,125 @@
+package fs
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"sort"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Manifest lists the SHA-256 checksum of each regular file in an archive, keyed by its tar header name
+type Manifest struct {
+	Codec string            `json:"codec"`
+	Files map[string]string `json:"files"`
+}
+
+// ManifestLoader returns an archive's manifest. It's only called if the archive is unpacked.
+type ManifestLoader func() (Manifest, error)
+
+// decompress detects the compression of 'r' from its magic number, then returns a decompressed reader. Supports gzip and zstd.
+func decompress(r io.Reader) (io.Reader, error) {
+	buf := bufio.NewReader(r)
+	magic, err := buf.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(buf)
+	case bytes.HasPrefix(magic, zstdMagic):
+		// one goroutine and low memory, since Wasm has one thread and memory is tight
+		decoder, err := zstd.NewReader(buf, zstd.WithDecoderConcurrency(1), zstd.WithDecoderLowmem(true))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return nil, errors.Errorf("Unsupported archive compression. Magic number: %x", magic)
+	}
+}
+
+// verifyManifest reads the tar archive 'r', checking each regular file against 'manifest'
+func verifyManifest(r io.Reader, manifest Manifest) error {
+	archive := tar.NewReader(r)
+	seen := make(map[string]bool, len(manifest.Files))
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		expected, ok := manifest.Files[header.Name]
+		if !ok {
+			return errors.Errorf("File %s is missing from the manifest", header.Name)
+		}
+		hash := sha256.New()
+		if _, err := io.Copy(hash, archive); err != nil {
+			return err
+		}
+		if actual := hex.EncodeToString(hash.Sum(nil)); actual != expected {
+			return errors.Errorf("Checksum mismatch for %s: expected %s, got %s", header.Name, expected, actual)
+		}
+		seen[header.Name] = true
+	}
+
+	var missing []string
+	for name := range manifest.Files {
+		if !seen[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return errors.Errorf("Archive is missing %d files from the manifest, including %s", len(missing), missing[0])
+	}
+	return nil
+}
+
+// manifestVerifier checks an archive against its manifest while it's read. Call Wait after reading the whole archive.
+type manifestVerifier struct {
+	writer *io.PipeWriter
+	result chan error
+}
+
+// newManifestVerifier returns a reader which passes through 'r', verifying the archive against the manifest from 'load' in the background
+func newManifestVerifier(r io.Reader, load ManifestLoader) (io.Reader, *manifestVerifier) {
+	pipeR, pipeW := io.Pipe()
+	v := &manifestVerifier{
+		writer: pipeW,
+		result: make(chan error, 1),
+	}
+	go func() {
+		manifest, err := load()
+		if err == nil {
+			err = verifyManifest(pipeR, manifest)
+		} else {
+			err = errors.Wrap(err, "Failed to load manifest")
+		}
+		_, _ = io.Copy(io.Discard, pipeR) // keep the archive flowing if verification stopped early
+		v.result <- err
+	}()
+	return io.TeeReader(r, pipeW), v
+}
+
+// Wait returns the result of verification
+func (v *manifestVerifier) Wait() error {
+	v.writer.Close()
+	return <-v.result
+}
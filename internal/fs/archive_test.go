@@ -0,0 +1,127 @@
+package fs
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func testTar(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := tar.NewWriter(&buf)
+	for _, name := range []string{"/a.txt", "/dir/b.txt"} {
+		contents, ok := files[name]
+		if !ok {
+			continue
+		}
+		err := archive.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(contents))})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := archive.Write([]byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecompress(t *testing.T) {
+	const contents = "hello world"
+	var gzipped bytes.Buffer
+	gzipWriter := gzip.NewWriter(&gzipped)
+	_, _ = gzipWriter.Write([]byte(contents))
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var zstdCompressed bytes.Buffer
+	zstdWriter, err := zstd.NewWriter(&zstdCompressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = zstdWriter.Write([]byte(contents))
+	if err := zstdWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		description string
+		data        []byte
+		expectErr   bool
+	}{
+		{description: "gzip", data: gzipped.Bytes()},
+		{description: "zstd", data: zstdCompressed.Bytes()},
+		{description: "uncompressed", data: []byte(contents), expectErr: true},
+	} {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			r, err := decompress(bytes.NewReader(tc.data))
+			if tc.expectErr {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(result) != contents {
+				t.Errorf("Expected %q, got %q", contents, string(result))
+			}
+		})
+	}
+}
+
+func TestVerifyManifest(t *testing.T) {
+	sum := func(s string) string {
+		hash := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(hash[:])
+	}
+	files := map[string]string{"/a.txt": "a", "/dir/b.txt": "b"}
+	manifest := Manifest{Files: map[string]string{"/a.txt": sum("a"), "/dir/b.txt": sum("b")}}
+
+	for _, tc := range []struct {
+		description string
+		files       map[string]string
+		expectErr   string
+	}{
+		{description: "match", files: files},
+		{description: "mismatch", files: map[string]string{"/a.txt": "a", "/dir/b.txt": "c"}, expectErr: "Checksum mismatch for /dir/b.txt"},
+		{description: "missing", files: map[string]string{"/a.txt": "a"}, expectErr: "missing 1 files from the manifest, including /dir/b.txt"},
+	} {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			// read through the verifier like tar.NewReaderFS would
+			r, verifier := newManifestVerifier(bytes.NewReader(testTar(t, tc.files)), func() (Manifest, error) {
+				return manifest, nil
+			})
+			if _, err := io.Copy(io.Discard, r); err != nil {
+				t.Fatal(err)
+			}
+			err := verifier.Wait()
+			if tc.expectErr == "" {
+				if err != nil {
+					t.Error("Unexpected error:", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.expectErr) {
+				t.Errorf("Expected error containing %q, got: %v", tc.expectErr, err)
+			}
+		})
+	}
+}
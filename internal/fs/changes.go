@@ -0,0 +1,52 @@
+package fs
+
+import (
+	"path"
+	"sync"
+
+	"github.com/hack-pad/hackpad/internal/common"
+	"github.com/hack-pad/hackpadfs"
+)
+
+// ChangeListener receives the absolute path of a file or directory that was created, removed, renamed, or had its mode changed.
+// Mounts report their mount path, since any file beneath it may have changed.
+type ChangeListener func(absPath string)
+
+var (
+	changeListenersMu sync.RWMutex
+	changeListeners   []ChangeListener
+)
+
+// OnChange calls 'listener' for every change made through FileDescriptors or new mounts
+func OnChange(listener ChangeListener) {
+	changeListenersMu.Lock()
+	changeListeners = append(changeListeners, listener)
+	changeListenersMu.Unlock()
+}
+
+// notifyChange reports a change to 'resolvedPath', a path from resolvePath
+func notifyChange(resolvedPath string) {
+	absPath := path.Join("/", resolvedPath)
+	changeListenersMu.RLock()
+	listeners := changeListeners
+	changeListenersMu.RUnlock()
+	for _, listener := range listeners {
+		listener(absPath)
+	}
+}
+
+// notifyChangeIfOK reports a change to 'resolvedPath' if 'err' is nil, then returns 'err'
+func notifyChangeIfOK(resolvedPath string, err error) error {
+	if err == nil {
+		notifyChange(resolvedPath)
+	}
+	return err
+}
+
+func addMount(mountPath string, fs hackpadfs.FS) error {
+	err := filesystem.AddMount(mountPath, fs)
+	if err == nil {
+		notifyChange(common.ResolvePath(".", mountPath))
+	}
+	return err
+}
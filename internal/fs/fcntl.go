@@ -0,0 +1,147 @@
+package fs
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hack-pad/hackpad/internal/interop"
+	"github.com/hack-pad/hackpadfs"
+	"github.com/pkg/errors"
+)
+
+// Open flags missing from Go's js/wasm syscall package. Values match Linux.
+const (
+	OpenNonBlock    = 0x800
+	OpenCloseOnExec = 0x80000
+)
+
+// Fcntl commands. Values match Linux.
+const (
+	FcntlDupFD            = 0
+	FcntlGetFD            = 1
+	FcntlSetFD            = 2
+	FcntlGetFL            = 3
+	FcntlSetFL            = 4
+	FcntlDupFDCloseOnExec = 1030
+)
+
+// FDCloseOnExec is the descriptor flag for close-on-exec, used by FcntlGetFD and FcntlSetFD
+const FDCloseOnExec = 1
+
+// statusFlags are the open flags kept after open and reported by FcntlGetFL
+const statusFlags = os.O_RDONLY | os.O_WRONLY | os.O_RDWR | os.O_APPEND | os.O_SYNC | OpenNonBlock
+
+// settableStatusFlags are the status flags FcntlSetFL may change. All others are ignored.
+const settableStatusFlags = os.O_APPEND | OpenNonBlock
+
+// Dup duplicates 'fd' onto the lowest available FID, like dup(2)
+func (f *FileDescriptors) Dup(fd FID) (FID, error) {
+	return f.dupFrom(fd, 0, false)
+}
+
+// Dup2 duplicates 'oldFD' onto 'newFD', like dup2(2). If 'newFD' is open, it is closed first.
+func (f *FileDescriptors) Dup2(oldFD, newFD FID) (FID, error) {
+	f.mu.Lock()
+	oldDescriptor := f.files[oldFD]
+	if oldDescriptor == nil {
+		f.mu.Unlock()
+		return 0, interop.BadFileNumber(oldFD)
+	}
+	if oldFD == newFD {
+		f.mu.Unlock()
+		return newFD, nil
+	}
+	replaced := f.files[newFD]
+	if replaced == nil {
+		if err := f.checkMaxFiles(1); err != nil {
+			f.mu.Unlock()
+			return 0, err
+		}
+	}
+	descriptor := oldDescriptor.Dup(newFD)
+	descriptor.Open()
+	f.addFileDescriptor(descriptor)
+	f.mu.Unlock()
+
+	if replaced != nil {
+		// like dup2(2), errors closing the replaced descriptor are silently ignored
+		_ = replaced.Close()
+	}
+	return newFD, nil
+}
+
+// dupFrom duplicates 'fd' onto the lowest available FID of at least 'minFID'
+func (f *FileDescriptors) dupFrom(fd, minFID FID, closeOnExec bool) (FID, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	oldDescriptor := f.files[fd]
+	if oldDescriptor == nil {
+		return 0, interop.BadFileNumber(fd)
+	}
+	if err := f.checkMaxFiles(1); err != nil {
+		return 0, err
+	}
+	descriptor := oldDescriptor.Dup(f.newFID(minFID))
+	descriptor.closeOnExec = closeOnExec
+	descriptor.Open()
+	f.addFileDescriptor(descriptor)
+	return descriptor.id, nil
+}
+
+// Fcntl performs 'cmd' on 'fd' with argument 'arg', like fcntl(2).
+// Supports duplicating descriptors, close-on-exec, and the O_APPEND and O_NONBLOCK status flags.
+func (f *FileDescriptors) Fcntl(fd FID, cmd int, arg int) (int, error) {
+	switch cmd {
+	case FcntlDupFD, FcntlDupFDCloseOnExec:
+		newFD, err := f.dupFrom(fd, FID(arg), cmd == FcntlDupFDCloseOnExec)
+		return int(newFD), err
+	case FcntlGetFD:
+		f.mu.RLock()
+		defer f.mu.RUnlock()
+		descriptor := f.files[fd]
+		if descriptor == nil {
+			return 0, interop.BadFileNumber(fd)
+		}
+		if descriptor.closeOnExec {
+			return FDCloseOnExec, nil
+		}
+		return 0, nil
+	case FcntlSetFD:
+		return 0, f.SetCloseOnExec(fd, arg&FDCloseOnExec != 0)
+	case FcntlGetFL:
+		descriptor, err := f.acquire(fd)
+		if err != nil {
+			return 0, err
+		}
+		defer descriptor.release()
+		return int(descriptor.flags.Load()), nil
+	case FcntlSetFL:
+		descriptor, err := f.acquire(fd)
+		if err != nil {
+			return 0, err
+		}
+		defer descriptor.release()
+		for {
+			flags := descriptor.flags.Load()
+			newFlags := flags&^int64(settableStatusFlags) | int64(arg&settableStatusFlags)
+			if descriptor.flags.CAS(flags, newFlags) {
+				return 0, nil
+			}
+		}
+	default:
+		return 0, interop.NewError(fmt.Sprintf("Unsupported fcntl command: %d", cmd), "EINVAL")
+	}
+}
+
+// seekAppend moves the offset to the end of the file if 'fd' is in append mode. Must be called with offsetMu held.
+func (fd *fileDescriptor) seekAppend() error {
+	if fd.flags.Load()&int64(os.O_APPEND) == 0 {
+		return nil
+	}
+	_, err := hackpadfs.SeekFile(fd.file, 0, io.SeekEnd)
+	if errors.Is(err, hackpadfs.ErrNotImplemented) {
+		err = nil // streams like pipes are always appended to
+	}
+	return err
+}
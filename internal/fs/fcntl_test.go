@@ -0,0 +1,109 @@
+package fs
+
+import (
+	"os"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+func TestLowestFIDReused(t *testing.T) {
+	f := newTestFileDescriptors(t)
+	a, err := f.Open("a", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := f.Open("b", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != 3 || b != 4 {
+		t.Fatalf("Expected FIDs 3 and 4, got %d and %d", a, b)
+	}
+	if err := f.Close(a); err != nil {
+		t.Fatal(err)
+	}
+	c, err := f.Dup(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != a {
+		t.Errorf("Expected lowest free FID %d, got %d", a, c)
+	}
+}
+
+func TestDup2Redirect(t *testing.T) {
+	f := newTestFileDescriptors(t)
+	out, err := f.Open("out", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1>out 2>&1
+	if _, err := f.Dup2(out, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Dup2(1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(out); err != nil {
+		t.Fatal(err)
+	}
+	for _, fd := range []FID{1, 2} {
+		data := []byte("hi")
+		if _, err := f.Write(fd, blob.NewBytes(data), 0, len(data), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	info, err := f.Fstat(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 4 {
+		t.Errorf("Expected both writes to share an offset in 'out', got size %d", info.Size())
+	}
+}
+
+func TestFcntl(t *testing.T) {
+	f := newTestFileDescriptors(t)
+	fd, err := f.Open("file", os.O_WRONLY|os.O_CREATE|OpenCloseOnExec, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if flags, err := f.Fcntl(fd, FcntlGetFD, 0); err != nil || flags != FDCloseOnExec {
+		t.Errorf("F_GETFD = %d, %v; expected FD_CLOEXEC", flags, err)
+	}
+	dupFD, err := f.Fcntl(fd, FcntlDupFD, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dupFD != 10 {
+		t.Errorf("Expected F_DUPFD to use FID 10, got %d", dupFD)
+	}
+	if flags, err := f.Fcntl(FID(dupFD), FcntlGetFD, 0); err != nil || flags != 0 {
+		t.Errorf("F_GETFD on duplicate = %d, %v; expected 0", flags, err)
+	}
+
+	if _, err := f.Fcntl(fd, FcntlSetFL, os.O_APPEND|OpenNonBlock|os.O_TRUNC); err != nil {
+		t.Fatal(err)
+	}
+	flags, err := f.Fcntl(FID(dupFD), FcntlGetFL, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := os.O_WRONLY | os.O_APPEND | OpenNonBlock; flags != expected {
+		t.Errorf("F_GETFL = %#o, expected status flags shared with duplicate: %#o", flags, expected)
+	}
+
+	child, _, err := NewFileDescriptors(2, f.WorkingDirectory(), f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer child.CloseAll()
+	if _, err := child.Fstat(fd); err == nil {
+		t.Error("Expected close-on-exec descriptor not to be inherited")
+	}
+	if _, err := child.Fstat(FID(dupFD)); err != nil {
+		t.Errorf("Expected descriptor %d to be inherited: %v", dupFD, err)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"os"
 	"path"
-	"strings"
 	"sync"
 
 	"github.com/hack-pad/hackpad/internal/common"
@@ -15,23 +14,29 @@ import (
 
 type FID = common.FID
 
+// fileDescriptor is an entry in a process's descriptor table. Descriptor flags like closeOnExec belong to the entry alone.
 type fileDescriptor struct {
-	id FID
+	id          FID
+	closeOnExec bool
 	*fileCore
 }
 
+// fileCore is an open file description. Descriptors duplicated or inherited from one another share the same fileCore, including its offset.
 type fileCore struct {
 	file hackpadfs.File
 	mode os.FileMode
 
-	openMu     sync.Mutex
-	openCounts map[common.PID]*atomic.Uint64
-	openedName string // used for debugging
+	offsetMu   sync.Mutex   // held by operations which use or move the file offset
+	refs       atomic.Int64 // number of descriptors referencing this description, across all processes
+	flags      atomic.Int64 // file status flags, see statusFlags
+	openedName string       // used for debugging
 }
 
 func NewFileDescriptor(fid FID, absPath string, flags int, mode os.FileMode) (*fileDescriptor, error) {
-	file, err := getFile(absPath, flags, mode)
+	file, err := getFile(absPath, flags&^(OpenCloseOnExec|OpenNonBlock), mode)
 	descriptor := newIrregularFileDescriptor(fid, path.Base(absPath), file, mode)
+	descriptor.closeOnExec = flags&OpenCloseOnExec != 0
+	descriptor.flags.Store(int64(flags & statusFlags))
 	return descriptor, err
 }
 
@@ -41,16 +46,17 @@ func newIrregularFileDescriptor(fid FID, name string, file hackpadfs.File, mode
 		fileCore: &fileCore{
 			file:       file,
 			mode:       mode,
-			openCounts: make(map[common.PID]*atomic.Uint64),
 			openedName: name,
 		},
 	}
 }
 
+// Dup returns a new descriptor sharing this descriptor's open file description. Call Open on the result to take a reference.
 func (fd *fileDescriptor) Dup(fid FID) *fileDescriptor {
-	fdCopy := *fd
-	fdCopy.id = fid
-	return &fdCopy
+	return &fileDescriptor{
+		id:       fid,
+		fileCore: fd.fileCore, // descriptor flags are not shared with duplicates
+	}
 }
 
 func (fd *fileDescriptor) FileName() string {
@@ -58,90 +64,25 @@ func (fd *fileDescriptor) FileName() string {
 }
 
 func (fd *fileDescriptor) String() string {
-	return fmt.Sprintf("%15s [%d] open=%v", fd.openedName, fd.id, openCountToString(fd.openCounts))
+	return fmt.Sprintf("%15s [%d] refs=%d", fd.openedName, fd.id, fd.refs.Load())
 }
 
-func (fd *fileDescriptor) Open(pid common.PID) {
-	count, ok := fd.openCounts[pid]
-	if ok {
-		count.Inc()
-		return
-	}
-	fd.fileCore.openMu.Lock()
-	if count, ok := fd.openCounts[pid]; ok {
-		count.Inc()
-	} else {
-		fd.openCounts[pid] = atomic.NewUint64(1)
-	}
-	fd.fileCore.openMu.Unlock()
+// Open takes a reference on the open file description
+func (fd *fileDescriptor) Open() {
+	fd.refs.Inc()
 }
 
-// Close decrements this process's open count. If the open count is 0, then it locks and runs cleanup.
-// If the open count is zero for all processes, then the internal file is closed.
-func (fd *fileDescriptor) Close(pid common.PID, locker sync.Locker, cleanUpFile func()) error {
-	count := fd.openCounts[pid]
-	if count == nil || count.Load() <= 0 {
-		return nil
-	}
-
-	if count.Dec() > 0 {
+// Close releases a reference on the open file description. Once no descriptors reference it, the internal file is closed.
+func (fd *fileDescriptor) Close() error {
+	if fd.refs.Dec() != 0 {
 		return nil
 	}
-	// if this process's open count is 0, then use 'locker' and 'cleanUpFile' to remove it from the parent
-	locker.Lock()
-	fd.openMu.Lock()
-	cleanedUp, err := fd.unsafeClose(pid)
-	if cleanedUp {
-		cleanUpFile()
-	}
-	fd.openMu.Unlock()
-	locker.Unlock()
-	return err
-}
-
-func (fd *fileDescriptor) unsafeClose(pid common.PID) (cleanUpFile bool, err error) {
-	count, ok := fd.openCounts[pid]
-	if !ok {
-		return
-	}
-	if count.Load() == 0 {
-		delete(fd.openCounts, pid)
-		cleanUpFile = true
-	}
-
-	if len(fd.openCounts) == 0 {
-		// if this fd is closed everywhere, then close the file
-		err = fd.file.Close()
-	}
-	return
-}
-
-func openCountToString(openCounts map[common.PID]*atomic.Uint64) string {
-	var s strings.Builder
-	s.WriteString("{")
-	for pid, count := range openCounts {
-		s.WriteString(fmt.Sprintf(" %d:%d", pid, count.Load()))
-	}
-	s.WriteString(" }")
-	return s.String()
+	return fd.file.Close()
 }
 
-func (fd *fileDescriptor) closeAll(pid common.PID) error {
-	fd.openMu.Lock()
-	defer fd.openMu.Unlock()
-
-	count := fd.openCounts[pid]
-	if count == nil {
-		return nil
-	}
-	var firstErr error
-	for count.Load() > 0 {
-		count.Dec()
-		_, err := fd.unsafeClose(pid)
-		if firstErr == nil && err != nil {
-			log.Errorf("Failed to close file for PID %d %q: %s", pid, fd.FileName(), err.Error())
-			firstErr = err
-		}
+// release drops a reference taken by FileDescriptors.acquire
+func (fd *fileDescriptor) release() {
+	if err := fd.Close(); err != nil {
+		log.Errorf("Failed to close file %q: %s", fd.FileName(), err.Error())
 	}
-	return firstErr
 }
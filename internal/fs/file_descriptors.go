@@ -7,32 +7,33 @@ import (
 	"sort"
 	"strings"
 	"sync"
-	goAtomic "sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/hack-pad/hackpad/internal/common"
 	"github.com/hack-pad/hackpad/internal/interop"
+	"github.com/hack-pad/hackpad/internal/log"
 	"github.com/hack-pad/hackpadfs"
 	"github.com/pkg/errors"
 )
 
 var (
-	ErrNotDir = interop.NewError("not a directory", "ENOTDIR")
+	ErrNotDir       = interop.NewError("not a directory", "ENOTDIR")
+	ErrTooManyFiles = interop.NewError("too many open files", "EMFILE")
 )
 
+// FileDescriptors is a process's descriptor table. It is safe for concurrent use.
 type FileDescriptors struct {
 	parentPID        common.PID
-	previousFID      FID
+	mu               sync.RWMutex // guards files and maxFiles
 	files            map[FID]*fileDescriptor
-	mu               sync.Mutex
+	maxFiles         int
 	workingDirectory *workingDirectory
 }
 
 func NewStdFileDescriptors(parentPID common.PID, workingDirectory string) (*FileDescriptors, error) {
 	f := &FileDescriptors{
 		parentPID:        parentPID,
-		previousFID:      0,
 		files:            make(map[FID]*fileDescriptor),
 		workingDirectory: newWorkingDirectory(workingDirectory),
 	}
@@ -52,16 +53,19 @@ func NewStdFileDescriptors(parentPID common.PID, workingDirectory string) (*File
 func NewFileDescriptors(parentPID common.PID, workingDirectory string, parentFiles *FileDescriptors, inheritFDs []Attr) (*FileDescriptors, func(wd string) error, error) {
 	f := &FileDescriptors{
 		parentPID:        parentPID,
-		previousFID:      0,
 		files:            make(map[FID]*fileDescriptor),
 		workingDirectory: newWorkingDirectory(workingDirectory),
 	}
 	if len(inheritFDs) == 0 {
-		inheritFDs = []Attr{{FID: 0}, {FID: 1}, {FID: 2}}
+		f.inheritAll(parentFiles)
+		f.claimTerminal()
+		return f, f.setWorkingDirectory, nil
 	}
 	if len(inheritFDs) < 3 {
 		return nil, nil, errors.Errorf("Invalid number of inherited file descriptors, must be 0 or at least 3: %#v", inheritFDs)
 	}
+	parentFiles.mu.RLock()
+	defer parentFiles.mu.RUnlock()
 	for _, attr := range inheritFDs {
 		var inheritFD FID
 		switch {
@@ -76,11 +80,12 @@ func NewFileDescriptors(parentPID common.PID, workingDirectory string, parentFil
 		if parentFD == nil {
 			return nil, nil, errors.Errorf("Invalid parent FID %d", attr.FID)
 		}
-		fid := f.newFID()
+		fid := f.newFID(0)
 		fd := parentFD.Dup(fid)
 		f.addFileDescriptor(fd)
-		fd.Open(parentPID)
+		fd.Open()
 	}
+	f.claimTerminal()
 	return f, f.setWorkingDirectory, nil
 }
 
@@ -101,31 +106,88 @@ func (f *FileDescriptors) resolvePath(path string) string {
 	return common.ResolvePath(f.WorkingDirectory(), path)
 }
 
-func (f *FileDescriptors) newFID() FID {
-	nextFID := goAtomic.AddUint64((*uint64)(&f.previousFID), 1)
-	return FID(nextFID - 1)
+// SetMaxFiles limits the number of open descriptors. Zero is unlimited.
+// Descriptors already open are unaffected, but no new ones may open until enough are closed.
+func (f *FileDescriptors) SetMaxFiles(maxFiles int) {
+	f.mu.Lock()
+	f.maxFiles = maxFiles
+	f.mu.Unlock()
+}
+
+// checkMaxFiles returns an error if opening 'count' more files would exceed the limit. Must be called with f.mu held.
+func (f *FileDescriptors) checkMaxFiles(count int) error {
+	if f.maxFiles > 0 && len(f.files)+count > f.maxFiles {
+		return ErrTooManyFiles
+	}
+	return nil
+}
+
+// inheritAll duplicates every parent descriptor not marked close-on-exec, keeping the same FIDs
+func (f *FileDescriptors) inheritAll(parentFiles *FileDescriptors) {
+	parentFiles.mu.RLock()
+	defer parentFiles.mu.RUnlock()
+	for fid, parentFD := range parentFiles.files {
+		if parentFD.closeOnExec {
+			continue
+		}
+		fd := parentFD.Dup(fid)
+		f.addFileDescriptor(fd)
+		fd.Open()
+	}
+}
+
+// newFID returns the lowest unused FID of at least 'minFID'. Must be called with f.mu held.
+func (f *FileDescriptors) newFID(minFID FID) FID {
+	fid := minFID
+	for f.files[fid] != nil {
+		fid++
+	}
+	return fid
 }
 
 func (f *FileDescriptors) Open(path string, flags int, mode os.FileMode) (fd FID, err error) {
 	path = f.resolvePath(path)
 
-	f.mu.Lock()
-	defer f.mu.Unlock()
-	descriptor, err := NewFileDescriptor(f.newFID(), path, flags, mode)
+	// open outside the lock, so a slow file system doesn't block every other descriptor operation
+	descriptor, err := NewFileDescriptor(0, path, flags, mode)
 	if err != nil {
 		return 0, err
 	}
+	if flags&os.O_CREATE != 0 {
+		notifyChange(path)
+	}
+
+	f.mu.Lock()
+	if err := f.checkMaxFiles(1); err != nil {
+		f.mu.Unlock()
+		if closeErr := descriptor.file.Close(); closeErr != nil {
+			log.Errorf("Failed to close file %q: %s", descriptor.FileName(), closeErr.Error())
+		}
+		return 0, err
+	}
+	descriptor.id = f.newFID(0)
 	f.addFileDescriptor(descriptor)
-	descriptor.Open(f.parentPID)
+	descriptor.Open()
+	f.mu.Unlock()
 	return descriptor.id, nil
 }
 
+// addFileDescriptor adds 'descriptor' to the table. Must be called with f.mu held.
 func (f *FileDescriptors) addFileDescriptor(descriptor *fileDescriptor) {
 	f.files[descriptor.id] = descriptor
 }
 
-func (f *FileDescriptors) removeFileDescriptor(descriptor *fileDescriptor) {
-	delete(f.files, descriptor.id) // TODO is it safe to leave the old FD's hanging around? they're useful for debugging
+// acquire returns the descriptor for 'fd' with a reference held, so a concurrent Close can't close the file mid-operation.
+// Call release when done.
+func (f *FileDescriptors) acquire(fd FID) (*fileDescriptor, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	fileDescriptor := f.files[fd]
+	if fileDescriptor == nil {
+		return nil, interop.BadFileNumber(fd)
+	}
+	fileDescriptor.Open()
+	return fileDescriptor, nil
 }
 
 func getFile(absPath string, flags int, mode os.FileMode) (hackpadfs.File, error) {
@@ -133,38 +195,81 @@ func getFile(absPath string, flags int, mode os.FileMode) (hackpadfs.File, error
 	case "dev/null":
 		return newNullFile("dev/null"), nil
 	case "dev/stdin":
-		return newNullFile("dev/stdin"), nil // TODO can this be mocked?
+		return stdin.open(), nil
 	case "dev/stdout":
-		return stdout, nil
+		return stdout.open(), nil
 	case "dev/stderr":
-		return stderr, nil
+		return stderr.open(), nil
+	}
+	if flags&os.O_CREATE != 0 && flags&os.O_EXCL != 0 {
+		// hackpadfs's file systems ignore O_EXCL
+		if _, err := hackpadfs.Stat(filesystem, absPath); err == nil {
+			return nil, &hackpadfs.PathError{Op: "open", Path: absPath, Err: hackpadfs.ErrExist}
+		}
 	}
 	return hackpadfs.OpenFile(filesystem, absPath, flags, mode)
 }
 
 func (f *FileDescriptors) Close(fd FID) error {
+	f.mu.Lock()
 	fileDescriptor := f.files[fd]
+	delete(f.files, fd)
+	f.mu.Unlock()
 	if fileDescriptor == nil {
 		return interop.BadFileNumber(fd)
 	}
-	return fileDescriptor.Close(f.parentPID, &f.mu, func() {
-		f.removeFileDescriptor(fileDescriptor)
-	})
+	return fileDescriptor.Close()
 }
 
 func (f *FileDescriptors) CloseAll() {
+	f.releaseTerminal()
 	f.mu.Lock()
-	for _, fd := range f.files {
-		_ = fd.closeAll(f.parentPID)
-	}
+	files := f.files
+	f.files = make(map[FID]*fileDescriptor)
 	f.mu.Unlock()
+	for _, fd := range files {
+		if err := fd.Close(); err != nil {
+			log.Errorf("Failed to close file for PID %d %q: %s", f.parentPID, fd.FileName(), err.Error())
+		}
+	}
+	flushOutput(f.parentPID)
 }
 
-func (f *FileDescriptors) Fstat(fd FID) (os.FileInfo, error) {
+// SetCloseOnExec marks 'fd' to be closed when its process replaces its image
+func (f *FileDescriptors) SetCloseOnExec(fd FID, closeOnExec bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	fileDescriptor := f.files[fd]
 	if fileDescriptor == nil {
-		return nil, interop.BadFileNumber(fd)
+		return interop.BadFileNumber(fd)
+	}
+	fileDescriptor.closeOnExec = closeOnExec
+	return nil
+}
+
+// CloseOnExec closes all descriptors marked close-on-exec
+func (f *FileDescriptors) CloseOnExec() {
+	var closeFIDs []FID
+	f.mu.Lock()
+	for fid, fd := range f.files {
+		if fd.closeOnExec {
+			closeFIDs = append(closeFIDs, fid)
+		}
+	}
+	f.mu.Unlock()
+	for _, fid := range closeFIDs {
+		if err := f.Close(fid); err != nil {
+			log.Errorf("Failed to close descriptor %d on exec: %s", fid, err.Error())
+		}
+	}
+}
+
+func (f *FileDescriptors) Fstat(fd FID) (os.FileInfo, error) {
+	fileDescriptor, err := f.acquire(fd)
+	if err != nil {
+		return nil, err
 	}
+	defer fileDescriptor.release()
 	return fileDescriptor.file.Stat()
 }
 
@@ -181,11 +286,12 @@ func (f *FileDescriptors) RemoveDir(path string) error {
 	if !info.IsDir() {
 		return ErrNotDir
 	}
-	return hackpadfs.Remove(filesystem, path)
+	return notifyChangeIfOK(path, hackpadfs.Remove(filesystem, path))
 }
 
 func (f *FileDescriptors) Chmod(path string, mode os.FileMode) error {
-	return hackpadfs.Chmod(filesystem, f.resolvePath(path), mode)
+	path = f.resolvePath(path)
+	return notifyChangeIfOK(path, hackpadfs.Chmod(filesystem, path, mode))
 }
 
 func (f *FileDescriptors) Stat(path string) (os.FileInfo, error) {
@@ -197,11 +303,13 @@ func (f *FileDescriptors) Lstat(path string) (os.FileInfo, error) {
 }
 
 func (f *FileDescriptors) Mkdir(path string, mode os.FileMode) error {
-	return hackpadfs.Mkdir(filesystem, f.resolvePath(path), mode)
+	path = f.resolvePath(path)
+	return notifyChangeIfOK(path, hackpadfs.Mkdir(filesystem, path, mode))
 }
 
 func (f *FileDescriptors) MkdirAll(path string, mode os.FileMode) error {
-	return hackpadfs.MkdirAll(filesystem, f.resolvePath(path), mode)
+	path = f.resolvePath(path)
+	return notifyChangeIfOK(path, hackpadfs.MkdirAll(filesystem, path, mode))
 }
 
 func (f *FileDescriptors) Unlink(path string) error {
@@ -213,7 +321,7 @@ func (f *FileDescriptors) Unlink(path string) error {
 	if info.IsDir() {
 		return os.ErrPermission
 	}
-	return hackpadfs.Remove(filesystem, path)
+	return notifyChangeIfOK(path, hackpadfs.Remove(filesystem, path))
 }
 
 func (f *FileDescriptors) Utimes(path string, atime, mtime time.Time) error {
@@ -221,34 +329,65 @@ func (f *FileDescriptors) Utimes(path string, atime, mtime time.Time) error {
 }
 
 func (f *FileDescriptors) String() string {
-	var s strings.Builder
-	var fids []FID
-	for fid := range f.files {
-		fids = append(fids, fid)
+	f.mu.RLock()
+	descriptors := make([]*fileDescriptor, 0, len(f.files))
+	for _, fd := range f.files {
+		descriptors = append(descriptors, fd)
 	}
-	sort.SliceStable(fids, func(a, b int) bool {
-		return fids[a] < fids[b]
+	f.mu.RUnlock()
+	sort.Slice(descriptors, func(a, b int) bool {
+		return descriptors[a].id < descriptors[b].id
 	})
-	for _, fid := range fids {
-		s.WriteString(f.files[fid].String() + "\n")
+	var s strings.Builder
+	for _, fd := range descriptors {
+		s.WriteString(fd.String() + "\n")
 	}
 	return s.String()
 }
 
+// OpenFile describes an entry in a descriptor table
+type OpenFile struct {
+	FID         FID    `json:"fd"`
+	Name        string `json:"name"`
+	CloseOnExec bool   `json:"closeOnExec"`
+	Refs        int64  `json:"refs"` // descriptors sharing the open file description, across all processes
+}
+
+// OpenFiles lists the open descriptors, sorted by FID
+func (f *FileDescriptors) OpenFiles() []OpenFile {
+	f.mu.RLock()
+	files := make([]OpenFile, 0, len(f.files))
+	for _, fd := range f.files {
+		files = append(files, OpenFile{
+			FID:         fd.id,
+			Name:        fd.FileName(),
+			CloseOnExec: fd.closeOnExec,
+			Refs:        fd.refs.Load(),
+		})
+	}
+	f.mu.RUnlock()
+	sort.Slice(files, func(a, b int) bool {
+		return files[a].FID < files[b].FID
+	})
+	return files
+}
+
 func (f *FileDescriptors) Truncate(fd FID, length int64) error {
-	fileDescriptor := f.files[fd]
-	if fileDescriptor == nil {
-		return interop.BadFileNumber(fd)
+	fileDescriptor, err := f.acquire(fd)
+	if err != nil {
+		return err
 	}
+	defer fileDescriptor.release()
 	return hackpadfs.TruncateFile(fileDescriptor.file, length)
 }
 
 func (f *FileDescriptors) Fsync(fd FID) error {
-	fileDescriptor := f.files[fd]
-	if fileDescriptor == nil {
-		return interop.BadFileNumber(fd)
+	fileDescriptor, err := f.acquire(fd)
+	if err != nil {
+		return err
 	}
-	err := hackpadfs.SyncFile(fileDescriptor.file)
+	defer fileDescriptor.release()
+	err = hackpadfs.SyncFile(fileDescriptor.file)
 	if errors.Is(err, hackpadfs.ErrNotImplemented) {
 		err = nil // not all FS implement Sync(), so fall back to a no-op
 	}
@@ -258,15 +397,22 @@ func (f *FileDescriptors) Fsync(fd FID) error {
 func (f *FileDescriptors) Rename(oldPath, newPath string) error {
 	oldPath = f.resolvePath(oldPath)
 	newPath = f.resolvePath(newPath)
-	return hackpadfs.Rename(filesystem, oldPath, newPath)
+	err := hackpadfs.Rename(filesystem, oldPath, newPath)
+	if err == nil {
+		notifyChange(oldPath)
+		notifyChange(newPath)
+	}
+	return err
 }
 
 func (f *FileDescriptors) Fchmod(fd FID, mode os.FileMode) error {
-	fileDescriptor := f.files[fd]
-	if fileDescriptor == nil {
-		return interop.BadFileNumber(fd)
+	fileDescriptor, err := f.acquire(fd)
+	if err != nil {
+		return err
 	}
-	return hackpadfs.Chmod(filesystem, f.resolvePath(fileDescriptor.FileName()), mode)
+	defer fileDescriptor.release()
+	path := f.resolvePath(fileDescriptor.FileName())
+	return notifyChangeIfOK(path, hackpadfs.Chmod(filesystem, path, mode))
 }
 
 type LockAction int
@@ -278,24 +424,28 @@ const (
 )
 
 var (
-	processFileLocks = make(map[string]*sync.RWMutex)
-	newFileLockMu    sync.Mutex
+	processFileLocks   = make(map[string]*sync.RWMutex)
+	processFileLocksMu sync.Mutex
 )
 
-func (f *FileDescriptors) Flock(fd FID, action LockAction) error {
-	fileDescriptor := f.files[fd]
-	if fileDescriptor == nil {
-		return interop.BadFileNumber(fd)
+func fileLock(absPath string) *sync.RWMutex {
+	processFileLocksMu.Lock()
+	defer processFileLocksMu.Unlock()
+	lock, ok := processFileLocks[absPath]
+	if !ok {
+		lock = new(sync.RWMutex)
+		processFileLocks[absPath] = lock
 	}
-	absPath := fileDescriptor.FileName()
-	if _, ok := processFileLocks[absPath]; !ok {
-		newFileLockMu.Lock()
-		if _, ok := processFileLocks[absPath]; !ok {
-			processFileLocks[absPath] = new(sync.RWMutex)
-		}
-		newFileLockMu.Unlock()
+	return lock
+}
+
+func (f *FileDescriptors) Flock(fd FID, action LockAction) error {
+	fileDescriptor, err := f.acquire(fd)
+	if err != nil {
+		return err
 	}
-	lock := processFileLocks[absPath]
+	defer fileDescriptor.release()
+	lock := fileLock(fileDescriptor.FileName())
 	switch action {
 	case LockShared, LockExclusive:
 		// TODO support shared locks
@@ -308,14 +458,30 @@ func (f *FileDescriptors) Flock(fd FID, action LockAction) error {
 	return nil
 }
 
-func (f *FileDescriptors) RawFID(fid FID) (io.Reader, error) {
-	if _, ok := f.files[fid]; !ok {
-		return nil, interop.BadFileNumber(fid)
+// RawFID returns a reader for the file behind 'fid'. The file stays open until the reader is closed, even if 'fid' is closed first.
+func (f *FileDescriptors) RawFID(fid FID) (io.ReadCloser, error) {
+	fileDescriptor, err := f.acquire(fid)
+	if err != nil {
+		return nil, err
 	}
-	return f.files[fid].file, nil
+	return &rawFile{Reader: fileDescriptor.file, descriptor: fileDescriptor}, nil
+}
+
+// rawFile holds a reference on its descriptor's file until closed
+type rawFile struct {
+	io.Reader
+	descriptor *fileDescriptor
+	closeOnce  sync.Once
+}
+
+func (r *rawFile) Close() error {
+	r.closeOnce.Do(r.descriptor.release)
+	return nil
 }
 
 func (f *FileDescriptors) RawFIDs() []io.Reader {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 	results := make([]io.Reader, 0, len(f.files))
 	for _, f := range f.files {
 		results = append(results, f.file)
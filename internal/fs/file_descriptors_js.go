@@ -9,6 +9,7 @@ import (
 
 type wasmInstancer interface {
 	WasmInstance(path string, importObject js.Value) (js.Value, error)
+	WasmModule(path string) (js.Value, error)
 }
 
 func (f *FileDescriptors) WasmInstance(path string, importObject js.Value) (js.Value, error) {
@@ -17,3 +18,11 @@ func (f *FileDescriptors) WasmInstance(path string, importObject js.Value) (js.V
 	}
 	panic("Wasm Cache not initialized")
 }
+
+// WasmModule returns the compiled WebAssembly.Module at 'path' if cached, otherwise the module's bytes
+func (f *FileDescriptors) WasmModule(path string) (js.Value, error) {
+	if instancer, ok := filesystem.(wasmInstancer); ok {
+		return instancer.WasmModule(f.resolvePath(path))
+	}
+	panic("Wasm Cache not initialized")
+}
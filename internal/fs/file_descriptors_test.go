@@ -0,0 +1,356 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/hack-pad/hackpad/internal/interop"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+func newTestFileDescriptors(t *testing.T) *FileDescriptors {
+	t.Helper()
+	dir := "/" + t.Name()
+	f, err := NewStdFileDescriptors(1, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.setWorkingDirectory(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(f.CloseAll)
+	return f
+}
+
+func openFileCount(f *FileDescriptors) int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return len(f.files)
+}
+
+func TestFileDescriptorsConcurrentUse(t *testing.T) {
+	f := newTestFileDescriptors(t)
+	const (
+		workers    = 16
+		iterations = 50
+	)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*3)
+	for worker := 0; worker < workers; worker++ {
+		worker := worker
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			name := fmt.Sprintf("file-%d", worker)
+			for i := 0; i < iterations; i++ {
+				if err := useFile(f, name); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				fids, err := f.Pipe()
+				if err != nil {
+					errs <- err
+					return
+				}
+				if _, err := f.Write(fids[1], blob.NewBytes([]byte("hi")), 0, 2, nil); err != nil {
+					errs <- err
+					return
+				}
+				if err := f.SetCloseOnExec(fids[0], true); err != nil {
+					errs <- err
+					return
+				}
+				if err := f.Close(fids[1]); err != nil {
+					errs <- err
+					return
+				}
+				if err := f.Close(fids[0]); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				_ = f.String()
+				_ = f.RawFIDs()
+				f.SetMaxFiles(0)
+				if _, err := f.Fstat(1); err != nil && !errors.Is(err, interop.ErrNotImplemented) {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if count := openFileCount(f); count != 3 {
+		t.Errorf("Expected only stdio to remain open, found %d descriptors:\n%s", count, f)
+	}
+}
+
+func useFile(f *FileDescriptors, name string) error {
+	fid, err := f.Open(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	data := []byte("hello world")
+	if _, err := f.Write(fid, blob.NewBytes(data), 0, len(data), nil); err != nil {
+		return err
+	}
+	if err := f.Flock(fid, LockExclusive); err != nil {
+		return err
+	}
+	if err := f.Flock(fid, Unlock); err != nil {
+		return err
+	}
+	position := int64(0)
+	buf := blob.NewBytesLength(len(data))
+	n, err := f.Read(fid, buf, 0, len(data), &position)
+	if err != nil {
+		return err
+	}
+	if got := string(buf.Bytes()[:n]); got != string(data) {
+		return fmt.Errorf("Read %q, expected %q", got, data)
+	}
+	if _, err := f.Fstat(fid); err != nil {
+		return err
+	}
+	if err := f.Fsync(fid); err != nil {
+		return err
+	}
+	if err := f.Truncate(fid, 0); err != nil {
+		return err
+	}
+	return f.Close(fid)
+}
+
+func TestFileDescriptorsConcurrentClose(t *testing.T) {
+	f := newTestFileDescriptors(t)
+	fid, err := f.Open("file", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const closers = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, closers)
+	for i := 0; i < closers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- f.Close(fid)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	closed := 0
+	for err := range errs {
+		if err == nil {
+			closed++
+		}
+	}
+	if closed != 1 {
+		t.Errorf("Expected exactly 1 successful close, got %d", closed)
+	}
+}
+
+func TestFileDescriptorsSharedOffset(t *testing.T) {
+	parent := newTestFileDescriptors(t)
+	fid, err := parent.Open("file", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, _, err := NewFileDescriptors(2, parent.WorkingDirectory(), parent, []Attr{{FID: 0}, {FID: 1}, {FID: 2}, {FID: fid}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer child.CloseAll()
+	const childFID = 3
+
+	const (
+		writes    = 100
+		chunkSize = 10
+	)
+	chunk := make([]byte, chunkSize)
+	var wg sync.WaitGroup
+	for _, write := range []func() error{
+		func() error {
+			_, err := parent.Write(fid, blob.NewBytes(chunk), 0, chunkSize, nil)
+			return err
+		},
+		func() error {
+			_, err := child.Write(childFID, blob.NewBytes(chunk), 0, chunkSize, nil)
+			return err
+		},
+	} {
+		write := write
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < writes; i++ {
+				if err := write(); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// duplicated descriptors share one offset, so no write may overwrite another
+	offset, err := child.Seek(childFID, 0, io.SeekCurrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expectedSize = 2 * writes * chunkSize
+	if offset != expectedSize {
+		t.Errorf("Expected offset %d, got %d", expectedSize, offset)
+	}
+	info, err := parent.Fstat(fid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != expectedSize {
+		t.Errorf("Expected size %d, got %d", expectedSize, info.Size())
+	}
+
+	// the description stays open until every descriptor referencing it is closed
+	if err := parent.Close(fid); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := child.Fstat(childFID); err != nil {
+		t.Errorf("Expected child's descriptor to remain open: %v", err)
+	}
+}
+
+func TestFileDescriptorsMaxFiles(t *testing.T) {
+	f := newTestFileDescriptors(t)
+	const maxFiles = 5 // stdin, stdout, stderr, and 2 more
+	f.SetMaxFiles(maxFiles)
+
+	fid, err := f.Open("file", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Pipe(); interop.ErrorCode(err) != "EMFILE" {
+		t.Errorf("Expected EMFILE opening a pipe past the limit, got %v", err)
+	}
+	if _, err := f.Open("file", os.O_RDONLY, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Open("file", os.O_RDONLY, 0); !errors.Is(err, ErrTooManyFiles) {
+		t.Errorf("Expected ErrTooManyFiles, got %v", err)
+	}
+	if count := openFileCount(f); count != maxFiles {
+		t.Errorf("Expected %d open files, got %d", maxFiles, count)
+	}
+
+	if err := f.Close(fid); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Open("file", os.O_RDONLY, 0); err != nil {
+		t.Errorf("Expected open to succeed after closing a file, got %v", err)
+	}
+
+	f.SetMaxFiles(0)
+	if _, err := f.Pipe(); err != nil {
+		t.Errorf("Expected no limit after SetMaxFiles(0), got %v", err)
+	}
+}
+
+func TestFileDescriptorsOpenExclusive(t *testing.T) {
+	f := newTestFileDescriptors(t)
+	_ = f.Unlink("file") // left over from a previous run
+	fid, err := f.Open("file", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(fid); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Open("file", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600); interop.ErrorCode(err) != "EEXIST" {
+		t.Errorf("Expected EEXIST creating an existing file exclusively, got %v", err)
+	}
+}
+
+func TestFileDescriptorsRawFIDOutlivesClose(t *testing.T) {
+	f := newTestFileDescriptors(t)
+	fid, err := f.Open("file", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const contents = "hello"
+	if _, err := f.Write(fid, blob.NewBytes([]byte(contents)), 0, len(contents), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(fid); err != nil {
+		t.Fatal(err)
+	}
+
+	fid, err = f.Open("file", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := f.RawFID(fid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(fid); err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != contents {
+		t.Errorf("Expected %q, got %q", contents, b)
+	}
+	if err := raw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.RawFID(fid); interop.ErrorCode(err) != "EBADF" {
+		t.Errorf("Expected EBADF for a closed descriptor, got %v", err)
+	}
+}
+
+func TestFileDescriptorsMaxFilesClosesFile(t *testing.T) {
+	f := newTestFileDescriptors(t)
+	f.SetMaxFiles(openFileCount(f))
+	if _, err := f.Open("file", os.O_RDWR|os.O_CREATE, 0600); !errors.Is(err, ErrTooManyFiles) {
+		t.Fatalf("Expected ErrTooManyFiles, got %v", err)
+	}
+	// the file opened before checking the limit is closed, so it can be removed and recreated exclusively
+	if err := f.Unlink("file"); err != nil {
+		t.Fatal(err)
+	}
+	f.SetMaxFiles(0)
+	fid, err := f.Open("file", os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(fid); err != nil {
+		t.Fatal(err)
+	}
+}
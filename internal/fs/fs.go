@@ -1,7 +1,6 @@
 package fs
 
 import (
-	"compress/gzip"
 	"context"
 	"io"
 	"path"
@@ -50,19 +49,20 @@ func DestroyMount(path string) error {
 
 func Overlay(mountPath string, fs hackpadfs.FS) error {
 	mountPath = common.ResolvePath(".", mountPath)
-	return filesystem.AddMount(mountPath, fs)
+	return addMount(mountPath, fs)
 }
 
 type ShouldCacher func(name string, info hackpadfs.FileInfo) bool
 
-func OverlayTarGzip(mountPath string, gzipReader io.ReadCloser, persist bool, shouldCache ShouldCacher) error {
-	r, err := gzip.NewReader(gzipReader)
-	if err != nil {
-		return err
-	}
-
+// OverlayTarGzip mounts a gzip or zstd compressed tar archive at 'mountPath'.
+// If 'loadManifest' is set, files are checked against the manifest's checksums as they're unpacked.
+func OverlayTarGzip(mountPath string, archiveReader io.ReadCloser, persist bool, shouldCache ShouldCacher, loadManifest ManifestLoader) error {
 	mountPath = common.ResolvePath(".", mountPath)
 	if !persist {
+		r, verifier, err := openArchive(archiveReader, loadManifest)
+		if err != nil {
+			return err
+		}
 		underlyingFS, err := mem.NewFS()
 		if err != nil {
 			return err
@@ -73,7 +73,15 @@ func OverlayTarGzip(mountPath string, gzipReader io.ReadCloser, persist bool, sh
 		if err != nil {
 			return err
 		}
-		return filesystem.AddMount(mountPath, fs)
+		if verifier != nil {
+			go func() {
+				<-fs.Done()
+				if err := verifier.Wait(); err != nil {
+					log.Errorf("Failed to verify mount %q: %v", mountPath, err)
+				}
+			}()
+		}
+		return addMount(mountPath, fs)
 	}
 
 	const tarfsDoneMarker = ".tarfs-complete"
@@ -104,13 +112,13 @@ func OverlayTarGzip(mountPath string, gzipReader io.ReadCloser, persist bool, sh
 	if err == nil {
 		// tarfs already completed successfully and is persisted,
 		// so close top-level reader and mount the existing files
-		gzipReader.Close()
+		archiveReader.Close()
 
 		cacheFS, err := newCacheFS(underlyingFS)
 		if err != nil {
 			return err
 		}
-		return filesystem.AddMount(mountPath, cacheFS)
+		return addMount(mountPath, cacheFS)
 	} else {
 		// either never untar'd or did not finish untaring, so start again
 		// should be idempotent, but rewriting buffers from JS is expensive, so just delete everything
@@ -120,6 +128,10 @@ func OverlayTarGzip(mountPath string, gzipReader io.ReadCloser, persist bool, sh
 		}
 	}
 
+	r, verifier, err := openArchive(archiveReader, loadManifest)
+	if err != nil {
+		return err
+	}
 	readCtx, readCancel := context.WithCancel(context.Background())
 	tarFS, err := tar.NewReaderFS(readCtx, r, tar.ReaderFSOptions{
 		UnarchiveFS: underlyingFS,
@@ -136,10 +148,19 @@ func OverlayTarGzip(mountPath string, gzipReader io.ReadCloser, persist bool, sh
 	go func() {
 		<-tarFS.Done()
 		err := tarFS.UnarchiveErr()
+		var verifyErr error
+		if verifier != nil {
+			verifyErr = verifier.Wait()
+		}
 		if err != nil {
 			log.Errorf("Failed to initialize mount %q: %v", mountPath, err)
 			return
 		}
+		if verifyErr != nil {
+			// leave the mount incomplete, so it's unpacked again next time
+			log.Errorf("Failed to verify mount %q: %v", mountPath, verifyErr)
+			return
+		}
 		f, err := hackpadfs.Create(underlyingFS, tarfsDoneMarker)
 		if err != nil {
 			log.Errorf("Failed to mark tarfs overlay %q complete: %v", mountPath, err)
@@ -147,7 +168,17 @@ func OverlayTarGzip(mountPath string, gzipReader io.ReadCloser, persist bool, sh
 		}
 		f.Close()
 	}()
-	return filesystem.AddMount(mountPath, cacheFS)
+	return addMount(mountPath, cacheFS)
+}
+
+// openArchive decompresses 'r', verifying it against the manifest from 'loadManifest' if set
+func openArchive(r io.Reader, loadManifest ManifestLoader) (io.Reader, *manifestVerifier, error) {
+	r, err := decompress(r)
+	if err != nil || loadManifest == nil {
+		return r, nil, err
+	}
+	r, verifier := newManifestVerifier(r, loadManifest)
+	return r, verifier, nil
 }
 
 type clearCtxFS struct {
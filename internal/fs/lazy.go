@@ -0,0 +1,426 @@
+package fs
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	gofs "io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpad/internal/common"
+	"github.com/hack-pad/hackpad/internal/log"
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/mem"
+	"github.com/pkg/errors"
+)
+
+const (
+	// lazyIndexFile is where a persisted lazy mount keeps its last index, so it still mounts offline
+	lazyIndexFile = ".chunks/index.json"
+	// lazyChunkMarkers holds a marker file for each unpacked chunk, named by its checksum
+	lazyChunkMarkers = ".chunks"
+)
+
+// ChunkIndex lists the files in a chunked archive and the chunk holding each directory's files
+type ChunkIndex struct {
+	Codec string `json:"codec"`
+	// Chunks are keyed by directory, like "src/fmt". Files at the root are in chunk ".".
+	Chunks map[string]Chunk `json:"chunks"`
+	Files  []ChunkFile      `json:"files"`
+}
+
+// Chunk is a compressed tar archive of one directory's files
+type Chunk struct {
+	URL    string `json:"url"` // relative to the index
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// ChunkFile describes a file or directory in a ChunkIndex
+type ChunkFile struct {
+	Name string             `json:"name"` // slash-separated path, relative to the root
+	Mode hackpadfs.FileMode `json:"mode"`
+	Size int64              `json:"size"`
+}
+
+// ChunkFetcher downloads the chunk at 'url', relative to the index
+type ChunkFetcher func(url string) ([]byte, error)
+
+// OverlayLazy mounts a chunked archive at 'mountPath'. Only the index from 'fetchIndex' is downloaded up front.
+// Each directory's files are fetched, unpacked, and cached the first time one of them is opened.
+func OverlayLazy(mountPath string, fetchIndex func() ([]byte, error), fetchChunk ChunkFetcher, persist bool, shouldCache ShouldCacher) error {
+	mountPath = common.ResolvePath(".", mountPath)
+	var store, cache hackpadfs.FS
+	var err error
+	if persist {
+		store, err = newPersistDB(mountPath, true, shouldCache)
+		if err != nil {
+			return err
+		}
+		cache, err = mem.NewFS()
+		if err != nil {
+			return err
+		}
+	} else {
+		store, err = mem.NewFS()
+		if err != nil {
+			return err
+		}
+	}
+
+	index, err := loadLazyIndex(mountPath, fetchIndex, store, persist)
+	if err != nil {
+		return err
+	}
+
+	lazyFS, err := newLazyFS(index, fetchChunk, store, cache, func(name string, info hackpadfs.FileInfo) bool {
+		return shouldCache(path.Join(mountPath, name), info)
+	})
+	if err != nil {
+		return err
+	}
+	return addMount(mountPath, lazyFS)
+}
+
+// loadLazyIndex fetches and parses the chunk index. A persisted mount saves the index in 'store', so it still mounts offline with the saved index.
+// When a persisted index changes, files unpacked from chunks it no longer lists are removed.
+func loadLazyIndex(mountPath string, fetchIndex func() ([]byte, error), store hackpadfs.FS, persist bool) (ChunkIndex, error) {
+	var index ChunkIndex
+	indexData, err := fetchIndex()
+	if err == nil {
+		if jsonErr := json.Unmarshal(indexData, &index); jsonErr != nil {
+			err = errors.Wrap(jsonErr, "Invalid chunk index")
+		}
+	}
+	if !persist {
+		return index, err
+	}
+
+	saved, savedErr := readSavedIndex(store)
+	switch {
+	case err == nil:
+		if savedErr == nil {
+			pruneChunks(store, saved, index)
+		}
+		if err := writeFile(store, lazyIndexFile, indexData, 0600); err != nil {
+			log.Warn("Failed to save index for ", mountPath, ": ", err)
+		}
+		return index, nil
+	case savedErr == nil:
+		// serve files unpacked in a previous session
+		log.Warn("Failed to fetch index for ", mountPath, ", using saved index: ", err)
+		return saved, nil
+	default:
+		return ChunkIndex{}, err
+	}
+}
+
+func readSavedIndex(store hackpadfs.FS) (ChunkIndex, error) {
+	var index ChunkIndex
+	indexData, err := hackpadfs.ReadFile(store, lazyIndexFile)
+	if err != nil {
+		return index, err
+	}
+	return index, json.Unmarshal(indexData, &index)
+}
+
+// pruneChunks removes files unpacked from chunks in 'saved' which 'index' replaced or no longer lists
+func pruneChunks(store hackpadfs.FS, saved, index ChunkIndex) {
+	stale := make(map[string]bool) // directories of stale chunks
+	for dir, chunk := range saved.Chunks {
+		if current, ok := index.Chunks[dir]; ok && current.SHA256 == chunk.SHA256 {
+			continue
+		}
+		stale[dir] = true
+		// remove the marker first, so an interrupted prune fetches the chunk again
+		removeStale(store, path.Join(lazyChunkMarkers, chunk.SHA256))
+	}
+	for _, file := range saved.Files {
+		if !file.Mode.IsDir() && stale[path.Dir(file.Name)] {
+			removeStale(store, file.Name)
+		}
+	}
+}
+
+func removeStale(store hackpadfs.FS, name string) {
+	if err := hackpadfs.Remove(store, name); err != nil && !errors.Is(err, hackpadfs.ErrNotExist) {
+		log.Warn("Failed to remove stale file ", name, ": ", err)
+	}
+}
+
+// lazyFS is a read-only FS serving a chunked archive's files from 'store', fetching chunks as they're needed
+type lazyFS struct {
+	files       map[string]ChunkFile
+	children    map[string][]ChunkFile // sorted directory entries, keyed by directory
+	chunks      map[string]Chunk
+	fetch       ChunkFetcher
+	store       hackpadfs.FS
+	cache       hackpadfs.FS // optional in-memory copy of files in 'store'
+	shouldCache func(name string, info hackpadfs.FileInfo) bool
+
+	mu    sync.Mutex
+	loads map[string]*chunkLoad // chunk loads, keyed by directory
+}
+
+type chunkLoad struct {
+	done chan struct{}
+	err  error
+}
+
+// failed returns true if the load finished with an error, so it can be tried again
+func (l *chunkLoad) failed() bool {
+	select {
+	case <-l.done:
+		return l.err != nil
+	default:
+		return false
+	}
+}
+
+func newLazyFS(index ChunkIndex, fetch ChunkFetcher, store, cache hackpadfs.FS, shouldCache func(string, hackpadfs.FileInfo) bool) (*lazyFS, error) {
+	fs := &lazyFS{
+		files: map[string]ChunkFile{
+			".": {Name: ".", Mode: hackpadfs.ModeDir | 0755},
+		},
+		children:    make(map[string][]ChunkFile),
+		chunks:      index.Chunks,
+		fetch:       fetch,
+		store:       store,
+		cache:       cache,
+		shouldCache: shouldCache,
+		loads:       make(map[string]*chunkLoad),
+	}
+	for _, file := range index.Files {
+		if !hackpadfs.ValidPath(file.Name) || file.Name == "." {
+			return nil, errors.Errorf("Invalid file name in chunk index: %q", file.Name)
+		}
+		fs.files[file.Name] = file
+		dir := path.Dir(file.Name)
+		fs.children[dir] = append(fs.children[dir], file)
+	}
+	for _, children := range fs.children {
+		sort.Slice(children, func(a, b int) bool {
+			return children[a].Name < children[b].Name
+		})
+	}
+	return fs, nil
+}
+
+func (fs *lazyFS) Open(name string) (hackpadfs.File, error) {
+	file, err := fs.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if file.Mode.IsDir() {
+		return &lazyDir{fs: fs, info: chunkFileInfo{file}}, nil
+	}
+	store, cache, err := fs.loadChunk(path.Dir(name))
+	if err != nil {
+		return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if cache != nil {
+		if f, err := cache.Open(name); err == nil {
+			return f, nil
+		}
+	}
+	return store.Open(name)
+}
+
+func (fs *lazyFS) Stat(name string) (hackpadfs.FileInfo, error) {
+	file, err := fs.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return chunkFileInfo{file}, nil
+}
+
+func (fs *lazyFS) lookup(op, name string) (ChunkFile, error) {
+	if !hackpadfs.ValidPath(name) {
+		return ChunkFile{}, &hackpadfs.PathError{Op: op, Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	file, ok := fs.files[name]
+	if !ok {
+		return ChunkFile{}, &hackpadfs.PathError{Op: op, Path: name, Err: hackpadfs.ErrNotExist}
+	}
+	return file, nil
+}
+
+// loadChunk unpacks the chunk for directory 'dir' once, then returns the FSs holding its files.
+// Concurrent callers wait for the same load, and failed loads are tried again.
+func (fs *lazyFS) loadChunk(dir string) (store, cache hackpadfs.FS, err error) {
+	fs.mu.Lock()
+	store, cache = fs.store, fs.cache
+	load, ok := fs.loads[dir]
+	if ok && !load.failed() {
+		fs.mu.Unlock()
+		<-load.done
+		return store, cache, load.err
+	}
+	load = &chunkLoad{done: make(chan struct{})}
+	fs.loads[dir] = load
+	fs.mu.Unlock()
+
+	load.err = fs.unpackChunk(dir, store, cache)
+	close(load.done)
+	return store, cache, load.err
+}
+
+func (fs *lazyFS) unpackChunk(dir string, store, cache hackpadfs.FS) error {
+	chunk, ok := fs.chunks[dir]
+	if !ok {
+		return errors.Errorf("Missing chunk for directory %q", dir)
+	}
+	marker := path.Join(lazyChunkMarkers, chunk.SHA256)
+	if _, err := hackpadfs.Stat(store, marker); err == nil {
+		return nil // unpacked in a previous session
+	}
+
+	start := time.Now()
+	data, err := fs.fetch(chunk.URL)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	if actual := hex.EncodeToString(sum[:]); actual != chunk.SHA256 {
+		return errors.Errorf("Checksum mismatch for chunk %q: expected %s, got %s", dir, chunk.SHA256, actual)
+	}
+	r, err := decompress(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	archive := tar.NewReader(r)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name := strings.TrimPrefix(path.Clean(header.Name), "/")
+		if header.Typeflag != tar.TypeReg || path.Dir(name) != dir {
+			continue
+		}
+		contents, err := io.ReadAll(archive)
+		if err != nil {
+			return err
+		}
+		mode := hackpadfs.FileMode(header.Mode).Perm()
+		if err := writeFile(store, name, contents, mode); err != nil {
+			return err
+		}
+		if cache != nil && fs.shouldCache(name, header.FileInfo()) {
+			if err := writeFile(cache, name, contents, mode); err != nil {
+				return err
+			}
+		}
+	}
+	if err := writeFile(store, marker, nil, 0600); err != nil {
+		return err
+	}
+	log.Debug("Loaded chunk ", dir, " in ", time.Since(start))
+	return nil
+}
+
+// Clear removes all unpacked files, so chunks are fetched again
+func (fs *lazyFS) Clear(ctx context.Context) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.loads = make(map[string]*chunkLoad)
+	if fs.cache != nil {
+		cache, err := mem.NewFS()
+		if err != nil {
+			return err
+		}
+		fs.cache = cache
+	}
+	if store, ok := fs.store.(clearFS); ok {
+		return store.Clear(ctx)
+	}
+	store, err := mem.NewFS()
+	if err != nil {
+		return err
+	}
+	fs.store = store
+	return nil
+}
+
+func writeFile(fs hackpadfs.FS, name string, contents []byte, mode hackpadfs.FileMode) error {
+	if err := hackpadfs.MkdirAll(fs, path.Dir(name), 0755); err != nil {
+		return err
+	}
+	return hackpadfs.WriteFullFile(fs, name, contents, mode)
+}
+
+// lazyDir is a directory listed from the chunk index, without fetching any chunks
+type lazyDir struct {
+	fs     *lazyFS
+	info   chunkFileInfo
+	offset int
+}
+
+func (d *lazyDir) Stat() (hackpadfs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *lazyDir) Read([]byte) (int, error) {
+	return 0, &hackpadfs.PathError{Op: "read", Path: d.info.file.Name, Err: hackpadfs.ErrIsDir}
+}
+
+func (d *lazyDir) Close() error {
+	return nil
+}
+
+func (d *lazyDir) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
+	children := d.fs.children[d.info.file.Name][d.offset:]
+	if n > 0 {
+		if len(children) == 0 {
+			return nil, io.EOF
+		}
+		if len(children) > n {
+			children = children[:n]
+		}
+	}
+	d.offset += len(children)
+	entries := make([]hackpadfs.DirEntry, len(children))
+	for i, child := range children {
+		entries[i] = gofs.FileInfoToDirEntry(chunkFileInfo{child})
+	}
+	return entries, nil
+}
+
+type chunkFileInfo struct {
+	file ChunkFile
+}
+
+func (c chunkFileInfo) Name() string {
+	return path.Base(c.file.Name)
+}
+
+func (c chunkFileInfo) Size() int64 {
+	return c.file.Size
+}
+
+func (c chunkFileInfo) Mode() hackpadfs.FileMode {
+	return c.file.Mode
+}
+
+func (c chunkFileInfo) ModTime() time.Time {
+	return time.Unix(0, 0)
+}
+
+func (c chunkFileInfo) IsDir() bool {
+	return c.file.Mode.IsDir()
+}
+
+func (c chunkFileInfo) Sys() interface{} {
+	return nil
+}
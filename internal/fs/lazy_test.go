@@ -0,0 +1,238 @@
+package fs
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+// testChunks builds a chunk index for 'files', keyed by name, and its chunks keyed by URL
+func testChunks(t *testing.T, files map[string]string) (ChunkIndex, map[string][]byte) {
+	t.Helper()
+	index := ChunkIndex{Codec: "gzip", Chunks: make(map[string]Chunk)}
+	dirs := make(map[string]map[string]string)
+	for name, contents := range files {
+		dir := path.Dir(name)
+		if dirs[dir] == nil {
+			dirs[dir] = make(map[string]string)
+		}
+		dirs[dir][name] = contents
+		index.Files = append(index.Files, ChunkFile{Name: name, Mode: 0644, Size: int64(len(contents))})
+	}
+
+	listed := make(map[string]bool)
+	for dir := range dirs {
+		for ; dir != "." && !listed[dir]; dir = path.Dir(dir) {
+			listed[dir] = true
+			index.Files = append(index.Files, ChunkFile{Name: dir, Mode: hackpadfs.ModeDir | 0755})
+		}
+	}
+
+	chunks := make(map[string][]byte)
+	for dir, dirFiles := range dirs {
+		var buf bytes.Buffer
+		compressor := gzip.NewWriter(&buf)
+		archive := tar.NewWriter(compressor)
+		for name, contents := range dirFiles {
+			err := archive.WriteHeader(&tar.Header{Name: "/" + name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(contents))})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := archive.Write([]byte(contents)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := archive.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := compressor.Close(); err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256(buf.Bytes())
+		url := hex.EncodeToString(sum[:]) + ".tar.gz"
+		chunks[url] = buf.Bytes()
+		index.Chunks[dir] = Chunk{URL: url, SHA256: hex.EncodeToString(sum[:]), Size: int64(buf.Len())}
+	}
+	return index, chunks
+}
+
+func newTestLazyFS(t *testing.T, index ChunkIndex, chunks map[string][]byte, store hackpadfs.FS) (*lazyFS, map[string]int) {
+	t.Helper()
+	fetches := make(map[string]int)
+	fetch := func(url string) ([]byte, error) {
+		fetches[url]++
+		data, ok := chunks[url]
+		if !ok {
+			return nil, hackpadfs.ErrNotExist
+		}
+		return data, nil
+	}
+	cache, err := mem.NewFS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs, err := newLazyFS(index, fetch, store, cache, func(string, hackpadfs.FileInfo) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fs, fetches
+}
+
+func TestLazyFS(t *testing.T) {
+	index, chunks := testChunks(t, map[string]string{
+		"VERSION":                "go1.20",
+		"src/fmt/print.go":       "package fmt",
+		"src/fmt/scan.go":        "package fmt // scan",
+		"src/strings/strings.go": "package strings",
+	})
+	store, err := mem.NewFS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs, fetches := newTestLazyFS(t, index, chunks, store)
+
+	info, err := hackpadfs.Stat(fs, "src/fmt/scan.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != int64(len("package fmt // scan")) {
+		t.Errorf("Expected size from index, got %d", info.Size())
+	}
+	entries, err := hackpadfs.ReadDir(fs, "src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Name() != "fmt" || !entries[0].IsDir() || entries[1].Name() != "strings" {
+		t.Errorf("Expected directories fmt and strings, got %v", entries)
+	}
+	if len(fetches) != 0 {
+		t.Errorf("Expected no chunks fetched for stat and readdir, got %v", fetches)
+	}
+
+	for _, name := range []string{"src/fmt/print.go", "src/fmt/scan.go", "src/fmt/print.go"} {
+		if _, err := hackpadfs.ReadFile(fs, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	contents, err := hackpadfs.ReadFile(fs, "src/fmt/scan.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != "package fmt // scan" {
+		t.Errorf("Expected file contents, got %q", string(contents))
+	}
+	fmtChunk := index.Chunks["src/fmt"].URL
+	if len(fetches) != 1 || fetches[fmtChunk] != 1 {
+		t.Errorf("Expected only the fmt chunk fetched once, got %v", fetches)
+	}
+	if _, err := fs.Open("src/fmt/missing.go"); !errors.Is(err, hackpadfs.ErrNotExist) {
+		t.Errorf("Expected not exist error, got: %v", err)
+	}
+
+	// a new mount over the same store reuses unpacked chunks
+	fs, fetches = newTestLazyFS(t, index, chunks, store)
+	if _, err := hackpadfs.ReadFile(fs, "src/fmt/print.go"); err != nil {
+		t.Fatal(err)
+	}
+	if len(fetches) != 0 {
+		t.Errorf("Expected no chunks fetched after unpacking in a previous mount, got %v", fetches)
+	}
+}
+
+func TestLazyFSChecksumMismatch(t *testing.T) {
+	index, chunks := testChunks(t, map[string]string{"VERSION": "go1.20"})
+	rootChunk := index.Chunks["."].URL
+	good := chunks[rootChunk]
+	chunks[rootChunk] = []byte("corrupt")
+	store, err := mem.NewFS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs, fetches := newTestLazyFS(t, index, chunks, store)
+	if _, err := fs.Open("VERSION"); err == nil {
+		t.Fatal("Expected checksum error, got nil")
+	}
+
+	chunks[rootChunk] = good
+	f, err := fs.Open("VERSION")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	contents, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != "go1.20" || fetches[rootChunk] != 2 {
+		t.Errorf("Expected a retry to load the chunk, got %q after %d fetches", string(contents), fetches[rootChunk])
+	}
+}
+
+func TestLazyIndexPersisted(t *testing.T) {
+	store, err := mem.NewFS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetchJSON := func(index ChunkIndex) func() ([]byte, error) {
+		return func() ([]byte, error) {
+			return json.Marshal(index)
+		}
+	}
+
+	oldIndex, oldChunks := testChunks(t, map[string]string{
+		"src/fmt/print.go":       "package fmt",
+		"src/fmt/old.go":         "package fmt // old",
+		"src/strings/strings.go": "package strings",
+	})
+	index, err := loadLazyIndex("/go", fetchJSON(oldIndex), store, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs, _ := newTestLazyFS(t, index, oldChunks, store)
+	for _, name := range []string{"src/fmt/old.go", "src/strings/strings.go"} {
+		if _, err := hackpadfs.ReadFile(fs, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the fmt chunk changed, so its old files are removed
+	newIndex, _ := testChunks(t, map[string]string{
+		"src/fmt/print.go":       "package fmt // new",
+		"src/strings/strings.go": "package strings",
+	})
+	if _, err := loadLazyIndex("/go", fetchJSON(newIndex), store, true); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"src/fmt/old.go", path.Join(lazyChunkMarkers, oldIndex.Chunks["src/fmt"].SHA256)} {
+		if _, err := hackpadfs.Stat(store, name); !errors.Is(err, hackpadfs.ErrNotExist) {
+			t.Errorf("Expected %s to be removed, got: %v", name, err)
+		}
+	}
+	if _, err := hackpadfs.Stat(store, "src/strings/strings.go"); err != nil {
+		t.Errorf("Expected unchanged chunk's files to remain, got: %v", err)
+	}
+
+	// an invalid index isn't saved, so the saved index is used instead
+	invalid := func() ([]byte, error) { return []byte("<html>not found</html>"), nil }
+	index, err = loadLazyIndex("/go", invalid, store, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(index, newIndex) {
+		t.Errorf("Expected saved index %v, got %v", newIndex, index)
+	}
+	if _, err := loadLazyIndex("/go", invalid, store, false); err == nil {
+		t.Error("Expected error for an invalid index without a saved one")
+	}
+}
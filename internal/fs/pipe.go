@@ -3,22 +3,32 @@ package fs
 import (
 	"io"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/hack-pad/hackpad/internal/interop"
 )
 
-func (f *FileDescriptors) Pipe() [2]FID {
-	r, w := newPipe(f.newFID)
+// ErrBrokenPipe is returned writing to a pipe once every read end is closed
+var ErrBrokenPipe = interop.NewError("broken pipe", "EPIPE")
+
+func (f *FileDescriptors) Pipe() ([2]FID, error) {
+	f.mu.Lock()
+	if err := f.checkMaxFiles(2); err != nil {
+		f.mu.Unlock()
+		return [2]FID{}, err
+	}
+	readerFID := f.newFID(0)
+	r, w := newPipe(readerFID, f.newFID(readerFID+1))
+	r.Open()
+	w.Open()
 	f.addFileDescriptor(r)
 	f.addFileDescriptor(w)
-	r.Open(f.parentPID)
-	w.Open(f.parentPID)
-	return [2]FID{r.id, w.id}
+	f.mu.Unlock()
+	return [2]FID{r.id, w.id}, nil
 }
 
-func newPipe(newFID func() FID) (r, w *fileDescriptor) {
-	readerFID, writerFID := newFID(), newFID()
+func newPipe(readerFID, writerFID FID) (r, w *fileDescriptor) {
 	pipeC := newPipeChan(readerFID, writerFID)
 	rPipe := &namedPipe{pipeChan: pipeC, fid: readerFID}
 	r = newIrregularFileDescriptor(
@@ -34,22 +44,27 @@ func newPipe(newFID func() FID) (r, w *fileDescriptor) {
 		&pipeWriteOnly{wPipe},
 		os.ModeNamedPipe,
 	)
+	w.flags.Store(int64(os.O_WRONLY))
 	return
 }
 
 type pipeChan struct {
 	buf            chan byte
-	done           chan struct{}
+	done           chan struct{} // closed with the write end
+	readerDone     chan struct{} // closed with the read end
+	readerOnce     sync.Once
 	reader, writer FID
+	notifier       readyNotifier
 }
 
 func newPipeChan(reader, writer FID) *pipeChan {
 	const maxPipeBuffer = 32 << 10 // 32KiB
 	return &pipeChan{
-		buf:    make(chan byte, maxPipeBuffer),
-		done:   make(chan struct{}),
-		reader: reader,
-		writer: writer,
+		buf:        make(chan byte, maxPipeBuffer),
+		done:       make(chan struct{}),
+		readerDone: make(chan struct{}),
+		reader:     reader,
+		writer:     writer,
 	}
 }
 
@@ -85,37 +100,92 @@ func (p *pipeChan) Sync() error {
 	}
 }
 
+// Read blocks until at least 1 byte is available or the pipe closes, then reads as much as is available
 func (p *pipeChan) Read(buf []byte) (n int, err error) {
+	if len(buf) == 0 {
+		return 0, nil
+	}
+	n, err = p.readNonBlocking(buf)
+	if err != ErrWouldBlock {
+		return n, err
+	}
+	b, ok := <-p.buf
+	if !ok {
+		return 0, io.EOF
+	}
+	buf[0] = b
+	n, err = p.readNonBlocking(buf[1:])
+	if err == ErrWouldBlock || err == io.EOF {
+		err = nil
+	}
+	return n + 1, err
+}
+
+func (p *pipeChan) readNonBlocking(buf []byte) (n int, err error) {
+	defer p.notifier.notify() // space freed up for writers
 	for n < len(buf) {
-		// Read should always block if the pipe is not closed
-		b, ok := <-p.buf
-		if !ok {
-			err = io.EOF
+		select {
+		case b, ok := <-p.buf:
+			if !ok {
+				if n == 0 {
+					err = io.EOF
+				}
+				return
+			}
+			buf[n] = b
+			n++
+		default:
+			if n == 0 {
+				err = ErrWouldBlock
+			}
 			return
 		}
-		buf[n] = b
-		n++
-	}
-	if n == 0 {
-		err = io.EOF
 	}
 	return
 }
 
 func (p *pipeChan) Write(buf []byte) (n int, err error) {
-	for _, b := range buf {
+	for n < len(buf) {
+		written, err := p.writeNonBlocking(buf[n:])
+		n += written
+		switch {
+		case err == ErrWouldBlock:
+			// Write should always return immediately if the pipe buffer has space, otherwise it should block
+			select {
+			case <-p.done:
+				// do not allow writes to a closed pipe
+				return n, interop.BadFileNumber(p.writer)
+			case <-p.readerDone:
+				return n, ErrBrokenPipe
+			case p.buf <- buf[n]:
+				n++
+			}
+		case err != nil:
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func (p *pipeChan) writeNonBlocking(buf []byte) (n int, err error) {
+	defer p.notifier.notify() // data available for readers
+	if p.readerClosed() {
+		return 0, ErrBrokenPipe
+	}
+	for n < len(buf) {
 		select {
 		case <-p.done:
 			// do not allow writes to a closed pipe
-			return 0, interop.BadFileNumber(p.writer)
-		case p.buf <- b:
+			return n, interop.BadFileNumber(p.writer)
+		case p.buf <- buf[n]:
 			n++
-			// no default case allowed, Write should always return immediately if the pipe buffer has space, otherwise it should block
+		default:
+			if n == 0 {
+				err = ErrWouldBlock
+			}
+			return
 		}
 	}
-	if n < len(buf) {
-		err = io.ErrShortWrite
-	}
 	return
 }
 
@@ -126,10 +196,41 @@ func (p *pipeChan) Close() error {
 	default:
 		close(p.done)
 		close(p.buf)
+		p.notifier.notify()
 		return nil
 	}
 }
 
+func (p *pipeChan) subscribe(wake chan<- struct{}) (unsubscribe func()) {
+	return p.notifier.subscribe(wake)
+}
+
+func (p *pipeChan) closed() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// closeReader fails pending and future writes, since nothing can read them
+func (p *pipeChan) closeReader() {
+	p.readerOnce.Do(func() {
+		close(p.readerDone)
+		p.notifier.notify()
+	})
+}
+
+func (p *pipeChan) readerClosed() bool {
+	select {
+	case <-p.readerDone:
+		return true
+	default:
+		return false
+	}
+}
+
 type namedPipe struct {
 	*pipeChan
 	fid FID
@@ -154,8 +255,24 @@ func (r *pipeReadOnly) Write(buf []byte) (n int, err error) {
 	return 0, interop.ErrNotImplemented
 }
 
+func (r *pipeReadOnly) writeNonBlocking(buf []byte) (n int, err error) {
+	return 0, interop.ErrNotImplemented
+}
+
+func (r *pipeReadOnly) ready() PollEvents {
+	var events PollEvents
+	if len(r.buf) > 0 {
+		events |= PollIn
+	}
+	if r.closed() {
+		events |= PollIn | PollHup
+	}
+	return events
+}
+
 func (r *pipeReadOnly) Close() error {
 	// only write side of pipe should close the buffer
+	r.closeReader()
 	return nil
 }
 
@@ -167,6 +284,21 @@ func (w *pipeWriteOnly) Read(buf []byte) (n int, err error) {
 	return 0, interop.ErrNotImplemented
 }
 
+func (w *pipeWriteOnly) readNonBlocking(buf []byte) (n int, err error) {
+	return 0, interop.ErrNotImplemented
+}
+
+func (w *pipeWriteOnly) ready() PollEvents {
+	switch {
+	case w.closed(), w.readerClosed():
+		return PollErr
+	case len(w.buf) < cap(w.buf):
+		return PollOut
+	default:
+		return 0
+	}
+}
+
 func (w *pipeWriteOnly) WriteAt(buf []byte, off int64) (n int, err error) {
 	if off == 0 {
 		return w.Write(buf)
@@ -0,0 +1,157 @@
+package fs
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpad/internal/interop"
+)
+
+var ErrWouldBlock = interop.NewError("resource temporarily unavailable", "EAGAIN")
+
+// PollEvents are poll(2) event bits. Values match Linux.
+type PollEvents int16
+
+const (
+	PollIn   PollEvents = 0x1
+	PollOut  PollEvents = 0x4
+	PollErr  PollEvents = 0x8
+	PollHup  PollEvents = 0x10
+	PollNval PollEvents = 0x20
+
+	// pollAlways are reported whether or not they were requested
+	pollAlways = PollErr | PollHup | PollNval
+)
+
+// pollableFile is a file which may not be ready for I/O, like a pipe. Files which aren't pollable are always ready.
+type pollableFile interface {
+	// ready returns the file's current readiness
+	ready() PollEvents
+	// subscribe signals 'wake' without blocking whenever the file's readiness may have changed
+	subscribe(wake chan<- struct{}) (unsubscribe func())
+}
+
+// nonBlockingFile performs I/O without blocking, returning ErrWouldBlock instead
+type nonBlockingFile interface {
+	readNonBlocking(p []byte) (n int, err error)
+	writeNonBlocking(p []byte) (n int, err error)
+}
+
+func (fd *fileDescriptor) nonBlocking() bool {
+	return fd.flags.Load()&OpenNonBlock != 0
+}
+
+func (fd *fileDescriptor) ready() PollEvents {
+	if file, ok := fd.file.(pollableFile); ok {
+		return file.ready()
+	}
+	return PollIn | PollOut
+}
+
+// Poll waits until any of 'fds' is ready for its corresponding 'events' or 'timeout' elapses, like poll(2).
+// Returns the ready events for each descriptor, which are all zero on timeout. A negative timeout waits indefinitely.
+func (f *FileDescriptors) Poll(fds []FID, events []PollEvents, timeout time.Duration) ([]PollEvents, error) {
+	if len(fds) != len(events) {
+		return nil, interop.NewError("poll: each descriptor must have events", "EINVAL")
+	}
+	descriptors := make([]*fileDescriptor, len(fds))
+	for i, fid := range fds {
+		descriptor, err := f.acquire(fid)
+		if err == nil {
+			defer descriptor.release()
+			descriptors[i] = descriptor
+		}
+	}
+
+	// subscribe before checking readiness, so no change can be missed in between
+	wake := make(chan struct{}, 1)
+	for _, descriptor := range descriptors {
+		if descriptor == nil {
+			continue
+		}
+		if file, ok := descriptor.file.(pollableFile); ok {
+			defer file.subscribe(wake)()
+		}
+	}
+
+	var deadline <-chan time.Time
+	if timeout >= 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	for {
+		revents := make([]PollEvents, len(fds))
+		readyCount := 0
+		for i, descriptor := range descriptors {
+			if descriptor == nil {
+				revents[i] = PollNval
+			} else {
+				revents[i] = descriptor.ready() & (events[i] | pollAlways)
+			}
+			if revents[i] != 0 {
+				readyCount++
+			}
+		}
+		if readyCount > 0 {
+			return revents, nil
+		}
+		select {
+		case <-wake:
+		case <-deadline:
+			return revents, nil
+		}
+	}
+}
+
+// readyNotifier broadcasts readiness changes to Poll subscribers
+type readyNotifier struct {
+	mu          sync.Mutex
+	lastID      int
+	subscribers map[int]chan<- struct{}
+}
+
+func (n *readyNotifier) subscribe(wake chan<- struct{}) (unsubscribe func()) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.subscribers == nil {
+		n.subscribers = make(map[int]chan<- struct{})
+	}
+	n.lastID++
+	id := n.lastID
+	n.subscribers[id] = wake
+	return func() {
+		n.mu.Lock()
+		delete(n.subscribers, id)
+		n.mu.Unlock()
+	}
+}
+
+func (n *readyNotifier) notify() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, wake := range n.subscribers {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// nonBlockingReader adapts a nonBlockingFile to io.Reader
+type nonBlockingReader struct {
+	file nonBlockingFile
+}
+
+func (r nonBlockingReader) Read(p []byte) (int, error) {
+	return r.file.readNonBlocking(p)
+}
+
+// nonBlockingWriter adapts a nonBlockingFile to io.Writer
+type nonBlockingWriter struct {
+	file nonBlockingFile
+}
+
+func (w nonBlockingWriter) Write(p []byte) (int, error) {
+	return w.file.writeNonBlocking(p)
+}
@@ -0,0 +1,146 @@
+package fs
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+func TestNonBlockingPipe(t *testing.T) {
+	f := newTestFileDescriptors(t)
+	fids, err := f.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, w := fids[0], fids[1]
+	if _, err := f.Fcntl(r, FcntlSetFL, OpenNonBlock); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := blob.NewBytesLength(10)
+	if _, err := f.Read(r, buf, 0, buf.Len(), nil); !errors.Is(err, ErrWouldBlock) {
+		t.Fatalf("Expected EAGAIN reading an empty pipe, got: %v", err)
+	}
+
+	data := []byte("hi")
+	if _, err := f.Write(w, blob.NewBytes(data), 0, len(data), nil); err != nil {
+		t.Fatal(err)
+	}
+	n, err := f.Read(r, buf, 0, buf.Len(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf.Bytes()[:n]); got != "hi" {
+		t.Errorf("Expected a short read of available data %q, got %q", "hi", got)
+	}
+}
+
+func TestPoll(t *testing.T) {
+	f := newTestFileDescriptors(t)
+	fids, err := f.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, w := fids[0], fids[1]
+	file, err := f.Open("file", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revents, err := f.Poll([]FID{r, w}, []PollEvents{PollIn, PollIn}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revents[0] != 0 || revents[1] != 0 {
+		t.Errorf("Expected nothing ready, got %v", revents)
+	}
+
+	revents, err = f.Poll([]FID{r, file, 99}, []PollEvents{PollIn, PollIn | PollOut, PollIn}, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []PollEvents{0, PollIn | PollOut, PollNval}; !equalEvents(revents, expected) {
+		t.Errorf("Expected %v, got %v", expected, revents)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		data := []byte("hi")
+		_, _ = f.Write(w, blob.NewBytes(data), 0, len(data), nil)
+	}()
+	revents, err = f.Poll([]FID{r}, []PollEvents{PollIn}, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revents[0] != PollIn {
+		t.Errorf("Expected pipe to become readable, got %v", revents)
+	}
+
+	if err := f.Close(w); err != nil {
+		t.Fatal(err)
+	}
+	revents, err = f.Poll([]FID{r}, []PollEvents{PollIn}, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revents[0] != PollIn|PollHup {
+		t.Errorf("Expected hang up after closing the writer, got %v", revents)
+	}
+}
+
+func equalEvents(a, b []PollEvents) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPipeBrokenAfterReaderCloses(t *testing.T) {
+	f := newTestFileDescriptors(t)
+	fids, err := f.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, w := fids[0], fids[1]
+
+	// fill the pipe, so the next write blocks until the reader closes
+	full := make([]byte, 32<<10)
+	if _, err := f.Write(w, blob.NewBytes(full), 0, len(full), nil); err != nil {
+		t.Fatal(err)
+	}
+	written := make(chan error, 1)
+	go func() {
+		_, err := f.Write(w, blob.NewBytes([]byte("hi")), 0, 2, nil)
+		written <- err
+	}()
+	select {
+	case err := <-written:
+		t.Fatalf("Expected write to a full pipe to block, got: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	if err := f.Close(r); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-written; !errors.Is(err, ErrBrokenPipe) {
+		t.Errorf("Expected pending write to fail with EPIPE, got: %v", err)
+	}
+	if _, err := f.Write(w, blob.NewBytes([]byte("hi")), 0, 2, nil); !errors.Is(err, ErrBrokenPipe) {
+		t.Errorf("Expected write after the reader closed to fail with EPIPE, got: %v", err)
+	}
+	revents, err := f.Poll([]FID{w}, []PollEvents{PollOut}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revents[0] != PollErr {
+		t.Errorf("Expected POLLERR on the write end, got %v", revents[0])
+	}
+}
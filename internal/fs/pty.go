@@ -0,0 +1,636 @@
+package fs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hack-pad/hackpad/internal/interop"
+	"go.uber.org/atomic"
+)
+
+// Termios input, output, and local mode flags. Values match Linux.
+const (
+	TermiosMapCRToNL = 0x100 // input: translate carriage return to newline
+
+	TermiosPostProcess = 0x1 // output: enable output processing
+	TermiosMapNLToCRNL = 0x4 // output: translate newline to carriage return and newline
+
+	TermiosSignals     = 0x1   // local: generate signals for the interrupt, quit, and suspend characters
+	TermiosCanonical   = 0x2   // local: read input a line at a time, with line editing
+	TermiosEcho        = 0x8   // local: echo input
+	TermiosEchoErase   = 0x10  // local: visually erase characters for the erase and kill characters
+	TermiosEchoControl = 0x200 // local: echo control characters like ^C
+)
+
+// Termios control character indexes. Values match Linux.
+const (
+	CharInterrupt = 0
+	CharQuit      = 1
+	CharErase     = 2
+	CharKill      = 3
+	CharEOF       = 4
+	CharSuspend   = 10
+	termiosChars  = 19
+)
+
+// Ioctl requests for terminals. Values match Linux.
+const (
+	IoctlGetTermios      = 0x5401
+	IoctlSetTermios      = 0x5402
+	IoctlSetTermiosDrain = 0x5403
+	IoctlSetTermiosFlush = 0x5404
+	IoctlGetWindowSize   = 0x5413
+	IoctlSetWindowSize   = 0x5414
+)
+
+// signals generated by terminals. Values match Linux and internal/process.
+const (
+	signalHangUp       = 1
+	signalInterrupt    = 2
+	signalQuit         = 3
+	signalTerminalStop = 20
+	signalWindowChange = 28
+)
+
+const (
+	maxCanonicalLine  = 4096     // longest line in canonical mode, further input is dropped
+	maxTerminalInput  = 32 << 10 // 32KiB
+	maxTerminalOutput = 32 << 10 // 32KiB
+)
+
+var (
+	ErrNotTerminal = interop.NewError("inappropriate ioctl for device", "ENOTTY")
+	errTerminalIO  = interop.NewError("input/output error", "EIO")
+)
+
+// Termios is a terminal's settings, like termios(3)
+type Termios struct {
+	InputFlags  uint32
+	OutputFlags uint32
+	LocalFlags  uint32
+	Chars       [termiosChars]byte
+}
+
+// DefaultTermios returns the settings for new terminals: canonical mode with echo and signals
+func DefaultTermios() Termios {
+	t := Termios{
+		InputFlags:  TermiosMapCRToNL,
+		OutputFlags: TermiosPostProcess | TermiosMapNLToCRNL,
+		LocalFlags:  TermiosSignals | TermiosCanonical | TermiosEcho | TermiosEchoErase | TermiosEchoControl,
+	}
+	t.Chars[CharInterrupt] = 0x03 // ^C
+	t.Chars[CharQuit] = 0x1c      // ^\
+	t.Chars[CharErase] = 0x7f     // DEL
+	t.Chars[CharKill] = 0x15      // ^U
+	t.Chars[CharEOF] = 0x04       // ^D
+	t.Chars[CharSuspend] = 0x1a   // ^Z
+	return t
+}
+
+// MakeRaw returns a copy of 't' in raw mode, where input is read byte by byte without echo or signals, like cfmakeraw(3).
+// Output processing is unchanged.
+func (t Termios) MakeRaw() Termios {
+	t.InputFlags &^= TermiosMapCRToNL
+	t.LocalFlags &^= TermiosSignals | TermiosCanonical | TermiosEcho | TermiosEchoErase | TermiosEchoControl
+	return t
+}
+
+// WindowSize is a terminal's size in characters
+type WindowSize struct {
+	Rows, Cols uint16
+}
+
+// TerminalSignaler delivers 'signal' to the processes attached to 't'
+type TerminalSignaler func(t *PTY, signal int)
+
+var terminalSignaler atomic.Value
+
+// SetTerminalSignaler sets the func which delivers signals generated by terminals, like ^C's SIGINT
+func SetTerminalSignaler(fn TerminalSignaler) {
+	terminalSignaler.Store(fn)
+}
+
+func (t *PTY) signal(signal int) {
+	if fn, ok := terminalSignaler.Load().(TerminalSignaler); ok && fn != nil {
+		go fn(t, signal)
+	}
+}
+
+var lastPTYNumber atomic.Int64
+
+// PTY is a pseudo-terminal device pair. The master end is the terminal emulator's, and the slave end is the program's.
+// Input written to the master passes through the line discipline before the slave reads it, and output written to the slave is read from the master.
+type PTY struct {
+	number int64
+
+	mu         sync.Mutex
+	changed    *sync.Cond // broadcast when buffers or settings change
+	termios    Termios
+	windowSize WindowSize
+	line       []byte   // canonical line being edited
+	lines      [][]byte // completed canonical lines. An empty line is an end of file.
+	input      []byte   // raw input
+	output     []byte
+	session    *FileDescriptors // the session leader's descriptor table, which isn't sent signals from special characters
+	masterOpen bool
+	slaveOpen  bool
+	notifier   readyNotifier
+}
+
+// OpenPTY opens a new pseudo-terminal, returning the master and slave FIDs
+func (f *FileDescriptors) OpenPTY() ([2]FID, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.checkMaxFiles(2); err != nil {
+		return [2]FID{}, err
+	}
+	t := &PTY{
+		number:     lastPTYNumber.Inc() - 1,
+		termios:    DefaultTermios(),
+		windowSize: WindowSize{Rows: 24, Cols: 80},
+		masterOpen: true,
+		slaveOpen:  true,
+	}
+	t.changed = sync.NewCond(&t.mu)
+	master := newIrregularFileDescriptor(f.newFID(0), "ptmx", &ptyMaster{t}, os.ModeDevice|os.ModeCharDevice)
+	master.flags.Store(int64(os.O_RDWR))
+	master.Open()
+	f.addFileDescriptor(master)
+	slave := newIrregularFileDescriptor(f.newFID(0), t.Name(), &ptySlave{t}, os.ModeDevice|os.ModeCharDevice)
+	slave.flags.Store(int64(os.O_RDWR))
+	slave.Open()
+	f.addFileDescriptor(slave)
+	return [2]FID{master.id, slave.id}, nil
+}
+
+// Terminal returns the terminal open on 'fd', or ErrNotTerminal
+func (f *FileDescriptors) Terminal(fd FID) (*PTY, error) {
+	descriptor, err := f.acquire(fd)
+	if err != nil {
+		return nil, err
+	}
+	defer descriptor.release()
+	switch file := descriptor.file.(type) {
+	case *ptyMaster:
+		return file.PTY, nil
+	case *ptySlave:
+		return file.PTY, nil
+	default:
+		return nil, ErrNotTerminal
+	}
+}
+
+// IsTerminal returns true if 'fd' is a terminal, like isatty(3)
+func (f *FileDescriptors) IsTerminal(fd FID) bool {
+	_, err := f.Terminal(fd)
+	return err == nil
+}
+
+// Attached returns true if 'f' has the slave end of 't' open
+func (t *PTY) Attached(f *FileDescriptors) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for _, descriptor := range f.files {
+		if slave, ok := descriptor.file.(*ptySlave); ok && slave.PTY == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Foreground returns true if 't' should send signals from special characters to 'f'.
+// Without job control, that's every attached process except the session leader, like an interactive shell ignoring ^C.
+func (t *PTY) Foreground(f *FileDescriptors) bool {
+	t.mu.Lock()
+	leader := t.session == f
+	t.mu.Unlock()
+	return !leader && t.Attached(f)
+}
+
+// claimTerminal makes 'f' the session leader of the terminal on its stdin, if that terminal has none yet
+func (f *FileDescriptors) claimTerminal() {
+	t, err := f.Terminal(0)
+	if err != nil {
+		return
+	}
+	t.mu.Lock()
+	if t.session == nil {
+		t.session = f
+	}
+	t.mu.Unlock()
+}
+
+// releaseTerminal ends the sessions led by 'f'
+func (f *FileDescriptors) releaseTerminal() {
+	t, err := f.Terminal(0)
+	if err != nil {
+		return
+	}
+	t.mu.Lock()
+	if t.session == f {
+		t.session = nil
+	}
+	t.mu.Unlock()
+}
+
+// Name returns the slave's device name
+func (t *PTY) Name() string {
+	return fmt.Sprintf("pts/%d", t.number)
+}
+
+// Termios returns the terminal's settings
+func (t *PTY) Termios() Termios {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.termios
+}
+
+// SetTermios changes the terminal's settings, like tcsetattr(3).
+// 'request' is IoctlSetTermios to change them now, IoctlSetTermiosDrain to first wait for output to be read, or IoctlSetTermiosFlush to also discard unread input.
+func (t *PTY) SetTermios(request int, termios Termios) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch request {
+	case IoctlSetTermios:
+	case IoctlSetTermiosDrain, IoctlSetTermiosFlush:
+		for len(t.output) > 0 && t.masterOpen {
+			t.changed.Wait()
+		}
+		if request == IoctlSetTermiosFlush {
+			t.flushInput()
+		}
+	default:
+		return interop.NewError(fmt.Sprintf("Invalid termios request: %d", request), "EINVAL")
+	}
+	wasCanonical := t.canonical()
+	t.termios = termios
+	if wasCanonical && !t.canonical() {
+		// pending input becomes readable immediately
+		for _, line := range t.lines {
+			t.input = append(t.input, line...)
+		}
+		t.input = append(t.input, t.line...)
+		t.lines, t.line = nil, nil
+	}
+	t.notifyChange()
+	return nil
+}
+
+// WindowSize returns the terminal's size
+func (t *PTY) WindowSize() WindowSize {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.windowSize
+}
+
+// SetWindowSize resizes the terminal, sending SIGWINCH to its foreground processes if the size changed.
+// Signals can't be caught, so programs see a process "signal" event and should check the size again.
+func (t *PTY) SetWindowSize(size WindowSize) {
+	t.mu.Lock()
+	changed := t.windowSize != size
+	t.windowSize = size
+	t.mu.Unlock()
+	if changed {
+		t.signal(signalWindowChange)
+	}
+}
+
+// notifyChange wakes blocked readers, writers, and pollers. Must be called with t.mu held.
+func (t *PTY) notifyChange() {
+	t.changed.Broadcast()
+	t.notifier.notify()
+}
+
+func (t *PTY) subscribe(wake chan<- struct{}) (unsubscribe func()) {
+	return t.notifier.subscribe(wake)
+}
+
+func (t *PTY) canonical() bool {
+	return t.termios.LocalFlags&TermiosCanonical != 0
+}
+
+func (t *PTY) flushInput() {
+	t.line, t.lines, t.input = nil, nil, nil
+}
+
+// receive runs input from the master through the line discipline. Must be called with t.mu held.
+func (t *PTY) receive(buf []byte) {
+	termios := t.termios
+	local := termios.LocalFlags
+	for _, c := range buf {
+		if c == '\r' && termios.InputFlags&TermiosMapCRToNL != 0 {
+			c = '\n'
+		}
+		if local&TermiosSignals != 0 && c != 0 {
+			signal := 0
+			switch c {
+			case termios.Chars[CharInterrupt]:
+				signal = signalInterrupt
+			case termios.Chars[CharQuit]:
+				signal = signalQuit
+			case termios.Chars[CharSuspend]:
+				signal = signalTerminalStop
+			}
+			if signal != 0 {
+				t.flushInput()
+				t.echo(c)
+				t.signal(signal)
+				continue
+			}
+		}
+		if !t.canonical() {
+			if len(t.input) < maxTerminalInput {
+				t.input = append(t.input, c)
+				t.echo(c)
+			}
+			continue
+		}
+		switch c {
+		case termios.Chars[CharErase]:
+			if len(t.line) > 0 {
+				_, size := utf8.DecodeLastRune(t.line)
+				t.line = t.line[:len(t.line)-size]
+				t.echoErase(1)
+			}
+		case termios.Chars[CharKill]:
+			t.echoErase(utf8.RuneCount(t.line))
+			t.line = nil
+		case termios.Chars[CharEOF]:
+			t.lines = append(t.lines, t.line) // an empty line reads as end of file
+			t.line = nil
+		case '\n':
+			t.echo(c)
+			t.lines = append(t.lines, append(t.line, c))
+			t.line = nil
+		default:
+			if len(t.line) < maxCanonicalLine {
+				t.line = append(t.line, c)
+				t.echo(c)
+			}
+		}
+	}
+	t.notifyChange()
+}
+
+// echo writes input character 'c' to the output, if enabled
+func (t *PTY) echo(c byte) {
+	if t.termios.LocalFlags&TermiosEcho == 0 {
+		return
+	}
+	if c < ' ' && c != '\n' && c != '\t' && t.termios.LocalFlags&TermiosEchoControl != 0 {
+		t.appendEcho([]byte{'^', c + '@'})
+		return
+	}
+	t.appendEcho(t.process(nil, []byte{c}))
+}
+
+// appendEcho adds echoed input to the output. Input never blocks, so echo is dropped once the output buffer is full.
+func (t *PTY) appendEcho(echoed []byte) {
+	if len(t.output)+len(echoed) <= maxTerminalOutput {
+		t.output = append(t.output, echoed...)
+	}
+}
+
+// echoErase visually erases 'runes' characters, if enabled
+func (t *PTY) echoErase(runes int) {
+	if t.termios.LocalFlags&(TermiosEcho|TermiosEchoErase) != TermiosEcho|TermiosEchoErase {
+		return
+	}
+	for i := 0; i < runes; i++ {
+		t.appendEcho([]byte("\b \b"))
+	}
+}
+
+// process appends 'buf' to 'output' with output processing, if enabled
+func (t *PTY) process(output, buf []byte) []byte {
+	flags := t.termios.OutputFlags
+	if flags&TermiosPostProcess == 0 || flags&TermiosMapNLToCRNL == 0 {
+		return append(output, buf...)
+	}
+	for _, c := range buf {
+		if c == '\n' {
+			output = append(output, '\r')
+		}
+		output = append(output, c)
+	}
+	return output
+}
+
+// readInput reads input for the slave. If 'block' is false, returns ErrWouldBlock instead of waiting. Must be called with t.mu held.
+func (t *PTY) readInput(buf []byte, block bool) (int, error) {
+	for {
+		switch {
+		case len(t.input) > 0:
+			n := copy(buf, t.input)
+			t.input = t.input[n:]
+			t.notifyChange()
+			return n, nil
+		case len(t.lines) > 0:
+			line := t.lines[0]
+			n := copy(buf, line)
+			if n == len(line) {
+				t.lines = t.lines[1:]
+			} else {
+				t.lines[0] = line[n:]
+			}
+			t.notifyChange()
+			if len(line) == 0 {
+				return 0, io.EOF
+			}
+			return n, nil
+		case !t.masterOpen:
+			return 0, io.EOF
+		case !block:
+			return 0, ErrWouldBlock
+		}
+		t.changed.Wait()
+	}
+}
+
+// writeOutput writes output from the slave. If 'block' is false, returns ErrWouldBlock instead of waiting. Must be called with t.mu held.
+func (t *PTY) writeOutput(buf []byte, block bool) (n int, err error) {
+	for n < len(buf) {
+		switch {
+		case !t.masterOpen:
+			return n, errTerminalIO
+		case len(t.output) < maxTerminalOutput:
+			chunk := buf[n:]
+			if space := maxTerminalOutput - len(t.output); len(chunk) > space {
+				chunk = chunk[:space]
+			}
+			t.output = t.process(t.output, chunk)
+			n += len(chunk)
+			t.notifyChange()
+		case !block:
+			if n == 0 {
+				err = ErrWouldBlock
+			}
+			return n, err
+		default:
+			t.changed.Wait()
+		}
+	}
+	return n, nil
+}
+
+// readOutput reads output for the master. If 'block' is false, returns ErrWouldBlock instead of waiting. Must be called with t.mu held.
+func (t *PTY) readOutput(buf []byte, block bool) (int, error) {
+	for {
+		switch {
+		case len(t.output) > 0:
+			n := copy(buf, t.output)
+			t.output = t.output[n:]
+			t.notifyChange()
+			return n, nil
+		case !t.slaveOpen:
+			return 0, io.EOF
+		case !block:
+			return 0, ErrWouldBlock
+		}
+		t.changed.Wait()
+	}
+}
+
+type ptyStat struct {
+	name string
+}
+
+func (p ptyStat) Name() string       { return p.name }
+func (p ptyStat) Size() int64        { return 0 }
+func (p ptyStat) Mode() os.FileMode  { return os.ModeDevice | os.ModeCharDevice | 0620 }
+func (p ptyStat) ModTime() time.Time { return time.Time{} }
+func (p ptyStat) IsDir() bool        { return false }
+func (p ptyStat) Sys() interface{}   { return nil }
+
+// ptyMaster is the terminal emulator's end of a PTY
+type ptyMaster struct {
+	*PTY
+}
+
+func (m *ptyMaster) Name() string {
+	return "ptmx"
+}
+
+func (m *ptyMaster) Stat() (os.FileInfo, error) {
+	return ptyStat{name: m.Name()}, nil
+}
+
+func (m *ptyMaster) Read(buf []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.readOutput(buf, true)
+}
+
+func (m *ptyMaster) readNonBlocking(buf []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.readOutput(buf, false)
+}
+
+// Write sends input to the slave. Input never blocks, instead input beyond the terminal's buffer is dropped.
+func (m *ptyMaster) Write(buf []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.slaveOpen {
+		return 0, errTerminalIO
+	}
+	m.receive(buf)
+	return len(buf), nil
+}
+
+func (m *ptyMaster) writeNonBlocking(buf []byte) (int, error) {
+	return m.Write(buf)
+}
+
+func (m *ptyMaster) ready() PollEvents {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	events := PollOut
+	if len(m.output) > 0 {
+		events |= PollIn
+	}
+	if !m.slaveOpen {
+		events |= PollIn | PollHup
+	}
+	return events
+}
+
+// Close hangs up the terminal
+func (m *ptyMaster) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.masterOpen {
+		return os.ErrClosed
+	}
+	m.masterOpen = false
+	m.notifyChange()
+	m.signal(signalHangUp)
+	return nil
+}
+
+// ptySlave is the program's end of a PTY
+type ptySlave struct {
+	*PTY
+}
+
+func (s *ptySlave) Stat() (os.FileInfo, error) {
+	return ptyStat{name: s.Name()}, nil
+}
+
+func (s *ptySlave) Read(buf []byte) (int, error) {
+	if len(buf) == 0 {
+		return 0, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.readInput(buf, true)
+}
+
+func (s *ptySlave) readNonBlocking(buf []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.readInput(buf, false)
+}
+
+func (s *ptySlave) Write(buf []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writeOutput(buf, true)
+}
+
+func (s *ptySlave) writeNonBlocking(buf []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writeOutput(buf, false)
+}
+
+func (s *ptySlave) ready() PollEvents {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var events PollEvents
+	if len(s.input) > 0 || len(s.lines) > 0 {
+		events |= PollIn
+	}
+	if len(s.output) < maxTerminalOutput {
+		events |= PollOut
+	}
+	if !s.masterOpen {
+		events |= PollIn | PollHup
+	}
+	return events
+}
+
+func (s *ptySlave) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.slaveOpen {
+		return os.ErrClosed
+	}
+	s.slaveOpen = false
+	s.notifyChange()
+	return nil
+}
@@ -0,0 +1,154 @@
+package fs
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+func openTestPTY(t *testing.T) (f *FileDescriptors, master, slave FID) {
+	t.Helper()
+	f = newTestFileDescriptors(t)
+	fids, err := f.OpenPTY()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, fids[0], fids[1]
+}
+
+func writeString(t *testing.T, f *FileDescriptors, fd FID, s string) {
+	t.Helper()
+	if _, err := f.Write(fd, blob.NewBytes([]byte(s)), 0, len(s), nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readString(t *testing.T, f *FileDescriptors, fd FID) string {
+	t.Helper()
+	buf := blob.NewBytesLength(100)
+	n, err := f.Read(fd, buf, 0, buf.Len(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf.Bytes()[:n])
+}
+
+func TestPTYCanonical(t *testing.T) {
+	f, master, slave := openTestPTY(t)
+	if !f.IsTerminal(slave) {
+		t.Error("Expected slave to be a terminal")
+	}
+
+	writeString(t, f, master, "hellp\x7fo\rworld")
+	if got := readString(t, f, slave); got != "hello\n" {
+		t.Errorf("Expected the edited line %q, got %q", "hello\n", got)
+	}
+	if got := readString(t, f, master); got != "hellp\b \bo\r\nworld" {
+		t.Errorf("Unexpected echo: %q", got)
+	}
+
+	writeString(t, f, master, "\x04") // ^D ends the line without a newline
+	if got := readString(t, f, slave); got != "world" {
+		t.Errorf("Expected %q, got %q", "world", got)
+	}
+	writeString(t, f, master, "\x04") // ^D on an empty line is end of file
+	if got := readString(t, f, slave); got != "" {
+		t.Errorf("Expected end of file, got %q", got)
+	}
+
+	writeString(t, f, slave, "out\n")
+	if got := readString(t, f, master); got != "out\r\n" {
+		t.Errorf("Expected newlines translated, got %q", got)
+	}
+}
+
+func TestPTYRaw(t *testing.T) {
+	f, master, slave := openTestPTY(t)
+	pty, err := f.Terminal(slave)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeString(t, f, master, "ab")
+	if err := pty.SetTermios(IoctlSetTermios, pty.Termios().MakeRaw()); err != nil {
+		t.Fatal(err)
+	}
+	writeString(t, f, master, "\x03\r")
+	if got := readString(t, f, slave); got != "ab\x03\r" {
+		t.Errorf("Expected pending and raw input, got %q", got)
+	}
+	if got := readString(t, f, master); got != "ab" {
+		t.Errorf("Expected no echo in raw mode, got %q", got)
+	}
+}
+
+func TestPTYSignals(t *testing.T) {
+	f, master, slave := openTestPTY(t)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var signal int
+	SetTerminalSignaler(func(pty *PTY, sig int) {
+		signal = sig
+		wg.Done()
+	})
+	defer SetTerminalSignaler(nil)
+
+	writeString(t, f, master, "discarded\x03")
+	wg.Wait()
+	if signal != signalInterrupt {
+		t.Errorf("Expected SIGINT, got %d", signal)
+	}
+	writeString(t, f, master, "kept\n")
+	if got := readString(t, f, slave); got != "kept\n" {
+		t.Errorf("Expected the interrupted line discarded, got %q", got)
+	}
+}
+
+func TestPTYEchoBounded(t *testing.T) {
+	f, master, _ := openTestPTY(t)
+	pty, err := f.Terminal(master)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// nothing reads the output, so echo must stop once the output buffer fills
+	line := strings.Repeat("a", 99) + "\n"
+	for i := 0; i < 2*maxTerminalOutput/len(line); i++ {
+		writeString(t, f, master, line)
+	}
+	pty.mu.Lock()
+	outputLen := len(pty.output)
+	pty.mu.Unlock()
+	if outputLen > maxTerminalOutput {
+		t.Errorf("Expected echo to stop at %d bytes of output, got %d", maxTerminalOutput, outputLen)
+	}
+}
+
+func TestPTYWindowChange(t *testing.T) {
+	f, master, _ := openTestPTY(t)
+	pty, err := f.Terminal(master)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signals := make(chan int, 2)
+	SetTerminalSignaler(func(_ *PTY, sig int) {
+		signals <- sig
+	})
+	defer SetTerminalSignaler(nil)
+
+	size := WindowSize{Rows: 40, Cols: 100}
+	pty.SetWindowSize(size)
+	if sig := <-signals; sig != signalWindowChange {
+		t.Errorf("Expected SIGWINCH, got %d", sig)
+	}
+	if got := pty.WindowSize(); got != size {
+		t.Errorf("Expected size %v, got %v", size, got)
+	}
+	pty.SetWindowSize(size)
+	select {
+	case sig := <-signals:
+		t.Errorf("Expected no signal when the size is unchanged, got %d", sig)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
@@ -3,21 +3,27 @@ package fs
 import (
 	"io"
 
-	"github.com/hack-pad/hackpad/internal/interop"
 	"github.com/hack-pad/hackpadfs"
 	"github.com/hack-pad/hackpadfs/keyvalue/blob"
 )
 
 func (f *FileDescriptors) Read(fd FID, buffer blob.Blob, offset, length int, position *int64) (n int, err error) {
-	fileDescriptor := f.files[fd]
-	if fileDescriptor == nil {
-		return 0, interop.BadFileNumber(fd)
+	fileDescriptor, err := f.acquire(fd)
+	if err != nil {
+		return 0, err
 	}
+	defer fileDescriptor.release()
 	// 'offset' in Node.js's read is the offset in the buffer to start writing at,
 	// and 'position' is where to begin reading from in the file.
 	var readBuf blob.Blob
 	if position == nil {
-		readBuf, n, err = blob.Read(fileDescriptor.file, length)
+		var reader io.Reader = fileDescriptor.file
+		if file, ok := fileDescriptor.file.(nonBlockingFile); ok && fileDescriptor.nonBlocking() {
+			reader = nonBlockingReader{file}
+		}
+		fileDescriptor.offsetMu.Lock()
+		readBuf, n, err = blob.Read(reader, length)
+		fileDescriptor.offsetMu.Unlock()
 	} else {
 		readerAt, ok := fileDescriptor.file.(io.ReaderAt)
 		if ok {
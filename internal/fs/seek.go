@@ -0,0 +1,16 @@
+package fs
+
+import (
+	"github.com/hack-pad/hackpadfs"
+)
+
+func (f *FileDescriptors) Seek(fd FID, offset int64, whence int) (int64, error) {
+	fileDescriptor, err := f.acquire(fd)
+	if err != nil {
+		return 0, err
+	}
+	defer fileDescriptor.release()
+	fileDescriptor.offsetMu.Lock()
+	defer fileDescriptor.offsetMu.Unlock()
+	return hackpadfs.SeekFile(fileDescriptor.file, offset, whence)
+}
@@ -0,0 +1,77 @@
+package fs
+
+import (
+	"os"
+	"sync"
+)
+
+var stdin = newInputStream()
+
+// inputStream is init's standard input, a pipe written by the host page.
+// Every process inheriting FID 0 from init reads from the same pipe.
+type inputStream struct {
+	pipe *pipeChan
+
+	mu        sync.Mutex
+	lastWrite <-chan struct{} // closed when the most recently queued write finishes
+	ended     bool
+}
+
+func newInputStream() *inputStream {
+	lastWrite := make(chan struct{})
+	close(lastWrite)
+	return &inputStream{
+		pipe:      newPipeChan(0, 0),
+		lastWrite: lastWrite,
+	}
+}
+
+// WriteStdin queues 'p' for init's standard input, then calls done once it's buffered or fails.
+// Writes are delivered in call order and never block the caller, so it's safe to call from JS.
+func WriteStdin(p []byte, done func(error)) {
+	stdin.enqueue(func() error {
+		_, err := stdin.pipe.Write(p)
+		return err
+	}, false, done)
+}
+
+// CloseStdin ends init's standard input after all queued writes, so readers see EOF
+func CloseStdin(done func(error)) {
+	stdin.enqueue(stdin.pipe.Close, true, done)
+}
+
+func (s *inputStream) enqueue(fn func() error, end bool, done func(error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		go done(os.ErrClosed)
+		return
+	}
+	s.ended = end
+	previous := s.lastWrite
+	next := make(chan struct{})
+	s.lastWrite = next
+	go func() {
+		<-previous
+		err := fn()
+		close(next)
+		done(err)
+	}()
+}
+
+func (s *inputStream) open() *stdinFile {
+	return &stdinFile{pipeReadOnly{&namedPipe{pipeChan: s.pipe, fid: 0}}}
+}
+
+type stdinFile struct {
+	pipeReadOnly
+}
+
+func (s *stdinFile) Name() string {
+	return "dev/stdin"
+}
+
+// Close leaves the pipe open for the host page, since every process opens its own reader for stdin
+func (s *stdinFile) Close() error {
+	return nil
+}
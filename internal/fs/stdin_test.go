@@ -0,0 +1,30 @@
+package fs
+
+import (
+	"testing"
+
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+func TestStdin(t *testing.T) {
+	f := newTestFileDescriptors(t)
+	errs := make(chan error, 2)
+	for _, data := range []string{"hello ", "world"} {
+		WriteStdin([]byte(data), func(err error) { errs <- err })
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const expected = "hello world"
+	buf := blob.NewBytesLength(len(expected))
+	n, err := f.Read(0, buf, 0, buf.Len(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf.Bytes()[:n]); got != expected {
+		t.Errorf("Expected writes in call order %q, got %q", expected, got)
+	}
+}
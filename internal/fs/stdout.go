@@ -2,80 +2,281 @@ package fs
 
 import (
 	"bytes"
+	"fmt"
+	"io"
+	"os"
 	"sync"
 	"time"
 
+	"github.com/hack-pad/hackpad/internal/common"
 	"github.com/hack-pad/hackpad/internal/log"
 	"github.com/hack-pad/hackpadfs"
+	"go.uber.org/atomic"
 )
 
 var (
-	stdout hackpadfs.File = &bufferedLogger{name: "dev/stdout", printFn: log.Print}
-	stderr hackpadfs.File = &bufferedLogger{name: "dev/stderr", printFn: log.Error}
+	stdout = newOutputStream("dev/stdout", NewConsoleSink(log.Print))
+	stderr = newOutputStream("dev/stderr", NewConsoleSink(log.Error))
 )
 
-type bufferedLogger struct {
-	unimplementedFile
+// OutputSink receives output written to the init process's standard streams.
+// Output usually arrives in whole lines, but long lines, idle partial lines, and a process's exit flush early.
+type OutputSink interface {
+	WriteOutput(pid common.PID, p []byte) error
+}
+
+// OutputSinkFunc adapts a function into an OutputSink
+type OutputSinkFunc func(pid common.PID, p []byte) error
 
-	name      string
-	printFn   func(...interface{}) int
-	mu        sync.Mutex
-	buf       bytes.Buffer
-	timerOnce sync.Once
+func (fn OutputSinkFunc) WriteOutput(pid common.PID, p []byte) error {
+	return fn(pid, p)
 }
 
-func (b *bufferedLogger) flush() {
-	if b.buf.Len() == 0 {
-		return
+// NewConsoleSink returns a sink printing with 'printFn', like log.Print. This is the default sink.
+func NewConsoleSink(printFn func(...interface{}) int) OutputSink {
+	return OutputSinkFunc(func(_ common.PID, p []byte) error {
+		printFn(string(p))
+		return nil
+	})
+}
+
+type fileSink struct {
+	file hackpadfs.File
+}
+
+// NewFileSink returns a sink appending to the file at 'absPath'. The file is closed when the sink is replaced.
+func NewFileSink(absPath string) (OutputSink, error) {
+	file, err := hackpadfs.OpenFile(filesystem, absPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := hackpadfs.SeekFile(file, 0, io.SeekEnd); err != nil {
+		_ = file.Close()
+		return nil, err
 	}
+	return &fileSink{file: file}, nil
+}
 
-	const maxBufLen = 4096
+func (s *fileSink) WriteOutput(_ common.PID, p []byte) error {
+	_, err := hackpadfs.WriteFile(s.file, p)
+	return err
+}
 
-	b.mu.Lock()
-	i := bytes.LastIndexByte(b.buf.Bytes(), '\n')
-	var buf []byte
-	if i == -1 || b.buf.Len() > maxBufLen {
-		buf = b.buf.Bytes()
-		b.buf.Reset()
-	} else {
-		i++ // include newline char if present
-		buf = make([]byte, i)
-		n, _ := b.buf.Read(buf) // at time of writing, only io.EOF can be returned -- which we don't need
-		buf = buf[:n]
+func (s *fileSink) Close() error {
+	return s.file.Close()
+}
+
+// SetStdout routes the init process's standard output to 'sink'. If prefixPID is set, each line is prefixed with its writer's PID.
+func SetStdout(sink OutputSink, prefixPID bool) {
+	stdout.setSink(sink, prefixPID)
+}
+
+// SetStderr routes the init process's standard error to 'sink'. If prefixPID is set, each line is prefixed with its writer's PID.
+func SetStderr(sink OutputSink, prefixPID bool) {
+	stderr.setSink(sink, prefixPID)
+}
+
+// flushOutput emits any partial lines written by 'pid', i.e. when it exits
+func flushOutput(pid common.PID) {
+	stdout.flush(pid)
+	stderr.flush(pid)
+}
+
+// outputStream multiplexes writes from many processes into one sink, buffering each process's partial line separately
+type outputStream struct {
+	name string
+
+	mu         sync.Mutex
+	sink       OutputSink
+	prefixPID  bool
+	pending    map[common.PID][]byte
+	flushTimer *time.Timer
+}
+
+func newOutputStream(name string, sink OutputSink) *outputStream {
+	return &outputStream{
+		name:    name,
+		sink:    sink,
+		pending: make(map[common.PID][]byte),
 	}
-	b.mu.Unlock()
-	if len(buf) != 0 {
-		b.printFn(string(buf))
+}
+
+func (s *outputStream) setSink(sink OutputSink, prefixPID bool) {
+	s.mu.Lock()
+	outputs := s.takeAllPending()
+	previous := s.sink
+	s.sink = sink
+	s.prefixPID = prefixPID
+	s.mu.Unlock()
+
+	emit(outputs...)
+	if closer, ok := previous.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Errorf("Failed to close %s sink: %s", s.name, err.Error())
+		}
 	}
 }
 
-func (b *bufferedLogger) Print(s string) int {
-	n, _ := b.Write([]byte(s))
-	return n
+func (s *outputStream) write(pid common.PID, p []byte) {
+	const maxLineLen = 4096
+
+	s.mu.Lock()
+	buf := append(s.pending[pid], p...)
+	var out []byte
+	if i := bytes.LastIndexByte(buf, '\n'); len(buf) > maxLineLen {
+		out, buf = buf, nil
+	} else if i != -1 {
+		i++ // include newline char
+		out, buf = buf[:i], append([]byte(nil), buf[i:]...)
+	}
+	if len(buf) == 0 {
+		delete(s.pending, pid)
+	} else {
+		s.pending[pid] = buf
+		s.scheduleFlush()
+	}
+	output := s.newOutput(pid, out)
+	s.mu.Unlock()
+	emit(output)
 }
 
-func (b *bufferedLogger) Write(p []byte) (n int, err error) {
-	b.timerOnce.Do(func() {
-		const waitTime = time.Second / 2
-		go func() {
-			ticker := time.NewTicker(waitTime)
-			for range ticker.C {
-				b.flush()
-			}
-		}()
+// scheduleFlush emits idle partial lines, like prompts, after a short wait. Requires s.mu.
+func (s *outputStream) scheduleFlush() {
+	const waitTime = time.Second / 2
+	if s.flushTimer != nil {
+		return
+	}
+	s.flushTimer = time.AfterFunc(waitTime, func() {
+		s.mu.Lock()
+		s.flushTimer = nil
+		outputs := s.takeAllPending()
+		s.mu.Unlock()
+		emit(outputs...)
 	})
+}
 
-	b.mu.Lock()
-	_, _ = b.buf.Write(p) // at time of writing, bytes.Buffer.Write cannot return an error
-	b.mu.Unlock()
-	return len(p), nil
+func (s *outputStream) flush(pid common.PID) {
+	s.mu.Lock()
+	output := s.takePending(pid)
+	s.mu.Unlock()
+	emit(output)
 }
 
-func (b *bufferedLogger) Name() string {
-	return b.name
+func (s *outputStream) flushAll() {
+	s.mu.Lock()
+	outputs := s.takeAllPending()
+	s.mu.Unlock()
+	emit(outputs...)
 }
 
-func (b *bufferedLogger) Close() error {
-	// TODO prevent writes and return os.ErrClosed
+// takeAllPending removes every process's partial line, returning them for emit. Requires s.mu.
+func (s *outputStream) takeAllPending() []output {
+	var outputs []output
+	for pid := range s.pending {
+		outputs = append(outputs, s.takePending(pid))
+	}
+	return outputs
+}
+
+// takePending removes the partial line written by 'pid', returning it for emit. Requires s.mu.
+func (s *outputStream) takePending(pid common.PID) output {
+	buf := s.pending[pid]
+	delete(s.pending, pid)
+	return s.newOutput(pid, buf)
+}
+
+// newOutput prepares 'p' for the current sink. Requires s.mu.
+func (s *outputStream) newOutput(pid common.PID, p []byte) output {
+	if len(p) > 0 && s.prefixPID {
+		p = prefixLines(pid, p)
+	}
+	return output{stream: s.name, sink: s.sink, pid: pid, p: p}
+}
+
+// output is a chunk of output bound for a sink. Sinks are called without holding the stream's lock, so they may write to the stream themselves.
+type output struct {
+	stream string
+	sink   OutputSink
+	pid    common.PID
+	p      []byte
+}
+
+// emit sends each output to its sink, falling back to the console if the sink fails
+func emit(outputs ...output) {
+	for _, o := range outputs {
+		if len(o.p) == 0 {
+			continue
+		}
+		if err := o.sink.WriteOutput(o.pid, o.p); err != nil {
+			log.Errorf("Failed writing to %s sink: %s\n%s", o.stream, err.Error(), o.p)
+		}
+	}
+}
+
+func prefixLines(pid common.PID, p []byte) []byte {
+	prefix := []byte(fmt.Sprintf("[%d] ", pid))
+	var buf bytes.Buffer
+	for len(p) > 0 {
+		line := p
+		if i := bytes.IndexByte(p, '\n'); i != -1 {
+			line = p[:i+1]
+		}
+		buf.Write(prefix)
+		buf.Write(line)
+		p = p[len(line):]
+	}
+	return buf.Bytes()
+}
+
+func (s *outputStream) open() hackpadfs.File {
+	return &outputFile{stream: s}
+}
+
+var _ processWriter = &outputFile{}
+
+// outputFile is one open handle on an output stream, like /dev/stdout
+type outputFile struct {
+	unimplementedFile
+
+	stream *outputStream
+	closed atomic.Bool
+}
+
+func (o *outputFile) Name() string {
+	return o.stream.name
+}
+
+// Write writes output without a known writer, so prefer writeFrom
+func (o *outputFile) Write(p []byte) (n int, err error) {
+	return o.writeFrom(0, p)
+}
+
+func (o *outputFile) writeFrom(pid common.PID, p []byte) (n int, err error) {
+	if o.closed.Load() {
+		return 0, os.ErrClosed
+	}
+	o.stream.write(pid, p)
+	return len(p), nil
+}
+
+func (o *outputFile) Close() error {
+	if !o.closed.CAS(false, true) {
+		return os.ErrClosed
+	}
+	o.stream.flushAll()
 	return nil
 }
+
+// processWriter is a file which attributes writes to the writing process, like the init process's standard streams
+type processWriter interface {
+	writeFrom(pid common.PID, p []byte) (n int, err error)
+}
+
+type processWriterAdapter struct {
+	processWriter
+	pid common.PID
+}
+
+func (p processWriterAdapter) Write(b []byte) (n int, err error) {
+	return p.writeFrom(p.pid, b)
+}
@@ -0,0 +1,86 @@
+package fs
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpad/internal/common"
+)
+
+func TestOutputStream(t *testing.T) {
+	var lines []string
+	stream := newOutputStream("dev/stdout", OutputSinkFunc(func(_ common.PID, p []byte) error {
+		lines = append(lines, string(p))
+		return nil
+	}))
+	stream.setSink(stream.sink, true)
+	file := stream.open().(*outputFile)
+
+	for _, write := range []struct {
+		pid  common.PID
+		data string
+	}{
+		{2, "hello "},
+		{3, "interleaved\n"},
+		{2, "world\nand "},
+	} {
+		if _, err := file.writeFrom(write.pid, []byte(write.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream.flush(2)
+	expected := []string{"[3] interleaved\n", "[2] hello world\n", "[2] and "}
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected lines %q, got %q", expected, lines)
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("Expected lines %q, got %q", expected, lines)
+			break
+		}
+	}
+
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Write([]byte("hi")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Expected os.ErrClosed after close, got: %v", err)
+	}
+}
+
+func TestOutputStreamReentrantSink(t *testing.T) {
+	var stream *outputStream
+	var lines []string
+	stream = newOutputStream("dev/stdout", OutputSinkFunc(func(pid common.PID, p []byte) error {
+		lines = append(lines, string(p))
+		if pid != 0 {
+			// sinks may log, which can write back to the same stream
+			stream.write(0, []byte("logged\n"))
+		}
+		return nil
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		stream.write(2, []byte("line\npartial"))
+		stream.flush(2)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out writing from a sink, the stream's lock may be held")
+	}
+	expected := []string{"line\n", "logged\n", "partial", "logged\n"}
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected lines %q, got %q", expected, lines)
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("Expected lines %q, got %q", expected, lines)
+			break
+		}
+	}
+}
@@ -68,22 +68,36 @@ func (w *wasmCacheFs) readFile(path string) (blob.Blob, error) {
 	return buf, err
 }
 
-func (w *wasmCacheFs) WasmInstance(path string, importObject js.Value) (js.Value, error) {
+// module returns the compiled Module for 'path' if cached, otherwise the module's bytes
+func (w *wasmCacheFs) module(path string) (module js.Value, compiled bool, err error) {
 	log.Debug("Checking wasm instance cache")
 	module, memCacheHit := w.memCache[path]
 	if memCacheHit {
 		log.Debug("memCache hit: ", path)
-	} else {
-		log.Debug("memCache miss: ", path)
-		moduleBlob, err := w.readFile(path)
-		if err != nil {
-			log.Debug("reading file failed: ", path)
-			return js.Value{}, err
-		}
-		module = idbblob.FromBlob(moduleBlob).JSValue()
-		if !module.Truthy() {
-			log.Debug("fs miss: ", path, module.Length())
-		}
+		return module, true, nil
+	}
+	log.Debug("memCache miss: ", path)
+	moduleBlob, err := w.readFile(path)
+	if err != nil {
+		log.Debug("reading file failed: ", path)
+		return js.Value{}, false, err
+	}
+	module = idbblob.FromBlob(moduleBlob).JSValue()
+	if !module.Truthy() {
+		log.Debug("fs miss: ", path, module.Length())
+	}
+	return module, false, nil
+}
+
+func (w *wasmCacheFs) WasmModule(path string) (js.Value, error) {
+	module, _, err := w.module(path)
+	return module, err
+}
+
+func (w *wasmCacheFs) WasmInstance(path string, importObject js.Value) (js.Value, error) {
+	module, memCacheHit, err := w.module(path)
+	if err != nil {
+		return js.Value{}, err
 	}
 
 	instantiatePromise := promise.From(jsWasm.Call("instantiate", module, importObject))
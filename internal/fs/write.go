@@ -3,27 +3,37 @@ package fs
 import (
 	"io"
 
-	"github.com/hack-pad/hackpad/internal/interop"
 	"github.com/hack-pad/hackpadfs"
 	"github.com/hack-pad/hackpadfs/keyvalue/blob"
 )
 
 func (f *FileDescriptors) Write(fd FID, buffer blob.Blob, offset, length int, position *int64) (n int, err error) {
-	fileDescriptor := f.files[fd]
-	if fileDescriptor == nil {
-		return 0, interop.BadFileNumber(fd)
+	fileDescriptor, err := f.acquire(fd)
+	if err != nil {
+		return 0, err
 	}
+	defer fileDescriptor.release()
 	file, ok := fileDescriptor.file.(io.Writer)
 	if !ok {
 		return 0, hackpadfs.ErrNotImplemented
 	}
+	if nonBlockingFile, ok := fileDescriptor.file.(nonBlockingFile); ok && fileDescriptor.nonBlocking() {
+		file = nonBlockingWriter{nonBlockingFile}
+	}
+	if processFile, ok := fileDescriptor.file.(processWriter); ok {
+		file = processWriterAdapter{processWriter: processFile, pid: f.parentPID}
+	}
 	// 'offset' in Node.js's read is the offset in the buffer to start writing at,
 	// and 'position' is where to begin reading from in the file.
+	fileDescriptor.offsetMu.Lock()
+	defer fileDescriptor.offsetMu.Unlock()
 	if position != nil {
 		_, err := hackpadfs.SeekFile(fileDescriptor.file, *position, io.SeekStart)
 		if err != nil {
 			return 0, err
 		}
+	} else if err := fileDescriptor.seekAppend(); err != nil {
+		return 0, err
 	}
 	dataToCopy, err := blob.View(buffer, int64(offset), int64(offset+length))
 	if err != nil {
@@ -0,0 +1,157 @@
+package hush
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+const invalidAliasChars = " \t\n/$`='\"\\|&;()<>"
+
+var (
+	aliasesMu sync.RWMutex
+	aliases   = map[string]string{}
+)
+
+func init() {
+	builtins["alias"] = func(term console, args ...string) error {
+		return Alias(term.Stdout(), args...)
+	}
+	builtins["unalias"] = func(term console, args ...string) error {
+		return Unalias(args...)
+	}
+}
+
+// Alias runs the 'alias' builtin. Each NAME=VALUE argument defines an alias and each NAME prints one. With no arguments, prints every alias.
+func Alias(out io.Writer, args ...string) error {
+	aliasesMu.Lock()
+	defer aliasesMu.Unlock()
+	if len(args) == 0 {
+		names := make([]string, 0, len(aliases))
+		for name := range aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			printAlias(out, name, aliases[name])
+		}
+		return nil
+	}
+
+	var notFound []string
+	for _, arg := range args {
+		name, value, isDefinition := strings.Cut(arg, "=")
+		if name == "" || strings.ContainsAny(name, invalidAliasChars) {
+			return errors.Errorf("alias: invalid alias name: %q", name)
+		}
+		if isDefinition {
+			aliases[name] = value
+			continue
+		}
+		value, ok := aliases[name]
+		if !ok {
+			notFound = append(notFound, name)
+			continue
+		}
+		printAlias(out, name, value)
+	}
+	if len(notFound) > 0 {
+		return errors.Errorf("alias: not found: %s", strings.Join(notFound, " "))
+	}
+	return nil
+}
+
+func printAlias(out io.Writer, name, value string) {
+	fmt.Fprintf(out, "alias %s='%s'\n", name, strings.ReplaceAll(value, "'", `'\''`))
+}
+
+// Unalias runs the 'unalias' builtin, which removes the named aliases. 'unalias -a' removes all of them.
+func Unalias(args ...string) error {
+	aliasesMu.Lock()
+	defer aliasesMu.Unlock()
+	if len(args) == 0 {
+		return errors.New("unalias: usage: unalias [-a] NAME...")
+	}
+	if len(args) == 1 && args[0] == "-a" {
+		aliases = map[string]string{}
+		return nil
+	}
+	var notFound []string
+	for _, name := range args {
+		if _, ok := aliases[name]; !ok {
+			notFound = append(notFound, name)
+			continue
+		}
+		delete(aliases, name)
+	}
+	if len(notFound) > 0 {
+		return errors.Errorf("unalias: not found: %s", strings.Join(notFound, " "))
+	}
+	return nil
+}
+
+// expandAliases replaces each unquoted command name in 'line' that's an alias with its value.
+// Like other shells, an alias's value is expanded again, except for aliases already expanded along the way, so 'alias ls="ls -l"' doesn't recurse.
+// Lines which don't parse are returned as-is, leaving the parser to report the error when the line runs.
+func expandAliases(line string) string {
+	aliasesMu.RLock()
+	defer aliasesMu.RUnlock()
+	return expandAliasesExcept(line, nil)
+}
+
+func expandAliasesExcept(line string, expanded map[string]bool) string {
+	if len(aliases) == 0 {
+		return line
+	}
+	file, err := syntax.NewParser().Parse(strings.NewReader(line), "")
+	if err != nil {
+		return line
+	}
+
+	type replacement struct {
+		start, end uint
+		name       string
+	}
+	var replacements []replacement
+	syntax.Walk(file, func(node syntax.Node) bool {
+		call, ok := node.(*syntax.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		name := call.Args[0]
+		if len(name.Parts) != 1 {
+			return true
+		}
+		lit, ok := name.Parts[0].(*syntax.Lit)
+		if !ok || expanded[lit.Value] {
+			return true
+		}
+		if _, ok := aliases[lit.Value]; ok {
+			replacements = append(replacements, replacement{
+				start: name.Pos().Offset(),
+				end:   name.End().Offset(),
+				name:  lit.Value,
+			})
+		}
+		return true
+	})
+
+	// replace from the end, so earlier offsets stay valid
+	sort.Slice(replacements, func(a, b int) bool {
+		return replacements[a].start > replacements[b].start
+	})
+	for _, r := range replacements {
+		nextExpanded := map[string]bool{r.name: true}
+		for name := range expanded {
+			nextExpanded[name] = true
+		}
+		value := expandAliasesExcept(aliases[r.name], nextExpanded)
+		line = line[:r.start] + value + line[r.end:]
+	}
+	return line
+}
@@ -0,0 +1,85 @@
+package hush
+
+import (
+	"bytes"
+	"testing"
+)
+
+func setAliases(t *testing.T, defs ...string) {
+	t.Helper()
+	if err := Unalias("-a"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = Unalias("-a")
+	})
+	if err := Alias(nil, defs...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExpandAliases(t *testing.T) {
+	setAliases(t,
+		"ll=ls -l",
+		"ls=ls --color",
+		"la=ll -a",
+		"up=cd .. && ls",
+		"loop=loop again",
+	)
+	for _, tc := range []struct {
+		line   string
+		expect string
+	}{
+		{"ll", "ls --color -l"},
+		{"ll dir", "ls --color -l dir"},
+		{"la", "ls --color -l -a"},
+		{"echo ll", "echo ll"},
+		{"'ll'", "'ll'"},
+		{"FOO=bar ll", "FOO=bar ls --color -l"},
+		{"ll | ll", "ls --color -l | ls --color -l"},
+		{"up; ll", "cd .. && ls --color; ls --color -l"},
+		{"loop", "loop again"},
+		{"ls", "ls --color"},
+		{"if ll", "if ll"},
+	} {
+		tc := tc
+		t.Run(tc.line, func(t *testing.T) {
+			if line := expandAliases(tc.line); line != tc.expect {
+				t.Errorf("expandAliases(%q) = %q, expected %q", tc.line, line, tc.expect)
+			}
+		})
+	}
+}
+
+func TestAlias(t *testing.T) {
+	setAliases(t, "b=two words", "a=it's")
+
+	var out bytes.Buffer
+	if err := Alias(&out); err != nil {
+		t.Fatal(err)
+	}
+	if expect := "alias a='it'\\''s'\nalias b='two words'\n"; out.String() != expect {
+		t.Errorf("Alias() = %q, expected %q", out.String(), expect)
+	}
+
+	out.Reset()
+	if err := Alias(&out, "b", "missing"); err == nil {
+		t.Error("Expected not found error")
+	}
+	if expect := "alias b='two words'\n"; out.String() != expect {
+		t.Errorf("Alias(b) = %q, expected %q", out.String(), expect)
+	}
+	if err := Alias(nil, "a b=c"); err == nil {
+		t.Error("Expected invalid name error")
+	}
+
+	if err := Unalias("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Unalias("a"); err == nil {
+		t.Error("Expected not found error")
+	}
+	if expandAliases("a") != "a" {
+		t.Error("Expected alias to be removed")
+	}
+}
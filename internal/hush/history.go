@@ -0,0 +1,127 @@
+package hush
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// History saves commands across shell sessions
+type History interface {
+	// Commands returns the saved commands, oldest first
+	Commands() ([]string, error)
+	// Push saves a command after it runs
+	Push(command string) error
+}
+
+const fileHistoryLimit = 100
+
+// fileHistory loads up to 100 commands from ~/.history and appends new ones to it
+type fileHistory struct {
+	path string
+}
+
+func newFileHistory() *fileHistory {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		panic(err)
+	}
+	return &fileHistory{path: filepath.Join(home, ".history")}
+}
+
+func (f *fileHistory) Commands() ([]string, error) {
+	historyFile, err := os.Open(f.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return nil, err
+	}
+	defer historyFile.Close()
+	scanner := bufio.NewScanner(historyFile)
+	var history []string
+	for i := 0; i < fileHistoryLimit && scanner.Scan(); i++ {
+		history = append(history, scanner.Text())
+	}
+	return history, scanner.Err()
+}
+
+func (f *fileHistory) Push(command string) error {
+	historyFile, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer historyFile.Close()
+	_, err = historyFile.WriteString(command + "\n")
+	return err
+}
+
+type history struct {
+	lastIndex int
+	lines     []string
+	store     History
+}
+
+func newHistory(store History) (*history, error) {
+	lines, err := store.Commands()
+	return &history{
+		lines: lines,
+		store: store,
+	}, err
+}
+
+func (h *history) Push(command string) error {
+	command = strings.TrimSpace(command)
+	h.lastIndex = 0
+	if command == "" || command == h.mostRecentCommand() {
+		return nil
+	}
+
+	h.lines = append(h.lines, command)
+	return h.store.Push(command)
+}
+
+func (h *history) mostRecentCommand() string {
+	if len(h.lines) > 0 {
+		return h.lines[len(h.lines)-1]
+	}
+	return ""
+}
+
+func (h *history) Previous() (command string, ok bool) {
+	if h.lastIndex < len(h.lines) {
+		h.lastIndex++
+		return h.lines[len(h.lines)-h.lastIndex], true
+	}
+	return "", false
+}
+
+func (h *history) Next() (command string, ok bool) {
+	if h.lastIndex > 1 {
+		h.lastIndex--
+		return h.lines[len(h.lines)-h.lastIndex], true
+	}
+	ok = h.lastIndex == 1
+	h.lastIndex = 0
+	return "", ok
+}
+
+// Search returns the index of the most recent command containing 'query', starting at index 'from' and moving back in time
+func (h *history) Search(query string, from int) (index int, ok bool) {
+	if from >= len(h.lines) {
+		from = len(h.lines) - 1
+	}
+	for i := from; i >= 0; i-- {
+		if strings.Contains(h.lines[i], query) {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// Get returns the command at 'index' and resumes Previous and Next from there
+func (h *history) Get(index int) string {
+	h.lastIndex = len(h.lines) - index
+	return h.lines[index]
+}
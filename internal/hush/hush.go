@@ -0,0 +1,77 @@
+// Package hush is the interactive shell behind cmd/sh.
+// It started as a copy of github.com/hack-pad/hush v0.1.0, extended with aliases, ^R history search, and pluggable history and builtins.
+package hush
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Options configure a hush shell
+type Options struct {
+	// History saves commands across sessions. Defaults to ~/.history
+	History History
+	// Builtins adds commands which run inside the shell instead of in a new process
+	Builtins map[string]Builtin
+}
+
+// Builtin runs a command inside the shell
+type Builtin func(stdin io.Reader, stdout, stderr io.Writer, args ...string) error
+
+// Run runs the hush shell
+func Run() int {
+	return RunOptions(Options{})
+}
+
+// RunOptions runs the hush shell with the given options
+func RunOptions(options Options) int {
+	cancel, err := ttySetup()
+	if err != nil {
+		panic(err)
+	}
+	defer cancel()
+	for name, builtin := range options.Builtins {
+		builtins[name] = wrapBuiltin(builtin)
+	}
+	return run(os.Stdin, os.Stdout, os.Stderr, os.Args, options.History)
+}
+
+func wrapBuiltin(builtin Builtin) builtinFunc {
+	return func(term console, args ...string) error {
+		return builtin(getconsoleStdin(term), term.Stdout(), term.Stderr(), args...)
+	}
+}
+
+func run(in io.Reader, out, outErr io.Writer, args []string, history History) int {
+	set := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	command := set.String("c", "", "Read and execute commands from the given string value.")
+	err := set.Parse(args[1:])
+	if err != nil {
+		fmt.Fprintln(outErr, err)
+		return 2
+	}
+
+	var reader io.RuneReader
+	if *command != "" {
+		reader = newRuneReader(strings.NewReader(*command))
+	} else {
+		reader = newRuneReader(in)
+	}
+	out, err = newCarriageReturnWriter(out)
+	if err != nil {
+		panic(err)
+	}
+	outErr, err = newCarriageReturnWriter(outErr)
+	if err != nil {
+		panic(err)
+	}
+	if history == nil {
+		history = newFileHistory()
+	}
+	term := newTerminal(out, outErr, history)
+
+	return term.ReadEvalPrintLoop(reader)
+}
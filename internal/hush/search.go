@@ -0,0 +1,102 @@
+package hush
+
+// reverseSearch is the state of an incremental search back through history, started with ^R
+type reverseSearch struct {
+	query  []rune
+	match  int  // index of the shown history command, or -1 if none
+	failed bool // true if the query doesn't match anything older than the shown command
+	// line and cursor before the search started, restored if it's canceled
+	line   []rune
+	cursor int
+}
+
+func (t *terminal) startSearch() {
+	t.search = &reverseSearch{
+		match:  -1,
+		line:   t.line,
+		cursor: t.cursor,
+	}
+	t.drawSearch()
+}
+
+// ReadSearch handles 'r' while searching. Returns false if the search ended and 'r' should be handled as regular input.
+//
+// Typing searches for older commands containing the query, ^R moves to the next older match, and backspace widens the search again.
+// ^C or ^G cancel the search. Enter runs the match, and any other control key accepts the match for editing.
+func (t *terminal) ReadSearch(r rune) bool {
+	search := t.search
+	switch {
+	case r == controlReverseSearch:
+		if len(search.query) > 0 {
+			from := len(t.history.lines) - 1
+			if search.match >= 0 {
+				from = search.match - 1
+			}
+			t.searchFrom(from)
+		}
+	case r == controlBackspace:
+		if len(search.query) > 0 {
+			search.query = search.query[:len(search.query)-1]
+		}
+		search.match = -1
+		search.failed = false
+		if len(search.query) > 0 {
+			t.searchFrom(len(t.history.lines) - 1)
+		}
+	case r == controlSigTStop, r == controlCancel:
+		t.line = search.line
+		t.cursor = search.cursor
+		t.endSearch()
+		return true
+	case r < ' ':
+		if search.match >= 0 {
+			t.line = []rune(t.history.Get(search.match))
+			t.cursor = len(t.line)
+		}
+		t.endSearch()
+		return false
+	default:
+		search.query = append(search.query, r)
+		from := len(t.history.lines) - 1
+		if search.match >= 0 {
+			from = search.match
+		}
+		t.searchFrom(from)
+	}
+	t.drawSearch()
+	return true
+}
+
+func (t *terminal) searchFrom(from int) {
+	index, ok := t.history.Search(string(t.search.query), from)
+	t.search.failed = !ok
+	if ok {
+		t.search.match = index
+	}
+}
+
+func (t *terminal) drawSearch() {
+	status := "reverse-i-search"
+	if t.search.failed {
+		status = "failing " + status
+	}
+	var match string
+	if t.search.match >= 0 {
+		match = t.history.lines[t.search.match]
+	}
+	t.clearLine()
+	t.Printf("(%s)`%s': %s", status, string(t.search.query), match)
+}
+
+// endSearch redraws the prompt and line in place of the search
+func (t *terminal) endSearch() {
+	t.search = nil
+	t.clearLine()
+	t.Print(prompt(t))
+	t.Print(string(t.line))
+	t.CursorLeftN(len(t.line) - t.cursor)
+}
+
+func (t *terminal) clearLine() {
+	t.Printf("\r%c%cK", escapeCSI, escapeLBracket)
+}
@@ -0,0 +1,91 @@
+package hush
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+type memoryHistory struct {
+	commands []string
+}
+
+func (m *memoryHistory) Commands() ([]string, error) {
+	return append([]string(nil), m.commands...), nil
+}
+
+func (m *memoryHistory) Push(command string) error {
+	m.commands = append(m.commands, command)
+	return nil
+}
+
+func TestReverseSearch(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		description string
+		input       string
+		expectLine  string
+	}{
+		{"most recent match", "\x12go\x05", "go test ./..."},
+		{"next match", "\x12go\x12\x05", "go build"},
+		{"no older match keeps last match", "\x12go\x12\x12\x05", "go build"},
+		{"backspace searches from most recent", "\x12go\x12b\x7f\x05", "go test ./..."},
+		{"no match keeps line", "ls\x12zzz\x05", "ls"},
+		{"cancel restores line", "partial\x12go\x03", "partial"},
+		{"cancel with ^G", "partial\x12go\x07", "partial"},
+		{"history resumes from match", "\x12ls\x1b[A", "go build"},
+		{"edit after accepting", "\x12echo\x05!", "echo hi!"},
+	} {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			var out bytes.Buffer
+			term := newTerminal(&out, &out, &memoryHistory{
+				commands: []string{"go build", "ls", "go test ./...", "echo hi"},
+			})
+			reader := strings.NewReader(tc.input)
+			for {
+				err := term.ReadEvalPrint(reader)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if term.search != nil {
+				t.Error("Search should have ended")
+			}
+			if line := string(term.line); line != tc.expectLine {
+				t.Errorf("Line = %q, expected %q", line, tc.expectLine)
+			}
+			if term.cursor > len(term.line) {
+				t.Errorf("Cursor %d is past the end of the line", term.cursor)
+			}
+		})
+	}
+}
+
+func TestReverseSearchDisplay(t *testing.T) {
+	t.Parallel()
+	var out bytes.Buffer
+	term := newTerminal(&out, &out, &memoryHistory{
+		commands: []string{"go build", "ls"},
+	})
+	for _, r := range "\x12go" {
+		if err := term.ReadEvalPrint(strings.NewReader(string(r))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if expect := "(reverse-i-search)`go': go build"; !strings.HasSuffix(out.String(), expect) {
+		t.Errorf("Expected output to end with %q, got: %q", expect, out.String())
+	}
+	out.Reset()
+	if err := term.ReadEvalPrint(strings.NewReader("x")); err != nil {
+		t.Fatal(err)
+	}
+	if expect := "(failing reverse-i-search)`gox': go build"; !strings.HasSuffix(out.String(), expect) {
+		t.Errorf("Expected output to end with %q, got: %q", expect, out.String())
+	}
+}
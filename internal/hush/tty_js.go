@@ -0,0 +1,10 @@
+//go:build js
+// +build js
+
+package hush
+
+import "context"
+
+func ttySetup() (context.CancelFunc, error) {
+	return func() {}, nil
+}
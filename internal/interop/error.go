@@ -45,6 +45,11 @@ func (e *interopErr) Code() string {
 	return e.code
 }
 
+// ErrorCode returns the errno name for err, i.e. "ENOENT"
+func ErrorCode(err error) string {
+	return mapToErrNo(err, err.Error())
+}
+
 // errno names pulled from syscall/tables_js.go
 func mapToErrNo(err error, debugMessage string) string {
 	if err, ok := err.(Error); ok {
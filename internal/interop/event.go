@@ -11,13 +11,14 @@ import (
 type EventCallback func(event Event, args ...interface{})
 
 type EventTarget interface {
-	Listen(eventName string, callback EventCallback)
+	// Listen calls 'callback' for each 'eventName' event until remove is called
+	Listen(eventName string, callback EventCallback) (remove func())
 	Emit(event Event, args ...interface{})
 }
 
 func NewEventTarget() EventTarget {
 	return &eventTarget{
-		listeners: make(map[string][]EventCallback),
+		listeners: make(map[string][]listener),
 	}
 }
 
@@ -28,13 +29,39 @@ type Event struct {
 
 type eventTarget struct {
 	mu        sync.Mutex
-	listeners map[string][]EventCallback
+	lastID    uint64
+	listeners map[string][]listener
 }
 
-func (e *eventTarget) Listen(eventName string, callback EventCallback) {
+type listener struct {
+	id       uint64
+	callback EventCallback
+}
+
+func (e *eventTarget) Listen(eventName string, callback EventCallback) (remove func()) {
 	e.mu.Lock()
-	e.listeners[eventName] = append(e.listeners[eventName], callback)
+	e.lastID++
+	id := e.lastID
+	e.listeners[eventName] = append(e.listeners[eventName], listener{id: id, callback: callback})
 	e.mu.Unlock()
+	return func() {
+		e.remove(eventName, id)
+	}
+}
+
+func (e *eventTarget) remove(eventName string, id uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	listeners := e.listeners[eventName]
+	for i, l := range listeners {
+		if l.id == id {
+			// copy, so in-progress Emits keep their listeners
+			remaining := make([]listener, 0, len(listeners)-1)
+			remaining = append(remaining, listeners[:i]...)
+			e.listeners[eventName] = append(remaining, listeners[i+1:]...)
+			return
+		}
+	}
 }
 
 func (e *eventTarget) Emit(event Event, args ...interface{}) {
@@ -42,6 +69,6 @@ func (e *eventTarget) Emit(event Event, args ...interface{}) {
 	listeners := e.listeners[event.Type]
 	e.mu.Unlock()
 	for _, l := range listeners {
-		l(event, args...)
+		l.callback(event, args...)
 	}
 }
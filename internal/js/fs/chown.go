@@ -6,15 +6,16 @@ package fs
 import (
 	"syscall/js"
 
+	"github.com/hack-pad/hackpad/internal/process"
 	"github.com/pkg/errors"
 )
 
-func chown(args []js.Value) ([]interface{}, error) {
-	_, err := chownSync(args)
+func chown(p process.Process, args []js.Value) ([]interface{}, error) {
+	_, err := chownSync(p, args)
 	return nil, err
 }
 
-func chownSync(args []js.Value) (interface{}, error) {
+func chownSync(p process.Process, args []js.Value) (interface{}, error) {
 	if len(args) != 3 {
 		return nil, errors.Errorf("Invalid number of args, expected 3: %v", args)
 	}
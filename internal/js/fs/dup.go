@@ -0,0 +1,56 @@
+//go:build js
+// +build js
+
+package fs
+
+import (
+	"syscall/js"
+
+	"github.com/hack-pad/hackpad/internal/fs"
+	"github.com/hack-pad/hackpad/internal/process"
+	"github.com/pkg/errors"
+)
+
+func dup(p process.Process, args []js.Value) ([]interface{}, error) {
+	fd, err := dupSync(p, args)
+	return []interface{}{fd}, err
+}
+
+func dupSync(p process.Process, args []js.Value) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, errors.Errorf("Invalid number of args, expected 1: %v", args)
+	}
+	fd, err := p.Files().Dup(fs.FID(args[0].Int()))
+	return fd.JSValue(), err
+}
+
+func dup2(p process.Process, args []js.Value) ([]interface{}, error) {
+	fd, err := dup2Sync(p, args)
+	return []interface{}{fd}, err
+}
+
+func dup2Sync(p process.Process, args []js.Value) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, errors.Errorf("Invalid number of args, expected 2: %v", args)
+	}
+	fd, err := p.Files().Dup2(fs.FID(args[0].Int()), fs.FID(args[1].Int()))
+	return fd.JSValue(), err
+}
+
+func fcntl(p process.Process, args []js.Value) ([]interface{}, error) {
+	ret, err := fcntlSync(p, args)
+	return []interface{}{ret}, err
+}
+
+func fcntlSync(p process.Process, args []js.Value) (interface{}, error) {
+	if len(args) < 2 {
+		return nil, errors.Errorf("Invalid number of args, expected fd, cmd, and optional arg: %v", args)
+	}
+	fd := fs.FID(args[0].Int())
+	cmd := args[1].Int()
+	arg := 0
+	if len(args) >= 3 && args[2].Type() == js.TypeNumber {
+		arg = args[2].Int()
+	}
+	return p.Files().Fcntl(fd, cmd, arg)
+}
@@ -12,18 +12,17 @@ import (
 	"github.com/pkg/errors"
 )
 
-func fchmod(args []js.Value) ([]interface{}, error) {
-	_, err := fchmodSync(args)
+func fchmod(p process.Process, args []js.Value) ([]interface{}, error) {
+	_, err := fchmodSync(p, args)
 	return nil, err
 }
 
-func fchmodSync(args []js.Value) (interface{}, error) {
+func fchmodSync(p process.Process, args []js.Value) (interface{}, error) {
 	if len(args) != 2 {
 		return nil, errors.Errorf("Invalid number of args, expected 2: %v", args)
 	}
 
 	fid := common.FID(args[0].Int())
 	mode := os.FileMode(args[1].Int())
-	p := process.Current()
 	return nil, p.Files().Fchmod(fid, mode)
 }
@@ -13,12 +13,12 @@ import (
 	"github.com/pkg/errors"
 )
 
-func flock(args []js.Value) ([]interface{}, error) {
-	_, err := flockSync(args)
+func flock(p process.Process, args []js.Value) ([]interface{}, error) {
+	_, err := flockSync(p, args)
 	return nil, err
 }
 
-func flockSync(args []js.Value) (interface{}, error) {
+func flockSync(p process.Process, args []js.Value) (interface{}, error) {
 	if len(args) != 2 {
 		return nil, errors.Errorf("Invalid number of args, expected 2: %v", args)
 	}
@@ -35,10 +35,9 @@ func flockSync(args []js.Value) (interface{}, error) {
 		action = fs.Unlock
 	}
 
-	return nil, Flock(fid, action, shouldLock)
+	return nil, Flock(p, fid, action, shouldLock)
 }
 
-func Flock(fid common.FID, action fs.LockAction, shouldLock bool) error {
-	p := process.Current()
+func Flock(p process.Process, fid common.FID, action fs.LockAction, shouldLock bool) error {
 	return p.Files().Flock(fid, action)
 }
@@ -26,9 +26,16 @@ symlink(path, link, callback) { callback(enosys()); },
 truncate(path, length, callback) { callback(enosys()); },
 */
 
+var jsObject = js.Global().Get("Object")
+
+type (
+	syncFunc     = func(p process.Process, args []js.Value) (interface{}, error)
+	callbackFunc = func(p process.Process, args []js.Value) ([]interface{}, error)
+)
+
 func Init() {
-	fs := js.Global().Get("fs")
-	constants := fs.Get("constants")
+	jsFS := js.Global().Get("fs")
+	constants := jsFS.Get("constants")
 	constants.Set("O_RDONLY", syscall.O_RDONLY)
 	constants.Set("O_WRONLY", syscall.O_WRONLY)
 	constants.Set("O_RDWR", syscall.O_RDWR)
@@ -36,52 +43,55 @@ func Init() {
 	constants.Set("O_TRUNC", syscall.O_TRUNC)
 	constants.Set("O_APPEND", syscall.O_APPEND)
 	constants.Set("O_EXCL", syscall.O_EXCL)
-	interop.SetFunc(fs, "chmod", chmod)
-	interop.SetFunc(fs, "chmodSync", chmodSync)
-	interop.SetFunc(fs, "chown", chown)
-	interop.SetFunc(fs, "chownSync", chownSync)
-	interop.SetFunc(fs, "close", closeFn)
-	interop.SetFunc(fs, "closeSync", closeSync)
-	interop.SetFunc(fs, "fchmod", fchmod)
-	interop.SetFunc(fs, "fchmodSync", fchmodSync)
-	interop.SetFunc(fs, "flock", flock)
-	interop.SetFunc(fs, "flockSync", flockSync)
-	interop.SetFunc(fs, "fstat", fstat)
-	interop.SetFunc(fs, "fstatSync", fstatSync)
-	interop.SetFunc(fs, "fsync", fsync)
-	interop.SetFunc(fs, "fsyncSync", fsyncSync)
-	interop.SetFunc(fs, "ftruncate", ftruncate)
-	interop.SetFunc(fs, "ftruncateSync", ftruncateSync)
-	interop.SetFunc(fs, "lstat", lstat)
-	interop.SetFunc(fs, "lstatSync", lstatSync)
-	interop.SetFunc(fs, "mkdir", mkdir)
-	interop.SetFunc(fs, "mkdirSync", mkdirSync)
-	interop.SetFunc(fs, "open", open)
-	interop.SetFunc(fs, "openSync", openSync)
-	interop.SetFunc(fs, "pipe", pipe)
-	interop.SetFunc(fs, "pipeSync", pipeSync)
-	interop.SetFunc(fs, "read", read)
-	interop.SetFunc(fs, "readSync", readSync)
-	interop.SetFunc(fs, "readdir", readdir)
-	interop.SetFunc(fs, "readdirSync", readdirSync)
-	interop.SetFunc(fs, "rename", rename)
-	interop.SetFunc(fs, "renameSync", renameSync)
-	interop.SetFunc(fs, "rmdir", rmdir)
-	interop.SetFunc(fs, "rmdirSync", rmdirSync)
-	interop.SetFunc(fs, "stat", stat)
-	interop.SetFunc(fs, "statSync", statSync)
-	interop.SetFunc(fs, "unlink", unlink)
-	interop.SetFunc(fs, "unlinkSync", unlinkSync)
-	interop.SetFunc(fs, "utimes", utimes)
-	interop.SetFunc(fs, "utimesSync", utimesSync)
-	interop.SetFunc(fs, "write", write)
-	interop.SetFunc(fs, "writeSync", writeSync)
+	constants.Set("O_NONBLOCK", fs.OpenNonBlock)
+	constants.Set("O_CLOEXEC", fs.OpenCloseOnExec)
+	constants.Set("F_DUPFD", fs.FcntlDupFD)
+	constants.Set("F_DUPFD_CLOEXEC", fs.FcntlDupFDCloseOnExec)
+	constants.Set("F_GETFD", fs.FcntlGetFD)
+	constants.Set("F_SETFD", fs.FcntlSetFD)
+	constants.Set("F_GETFL", fs.FcntlGetFL)
+	constants.Set("F_SETFL", fs.FcntlSetFL)
+	constants.Set("FD_CLOEXEC", fs.FDCloseOnExec)
+	constants.Set("POLLIN", int(fs.PollIn))
+	constants.Set("POLLOUT", int(fs.PollOut))
+	constants.Set("POLLERR", int(fs.PollErr))
+	constants.Set("POLLHUP", int(fs.PollHup))
+	constants.Set("POLLNVAL", int(fs.PollNval))
+	constants.Set("TCGETS", fs.IoctlGetTermios)
+	constants.Set("TCSETS", fs.IoctlSetTermios)
+	constants.Set("TCSETSW", fs.IoctlSetTermiosDrain)
+	constants.Set("TCSETSF", fs.IoctlSetTermiosFlush)
+	constants.Set("TIOCGWINSZ", fs.IoctlGetWindowSize)
+	constants.Set("TIOCSWINSZ", fs.IoctlSetWindowSize)
+	constants.Set("ICRNL", fs.TermiosMapCRToNL)
+	constants.Set("OPOST", fs.TermiosPostProcess)
+	constants.Set("ONLCR", fs.TermiosMapNLToCRNL)
+	constants.Set("ISIG", fs.TermiosSignals)
+	constants.Set("ICANON", fs.TermiosCanonical)
+	constants.Set("ECHO", fs.TermiosEcho)
+	constants.Set("ECHOE", fs.TermiosEchoErase)
+	constants.Set("ECHOCTL", fs.TermiosEchoControl)
+	constants.Set("VINTR", fs.CharInterrupt)
+	constants.Set("VQUIT", fs.CharQuit)
+	constants.Set("VERASE", fs.CharErase)
+	constants.Set("VKILL", fs.CharKill)
+	constants.Set("VEOF", fs.CharEOF)
+	constants.Set("VSUSP", fs.CharSuspend)
+	// hackpad itself runs as 'init', and captured the global 'fs' on startup, so bind it in place
+	setFuncs(jsFS, process.Current())
+	process.RegisterGlobal("fs", func(p process.Process) (js.Value, func()) {
+		processFS := jsObject.Call("create", jsFS) // inherit constants and anything else wasm_exec.js set up
+		return processFS, setFuncs(processFS, p)
+	})
 
 	global.Set("getMounts", js.FuncOf(getMounts))
 	global.Set("destroyMount", js.FuncOf(destroyMount))
 	global.Set("overlayTarGzip", js.FuncOf(overlayTarGzip))
+	global.Set("overlayLazy", js.FuncOf(overlayLazy))
 	global.Set("overlayIndexedDB", js.FuncOf(overlayIndexedDB))
 	global.Set("dumpZip", js.FuncOf(dumpZip))
+	global.Set("setOutput", js.FuncOf(setOutput))
+	global.Set("stdin", newStdin())
 
 	// Set up system directories
 	files := process.Current().Files()
@@ -90,6 +100,84 @@ func Init() {
 	}
 }
 
+// setFuncs binds fs syscalls on 'fs' to process 'p'. Call release once 'p' no longer needs them.
+func setFuncs(fs js.Value, p process.Process) (release func()) {
+	fns := map[string]interface{}{
+		"chmod":         chmod,
+		"chmodSync":     chmodSync,
+		"chown":         chown,
+		"chownSync":     chownSync,
+		"close":         closeFn,
+		"closeSync":     closeSync,
+		"dup":           dup,
+		"dupSync":       dupSync,
+		"dup2":          dup2,
+		"dup2Sync":      dup2Sync,
+		"fcntl":         fcntl,
+		"fcntlSync":     fcntlSync,
+		"fchmod":        fchmod,
+		"fchmodSync":    fchmodSync,
+		"flock":         flock,
+		"flockSync":     flockSync,
+		"fstat":         fstat,
+		"fstatSync":     fstatSync,
+		"fsync":         fsync,
+		"fsyncSync":     fsyncSync,
+		"ftruncate":     ftruncate,
+		"ftruncateSync": ftruncateSync,
+		"ioctl":         ioctl,
+		"ioctlSync":     ioctlSync,
+		"lstat":         lstat,
+		"lstatSync":     lstatSync,
+		"mkdir":         mkdir,
+		"mkdirSync":     mkdirSync,
+		"open":          open,
+		"openSync":      openSync,
+		"openpty":       openpty,
+		"openptySync":   openptySync,
+		"pipe":          pipe,
+		"pipeSync":      pipeSync,
+		"poll":          poll,
+		"pollSync":      pollSync,
+		"read":          read,
+		"readSync":      readSync,
+		"readdir":       readdir,
+		"readdirSync":   readdirSync,
+		"rename":        rename,
+		"renameSync":    renameSync,
+		"rmdir":         rmdir,
+		"rmdirSync":     rmdirSync,
+		"stat":          stat,
+		"statSync":      statSync,
+		"unlink":        unlink,
+		"unlinkSync":    unlinkSync,
+		"utimes":        utimes,
+		"utimesSync":    utimesSync,
+		"write":         write,
+		"writeSync":     writeSync,
+	}
+	var funcs []js.Func
+	for name, fn := range fns {
+		var bound interface{}
+		switch fn := fn.(type) {
+		case syncFunc:
+			bound = interop.Func(func(args []js.Value) (interface{}, error) {
+				return fn(p, args)
+			})
+		case callbackFunc:
+			bound = interop.CallbackFunc(func(args []js.Value) ([]interface{}, error) {
+				return fn(p, args)
+			})
+		}
+		funcs = append(funcs, interop.SetFunc(fs, name, bound))
+	}
+	return func() {
+		for _, fn := range funcs {
+			fn.Release()
+		}
+	}
+}
+
 func Dump(basePath string) interface{} {
 	basePath = common.ResolvePath(process.Current().WorkingDirectory(), basePath)
 	return fs.Dump(basePath)
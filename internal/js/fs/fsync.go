@@ -13,16 +13,15 @@ import (
 
 // fsync(fd, callback) { callback(null); },
 
-func fsync(args []js.Value) ([]interface{}, error) {
-	_, err := fsyncSync(args)
+func fsync(p process.Process, args []js.Value) ([]interface{}, error) {
+	_, err := fsyncSync(p, args)
 	return nil, err
 }
 
-func fsyncSync(args []js.Value) (interface{}, error) {
+func fsyncSync(p process.Process, args []js.Value) (interface{}, error) {
 	if len(args) != 1 {
 		return nil, errors.Errorf("Invalid number of args, expected 1: %v", args)
 	}
 	fd := fs.FID(args[0].Int())
-	p := process.Current()
 	return nil, p.Files().Fsync(fd)
 }
@@ -11,12 +11,12 @@ import (
 	"github.com/pkg/errors"
 )
 
-func ftruncateSync(args []js.Value) (interface{}, error) {
-	_, err := ftruncate(args)
+func ftruncateSync(p process.Process, args []js.Value) (interface{}, error) {
+	_, err := ftruncate(p, args)
 	return nil, err
 }
 
-func ftruncate(args []js.Value) ([]interface{}, error) {
+func ftruncate(p process.Process, args []js.Value) ([]interface{}, error) {
 	// args: fd, len
 	if len(args) == 0 {
 		return nil, errors.Errorf("missing required args, expected fd: %+v", args)
@@ -27,6 +27,5 @@ func ftruncate(args []js.Value) ([]interface{}, error) {
 		length = args[1].Int()
 	}
 
-	p := process.Current()
 	return nil, p.Files().Truncate(fd, int64(length))
 }
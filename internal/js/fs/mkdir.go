@@ -11,12 +11,12 @@ import (
 	"github.com/pkg/errors"
 )
 
-func mkdir(args []js.Value) ([]interface{}, error) {
-	_, err := mkdirSync(args)
+func mkdir(p process.Process, args []js.Value) ([]interface{}, error) {
+	_, err := mkdirSync(p, args)
 	return nil, err
 }
 
-func mkdirSync(args []js.Value) (interface{}, error) {
+func mkdirSync(p process.Process, args []js.Value) (interface{}, error) {
 	if len(args) != 2 {
 		return nil, errors.Errorf("Invalid number of args, expected 2: %v", args)
 	}
@@ -36,7 +36,6 @@ func mkdirSync(args []js.Value) (interface{}, error) {
 		recursive = true
 	}
 
-	p := process.Current()
 	if recursive {
 		return nil, p.Files().MkdirAll(path, mode)
 	}
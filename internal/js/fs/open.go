@@ -11,12 +11,12 @@ import (
 	"github.com/pkg/errors"
 )
 
-func open(args []js.Value) ([]interface{}, error) {
-	fd, err := openSync(args)
+func open(p process.Process, args []js.Value) ([]interface{}, error) {
+	fd, err := openSync(p, args)
 	return []interface{}{fd}, err
 }
 
-func openSync(args []js.Value) (interface{}, error) {
+func openSync(p process.Process, args []js.Value) (interface{}, error) {
 	if len(args) == 0 {
 		return nil, errors.Errorf("Expected path, received: %v", args)
 	}
@@ -30,7 +30,6 @@ func openSync(args []js.Value) (interface{}, error) {
 		mode = os.FileMode(args[2].Int())
 	}
 
-	p := process.Current()
 	fd, err := p.Files().Open(path, flags, mode)
 	return fd.JSValue(), err
 }
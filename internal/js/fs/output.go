@@ -0,0 +1,66 @@
+//go:build js
+// +build js
+
+package fs
+
+import (
+	"syscall/js"
+
+	"github.com/hack-pad/hackpad/internal/common"
+	"github.com/hack-pad/hackpad/internal/fs"
+	"github.com/hack-pad/hackpad/internal/interop"
+	"github.com/hack-pad/hackpad/internal/log"
+	"github.com/hack-pad/hackpad/internal/process"
+	"github.com/pkg/errors"
+)
+
+// setOutput routes init's stdout or stderr, and anything inheriting it, to a sink.
+//
+//	hackpad.setOutput("stdout", {callback: (text, pid) => ..., prefixPID: true})
+//	hackpad.setOutput("stderr", {file: "/tmp/errors.log"})
+//	hackpad.setOutput("stdout", {console: true})
+func setOutput(this js.Value, args []js.Value) interface{} {
+	if len(args) != 2 {
+		return interop.WrapAsJSError(errors.New("setOutput: stream name and options are required"), "EINVAL")
+	}
+	var setSink func(fs.OutputSink, bool)
+	var printFn func(...interface{}) int
+	switch stream := args[0].String(); stream {
+	case "stdout":
+		setSink, printFn = fs.SetStdout, log.Print
+	case "stderr":
+		setSink, printFn = fs.SetStderr, log.Error
+	default:
+		return interop.WrapAsJSError(errors.Errorf("setOutput: unknown stream %q, must be stdout or stderr", stream), "EINVAL")
+	}
+	sink, prefixPID, err := parseOutputOptions(args[1], printFn)
+	if err != nil {
+		return interop.WrapAsJSError(err, "EINVAL")
+	}
+	setSink(sink, prefixPID)
+	return nil
+}
+
+func parseOutputOptions(options js.Value, consolePrintFn func(...interface{}) int) (sink fs.OutputSink, prefixPID bool, err error) {
+	if options.Type() != js.TypeObject {
+		return nil, false, errors.New("setOutput: options must be an object")
+	}
+	prefixPID = options.Get("prefixPID").Truthy()
+	callback, file := options.Get("callback"), options.Get("file")
+	switch {
+	case callback.Type() == js.TypeFunction:
+		sink = fs.OutputSinkFunc(func(pid common.PID, p []byte) (err error) {
+			defer common.CatchException(&err) // i.e. the callback's owner exited and released it
+			callback.Invoke(string(p), pid.JSValue())
+			return nil
+		})
+	case file.Truthy():
+		path := common.ResolvePath(process.Current().WorkingDirectory(), file.String())
+		sink, err = fs.NewFileSink(path)
+	case options.Get("console").Truthy():
+		sink = fs.NewConsoleSink(consolePrintFn)
+	default:
+		err = errors.New("setOutput: one of callback, file, or console is required")
+	}
+	return
+}
@@ -5,7 +5,9 @@ package fs
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
@@ -19,6 +21,7 @@ import (
 	"github.com/machinebox/progress"
 
 	"github.com/hack-pad/hackpad/internal/common"
+	"github.com/hack-pad/hackpad/internal/download"
 	"github.com/hack-pad/hackpad/internal/fs"
 	"github.com/hack-pad/hackpad/internal/interop"
 	"github.com/hack-pad/hackpad/internal/log"
@@ -110,18 +113,100 @@ func OverlayTarGzip(args []js.Value) error {
 		})
 	}
 	persist := options["persist"].Truthy()
-	shouldCache := func(string, hackpadfs.FileInfo) bool { return true }
-	if options["skipCacheDirs"].Type() == js.TypeObject {
-		skipDirs := make(map[string]bool)
-		for _, d := range interop.StringsFromJSValue(options["skipCacheDirs"]) {
-			skipDirs[common.ResolvePath(process.Current().WorkingDirectory(), d)] = true
+	shouldCache := shouldCacheOption(options)
+	var loadManifest fs.ManifestLoader
+	if manifestPath := options["manifest"]; manifestPath.Type() == js.TypeString {
+		loadManifest = func() (fs.Manifest, error) {
+			return fetchManifest(manifestPath.String())
 		}
-		maxFileBytes := datasize.Kibibytes(100).Bytes()
-		shouldCache = func(name string, info hackpadfs.FileInfo) bool {
-			return !skipDirs[path.Dir(name)] && info.Size() < maxFileBytes
+	}
+	return fs.OverlayTarGzip(mountPath, reader, persist, shouldCache, loadManifest)
+}
+
+// shouldCacheOption keeps files in memory unless they're in the 'skipCacheDirs' option, which also limits cached file size
+func shouldCacheOption(options map[string]js.Value) fs.ShouldCacher {
+	if options["skipCacheDirs"].Type() != js.TypeObject {
+		return func(string, hackpadfs.FileInfo) bool { return true }
+	}
+	skipDirs := make(map[string]bool)
+	for _, d := range interop.StringsFromJSValue(options["skipCacheDirs"]) {
+		skipDirs[common.ResolvePath(process.Current().WorkingDirectory(), d)] = true
+	}
+	maxFileBytes := datasize.Kibibytes(100).Bytes()
+	return func(name string, info hackpadfs.FileInfo) bool {
+		return !skipDirs[path.Dir(name)] && info.Size() < maxFileBytes
+	}
+}
+
+func overlayLazy(this js.Value, args []js.Value) interface{} {
+	resolve, reject, prom := promise.New()
+	go func() {
+		err := OverlayLazy(args)
+		if err != nil {
+			reject(interop.WrapAsJSError(err, "Failed overlaying lazy FS"))
+		} else {
+			log.Debug("Successfully overlayed lazy FS")
+			resolve(nil)
+		}
+	}()
+	return prom.JSValue()
+}
+
+// OverlayLazy mounts a chunked archive from gozip -chunks, fetching each directory's files the first time they're opened.
+// Supports the same options as OverlayTarGzip, except 'manifest'. Progress completes once the index is loaded.
+func OverlayLazy(args []js.Value) error {
+	if len(args) < 2 {
+		return errors.New("overlayLazy: mount path and index URL path is required")
+	}
+
+	mountPath := args[0].String()
+	u, err := url.Parse(args[1].String())
+	if err != nil {
+		return err
+	}
+	// only download from current server, not just any URL
+	indexPath := u.Path
+	var options map[string]js.Value
+	if len(args) >= 3 && args[2].Type() == js.TypeObject {
+		options = interop.Entries(args[2])
+	}
+
+	fetch := func(urlPath string) ([]byte, error) {
+		b, err := download.Get(context.Background(), download.Fetch{}, urlPath, download.Options{})
+		if err != nil {
+			return nil, err
 		}
+		return b.Bytes(), nil
+	}
+	fetchIndex := func() ([]byte, error) {
+		return fetch(indexPath)
+	}
+	fetchChunk := func(chunkURL string) ([]byte, error) {
+		return fetch(path.Join(path.Dir(indexPath), chunkURL))
+	}
+	err = fs.OverlayLazy(mountPath, fetchIndex, fetchChunk, options["persist"].Truthy(), shouldCacheOption(options))
+	if progressCallback := options["progress"]; err == nil && progressCallback.Type() == js.TypeFunction {
+		progressCallback.Invoke(100)
+	}
+	return err
+}
+
+func fetchManifest(manifestPath string) (fs.Manifest, error) {
+	u, err := url.Parse(manifestPath)
+	if err != nil {
+		return fs.Manifest{}, err
+	}
+	resp, err := http.Get(u.Path)
+	if err != nil {
+		return fs.Manifest{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fs.Manifest{}, fmt.Errorf("Failed to download %s: %s", manifestPath, resp.Status)
 	}
-	return fs.OverlayTarGzip(mountPath, reader, persist, shouldCache)
+	var manifest fs.Manifest
+	err = json.NewDecoder(resp.Body).Decode(&manifest)
+	return manifest, err
 }
 
 func wrapProgress(r io.ReadCloser, contentLength int64, setProgress func(float64)) io.ReadCloser {
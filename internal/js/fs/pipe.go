@@ -10,16 +10,18 @@ import (
 	"github.com/pkg/errors"
 )
 
-func pipe(args []js.Value) ([]interface{}, error) {
-	fds, err := pipeSync(args)
+func pipe(p process.Process, args []js.Value) ([]interface{}, error) {
+	fds, err := pipeSync(p, args)
 	return []interface{}{fds}, err
 }
 
-func pipeSync(args []js.Value) (interface{}, error) {
+func pipeSync(p process.Process, args []js.Value) (interface{}, error) {
 	if len(args) != 0 {
 		return nil, errors.Errorf("Invalid number of args, expected 0: %v", args)
 	}
-	p := process.Current()
-	fds := p.Files().Pipe()
+	fds, err := p.Files().Pipe()
+	if err != nil {
+		return nil, err
+	}
 	return []interface{}{fds[0].JSValue(), fds[1].JSValue()}, nil
 }
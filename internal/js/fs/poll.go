@@ -0,0 +1,46 @@
+//go:build js
+// +build js
+
+package fs
+
+import (
+	"syscall/js"
+	"time"
+
+	"github.com/hack-pad/hackpad/internal/fs"
+	"github.com/hack-pad/hackpad/internal/interop"
+	"github.com/hack-pad/hackpad/internal/process"
+	"github.com/pkg/errors"
+)
+
+func poll(p process.Process, args []js.Value) ([]interface{}, error) {
+	revents, err := pollSync(p, args)
+	return []interface{}{revents}, err
+}
+
+// pollSync waits for I/O readiness: poll(fds, events, timeoutMillis)
+// Returns the ready events for each descriptor. A negative timeout waits indefinitely.
+// Only use pollSync with a timeout of 0, since waiting blocks the JS event loop.
+func pollSync(p process.Process, args []js.Value) (interface{}, error) {
+	if len(args) != 3 {
+		return nil, errors.Errorf("Invalid number of args, expected 3: %v", args)
+	}
+	var fds []fs.FID
+	for _, fd := range interop.SliceFromJSValue(args[0]) {
+		fds = append(fds, fs.FID(fd.Int()))
+	}
+	var events []fs.PollEvents
+	for _, event := range interop.SliceFromJSValue(args[1]) {
+		events = append(events, fs.PollEvents(event.Int()))
+	}
+	timeout := time.Duration(args[2].Int()) * time.Millisecond
+	revents, err := p.Files().Poll(fds, events, timeout)
+	if err != nil {
+		return nil, err
+	}
+	jsRevents := make([]interface{}, len(revents))
+	for i, revent := range revents {
+		jsRevents[i] = int(revent)
+	}
+	return jsRevents, nil
+}
@@ -0,0 +1,103 @@
+//go:build js
+// +build js
+
+package fs
+
+import (
+	"syscall/js"
+
+	"github.com/hack-pad/hackpad/internal/fs"
+	"github.com/hack-pad/hackpad/internal/interop"
+	"github.com/hack-pad/hackpad/internal/process"
+	"github.com/pkg/errors"
+)
+
+func openpty(p process.Process, args []js.Value) ([]interface{}, error) {
+	fds, err := openptySync(p, args)
+	return []interface{}{fds}, err
+}
+
+// openptySync opens a pseudo-terminal, returning [master, slave]
+func openptySync(p process.Process, args []js.Value) (interface{}, error) {
+	if len(args) != 0 {
+		return nil, errors.Errorf("Invalid number of args, expected 0: %v", args)
+	}
+	fds, err := p.Files().OpenPTY()
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{fds[0].JSValue(), fds[1].JSValue()}, nil
+}
+
+func ioctl(p process.Process, args []js.Value) ([]interface{}, error) {
+	ret, err := ioctlSync(p, args)
+	return []interface{}{ret}, err
+}
+
+// ioctlSync performs a terminal request: ioctlSync(fd, request[, arg])
+// TCGETS returns {iflag, oflag, lflag, cc}, which TCSETS, TCSETSW, and TCSETSF accept. TIOCGWINSZ returns {rows, cols}, which TIOCSWINSZ accepts.
+func ioctlSync(p process.Process, args []js.Value) (interface{}, error) {
+	if len(args) < 2 {
+		return nil, errors.Errorf("Invalid number of args, expected fd, request, and optional arg: %v", args)
+	}
+	terminal, err := p.Files().Terminal(fs.FID(args[0].Int()))
+	if err != nil {
+		return nil, err
+	}
+	request := args[1].Int()
+	arg := js.Undefined()
+	if len(args) >= 3 {
+		arg = args[2]
+	}
+	switch request {
+	case fs.IoctlGetTermios:
+		termios := terminal.Termios()
+		chars := make([]interface{}, len(termios.Chars))
+		for i, c := range termios.Chars {
+			chars[i] = int(c)
+		}
+		return map[string]interface{}{
+			"iflag": termios.InputFlags,
+			"oflag": termios.OutputFlags,
+			"lflag": termios.LocalFlags,
+			"cc":    chars,
+		}, nil
+	case fs.IoctlSetTermios, fs.IoctlSetTermiosDrain, fs.IoctlSetTermiosFlush:
+		if arg.Type() != js.TypeObject {
+			return nil, interop.NewError("termios object is required", "EINVAL")
+		}
+		termios := terminal.Termios()
+		if value := arg.Get("iflag"); value.Type() == js.TypeNumber {
+			termios.InputFlags = uint32(value.Int())
+		}
+		if value := arg.Get("oflag"); value.Type() == js.TypeNumber {
+			termios.OutputFlags = uint32(value.Int())
+		}
+		if value := arg.Get("lflag"); value.Type() == js.TypeNumber {
+			termios.LocalFlags = uint32(value.Int())
+		}
+		if chars := arg.Get("cc"); chars.Truthy() {
+			for i := 0; i < chars.Length() && i < len(termios.Chars); i++ {
+				termios.Chars[i] = byte(chars.Index(i).Int())
+			}
+		}
+		return nil, terminal.SetTermios(request, termios)
+	case fs.IoctlGetWindowSize:
+		size := terminal.WindowSize()
+		return map[string]interface{}{
+			"rows": size.Rows,
+			"cols": size.Cols,
+		}, nil
+	case fs.IoctlSetWindowSize:
+		if arg.Type() != js.TypeObject {
+			return nil, interop.NewError("window size object is required", "EINVAL")
+		}
+		terminal.SetWindowSize(fs.WindowSize{
+			Rows: uint16(arg.Get("rows").Int()),
+			Cols: uint16(arg.Get("cols").Int()),
+		})
+		return nil, nil
+	default:
+		return nil, fs.ErrNotTerminal
+	}
+}
@@ -12,17 +12,17 @@ import (
 	"github.com/pkg/errors"
 )
 
-func read(args []js.Value) ([]interface{}, error) {
-	n, buf, err := readSyncImpl(args)
+func read(p process.Process, args []js.Value) ([]interface{}, error) {
+	n, buf, err := readSyncImpl(p, args)
 	return []interface{}{n, buf}, err
 }
 
-func readSync(args []js.Value) (interface{}, error) {
-	n, _, err := readSyncImpl(args)
+func readSync(p process.Process, args []js.Value) (interface{}, error) {
+	n, _, err := readSyncImpl(p, args)
 	return n, err
 }
 
-func readSyncImpl(args []js.Value) (int, js.Value, error) {
+func readSyncImpl(p process.Process, args []js.Value) (int, js.Value, error) {
 	// args: fd, buffer, offset, length, position
 	if len(args) != 5 {
 		return 0, js.Null(), errors.Errorf("missing required args, expected 5: %+v", args)
@@ -40,7 +40,6 @@ func readSyncImpl(args []js.Value) (int, js.Value, error) {
 		*position = int64(args[4].Int())
 	}
 
-	p := process.Current()
 	n, err := p.Files().Read(fd, buffer, offset, length, position)
 	return n, buffer.JSValue(), err
 }
@@ -10,17 +10,16 @@ import (
 	"github.com/pkg/errors"
 )
 
-func readdir(args []js.Value) ([]interface{}, error) {
-	fileNames, err := readdirSync(args)
+func readdir(p process.Process, args []js.Value) ([]interface{}, error) {
+	fileNames, err := readdirSync(p, args)
 	return []interface{}{fileNames}, err
 }
 
-func readdirSync(args []js.Value) (interface{}, error) {
+func readdirSync(p process.Process, args []js.Value) (interface{}, error) {
 	if len(args) != 1 {
 		return nil, errors.Errorf("Invalid number of args, expected 1: %v", args)
 	}
 	path := args[0].String()
-	p := process.Current()
 	dir, err := p.Files().ReadDir(path)
 	if err != nil {
 		return nil, err
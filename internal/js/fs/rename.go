@@ -12,17 +12,16 @@ import (
 
 // rename(from, to, callback) { callback(enosys()); },
 
-func rename(args []js.Value) ([]interface{}, error) {
-	_, err := renameSync(args)
+func rename(p process.Process, args []js.Value) ([]interface{}, error) {
+	_, err := renameSync(p, args)
 	return nil, err
 }
 
-func renameSync(args []js.Value) (interface{}, error) {
+func renameSync(p process.Process, args []js.Value) (interface{}, error) {
 	if len(args) != 2 {
 		return nil, errors.Errorf("Invalid number of args, expected 2: %v", args)
 	}
 	oldPath := args[0].String()
 	newPath := args[1].String()
-	p := process.Current()
 	return nil, p.Files().Rename(oldPath, newPath)
 }
@@ -12,17 +12,16 @@ import (
 	"github.com/pkg/errors"
 )
 
-func stat(args []js.Value) ([]interface{}, error) {
-	info, err := statSync(args)
+func stat(p process.Process, args []js.Value) ([]interface{}, error) {
+	info, err := statSync(p, args)
 	return []interface{}{info}, err
 }
 
-func statSync(args []js.Value) (interface{}, error) {
+func statSync(p process.Process, args []js.Value) (interface{}, error) {
 	if len(args) != 1 {
 		return nil, errors.Errorf("Invalid number of args, expected 1: %v", args)
 	}
 	path := args[0].String()
-	p := process.Current()
 	info, err := p.Files().Stat(path)
 	return jsStat(info), err
 }
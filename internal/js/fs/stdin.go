@@ -0,0 +1,59 @@
+//go:build js
+// +build js
+
+package fs
+
+import (
+	"syscall/js"
+
+	"github.com/hack-pad/hackpad/internal/fs"
+	"github.com/hack-pad/hackpad/internal/interop"
+	"github.com/hack-pad/hackpad/internal/promise"
+	"github.com/pkg/errors"
+)
+
+// newStdin returns the JS handle for init's standard input:
+//
+//	await hackpad.stdin.write("some input\n") // or a Uint8Array
+//	await hackpad.stdin.end()
+func newStdin() js.Value {
+	return js.ValueOf(map[string]interface{}{
+		"write": js.FuncOf(writeStdin),
+		"end":   js.FuncOf(endStdin),
+	})
+}
+
+func writeStdin(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 {
+		return interop.WrapAsJSError(errors.New("stdin.write: data is required"), "EINVAL")
+	}
+	var data []byte
+	switch {
+	case args[0].Type() == js.TypeString:
+		data = []byte(args[0].String())
+	case args[0].InstanceOf(js.Global().Get("Uint8Array")):
+		data = make([]byte, args[0].Length())
+		js.CopyBytesToGo(data, args[0])
+	default:
+		return interop.WrapAsJSError(errors.New("stdin.write: data must be a string or Uint8Array"), "EINVAL")
+	}
+	resolve, reject, prom := promise.New()
+	fs.WriteStdin(data, settle(resolve, reject, "stdin.write"))
+	return prom.JSValue()
+}
+
+func endStdin(this js.Value, args []js.Value) interface{} {
+	resolve, reject, prom := promise.New()
+	fs.CloseStdin(settle(resolve, reject, "stdin.end"))
+	return prom.JSValue()
+}
+
+func settle(resolve, reject promise.Resolver, message string) func(error) {
+	return func(err error) {
+		if err != nil {
+			reject(interop.WrapAsJSError(err, message))
+		} else {
+			resolve(nil)
+		}
+	}
+}
@@ -12,16 +12,15 @@ import (
 
 // unlink(path, callback) { callback(enosys()); },
 
-func unlink(args []js.Value) ([]interface{}, error) {
-	_, err := unlinkSync(args)
+func unlink(p process.Process, args []js.Value) ([]interface{}, error) {
+	_, err := unlinkSync(p, args)
 	return nil, err
 }
 
-func unlinkSync(args []js.Value) (interface{}, error) {
+func unlinkSync(p process.Process, args []js.Value) (interface{}, error) {
 	if len(args) != 1 {
 		return nil, errors.Errorf("Invalid number of args, expected 1: %v", args)
 	}
 	path := args[0].String()
-	p := process.Current()
 	return nil, p.Files().Unlink(path)
 }
@@ -12,15 +12,15 @@ import (
 	"github.com/pkg/errors"
 )
 
-func writeSync(args []js.Value) (interface{}, error) {
-	ret, err := write(args)
+func writeSync(p process.Process, args []js.Value) (interface{}, error) {
+	ret, err := write(p, args)
 	if len(ret) > 1 {
 		return ret[0], err
 	}
 	return ret, err
 }
 
-func write(args []js.Value) ([]interface{}, error) {
+func write(p process.Process, args []js.Value) ([]interface{}, error) {
 	// args: fd, buffer, offset, length, position
 	if len(args) < 2 {
 		return nil, errors.Errorf("missing required args, expected fd and buffer: %+v", args)
@@ -44,7 +44,6 @@ func write(args []js.Value) ([]interface{}, error) {
 		*position = int64(args[4].Int())
 	}
 
-	p := process.Current()
 	n, err := p.Files().Write(fd, buffer, offset, length, position)
 	return []interface{}{n, buffer.JSValue()}, err
 }
@@ -0,0 +1,127 @@
+//go:build js
+// +build js
+
+// Package packages exposes the package manager to JS, as hackpad globals and the per-process 'packages' global.
+package packages
+
+import (
+	"context"
+	"syscall/js"
+
+	"github.com/hack-pad/hackpad/internal/global"
+	"github.com/hack-pad/hackpad/internal/interop"
+	"github.com/hack-pad/hackpad/internal/packages"
+	"github.com/hack-pad/hackpad/internal/process"
+	"github.com/hack-pad/hackpad/internal/promise"
+)
+
+// Init binds hackpad.install, uninstall, list, and upgrade to 'manager'.
+// Processes get a 'packages' global with Node.js-style callback versions, which work in Workers too.
+func Init(manager *packages.Manager) {
+	ops := map[string]func(ctx context.Context, args []string) (interface{}, error){
+		"install": func(ctx context.Context, names []string) (interface{}, error) {
+			return nil, manager.Install(ctx, names...)
+		},
+		"uninstall": func(_ context.Context, names []string) (interface{}, error) {
+			return nil, manager.Uninstall(names...)
+		},
+		"list": func(context.Context, []string) (interface{}, error) {
+			installed, err := manager.List()
+			return installedValues(installed), err
+		},
+		"available": func(ctx context.Context, _ []string) (interface{}, error) {
+			available, err := manager.Available(ctx)
+			return availableValues(available), err
+		},
+		"upgrade": func(ctx context.Context, names []string) (interface{}, error) {
+			upgraded, err := manager.Upgrade(ctx, names...)
+			return interop.SliceFromStrings(upgraded), err
+		},
+	}
+
+	callbacks := js.Global().Get("Object").New()
+	for name, op := range ops {
+		name, op := name, op
+		global.Set(name, js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			return promiseOp(name, op, args)
+		}))
+		interop.SetFunc(callbacks, name, func(args []js.Value) ([]interface{}, error) {
+			ctx, args := withOptions(context.Background(), args)
+			result, err := op(ctx, stringArgs(args))
+			return []interface{}{result}, err
+		})
+	}
+	process.RegisterGlobal("packages", func(process.Process) (js.Value, func()) {
+		return callbacks, func() {}
+	})
+}
+
+// promiseOp runs 'op' with string arguments, which may be followed by an options object
+func promiseOp(name string, op func(context.Context, []string) (interface{}, error), args []js.Value) js.Value {
+	resolve, reject, prom := promise.New()
+	ctx, args := withOptions(context.Background(), args)
+	names := stringArgs(args)
+	go func() {
+		result, err := op(ctx, names)
+		if err != nil {
+			reject(interop.WrapAsJSError(err, "Failed to "+name))
+			return
+		}
+		resolve(result)
+	}()
+	return prom.JSValue()
+}
+
+// withOptions applies a trailing options object in 'args' to 'ctx', returning the remaining args.
+// Options are a 'progress' callback receiving each package name and its percentage downloaded, and 'force' to replace existing files no package installed.
+func withOptions(ctx context.Context, args []js.Value) (context.Context, []js.Value) {
+	if len(args) == 0 || args[len(args)-1].Type() != js.TypeObject {
+		return ctx, args
+	}
+	options := args[len(args)-1]
+	if progress := options.Get("progress"); progress.Type() == js.TypeFunction {
+		ctx = packages.WithProgress(ctx, func(name string, percentage float64) {
+			progress.Invoke(name, percentage)
+		})
+	}
+	if options.Get("force").Truthy() {
+		ctx = packages.WithForce(ctx)
+	}
+	return ctx, args[:len(args)-1]
+}
+
+func stringArgs(args []js.Value) []string {
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		strs[i] = arg.String()
+	}
+	return strs
+}
+
+func installedValues(installed []packages.Installed) []interface{} {
+	values := make([]interface{}, len(installed))
+	for i, pkg := range installed {
+		values[i] = map[string]interface{}{
+			"name":         pkg.Name,
+			"version":      pkg.Version,
+			"sha256":       pkg.SHA256,
+			"files":        interop.SliceFromStrings(pkg.Files),
+			"dependency":   pkg.Dependency,
+			"dependencies": interop.SliceFromStrings(pkg.Dependencies),
+		}
+	}
+	return values
+}
+
+func availableValues(available []packages.Package) []interface{} {
+	values := make([]interface{}, len(available))
+	for i, pkg := range available {
+		values[i] = map[string]interface{}{
+			"name":         pkg.Name,
+			"version":      pkg.Version,
+			"sha256":       pkg.SHA256,
+			"dependencies": interop.SliceFromStrings(pkg.Dependencies),
+		}
+	}
+	return values
+}
@@ -0,0 +1,51 @@
+package process
+
+import (
+	"time"
+)
+
+const (
+	// blockedSpins is how many idle polls a blocked kernel makes before sleeping, so its own goroutines can finish in-flight syscalls without delay
+	blockedSpins = 100
+	// blockedSleep is the longest a blocked kernel sleeps between polls, in case a syscall finishes without a Worker signaling
+	blockedSleep = time.Millisecond
+)
+
+// blockedWait serves a Worker's syscalls while the kernel can't return to the event loop, i.e. during spawnSync
+type blockedWait struct {
+	serve func() bool         // handles pending Worker syscalls, returning true if any Worker signaled
+	sleep func(time.Duration) // sleeps until a Worker signals or the duration passes
+	yield func()              // runs the kernel's other goroutines
+	now   func() time.Time
+}
+
+// until serves syscalls until 'done' is closed. If 'timeout' is set and passes first, onTimeout is called once and serving continues.
+// Idle polls spin briefly, then sleep between polls so an idle child doesn't keep the kernel busy.
+func (b blockedWait) until(done <-chan struct{}, timeout time.Duration, onTimeout func()) {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = b.now().Add(timeout)
+	}
+	idle := 0
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		if b.serve() {
+			idle = 0
+		} else {
+			idle++
+		}
+		if !deadline.IsZero() && !b.now().Before(deadline) {
+			deadline = time.Time{}
+			onTimeout()
+		}
+		// yield rather than block, so the Go scheduler never idles and returns control to the JS event loop
+		b.yield()
+		if idle > blockedSpins {
+			b.sleep(blockedSleep)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package process
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeBlockedWait is a blockedWait with a fake clock, which advances on each sleep
+type fakeBlockedWait struct {
+	now      time.Time
+	polls    int
+	sleeps   int
+	yields   int
+	signaled func(poll int) bool
+	onPoll   func(poll int)
+}
+
+func (f *fakeBlockedWait) wait() blockedWait {
+	return blockedWait{
+		serve: func() bool {
+			f.polls++
+			if f.onPoll != nil {
+				f.onPoll(f.polls)
+			}
+			return f.signaled != nil && f.signaled(f.polls)
+		},
+		sleep: func(d time.Duration) {
+			f.sleeps++
+			f.now = f.now.Add(d)
+		},
+		yield: func() {
+			f.yields++
+		},
+		now: func() time.Time {
+			return f.now
+		},
+	}
+}
+
+func TestBlockedWaitDone(t *testing.T) {
+	done := make(chan struct{})
+	f := &fakeBlockedWait{
+		now: time.Unix(0, 0),
+		onPoll: func(poll int) {
+			if poll == 3 {
+				close(done)
+			}
+		},
+	}
+	f.wait().until(done, 0, func() {
+		t.Error("Unexpected timeout")
+	})
+	if f.polls != 3 {
+		t.Errorf("Expected 3 polls, got %d", f.polls)
+	}
+	if f.yields != 3 {
+		t.Errorf("Expected a yield after each poll, got %d", f.yields)
+	}
+	if f.sleeps != 0 {
+		t.Errorf("Expected no sleeps before %d idle polls, got %d", blockedSpins, f.sleeps)
+	}
+}
+
+func TestBlockedWaitSleepsWhenIdle(t *testing.T) {
+	const polls = blockedSpins + 10
+	done := make(chan struct{})
+	f := &fakeBlockedWait{
+		now: time.Unix(0, 0),
+		onPoll: func(poll int) {
+			if poll == polls {
+				close(done)
+			}
+		},
+	}
+	f.wait().until(done, 0, func() {})
+	if expect := polls - blockedSpins; f.sleeps != expect {
+		t.Errorf("Expected %d sleeps, got %d", expect, f.sleeps)
+	}
+}
+
+func TestBlockedWaitSignalsResetIdle(t *testing.T) {
+	const polls = 3 * blockedSpins
+	done := make(chan struct{})
+	f := &fakeBlockedWait{
+		now: time.Unix(0, 0),
+		signaled: func(poll int) bool {
+			return poll%blockedSpins == 0 // a Worker signals just before the kernel would start sleeping
+		},
+		onPoll: func(poll int) {
+			if poll == polls {
+				close(done)
+			}
+		},
+	}
+	f.wait().until(done, 0, func() {})
+	if f.sleeps != 0 {
+		t.Errorf("Expected no sleeps while Workers signal, got %d", f.sleeps)
+	}
+}
+
+func TestBlockedWaitTimeout(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	done := make(chan struct{})
+	timeouts := 0
+	f := &fakeBlockedWait{now: time.Unix(0, 0)}
+	start := f.now
+	var timedOutAt time.Time
+	f.onPoll = func(poll int) {
+		if timeouts > 0 && timedOutAt.IsZero() {
+			timedOutAt = f.now
+		}
+		if !timedOutAt.IsZero() && f.now.Sub(timedOutAt) >= 10*blockedSleep {
+			close(done) // the child exits some time after being killed
+		}
+	}
+	f.wait().until(done, timeout, func() {
+		timeouts++
+	})
+	if timeouts != 1 {
+		t.Fatalf("Expected 1 timeout, got %d", timeouts)
+	}
+	if elapsed := timedOutAt.Sub(start); elapsed < timeout || elapsed > timeout+blockedSleep {
+		t.Errorf("Expected timeout after %s, got %s", timeout, elapsed)
+	}
+}
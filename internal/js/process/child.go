@@ -0,0 +1,484 @@
+//go:build js
+// +build js
+
+package process
+
+import (
+	"os"
+	"sync"
+	"syscall/js"
+
+	"github.com/hack-pad/hackpad/internal/fs"
+	"github.com/hack-pad/hackpad/internal/interop"
+	"github.com/hack-pad/hackpad/internal/log"
+	"github.com/hack-pad/hackpad/internal/process"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+	"go.uber.org/atomic"
+)
+
+var (
+	jsUint8Array = js.Global().Get("Uint8Array")
+	jsNoop       = js.Global().Get("Function").New("return false")
+)
+
+// childProcess is a Node.js-style ChildProcess, emitting 'spawn', 'exit', 'close', and 'error' events for a process and its piped stdio
+type childProcess struct {
+	process.Process
+	parent    process.Process
+	value     js.Value
+	events    interop.EventTarget
+	ready     chan struct{} // closed once the spawning JS task completes, so callers can attach listeners first
+	readyOnce sync.Once
+
+	funcsMu sync.Mutex
+	funcs   []js.Func
+
+	stdin          *childInput
+	stdout, stderr *childOutput
+	outputs        sync.WaitGroup
+	killSignal     atomic.String
+}
+
+func newChildProcess(parent, p process.Process, stdio *childStdio) *childProcess {
+	c := &childProcess{
+		Process: p,
+		parent:  parent,
+		value:   p.(jsWrapper).JSValue(),
+		events:  interop.NewEventTarget(),
+		ready:   make(chan struct{}),
+	}
+	js.Global().Call("setTimeout", interop.SingleUseFunc(func(js.Value, []js.Value) interface{} {
+		c.setReady()
+		return nil
+	}), 0)
+
+	c.value.Set("exitCode", js.Null())
+	c.value.Set("signalCode", js.Null())
+	c.value.Set("killed", false)
+	c.setEmitterFuncs(c.value, c.events)
+	c.setFunc(c.value, "kill", c.kill)
+
+	c.value.Set("stdin", js.Null())
+	if fid, ok := stdio.parentEnds[0]; ok {
+		c.stdin = c.newInput(fid)
+		c.value.Set("stdin", c.stdin.value)
+	}
+	c.value.Set("stdout", js.Null())
+	if fid, ok := stdio.parentEnds[1]; ok {
+		c.stdout = c.newOutput(fid)
+		c.value.Set("stdout", c.stdout.value)
+	}
+	c.value.Set("stderr", js.Null())
+	if fid, ok := stdio.parentEnds[2]; ok {
+		c.stderr = c.newOutput(fid)
+		c.value.Set("stderr", c.stderr.value)
+	}
+	return c
+}
+
+// setReady starts emitting events. Synchronous callers that never yield to the event loop call this once their listeners are attached.
+func (c *childProcess) setReady() {
+	c.readyOnce.Do(func() {
+		close(c.ready)
+	})
+}
+
+func (c *childProcess) setFunc(value js.Value, name string, fn func(args []js.Value) interface{}) {
+	jsFn := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		return fn(args)
+	})
+	value.Set(name, jsFn)
+	c.funcsMu.Lock()
+	c.funcs = append(c.funcs, jsFn)
+	c.funcsMu.Unlock()
+}
+
+// emitterFuncs are the EventEmitter methods added by setEmitterFuncs
+var emitterFuncs = []string{"on", "addListener", "once", "off", "removeListener", "removeAllListeners"}
+
+// jsListener is a JS function listening to an EventTarget
+type jsListener struct {
+	fn     js.Value
+	remove func()
+}
+
+// setEmitterFuncs adds EventEmitter methods to 'value', dispatching to 'events'
+func (c *childProcess) setEmitterFuncs(value js.Value, events interop.EventTarget) {
+	var mu sync.Mutex
+	listeners := make(map[string][]*jsListener)
+	// removeListener removes 'l', returning false if it was already removed
+	removeListener := func(eventName string, l *jsListener) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		for i, registered := range listeners[eventName] {
+			if registered == l {
+				listeners[eventName] = append(listeners[eventName][:i:i], listeners[eventName][i+1:]...)
+				l.remove()
+				return true
+			}
+		}
+		return false
+	}
+
+	listen := func(once bool) func(args []js.Value) interface{} {
+		return func(args []js.Value) interface{} {
+			if len(args) < 2 || args[1].Type() != js.TypeFunction {
+				return value
+			}
+			eventName, fn := args[0].String(), args[1]
+			l := &jsListener{fn: fn}
+			mu.Lock()
+			l.remove = events.Listen(eventName, func(event interop.Event, args ...interface{}) {
+				if once && !removeListener(eventName, l) {
+					return
+				}
+				fn.Invoke(args...)
+			})
+			listeners[eventName] = append(listeners[eventName], l)
+			mu.Unlock()
+			return value
+		}
+	}
+	c.setFunc(value, "on", listen(false))
+	c.setFunc(value, "addListener", listen(false))
+	c.setFunc(value, "once", listen(true))
+
+	// removeListener(eventName, listener) removes the most recently added 'listener', like Node.js
+	off := func(args []js.Value) interface{} {
+		if len(args) < 2 {
+			return value
+		}
+		eventName, fn := args[0].String(), args[1]
+		mu.Lock()
+		var match *jsListener
+		for i := len(listeners[eventName]) - 1; i >= 0 && match == nil; i-- {
+			if listeners[eventName][i].fn.Equal(fn) {
+				match = listeners[eventName][i]
+			}
+		}
+		mu.Unlock()
+		if match != nil {
+			removeListener(eventName, match)
+		}
+		return value
+	}
+	c.setFunc(value, "off", off)
+	c.setFunc(value, "removeListener", off)
+
+	// removeAllListeners([eventName]) removes every listener for 'eventName', or for all events
+	c.setFunc(value, "removeAllListeners", func(args []js.Value) interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		for eventName, registered := range listeners {
+			if len(args) > 0 && !args[0].IsUndefined() && args[0].String() != eventName {
+				continue
+			}
+			for _, l := range registered {
+				l.remove()
+			}
+			delete(listeners, eventName)
+		}
+		return value
+	})
+}
+
+func (c *childProcess) emit(value js.Value, events interop.EventTarget, eventName string, args ...interface{}) {
+	<-c.ready
+	events.Emit(interop.Event{Target: value, Type: eventName}, args...)
+}
+
+// start runs the process, then emits its lifecycle events
+func (c *childProcess) start() error {
+	err := c.Start()
+	if err != nil {
+		c.value.Set("error", interop.WrapAsJSError(err, "spawn"))
+		go func() {
+			c.emit(c.value, c.events, "error", interop.WrapAsJSError(err, "spawn"))
+			c.release()
+		}()
+		c.closeStdio()
+		return err
+	}
+	go c.emit(c.value, c.events, "spawn")
+	if c.stdout != nil {
+		c.outputs.Add(1)
+		go c.stdout.pump()
+	}
+	if c.stderr != nil {
+		c.outputs.Add(1)
+		go c.stderr.pump()
+	}
+	go c.waitForExit()
+	return nil
+}
+
+func (c *childProcess) waitForExit() {
+	exitCode, err := c.Wait()
+	if c.stdin != nil {
+		c.stdin.exited.Store(true)
+	}
+	if err != nil {
+		c.emit(c.value, c.events, "error", interop.WrapAsJSError(err, "child process"))
+	}
+	code, signal := interface{}(exitCode), interface{}(nil)
+	if name := c.killSignal.Load(); name != "" {
+		code, signal = nil, name
+	}
+	c.value.Set("exitCode", code)
+	c.value.Set("signalCode", signal)
+	c.emit(c.value, c.events, "exit", code, signal)
+
+	if c.stdin != nil {
+		c.stdin.close()
+	}
+	c.outputs.Wait()
+	c.emit(c.value, c.events, "close", code, signal)
+	c.release()
+}
+
+// release frees the Go funcs backing this ChildProcess. Later calls are no-ops, like calling kill() on an exited process.
+func (c *childProcess) release() {
+	c.funcsMu.Lock()
+	defer c.funcsMu.Unlock()
+	for _, stream := range []js.Value{c.value, c.value.Get("stdin"), c.value.Get("stdout"), c.value.Get("stderr")} {
+		if stream.IsNull() {
+			continue
+		}
+		for _, name := range append(emitterFuncs, "kill", "write", "end", "setEncoding") {
+			if !stream.Get(name).IsUndefined() {
+				stream.Set(name, jsNoop)
+			}
+		}
+	}
+	for _, fn := range c.funcs {
+		fn.Release()
+	}
+	c.funcs = nil
+}
+
+func (c *childProcess) closeStdio() {
+	if c.stdin != nil {
+		c.stdin.close()
+	}
+	for _, output := range []*childOutput{c.stdout, c.stderr} {
+		if output != nil {
+			_ = c.parent.Files().Close(output.fid)
+		}
+	}
+}
+
+// kill sends a signal to the process: kill([signal]), where signal is a name like 'SIGTERM' or a number. Defaults to SIGTERM.
+func (c *childProcess) kill(args []js.Value) interface{} {
+	signal, name := process.SignalTerminate, "SIGTERM"
+	if len(args) > 0 && args[0].Truthy() {
+		var ok bool
+		signal, name, ok = parseSignal(args[0])
+		if !ok {
+			log.Error("kill: unknown signal: ", args[0].String())
+			return false
+		}
+	}
+	return c.signal(signal, name)
+}
+
+func (c *childProcess) signal(signal int, name string) bool {
+	if err := c.Kill(signal); err != nil {
+		return false
+	}
+	if signal != 0 && !process.SignalIgnored(signal) {
+		c.killSignal.Store(name)
+		c.value.Set("killed", true)
+	}
+	return true
+}
+
+var signalNames = map[string]int{
+	"SIGHUP":   process.SignalHangUp,
+	"SIGINT":   process.SignalInterrupt,
+	"SIGQUIT":  process.SignalQuit,
+	"SIGKILL":  process.SignalKill,
+	"SIGTERM":  process.SignalTerminate,
+	"SIGTSTP":  process.SignalTerminalStop,
+	"SIGWINCH": process.SignalWindowChange,
+}
+
+func parseSignal(value js.Value) (signal int, name string, ok bool) {
+	if value.Type() == js.TypeNumber {
+		signal = value.Int()
+		for name, number := range signalNames {
+			if number == signal {
+				return signal, name, true
+			}
+		}
+		return signal, "", signal >= 0
+	}
+	name = value.String()
+	signal, ok = signalNames[name]
+	return
+}
+
+// childInput is the writable end of a child's stdin pipe
+type childInput struct {
+	files  *fs.FileDescriptors
+	fid    fs.FID
+	value  js.Value
+	queue  serialQueue
+	closed atomic.Bool
+	exited atomic.Bool // set once the child exits, so writes fail like they would once its read end closed
+}
+
+func (c *childProcess) newInput(fid fs.FID) *childInput {
+	in := &childInput{
+		files: c.parent.Files(),
+		fid:   fid,
+		value: js.ValueOf(map[string]interface{}{}),
+	}
+	c.setFunc(in.value, "write", in.write)
+	c.setFunc(in.value, "end", in.end)
+	return in
+}
+
+// write queues data for the child: write(data[, callback]). Data is a string or Uint8Array.
+func (in *childInput) write(args []js.Value) interface{} {
+	if len(args) == 0 {
+		return false
+	}
+	var data []byte
+	if args[0].InstanceOf(jsUint8Array) {
+		data = make([]byte, args[0].Length())
+		js.CopyBytesToGo(data, args[0])
+	} else {
+		data = []byte(args[0].String())
+	}
+	callback := lastCallback(args[1:])
+	in.queue.Go(func() {
+		// a write blocked on a full pipe fails with EPIPE once the child's read end closes
+		var err error
+		switch {
+		case in.exited.Load():
+			err = fs.ErrBrokenPipe
+		case in.closed.Load():
+			err = os.ErrClosed
+		default:
+			_, err = in.files.Write(in.fid, blob.NewBytes(data), 0, len(data), nil)
+		}
+		if callback.Truthy() {
+			callback.Invoke(interop.WrapAsJSError(err, "write"))
+		}
+	})
+	return true
+}
+
+// end closes the child's stdin after queued writes: end([data][, callback])
+func (in *childInput) end(args []js.Value) interface{} {
+	if len(args) > 0 && args[0].Type() != js.TypeFunction && args[0].Truthy() {
+		in.write(args[:1])
+	}
+	callback := lastCallback(args)
+	in.queue.Go(func() {
+		in.close()
+		if callback.Truthy() {
+			callback.Invoke()
+		}
+	})
+	return in.value
+}
+
+func (in *childInput) close() {
+	if in.closed.CAS(false, true) {
+		_ = in.files.Close(in.fid)
+	}
+}
+
+func lastCallback(args []js.Value) js.Value {
+	if len(args) > 0 && args[len(args)-1].Type() == js.TypeFunction {
+		return args[len(args)-1]
+	}
+	return js.Null()
+}
+
+// childOutput is the readable end of a child's stdout or stderr pipe, emitting 'data' and 'end' events
+type childOutput struct {
+	child    *childProcess
+	fid      fs.FID
+	value    js.Value
+	events   interop.EventTarget
+	encoding atomic.String
+	capture  func(data []byte) // if set before the child is ready, receives output instead of 'data' listeners
+}
+
+func (c *childProcess) newOutput(fid fs.FID) *childOutput {
+	out := &childOutput{
+		child:  c,
+		fid:    fid,
+		value:  js.ValueOf(map[string]interface{}{}),
+		events: interop.NewEventTarget(),
+	}
+	c.setEmitterFuncs(out.value, out.events)
+	c.setFunc(out.value, "setEncoding", func(args []js.Value) interface{} {
+		encoding := "utf8"
+		if len(args) > 0 && args[0].Truthy() {
+			encoding = args[0].String()
+		}
+		out.encoding.Store(encoding)
+		return out.value
+	})
+	return out
+}
+
+func (out *childOutput) pump() {
+	defer out.child.outputs.Done()
+	files := out.child.parent.Files()
+	defer files.Close(out.fid)
+	<-out.child.ready
+
+	const bufSize = 32 << 10
+	buf := blob.NewBytesLength(bufSize)
+	for {
+		n, err := files.Read(out.fid, buf, 0, bufSize, nil)
+		if err != nil {
+			out.child.emit(out.value, out.events, "error", interop.WrapAsJSError(err, "read"))
+			break
+		}
+		if n == 0 {
+			break
+		}
+		if out.capture != nil {
+			out.capture(buf.Bytes()[:n])
+			continue
+		}
+		out.child.emit(out.value, out.events, "data", out.chunk(buf.Bytes()[:n]))
+	}
+	out.child.emit(out.value, out.events, "end")
+}
+
+// chunk converts 'data' into a string if an encoding was set, otherwise a Uint8Array
+func (out *childOutput) chunk(data []byte) interface{} {
+	if out.encoding.Load() != "" {
+		return string(data)
+	}
+	array := jsUint8Array.New(len(data))
+	js.CopyBytesToJS(array, data)
+	return array
+}
+
+// serialQueue runs funcs in submission order without blocking the submitter, which may be a JS event handler
+type serialQueue struct {
+	mu   sync.Mutex
+	last <-chan struct{}
+}
+
+func (q *serialQueue) Go(fn func()) {
+	q.mu.Lock()
+	previous := q.last
+	next := make(chan struct{})
+	q.last = next
+	q.mu.Unlock()
+	go func() {
+		if previous != nil {
+			<-previous
+		}
+		fn()
+		close(next)
+	}()
+}
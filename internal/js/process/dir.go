@@ -10,14 +10,13 @@ import (
 	"github.com/pkg/errors"
 )
 
-func cwd(args []js.Value) (interface{}, error) {
-	return process.Current().WorkingDirectory(), nil
+func cwd(p process.Process, args []js.Value) (interface{}, error) {
+	return p.WorkingDirectory(), nil
 }
 
-func chdir(args []js.Value) (interface{}, error) {
+func chdir(p process.Process, args []js.Value) (interface{}, error) {
 	if len(args) == 0 {
 		return nil, errors.New("a new directory argument is required")
 	}
-	p := process.Current()
 	return nil, p.SetWorkingDirectory(args[0].String())
 }
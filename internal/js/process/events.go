@@ -0,0 +1,80 @@
+//go:build js
+// +build js
+
+package process
+
+import (
+	"syscall/js"
+
+	"github.com/hack-pad/hackpad/internal/common"
+	"github.com/hack-pad/hackpad/internal/interop"
+	"github.com/hack-pad/hackpad/internal/log"
+	"github.com/hack-pad/hackpad/internal/process"
+)
+
+// on listens for kernel events: on('process', callback)
+// The callback receives each process lifecycle event: {type, pid, ppid, argv, time, exitCode, duration, error}
+func on(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 || args[1].Type() != js.TypeFunction {
+		log.Error("on: event name and callback are required")
+		return nil
+	}
+	eventName, callback := args[0].String(), args[1]
+	if eventName != process.ProcessEvent {
+		log.Error("on: unknown event: ", eventName)
+		return nil
+	}
+	process.Events().Listen(eventName, func(_ interop.Event, args ...interface{}) {
+		var err error
+		func() {
+			defer common.CatchException(&err)
+			callback.Invoke(args...)
+		}()
+		if err != nil {
+			log.Error("Process event listener failed: ", err)
+		}
+	})
+	return nil
+}
+
+// processes returns a snapshot of the process table: [{pid, ppid, argv, state, cwd, backend, startTime, elapsed, exitCode, error, files}]
+// Times are milliseconds since the Unix epoch, and durations are milliseconds.
+func processes(this js.Value, args []js.Value) interface{} {
+	snapshot := process.Snapshot()
+	values := make([]interface{}, 0, len(snapshot))
+	for _, info := range snapshot {
+		files := make([]interface{}, 0, len(info.Files))
+		for _, file := range info.Files {
+			files = append(files, map[string]interface{}{
+				"fd":          file.FID.JSValue(),
+				"name":        file.Name,
+				"closeOnExec": file.CloseOnExec,
+				"refs":        file.Refs,
+			})
+		}
+		value := map[string]interface{}{
+			"pid":       info.PID.JSValue(),
+			"ppid":      info.ParentPID.JSValue(),
+			"argv":      interop.SliceFromStrings(info.Args),
+			"state":     info.State,
+			"cwd":       info.WorkingDirectory,
+			"backend":   info.Backend,
+			"startTime": nil,
+			"elapsed":   info.Elapsed.Seconds() * 1000,
+			"exitCode":  nil,
+			"error":     nil,
+			"files":     files,
+		}
+		if !info.StartTime.IsZero() {
+			value["startTime"] = float64(info.StartTime.UnixNano()) / 1e6
+		}
+		if info.Done() {
+			value["exitCode"] = info.ExitCode
+		}
+		if info.Err != "" {
+			value["error"] = info.Err
+		}
+		values = append(values, value)
+	}
+	return values
+}
@@ -0,0 +1,48 @@
+//go:build js
+// +build js
+
+package process
+
+import (
+	"syscall/js"
+
+	"github.com/hack-pad/hackpad/internal/interop"
+	"github.com/hack-pad/hackpad/internal/log"
+	"github.com/hack-pad/hackpad/internal/process"
+	"github.com/pkg/errors"
+)
+
+// execve replaces the calling process's image: execve(path, argv, env, callback)
+// On success the callback is never called, since the calling image never runs again.
+func execve(p process.Process, args []js.Value) interface{} {
+	if len(args) == 0 || args[len(args)-1].Type() != js.TypeFunction {
+		log.Error("execve: callback is required")
+		return nil
+	}
+	callback := args[len(args)-1]
+	args = args[:len(args)-1]
+	pid := p.PID()
+	go func() {
+		err := Exec(pid, args)
+		if err != nil {
+			callback.Invoke(interop.WrapAsJSError(err, "execve"))
+		}
+	}()
+	return nil
+}
+
+func Exec(pid process.PID, args []js.Value) error {
+	if len(args) < 2 {
+		return errors.Errorf("Invalid number of args, expected path and argv: %v", args)
+	}
+	path := args[0].String()
+	argv := interop.StringsFromJSValue(args[1])
+	var env map[string]string
+	if len(args) >= 3 && args[2].Truthy() {
+		env = make(map[string]string)
+		for name, prop := range interop.Entries(args[2]) {
+			env[name] = prop.String()
+		}
+	}
+	return process.Exec(pid, path, argv, env)
+}
@@ -0,0 +1,131 @@
+//go:build js
+// +build js
+
+package process
+
+import (
+	"strings"
+	"sync"
+	"syscall/js"
+
+	"github.com/hack-pad/hackpad/internal/fs"
+	"github.com/hack-pad/hackpad/internal/interop"
+	"github.com/hack-pad/hackpad/internal/process"
+	"github.com/hack-pad/hackpad/internal/promise"
+	"github.com/pkg/errors"
+)
+
+// execCommand runs a command with the shell, buffering its output: exec(command[, options][, callback])
+// The callback receives (err, stdout, stderr). Without a callback, returns a promise resolving to {stdout, stderr}.
+func execCommand(parent process.Process, args []js.Value) (interface{}, error) {
+	if len(args) == 0 {
+		return nil, errors.New("exec: command is required")
+	}
+	command := args[0].String()
+	return execBuffered(parent, "sh", []string{"sh", "-c", command}, command, args[1:])
+}
+
+// execFile runs a file directly, buffering its output: execFile(file[, args][, options][, callback])
+// The callback receives (err, stdout, stderr). Without a callback, returns a promise resolving to {stdout, stderr}.
+func execFile(parent process.Process, args []js.Value) (interface{}, error) {
+	if len(args) == 0 {
+		return nil, errors.New("execFile: file is required")
+	}
+	file := args[0].String()
+	argv := []string{file}
+	args = args[1:]
+	if len(args) > 0 && args[0].InstanceOf(js.Global().Get("Array")) {
+		argv = append(argv, interop.StringsFromJSValue(args[0])...)
+		args = args[1:]
+	}
+	return execBuffered(parent, file, argv, strings.Join(argv, " "), args)
+}
+
+// execBuffered spawns 'command' with piped stdio. 'args' are the optional options object and callback.
+func execBuffered(parent process.Process, command string, argv []string, description string, args []js.Value) (interface{}, error) {
+	callback := lastCallback(args)
+	if callback.Truthy() {
+		args = args[:len(args)-1]
+	}
+	attr := &process.ProcAttr{}
+	if len(args) > 0 && args[0].Type() == js.TypeObject {
+		var err error
+		_, attr, err = parseProcAttr(command, args[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+	attr.Files = []fs.Attr{{Pipe: true}, {Pipe: true}, {Pipe: true}}
+
+	var resolve, reject promise.Resolver
+	var prom promise.JS
+	if !callback.Truthy() {
+		resolve, reject, prom = promise.New()
+	}
+	finish := func(err js.Value, stdout, stderr string) {
+		if callback.Truthy() {
+			callback.Invoke(err, stdout, stderr)
+			return
+		}
+		if !err.IsNull() {
+			err.Set("stdout", stdout)
+			err.Set("stderr", stderr)
+			reject(err)
+			return
+		}
+		resolve(map[string]interface{}{"stdout": stdout, "stderr": stderr})
+	}
+
+	child, err := spawnChild(parent, command, argv, attr)
+	if err != nil {
+		jsErr := interop.WrapAsJSError(err, "spawn")
+		go finish(jsErr, "", "")
+		if callback.Truthy() {
+			return nil, nil
+		}
+		return prom.JSValue(), nil
+	}
+	var mu sync.Mutex
+	var stdout, stderr strings.Builder
+	var spawnErr error
+	for _, output := range []struct {
+		stream *childOutput
+		buf    *strings.Builder
+	}{
+		{child.stdout, &stdout},
+		{child.stderr, &stderr},
+	} {
+		buf := output.buf
+		output.stream.encoding.Store("utf8")
+		output.stream.events.Listen("data", func(_ interop.Event, args ...interface{}) {
+			mu.Lock()
+			buf.WriteString(args[0].(string))
+			mu.Unlock()
+		})
+	}
+	child.events.Listen("error", func(_ interop.Event, args ...interface{}) {
+		mu.Lock()
+		spawnErr = js.Error{Value: args[0].(js.Value)}
+		mu.Unlock()
+	})
+	child.events.Listen("close", func(_ interop.Event, args ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		code, signal := args[0], args[1]
+		jsErr := js.Null()
+		switch {
+		case spawnErr != nil:
+			jsErr = interop.WrapAsJSError(spawnErr, "Command failed: "+description)
+		case code != 0:
+			jsErr = interop.WrapAsJSError(errors.New(stderr.String()), "Command failed: "+description)
+			jsErr.Set("code", code)
+			jsErr.Set("killed", signal != nil)
+			jsErr.Set("signal", signal)
+		}
+		finish(jsErr, stdout.String(), stderr.String())
+	})
+	if callback.Truthy() {
+		return child.value, nil
+	}
+	return prom.JSValue(), nil
+}
@@ -0,0 +1,32 @@
+//go:build js
+// +build js
+
+package process
+
+import (
+	"syscall/js"
+
+	"github.com/hack-pad/hackpad/internal/process"
+)
+
+// hash remembers and returns the PATH locations of commands, like a shell's 'hash' builtin: hash([-r][, ...commands])
+// Returns an object of command names to paths. '-r' forgets all remembered commands first.
+func hash(p process.Process, args []js.Value) (interface{}, error) {
+	var commands []string
+	for _, arg := range args {
+		if arg.String() == "-r" {
+			process.ResetHash()
+			continue
+		}
+		commands = append(commands, arg.String())
+	}
+	found, err := process.Hash(p, commands...)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]interface{}, len(found))
+	for command, path := range found {
+		result[command] = path
+	}
+	return result, nil
+}
@@ -0,0 +1,48 @@
+//go:build js
+// +build js
+
+package process
+
+import (
+	"syscall/js"
+
+	"github.com/hack-pad/hackpad/internal/interop"
+	"github.com/hack-pad/hackpad/internal/process"
+	"github.com/pkg/errors"
+)
+
+var errKillInit = interop.NewError("init can't be signaled", "EPERM")
+
+// kill sends a signal to a process: kill(pid[, signal]), where signal is a name like 'SIGTERM' or a number. Defaults to SIGTERM.
+// Throws if the process doesn't exist, like Node.js's process.kill, or if the caller may not signal it. See process.CanSignal.
+func kill(caller process.Process, args []js.Value) js.Value {
+	if len(args) == 0 || args[0].Type() != js.TypeNumber {
+		return throwValue(errors.New("pid is required"), "kill")
+	}
+	pid := args[0].Int()
+	signal := process.SignalTerminate
+	if len(args) > 1 && args[1].Truthy() {
+		var ok bool
+		signal, _, ok = parseSignal(args[1])
+		if !ok {
+			return throwValue(errors.Errorf("unknown signal: %s", args[1].String()), "kill")
+		}
+	}
+	if pid <= 0 {
+		return throwValue(interop.NewError("process groups are unsupported", "EINVAL"), "kill")
+	}
+	if process.PID(pid) == process.Current().PID() {
+		return throwValue(errKillInit, "kill")
+	}
+	target, ok := process.Get(process.PID(pid))
+	if !ok {
+		return throwValue(process.ErrNoSuchProcess, "kill")
+	}
+	if err := process.CanSignal(caller, target.PID()); err != nil {
+		return throwValue(err, "kill")
+	}
+	if err := target.Kill(signal); err != nil {
+		return throwValue(err, "kill")
+	}
+	return js.ValueOf(true)
+}
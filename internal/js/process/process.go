@@ -6,14 +6,26 @@ package process
 import (
 	"syscall/js"
 
+	"github.com/hack-pad/hackpad/internal/global"
 	"github.com/hack-pad/hackpad/internal/interop"
 	"github.com/hack-pad/hackpad/internal/process"
+	"github.com/hack-pad/hackpad/internal/worker"
 )
 
-var jsProcess = js.Global().Get("process")
+var (
+	jsObject  = js.Global().Get("Object")
+	jsProcess = js.Global().Get("process")
+)
+
+type (
+	syncFunc     = func(p process.Process, args []js.Value) (interface{}, error)
+	callbackFunc = func(p process.Process, args []js.Value) ([]interface{}, error)
+	// throwingFunc returns a value with a 'throw' property to throw it, like Node.js's synchronous funcs
+	throwingFunc = func(p process.Process, args []js.Value) js.Value
+)
 
 func Init() {
-	process.Init(switchedContext)
+	process.Init()
 
 	currentProcess := process.Current()
 	err := currentProcess.Files().MkdirAll(currentProcess.WorkingDirectory(), 0750)
@@ -27,23 +39,94 @@ func Init() {
 	interop.SetFunc(jsProcess, "getgid", getegid)
 	interop.SetFunc(jsProcess, "getegid", getegid)
 	interop.SetFunc(jsProcess, "getgroups", getgroups)
-	jsProcess.Set("pid", currentProcess.PID().JSValue())
-	jsProcess.Set("ppid", currentProcess.ParentPID().JSValue())
 	interop.SetFunc(jsProcess, "umask", umask)
-	interop.SetFunc(jsProcess, "cwd", cwd)
-	interop.SetFunc(jsProcess, "chdir", chdir)
 
 	globals.Set("child_process", map[string]interface{}{})
 	childProcess := globals.Get("child_process")
-	interop.SetFunc(childProcess, "spawn", spawn)
-	// interop.SetFunc(childProcess, "spawnSync", spawnSync) // TODO is there any way to run spawnSync so we don't hit deadlock?
+	childProcess.Set("processes", js.FuncOf(processes))
 	interop.SetFunc(childProcess, "wait", wait)
 	interop.SetFunc(childProcess, "waitSync", waitSync)
+
+	// hackpad itself runs as 'init', and captured these globals on startup, so bind them in place
+	setProcessFuncs(jsProcess, currentProcess)
+	setChildProcessFuncs(childProcess, currentProcess)
+	process.RegisterGlobal("process", func(p process.Process) (js.Value, func()) {
+		value := jsObject.Call("create", jsProcess)
+		return value, setProcessFuncs(value, p)
+	})
+	process.RegisterGlobal("child_process", func(p process.Process) (js.Value, func()) {
+		value := jsObject.Call("create", childProcess)
+		return value, setChildProcessFuncs(value, p)
+	})
+
+	global.Set("startWorkers", js.FuncOf(startWorkers))
+	global.Set("on", js.FuncOf(on))
+	global.Set("processes", js.FuncOf(processes))
+	worker.FillPool()
+}
+
+// setProcessFuncs binds the process-specific parts of 'process' to 'p'
+func setProcessFuncs(value js.Value, p process.Process) (release func()) {
+	value.Set("pid", p.PID().JSValue())
+	value.Set("ppid", p.ParentPID().JSValue())
+	return setFuncs(value, p, map[string]interface{}{
+		"cwd":       cwd,
+		"chdir":     chdir,
+		"getrlimit": getrlimit,
+		"setrlimit": setrlimit,
+		"kill":      kill,
+	})
+}
+
+// setChildProcessFuncs binds 'child_process' to parent process 'p'
+func setChildProcessFuncs(value js.Value, p process.Process) (release func()) {
+	execveFn := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		return execve(p, args)
+	})
+	value.Set("execve", execveFn)
+	release = setFuncs(value, p, map[string]interface{}{
+		"spawn":        spawn,
+		"spawnSync":    spawnSync,
+		"exec":         execCommand,
+		"execSync":     execSync,
+		"execFile":     execFile,
+		"execFileSync": execFileSync,
+		"hash":         hash,
+	})
+	return func() {
+		execveFn.Release()
+		release()
+	}
 }
 
-func switchedContext(pid, ppid process.PID) {
-	jsProcess.Set("pid", pid.JSValue())
-	jsProcess.Set("ppid", ppid.JSValue())
+func setFuncs(value js.Value, p process.Process, fns map[string]interface{}) (release func()) {
+	var funcs []js.Func
+	for name, fn := range fns {
+		var bound interface{}
+		switch fn := fn.(type) {
+		case syncFunc:
+			bound = interop.Func(func(args []js.Value) (interface{}, error) {
+				return fn(p, args)
+			})
+		case callbackFunc:
+			bound = interop.CallbackFunc(func(args []js.Value) ([]interface{}, error) {
+				return fn(p, args)
+			})
+		case throwingFunc:
+			jsFn := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+				return fn(p, args)
+			})
+			value.Set(name, throwShim.Invoke(jsFn))
+			funcs = append(funcs, jsFn)
+			continue
+		}
+		funcs = append(funcs, interop.SetFunc(value, name, bound))
+	}
+	return func() {
+		for _, fn := range funcs {
+			fn.Release()
+		}
+	}
 }
 
 func Dump() interface{} {
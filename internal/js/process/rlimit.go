@@ -0,0 +1,63 @@
+//go:build js
+// +build js
+
+package process
+
+import (
+	"syscall/js"
+
+	"github.com/hack-pad/hackpad/internal/common"
+	"github.com/hack-pad/hackpad/internal/interop"
+	"github.com/hack-pad/hackpad/internal/process"
+	"github.com/pkg/errors"
+)
+
+// getrlimit returns {soft, hard} for a resource
+func getrlimit(p process.Process, args []js.Value) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, errors.Errorf("Invalid number of args, expected 1: %v", args)
+	}
+	soft, hard, err := p.Limits().Get(process.Resource(args[0].String()))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"soft": soft,
+		"hard": hard,
+	}, nil
+}
+
+// setrlimit(resource, soft, [hard], [pid]) updates a resource limit. The hard limit defaults to its current value, and the pid to the calling process.
+func setrlimit(p process.Process, args []js.Value) (interface{}, error) {
+	if len(args) < 2 || len(args) > 4 {
+		return nil, errors.Errorf("Invalid number of args, expected 2 to 4: %v", args)
+	}
+	resource := process.Resource(args[0].String())
+	target := p
+	if len(args) == 4 && args[3].Type() == js.TypeNumber {
+		var ok bool
+		target, ok = process.Get(common.PID(args[3].Int()))
+		if !ok {
+			return nil, interop.NewError("No such process", "ESRCH")
+		}
+	}
+	_, hard, err := target.Limits().Get(resource)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) >= 3 && args[2].Type() == js.TypeNumber {
+		hard = int64(args[2].Int())
+	}
+	return nil, target.SetLimit(p, resource, int64(args[1].Int()), hard)
+}
+
+// parseLimits reads spawn options of the form {cpu: seconds, as: bytes, nofile: count, nproc: count}. Each becomes both the child's soft and hard limit.
+func parseLimits(value js.Value) (process.ResourceLimits, error) {
+	var limits process.ResourceLimits
+	for name, limit := range interop.Entries(value) {
+		if err := limits.Set(process.Resource(name), int64(limit.Int())); err != nil {
+			return process.ResourceLimits{}, err
+		}
+	}
+	return limits, nil
+}
@@ -12,43 +12,83 @@ import (
 	"github.com/pkg/errors"
 )
 
-func spawn(args []js.Value) (interface{}, error) {
+var jsArray = js.Global().Get("Array")
+
+func spawn(parent process.Process, args []js.Value) (interface{}, error) {
+	command, argv, procAttr, _, err := parseSpawnArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	return Spawn(parent, command, argv, procAttr)
+}
+
+// parseSpawnArgs parses spawn's args: (command[, args][, options]). Returns the options object, or undefined if none was passed.
+func parseSpawnArgs(args []js.Value) (command string, argv []string, procAttr *process.ProcAttr, options js.Value, err error) {
+	options = js.Undefined()
 	if len(args) == 0 {
-		return nil, errors.Errorf("Invalid number of args, expected command name: %v", args)
+		return "", nil, nil, options, errors.Errorf("Invalid number of args, expected command name: %v", args)
 	}
 
-	command := args[0].String()
-	argv := []string{command}
-	if len(args) >= 2 {
-		if args[1].Type() != js.TypeObject || args[1].Get("length").IsUndefined() {
-			return nil, errors.New("Second arg must be an array of arguments")
-		}
-		length := args[1].Length()
-		for i := 0; i < length; i++ {
-			argv = append(argv, args[1].Index(i).String())
+	command = args[0].String()
+	argv = []string{command}
+	rest := args[1:]
+	if len(rest) > 0 {
+		switch {
+		case jsArray.Call("isArray", rest[0]).Bool():
+			length := rest[0].Length()
+			for i := 0; i < length; i++ {
+				argv = append(argv, rest[0].Index(i).String())
+			}
+			rest = rest[1:]
+		case rest[0].IsUndefined() || rest[0].IsNull():
+			rest = rest[1:]
+		case rest[0].Type() != js.TypeObject:
+			return "", nil, nil, options, errors.New("Second arg must be an array of arguments or an options object")
 		}
 	}
 
-	procAttr := &process.ProcAttr{}
-	if len(args) >= 3 {
-		argv[0], procAttr = parseProcAttr(command, args[2])
+	procAttr = &process.ProcAttr{}
+	if len(rest) > 0 && rest[0].Truthy() {
+		if rest[0].Type() != js.TypeObject {
+			return "", nil, nil, options, errors.New("Options must be an object")
+		}
+		options = rest[0]
+		argv[0], procAttr, err = parseProcAttr(command, options)
 	}
-	return Spawn(command, argv, procAttr)
+	return
 }
 
 type jsWrapper interface {
 	JSValue() js.Value
 }
 
-func Spawn(command string, args []string, attr *process.ProcAttr) (js.Value, error) {
-	p, err := process.New(command, args, attr)
-	if err != nil {
+// Spawn starts a child of 'parent', returning a Node.js-style ChildProcess
+func Spawn(parent process.Process, command string, args []string, attr *process.ProcAttr) (js.Value, error) {
+	child, err := spawnChild(parent, command, args, attr)
+	if child == nil {
 		return js.Value{}, err
 	}
-	return p.(jsWrapper).JSValue(), p.Start()
+	return child.value, err
+}
+
+func spawnChild(parent process.Process, command string, args []string, attr *process.ProcAttr) (*childProcess, error) {
+	stdio, err := openStdio(parent, attr)
+	if err != nil {
+		return nil, err
+	}
+	p, err := process.New(parent, command, args, attr)
+	stdio.closeChildEnds()
+	if err != nil {
+		for _, fid := range stdio.parentEnds {
+			_ = stdio.files.Close(fid)
+		}
+		return nil, err
+	}
+	child := newChildProcess(parent, p, stdio)
+	return child, child.start()
 }
 
-func parseProcAttr(defaultCommand string, value js.Value) (argv0 string, attr *process.ProcAttr) {
+func parseProcAttr(defaultCommand string, value js.Value) (argv0 string, attr *process.ProcAttr, err error) {
 	argv0 = defaultCommand
 	attr = &process.ProcAttr{}
 	if dir := value.Get("cwd"); dir.Truthy() {
@@ -62,6 +102,10 @@ func parseProcAttr(defaultCommand string, value js.Value) (argv0 string, attr *p
 	}
 
 	if stdio := value.Get("stdio"); stdio.Truthy() {
+		if stdio.Type() == js.TypeString {
+			// a single string applies to stdin, stdout, and stderr
+			stdio = js.ValueOf([]interface{}{stdio, stdio, stdio})
+		}
 		length := stdio.Length()
 		for i := 0; i < length; i++ {
 			file := stdio.Index(i)
@@ -86,5 +130,27 @@ func parseProcAttr(defaultCommand string, value js.Value) (argv0 string, attr *p
 		argv0 = jsArgv0.String()
 	}
 
+	if backend := value.Get("backend"); backend.Truthy() {
+		attr.Backend, err = parseBackend(backend.String())
+		if err != nil {
+			return
+		}
+	}
+
+	if rlimit := value.Get("rlimit"); rlimit.Truthy() {
+		attr.Limits, err = parseLimits(rlimit)
+	}
 	return
 }
+
+// parseBackend parses a backend name: 'main' or 'worker'
+func parseBackend(name string) (process.Backend, error) {
+	switch name {
+	case "main":
+		return process.BackendMain, nil
+	case "worker":
+		return process.BackendWorker, nil
+	default:
+		return process.BackendDefault, errors.Errorf("Unknown backend: %q", name)
+	}
+}
@@ -0,0 +1,272 @@
+//go:build js
+// +build js
+
+package process
+
+import (
+	"runtime"
+	"strings"
+	"sync"
+	"syscall/js"
+	"time"
+
+	"github.com/hack-pad/hackpad/internal/fs"
+	"github.com/hack-pad/hackpad/internal/interop"
+	"github.com/hack-pad/hackpad/internal/process"
+	"github.com/hack-pad/hackpad/internal/worker"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+	"github.com/pkg/errors"
+)
+
+var (
+	errTimedOut = interop.NewError("timed out", "ETIMEDOUT")
+
+	// throwShim adapts a Go func into a JS function which throws the returned object's 'throw' property, if set.
+	// Go funcs cannot throw JS exceptions themselves, so this small shim is defined in JS.
+	throwShim = js.Global().Get("Function").New("fn", `
+return function(...args) {
+	const result = fn(...args);
+	if (result && result.throw) {
+		throw Object.assign(new Error(result.throw.message), result.throw);
+	}
+	return result;
+}`)
+)
+
+type syncOptions struct {
+	input      []byte
+	encoding   string
+	timeout    time.Duration
+	killSignal int
+	signalName string
+}
+
+func parseSyncOptions(value js.Value) (syncOptions, error) {
+	options := syncOptions{
+		killSignal: process.SignalTerminate,
+		signalName: "SIGTERM",
+	}
+	if value.Type() != js.TypeObject {
+		return options, nil
+	}
+	if input := value.Get("input"); input.Truthy() {
+		if input.InstanceOf(jsUint8Array) {
+			options.input = make([]byte, input.Length())
+			js.CopyBytesToGo(options.input, input)
+		} else {
+			options.input = []byte(input.String())
+		}
+	}
+	if encoding := value.Get("encoding"); encoding.Truthy() && encoding.String() != "buffer" {
+		options.encoding = encoding.String()
+	}
+	if timeout := value.Get("timeout"); timeout.Truthy() {
+		options.timeout = time.Duration(timeout.Float() * float64(time.Millisecond))
+	}
+	if killSignal := value.Get("killSignal"); killSignal.Truthy() {
+		var ok bool
+		options.killSignal, options.signalName, ok = parseSignal(killSignal)
+		if !ok {
+			return options, errors.Errorf("Unknown signal: %s", killSignal.String())
+		}
+	}
+	return options, nil
+}
+
+// syncResult is the outcome of a child process run to completion
+type syncResult struct {
+	pid            process.PID
+	stdout, stderr []byte
+	status         interface{} // the exit code, or nil if killed by a signal
+	signal         interface{}
+	err            error
+}
+
+func (r *syncResult) output(data []byte, encoding string) interface{} {
+	if encoding != "" {
+		return string(data)
+	}
+	array := jsUint8Array.New(len(data))
+	js.CopyBytesToJS(array, data)
+	return array
+}
+
+// JSValue returns a Node.js-style spawnSync result
+func (r *syncResult) JSValue(encoding string) js.Value {
+	stdout, stderr := r.output(r.stdout, encoding), r.output(r.stderr, encoding)
+	return js.ValueOf(map[string]interface{}{
+		"pid":    r.pid.JSValue(),
+		"output": []interface{}{nil, stdout, stderr},
+		"stdout": stdout,
+		"stderr": stderr,
+		"status": r.status,
+		"signal": r.signal,
+		"error":  interop.WrapAsJSError(r.err, "spawnSync"),
+	})
+}
+
+// spawnSync runs a command to completion: spawnSync(command[, args][, options])
+// Returns {pid, output, stdout, stderr, status, signal, error}.
+func spawnSync(parent process.Process, args []js.Value) js.Value {
+	command, argv, attr, optionsValue, err := parseSpawnArgs(args)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{"error": interop.WrapAsJSError(err, "spawnSync")})
+	}
+	options, err := parseSyncOptions(optionsValue)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{"error": interop.WrapAsJSError(err, "spawnSync")})
+	}
+	result := runSync(parent, command, argv, attr, options)
+	return result.JSValue(options.encoding)
+}
+
+// execSync runs a command with the shell to completion, returning its stdout: execSync(command[, options])
+// Throws if the command fails or exits non-zero.
+func execSync(parent process.Process, args []js.Value) js.Value {
+	if len(args) == 0 {
+		return throwValue(errors.New("command is required"), "execSync")
+	}
+	command := args[0].String()
+	return execBufferedSync(parent, "sh", []string{"sh", "-c", command}, command, args[1:])
+}
+
+// execFileSync runs a file to completion, returning its stdout: execFileSync(file[, args][, options])
+// Throws if the file fails to run or exits non-zero.
+func execFileSync(parent process.Process, args []js.Value) js.Value {
+	if len(args) == 0 {
+		return throwValue(errors.New("file is required"), "execFileSync")
+	}
+	file := args[0].String()
+	argv := []string{file}
+	args = args[1:]
+	if len(args) > 0 && args[0].InstanceOf(js.Global().Get("Array")) {
+		argv = append(argv, interop.StringsFromJSValue(args[0])...)
+		args = args[1:]
+	}
+	return execBufferedSync(parent, file, argv, strings.Join(argv, " "), args)
+}
+
+// execBufferedSync runs 'command' to completion. 'args' is the optional options object.
+func execBufferedSync(parent process.Process, command string, argv []string, description string, args []js.Value) js.Value {
+	attr := &process.ProcAttr{}
+	optionsValue := js.Undefined()
+	if len(args) > 0 && args[0].Type() == js.TypeObject {
+		optionsValue = args[0]
+		var err error
+		_, attr, err = parseProcAttr(command, optionsValue)
+		if err != nil {
+			return throwValue(err, "child_process")
+		}
+	}
+	options, err := parseSyncOptions(optionsValue)
+	if err != nil {
+		return throwValue(err, "child_process")
+	}
+	result := runSync(parent, command, argv, attr, options)
+	if result.err == nil && result.status == 0 {
+		return js.ValueOf(result.output(result.stdout, options.encoding))
+	}
+
+	err = result.err
+	if err == nil {
+		err = errors.New(string(result.stderr))
+	}
+	jsErr := interop.WrapAsJSError(err, "Command failed: "+description)
+	for name, value := range interop.Entries(result.JSValue(options.encoding)) {
+		if name != "error" {
+			jsErr.Set(name, value)
+		}
+	}
+	return js.ValueOf(map[string]interface{}{"throw": jsErr})
+}
+
+// throwValue returns a value for throwShim to throw 'err'
+func throwValue(err error, message string) js.Value {
+	return js.ValueOf(map[string]interface{}{
+		"throw": interop.WrapAsJSError(err, message),
+	})
+}
+
+// runSync runs a child of 'parent' in a Worker, blocking until the child's stdio closes.
+// The kernel can't return to the event loop in the meantime, so it handles the Worker's syscalls in a loop instead, sleeping between polls where the main thread allows it.
+// Missing stdio entries default to pipes, whose output is captured in the result.
+func runSync(parent process.Process, command string, argv []string, attr *process.ProcAttr, options syncOptions) syncResult {
+	if err := worker.Supported(); err != nil {
+		return syncResult{err: err}
+	}
+	for len(attr.Files) < 3 {
+		attr.Files = append(attr.Files, fs.Attr{Pipe: true})
+	}
+	attr.Backend = process.BackendWorker
+	unblock := worker.Block()
+	defer unblock()
+
+	child, err := spawnChild(parent, command, argv, attr)
+	if child == nil {
+		return syncResult{err: err}
+	}
+	result := syncResult{pid: child.PID()}
+	if err != nil {
+		child.setReady()
+		result.err = err
+		return result
+	}
+
+	var mu sync.Mutex
+	for _, output := range []struct {
+		stream *childOutput
+		buf    *[]byte
+	}{
+		{child.stdout, &result.stdout},
+		{child.stderr, &result.stderr},
+	} {
+		if output.stream == nil {
+			continue
+		}
+		buf := output.buf
+		output.stream.capture = func(data []byte) {
+			mu.Lock()
+			*buf = append(*buf, data...)
+			mu.Unlock()
+		}
+	}
+	closed := make(chan struct{})
+	child.events.Listen("error", func(_ interop.Event, args ...interface{}) {
+		mu.Lock()
+		if result.err == nil {
+			result.err = js.Error{Value: args[0].(js.Value)}
+		}
+		mu.Unlock()
+	})
+	child.events.Listen("close", func(_ interop.Event, args ...interface{}) {
+		mu.Lock()
+		result.status, result.signal = args[0], args[1]
+		mu.Unlock()
+		close(closed)
+	})
+	if child.stdin != nil {
+		go func() {
+			if len(options.input) > 0 {
+				_, _ = child.stdin.files.Write(child.stdin.fid, blob.NewBytes(options.input), 0, len(options.input), nil)
+			}
+			child.stdin.close()
+		}()
+	}
+	child.setReady()
+
+	wait := blockedWait{
+		serve: worker.ServeBlocked,
+		sleep: worker.WaitBlocked,
+		yield: runtime.Gosched,
+		now:   time.Now,
+	}
+	wait.until(closed, options.timeout, func() {
+		mu.Lock()
+		result.err = errTimedOut
+		mu.Unlock()
+		child.signal(options.killSignal, options.signalName)
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	return result
+}
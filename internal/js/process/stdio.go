@@ -0,0 +1,72 @@
+//go:build js
+// +build js
+
+package process
+
+import (
+	"os"
+
+	"github.com/hack-pad/hackpad/internal/fs"
+	"github.com/hack-pad/hackpad/internal/process"
+)
+
+// childStdio holds descriptors the parent opened for a child's 'pipe' and 'ignore' stdio
+type childStdio struct {
+	files      *fs.FileDescriptors
+	parentEnds map[int]fs.FID // stdio index to the parent's end of each pipe
+	childEnds  []fs.FID
+}
+
+// openStdio replaces 'pipe' and 'ignore' entries in attr.Files with real descriptors in 'parent'.
+// Call closeChildEnds once the child has inherited them.
+func openStdio(parent process.Process, attr *process.ProcAttr) (_ *childStdio, err error) {
+	stdio := &childStdio{
+		files:      parent.Files(),
+		parentEnds: make(map[int]fs.FID),
+	}
+	defer func() {
+		if err != nil {
+			stdio.closeChildEnds()
+			for _, fid := range stdio.parentEnds {
+				_ = stdio.files.Close(fid)
+			}
+		}
+	}()
+	for i, file := range attr.Files {
+		switch {
+		case file.Ignore:
+			fid, err := stdio.files.Open("/dev/null", os.O_RDWR|fs.OpenCloseOnExec, 0)
+			if err != nil {
+				return nil, err
+			}
+			stdio.childEnds = append(stdio.childEnds, fid)
+			attr.Files[i] = fs.Attr{FID: fid}
+		case file.Pipe:
+			fids, err := stdio.files.Pipe()
+			if err != nil {
+				return nil, err
+			}
+			// neither end may leak into the parent's other children
+			for _, fid := range fids {
+				if err := stdio.files.SetCloseOnExec(fid, true); err != nil {
+					return nil, err
+				}
+			}
+			childEnd, parentEnd := fids[1], fids[0]
+			if i == 0 {
+				childEnd, parentEnd = fids[0], fids[1]
+			}
+			stdio.childEnds = append(stdio.childEnds, childEnd)
+			stdio.parentEnds[i] = parentEnd
+			attr.Files[i] = fs.Attr{FID: childEnd}
+		}
+	}
+	return stdio, nil
+}
+
+func (s *childStdio) closeChildEnds() {
+	for _, fid := range s.childEnds {
+		_ = s.files.Close(fid)
+	}
+	s.childEnds = nil
+}
@@ -0,0 +1,38 @@
+//go:build js
+// +build js
+
+package process
+
+import (
+	"syscall/js"
+
+	"github.com/hack-pad/hackpad/internal/process"
+	"github.com/hack-pad/hackpad/internal/worker"
+)
+
+// startWorkers configures the Worker pool used by spawnSync and Worker-backed processes: startWorkers({wasmExec, poolSize, backend})
+// Set backend to 'worker' to run processes spawned without a backend in Workers.
+// Returns an error message if this environment can't run processes in Workers, i.e. the page is not cross-origin isolated.
+func startWorkers(this js.Value, args []js.Value) interface{} {
+	var options worker.Options
+	if len(args) > 0 && args[0].Type() == js.TypeObject {
+		if wasmExec := args[0].Get("wasmExec"); wasmExec.Truthy() {
+			options.WasmExec = wasmExec.String()
+		}
+		if poolSize := args[0].Get("poolSize"); poolSize.Truthy() {
+			options.PoolSize = poolSize.Int()
+		}
+		if backend := args[0].Get("backend"); backend.Truthy() {
+			defaultBackend, err := parseBackend(backend.String())
+			if err != nil {
+				return err.Error()
+			}
+			process.SetDefaultBackend(defaultBackend)
+		}
+	}
+	worker.Configure(options)
+	if err := worker.Supported(); err != nil {
+		return err.Error()
+	}
+	return nil
+}
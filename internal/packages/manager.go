@@ -0,0 +1,573 @@
+package packages
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpad/internal/fs"
+	"github.com/hack-pad/hackpad/internal/log"
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+	"github.com/pkg/errors"
+)
+
+// Fetcher downloads the file at 'url'. Calls 'progress', if set, with the percentage downloaded.
+type Fetcher func(ctx context.Context, url string, progress func(percentage float64)) (blob.Blob, error)
+
+// ProgressFunc receives the percentage downloaded of package 'name', from 0 to 100
+type ProgressFunc func(name string, percentage float64)
+
+type progressKey struct{}
+
+type forceKey struct{}
+
+// WithForce returns a context which lets installs replace existing files no package installed
+func WithForce(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceKey{}, true)
+}
+
+func forced(ctx context.Context) bool {
+	force, _ := ctx.Value(forceKey{}).(bool)
+	return force
+}
+
+// WithProgress returns a context which reports package download progress to 'progress'
+func WithProgress(ctx context.Context, progress ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, progress)
+}
+
+func progressFromContext(ctx context.Context, name string) func(float64) {
+	progress, ok := ctx.Value(progressKey{}).(ProgressFunc)
+	if !ok || progress == nil {
+		return nil
+	}
+	return func(percentage float64) {
+		progress(name, percentage)
+	}
+}
+
+// Manager installs, upgrades, and removes packages. It is safe for concurrent use.
+type Manager struct {
+	mu        sync.Mutex
+	files     *fs.FileDescriptors
+	baseURL   string
+	fetch     Fetcher
+	binDir    string
+	dataDir   string
+	statePath string
+}
+
+// NewManager returns a Manager installing packages listed in the index at 'baseURL' into 'files'
+func NewManager(files *fs.FileDescriptors, baseURL string, fetch Fetcher) *Manager {
+	return &Manager{
+		files:     files,
+		baseURL:   baseURL,
+		fetch:     fetch,
+		binDir:    binDir,
+		dataDir:   dataDir,
+		statePath: StatePath,
+	}
+}
+
+type state struct {
+	Packages map[string]Installed `json:"packages"`
+	// untracked is true if no installs were ever recorded, so existing files came from hackpad's installer before packages were tracked
+	untracked bool
+}
+
+// Install installs packages 'names' and their dependencies. Installed packages are upgraded if the index has a different build.
+// If the index can't be fetched, already installed packages are left as-is, so hackpad still starts offline.
+func (m *Manager) Install(ctx context.Context, names ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st, err := m.readState()
+	if err != nil {
+		return err
+	}
+	index, err := m.index(ctx)
+	if err != nil {
+		if m.allInstalled(st, names) {
+			log.Warn("Failed to fetch package index, using installed packages: ", err)
+			return nil
+		}
+		return err
+	}
+
+	for _, name := range names {
+		err := m.install(ctx, st, index, name, false, nil)
+		if err != nil {
+			return m.writeStateAfterError(st, err)
+		}
+	}
+	return m.writeState(st)
+}
+
+func (m *Manager) allInstalled(st *state, names []string) bool {
+	for _, name := range names {
+		if _, ok := st.Packages[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// install installs 'name' after its dependencies. 'visiting' detects dependency cycles.
+func (m *Manager) install(ctx context.Context, st *state, index Index, name string, dependency bool, visiting []string) error {
+	for _, visited := range visiting {
+		if visited == name {
+			return errors.Errorf("Dependency cycle: %v -> %s", visiting, name)
+		}
+	}
+	pkg, ok := index.find(name)
+	if !ok {
+		if len(visiting) > 0 {
+			return errors.Errorf("Package %q depends on unknown package %q", visiting[len(visiting)-1], name)
+		}
+		return errors.Errorf("Unknown package: %q", name)
+	}
+	for _, dep := range pkg.Dependencies {
+		if _, installed := st.Packages[dep]; installed {
+			continue
+		}
+		if err := m.install(ctx, st, index, dep, true, append(visiting, name)); err != nil {
+			return err
+		}
+	}
+
+	current, installed := st.Packages[name]
+	if installed {
+		dependency = dependency && current.Dependency
+		if current.SHA256 == pkg.SHA256 && current.Version == pkg.Version {
+			current.Dependency = dependency
+			st.Packages[name] = current
+			return nil
+		}
+	}
+	files, err := m.installFiles(ctx, st, pkg)
+	if err != nil {
+		if len(files) > 0 {
+			// some files were replaced, so record them to be removed or reinstalled later
+			partial := current
+			if !installed {
+				partial = Installed{Name: pkg.Name, Version: pkg.Version, Dependency: dependency, Dependencies: pkg.Dependencies}
+			}
+			partial.SHA256 = ""
+			for _, file := range files {
+				if !contains(partial.Files, file) {
+					partial.Files = append(partial.Files, file)
+				}
+			}
+			st.Packages[name] = partial
+		}
+		return errors.Wrapf(err, "Failed to install %s", name)
+	}
+	if installed {
+		// remove files the new version no longer has
+		for _, file := range current.Files {
+			if !contains(files, file) {
+				m.remove(file)
+			}
+		}
+	}
+	st.Packages[name] = Installed{
+		Name:         pkg.Name,
+		Version:      pkg.Version,
+		SHA256:       pkg.SHA256,
+		Files:        files,
+		Dependency:   dependency,
+		Dependencies: pkg.Dependencies,
+	}
+	log.Print("Install completed: ", name, " ", pkg.Version)
+	return nil
+}
+
+// installFiles downloads and verifies all of a package's files, then moves them into place. Returns the installed paths.
+// If moving a file fails, returns the paths already moved into place along with the error.
+// Progress covers the package's binary, its largest file.
+func (m *Manager) installFiles(ctx context.Context, st *state, pkg Package) ([]string, error) {
+	files, toolPaths, err := m.packageFiles(st, pkg, forced(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	type staged struct {
+		temp, path string
+	}
+	var stagedFiles []staged
+	defer func() {
+		for _, file := range stagedFiles {
+			m.remove(file.temp) // no-op after a successful rename
+		}
+	}()
+	for i, file := range files {
+		var progress func(float64)
+		if i == 0 {
+			progress = progressFromContext(ctx, pkg.Name)
+		}
+		contents, err := m.fetch(ctx, resolveURL(m.baseURL, file.URL), progress)
+		if err != nil {
+			return nil, err
+		}
+		if err := verify(file, contents); err != nil {
+			return nil, err
+		}
+		mode := os.FileMode(file.Mode).Perm()
+		if mode == 0 {
+			mode = 0644
+		}
+		temp, err := m.writeTemp(file.Path, contents, mode)
+		if err != nil {
+			return nil, err
+		}
+		stagedFiles = append(stagedFiles, staged{temp: temp, path: file.Path})
+	}
+	for _, toolPath := range toolPaths {
+		// "#!" runs the binary with the entry's path as its first argument, which it dispatches on
+		script := blob.NewBytes([]byte("#!" + path.Join(m.binDir, pkg.Name) + "\n"))
+		temp, err := m.writeTemp(toolPath, script, 0750)
+		if err != nil {
+			return nil, err
+		}
+		stagedFiles = append(stagedFiles, staged{temp: temp, path: toolPath})
+	}
+
+	// everything is downloaded and verified, so commit
+	var paths []string
+	for _, file := range stagedFiles {
+		if err := m.files.Rename(file.temp, file.path); err != nil {
+			return paths, err
+		}
+		paths = append(paths, file.path)
+	}
+	return paths, nil
+}
+
+// packageFiles returns the files to install for 'pkg', with paths resolved in its install root, and the paths of its tool entries.
+// Existing files no package installed are only replaced if 'force' is true.
+// Returns an error if a path escapes the install root or belongs to another installed package.
+func (m *Manager) packageFiles(st *state, pkg Package, force bool) ([]File, []string, error) {
+	if !validName(pkg.Name) {
+		return nil, nil, errors.Errorf("Invalid package name: %q", pkg.Name)
+	}
+	binURL := pkg.URL
+	if binURL == "" {
+		binURL = pkg.Name + ".wasm"
+	}
+	files := []File{{
+		Path:   path.Join(m.binDir, pkg.Name),
+		URL:    binURL,
+		SHA256: pkg.SHA256,
+		Mode:   0750,
+	}}
+	root := path.Join(m.dataDir, pkg.Name)
+	for _, file := range pkg.Files {
+		if !hackpadfs.ValidPath(file.Path) || file.Path == "." {
+			return nil, nil, errors.Errorf("Invalid file path in package %q: %q", pkg.Name, file.Path)
+		}
+		file.Path = path.Join(root, file.Path)
+		files = append(files, file)
+	}
+	var toolPaths []string
+	for _, tool := range pkg.Tools {
+		if !validName(tool) {
+			return nil, nil, errors.Errorf("Invalid tool name in package %q: %q", pkg.Name, tool)
+		}
+		toolPaths = append(toolPaths, path.Join(m.binDir, tool))
+	}
+
+	owners := fileOwners(st, pkg.Name)
+	seen := make(map[string]bool)
+	checkPath := func(filePath string) error {
+		if owner, owned := owners[filePath]; owned {
+			return errors.Errorf("Package %q conflicts with package %q: both install %s", pkg.Name, owner, filePath)
+		}
+		if seen[filePath] {
+			return errors.Errorf("Package %q installs %s more than once", pkg.Name, filePath)
+		}
+		seen[filePath] = true
+		if contains(st.Packages[pkg.Name].Files, filePath) {
+			return nil
+		}
+		if _, err := m.files.Stat(filePath); err == nil {
+			switch {
+			case force || st.untracked:
+				log.Warn("Package ", pkg.Name, " is replacing ", filePath, ", which no package installed")
+			default:
+				return errors.Errorf("Package %q would replace %s, which no package installed. Install with force to replace it.", pkg.Name, filePath)
+			}
+		}
+		return nil
+	}
+	for _, file := range files {
+		if err := checkPath(file.Path); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, toolPath := range toolPaths {
+		if err := checkPath(toolPath); err != nil {
+			return nil, nil, err
+		}
+	}
+	return files, toolPaths, nil
+}
+
+// validName returns true if 'name' is a single path element, so it can't escape its directory
+func validName(name string) bool {
+	return hackpadfs.ValidPath(name) && name != "." && !strings.Contains(name, "/")
+}
+
+// fileOwners maps each installed file to its package, excluding package 'except'
+func fileOwners(st *state, except string) map[string]string {
+	owners := make(map[string]string)
+	for name, pkg := range st.Packages {
+		if name == except {
+			continue
+		}
+		for _, file := range pkg.Files {
+			owners[file] = name
+		}
+	}
+	return owners
+}
+
+func verify(file File, contents blob.Blob) error {
+	if file.SHA256 == "" {
+		return errors.Errorf("Missing checksum for %s", file.Path)
+	}
+	sum := sha256.Sum256(contents.Bytes())
+	if actual := hex.EncodeToString(sum[:]); actual != file.SHA256 {
+		return errors.Errorf("Checksum mismatch for %s: expected %s, got %s", file.Path, file.SHA256, actual)
+	}
+	return nil
+}
+
+// writeTemp writes 'contents' to a temporary file next to 'filePath', so it can be renamed into place atomically
+func (m *Manager) writeTemp(filePath string, contents blob.Blob, mode os.FileMode) (string, error) {
+	dir, base := path.Split(filePath)
+	if err := m.files.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	temp := path.Join(dir, fmt.Sprintf(".%s.%d.tmp", base, time.Now().UnixNano()))
+	return temp, m.writeFile(temp, contents, mode)
+}
+
+func (m *Manager) writeFile(filePath string, contents blob.Blob, mode os.FileMode) error {
+	fd, err := m.files.Open(filePath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	_, err = m.files.Write(fd, contents, 0, contents.Len(), nil)
+	if closeErr := m.files.Close(fd); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (m *Manager) readFile(filePath string) ([]byte, error) {
+	fd, err := m.files.Open(filePath, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer m.files.Close(fd)
+	r, err := m.files.RawFID(fd)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func (m *Manager) remove(filePath string) {
+	err := m.files.Unlink(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		log.Warn("Failed to remove ", filePath, ": ", err)
+	}
+}
+
+// Uninstall removes packages 'names', then any dependencies no other package needs
+func (m *Manager) Uninstall(names ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st, err := m.readState()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if _, ok := st.Packages[name]; !ok {
+			return errors.Errorf("Package %q is not installed", name)
+		}
+	}
+	for _, name := range names {
+		for _, other := range st.Packages {
+			if contains(other.Dependencies, name) && !contains(names, other.Name) {
+				return errors.Errorf("Package %q is required by %q", name, other.Name)
+			}
+		}
+	}
+
+	for _, name := range names {
+		m.uninstall(st, name)
+	}
+	for removed := true; removed; {
+		removed = false
+		for name, pkg := range st.Packages {
+			if pkg.Dependency && !requiredByAny(st, name) {
+				m.uninstall(st, name)
+				removed = true
+			}
+		}
+	}
+	return m.writeState(st)
+}
+
+func (m *Manager) uninstall(st *state, name string) {
+	owners := fileOwners(st, name)
+	for _, file := range st.Packages[name].Files {
+		if owner, owned := owners[file]; owned {
+			log.Warn("Keeping ", file, " from package ", name, ", since package ", owner, " also installed it")
+			continue
+		}
+		m.remove(file)
+	}
+	delete(st.Packages, name)
+	log.Print("Uninstall completed: ", name)
+}
+
+func requiredByAny(st *state, name string) bool {
+	for _, pkg := range st.Packages {
+		if contains(pkg.Dependencies, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns the installed packages, sorted by name
+func (m *Manager) List() ([]Installed, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st, err := m.readState()
+	if err != nil {
+		return nil, err
+	}
+	installed := make([]Installed, 0, len(st.Packages))
+	for _, pkg := range st.Packages {
+		installed = append(installed, pkg)
+	}
+	sort.Slice(installed, func(a, b int) bool {
+		return installed[a].Name < installed[b].Name
+	})
+	return installed, nil
+}
+
+// Available returns the packages in the index
+func (m *Manager) Available(ctx context.Context) ([]Package, error) {
+	index, err := m.index(ctx)
+	return index.Packages, err
+}
+
+// Upgrade reinstalls packages 'names', or all installed packages if empty, whose index entry has a different version or checksum.
+// Returns the names of upgraded packages.
+func (m *Manager) Upgrade(ctx context.Context, names ...string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st, err := m.readState()
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		for name := range st.Packages {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	index, err := m.index(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var upgraded []string
+	for _, name := range names {
+		current, ok := st.Packages[name]
+		if !ok {
+			return upgraded, errors.Errorf("Package %q is not installed", name)
+		}
+		pkg, ok := index.find(name)
+		if !ok || (pkg.SHA256 == current.SHA256 && pkg.Version == current.Version) {
+			continue
+		}
+		if err := m.install(ctx, st, index, name, current.Dependency, nil); err != nil {
+			return upgraded, m.writeStateAfterError(st, err)
+		}
+		upgraded = append(upgraded, name)
+	}
+	return upgraded, m.writeState(st)
+}
+
+func (m *Manager) index(ctx context.Context) (Index, error) {
+	contents, err := m.fetch(ctx, resolveURL(m.baseURL, IndexFile), nil)
+	if err != nil {
+		return Index{}, errors.Wrap(err, "Failed to fetch package index")
+	}
+	var index Index
+	err = json.Unmarshal(contents.Bytes(), &index)
+	return index, errors.Wrap(err, "Invalid package index")
+}
+
+func (m *Manager) readState() (*state, error) {
+	st := &state{Packages: make(map[string]Installed)}
+	contents, err := m.readFile(m.statePath)
+	if os.IsNotExist(err) {
+		st.untracked = true
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(contents, st); err != nil {
+		return nil, errors.Wrap(err, "Invalid installed packages file")
+	}
+	if st.Packages == nil {
+		st.Packages = make(map[string]Installed)
+	}
+	return st, nil
+}
+
+func (m *Manager) writeState(st *state) error {
+	contents, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	temp, err := m.writeTemp(m.statePath, blob.NewBytes(contents), 0644)
+	if err != nil {
+		return err
+	}
+	return m.files.Rename(temp, m.statePath)
+}
+
+// writeStateAfterError records the packages installed before 'err', so their files aren't orphaned, then returns 'err'
+func (m *Manager) writeStateAfterError(st *state, err error) error {
+	if stateErr := m.writeState(st); stateErr != nil {
+		log.Warn("Failed to record installed packages: ", stateErr)
+	}
+	return err
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,338 @@
+package packages
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/hack-pad/hackpad/internal/fs"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+type testServer map[string][]byte
+
+func (s testServer) fetch(ctx context.Context, url string, progress func(float64)) (blob.Blob, error) {
+	contents, ok := s[url]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	if progress != nil {
+		progress(100)
+	}
+	return blob.NewBytes(contents), nil
+}
+
+// publish adds a package binary and updates the index
+func (s testServer) publish(t *testing.T, pkg Package, binary string) {
+	t.Helper()
+	sum := sha256.Sum256([]byte(binary))
+	pkg.SHA256 = hex.EncodeToString(sum[:])
+	s["wasm/"+pkg.Name+".wasm"] = []byte(binary)
+
+	var index Index
+	if contents, ok := s["wasm/"+IndexFile]; ok {
+		if err := json.Unmarshal(contents, &index); err != nil {
+			t.Fatal(err)
+		}
+	}
+	replaced := false
+	for i := range index.Packages {
+		if index.Packages[i].Name == pkg.Name {
+			index.Packages[i] = pkg
+			replaced = true
+		}
+	}
+	if !replaced {
+		index.Packages = append(index.Packages, pkg)
+	}
+	contents, err := json.Marshal(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s["wasm/"+IndexFile] = contents
+}
+
+func newTestManager(t *testing.T, server testServer) (*Manager, *fs.FileDescriptors) {
+	t.Helper()
+	files, err := fs.NewStdFileDescriptors(1, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(files.CloseAll)
+	root := "/" + t.Name()
+	m := NewManager(files, "wasm", server.fetch)
+	m.binDir = path.Join(root, "bin")
+	m.dataDir = path.Join(root, "usr/share")
+	m.statePath = path.Join(root, "var/lib/hackpad/packages.json")
+	return m, files
+}
+
+// file returns an extra file entry for 'contents', published at 'url'
+func (s testServer) file(filePath, url, contents string) File {
+	sum := sha256.Sum256([]byte(contents))
+	s["wasm/"+url] = []byte(contents)
+	return File{Path: filePath, URL: url, SHA256: hex.EncodeToString(sum[:])}
+}
+
+func readTestFile(t *testing.T, files *fs.FileDescriptors, filePath string) string {
+	t.Helper()
+	m := &Manager{files: files}
+	contents, err := m.readFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(contents)
+}
+
+func installedNames(t *testing.T, m *Manager) []string {
+	t.Helper()
+	installed, err := m.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, pkg := range installed {
+		names = append(names, pkg.Name)
+	}
+	return names
+}
+
+func TestInstallDependenciesAndTools(t *testing.T) {
+	server := testServer{}
+	server.publish(t, Package{Name: "coreutils", Version: "1", Tools: []string{"cat", "grep"}}, "coreutils v1")
+	server.publish(t, Package{Name: "sh", Version: "1", Dependencies: []string{"coreutils"}}, "sh v1")
+	m, files := newTestManager(t, server)
+
+	var progressed []string
+	ctx := WithProgress(context.Background(), func(name string, percentage float64) {
+		progressed = append(progressed, name)
+	})
+	if err := m.Install(ctx, "sh"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(progressed, []string{"coreutils", "sh"}) {
+		t.Errorf("Expected progress for each package download, got %v", progressed)
+	}
+	if names := installedNames(t, m); !reflect.DeepEqual(names, []string{"coreutils", "sh"}) {
+		t.Errorf("Expected sh and its dependency installed, got %v", names)
+	}
+	if contents := readTestFile(t, files, path.Join(m.binDir, "sh")); contents != "sh v1" {
+		t.Errorf("Expected sh binary, got %q", contents)
+	}
+	expectScript := "#!" + path.Join(m.binDir, "coreutils") + "\n"
+	if contents := readTestFile(t, files, path.Join(m.binDir, "grep")); contents != expectScript {
+		t.Errorf("Expected tool entry %q, got %q", expectScript, contents)
+	}
+
+	// removing sh also removes coreutils, since nothing else needs it
+	if err := m.Uninstall("coreutils"); err == nil {
+		t.Error("Expected error uninstalling a required package")
+	}
+	if err := m.Uninstall("sh"); err != nil {
+		t.Fatal(err)
+	}
+	if names := installedNames(t, m); len(names) != 0 {
+		t.Errorf("Expected no packages installed, got %v", names)
+	}
+	if _, err := files.Stat(path.Join(m.binDir, "cat")); !os.IsNotExist(err) {
+		t.Errorf("Expected tool entry to be removed, got: %v", err)
+	}
+}
+
+func TestInstallChecksumMismatch(t *testing.T) {
+	server := testServer{}
+	server.publish(t, Package{Name: "sh", Version: "1"}, "sh v1")
+	m, files := newTestManager(t, server)
+	if err := m.Install(context.Background(), "sh"); err != nil {
+		t.Fatal(err)
+	}
+
+	server.publish(t, Package{Name: "sh", Version: "2"}, "sh v2")
+	server["wasm/sh.wasm"] = []byte("tampered")
+	if _, err := m.Upgrade(context.Background()); err == nil {
+		t.Fatal("Expected checksum error")
+	}
+	if contents := readTestFile(t, files, path.Join(m.binDir, "sh")); contents != "sh v1" {
+		t.Errorf("Expected failed upgrade to keep the old binary, got %q", contents)
+	}
+	entries, err := files.ReadDir(m.binDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected temporary files to be removed, got %v", entries)
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	server := testServer{}
+	server.publish(t, Package{Name: "editor", Version: "1"}, "editor v1")
+	server.publish(t, Package{Name: "sh", Version: "1"}, "sh v1")
+	m, files := newTestManager(t, server)
+	if err := m.Install(context.Background(), "editor", "sh"); err != nil {
+		t.Fatal(err)
+	}
+
+	server.publish(t, Package{Name: "sh", Version: "2"}, "sh v2")
+	upgraded, err := m.Upgrade(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(upgraded, []string{"sh"}) {
+		t.Errorf("Expected only sh to upgrade, got %v", upgraded)
+	}
+	if contents := readTestFile(t, files, path.Join(m.binDir, "sh")); contents != "sh v2" {
+		t.Errorf("Expected upgraded binary, got %q", contents)
+	}
+
+	// offline, installed packages still "install"
+	delete(server, "wasm/"+IndexFile)
+	if err := m.Install(context.Background(), "sh"); err != nil {
+		t.Errorf("Expected installed package to succeed offline, got: %v", err)
+	}
+	if err := m.Install(context.Background(), "procps"); err == nil {
+		t.Error("Expected error installing a new package offline")
+	}
+}
+
+func TestInstallExtraFiles(t *testing.T) {
+	server := testServer{}
+	server.publish(t, Package{Name: "sh", Version: "1", Files: []File{
+		server.file("profile", "sh.profile", "export PS1='$ '"),
+	}}, "sh v1")
+	m, files := newTestManager(t, server)
+	if err := m.Install(context.Background(), "sh"); err != nil {
+		t.Fatal(err)
+	}
+	profile := path.Join(m.dataDir, "sh", "profile")
+	if contents := readTestFile(t, files, profile); contents != "export PS1='$ '" {
+		t.Errorf("Expected profile in the package's directory, got %q", contents)
+	}
+	if err := m.Uninstall("sh"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := files.Stat(profile); !os.IsNotExist(err) {
+		t.Errorf("Expected profile to be removed, got: %v", err)
+	}
+}
+
+func TestInstallInvalidPaths(t *testing.T) {
+	for _, tc := range []struct {
+		description string
+		pkg         Package
+	}{
+		{"absolute file path", Package{Name: "evil", Files: []File{{Path: "/etc/profile"}}}},
+		{"parent file path", Package{Name: "evil", Files: []File{{Path: "../../etc/profile"}}}},
+		{"empty file path", Package{Name: "evil", Files: []File{{Path: ""}}}},
+		{"tool path", Package{Name: "evil", Tools: []string{"../etc/profile"}}},
+		{"tool dot", Package{Name: "evil", Tools: []string{".."}}},
+		{"package name", Package{Name: "../evil"}},
+		{"duplicate tool", Package{Name: "evil", Tools: []string{"evil"}}},
+	} {
+		t.Run(tc.description, func(t *testing.T) {
+			server := testServer{}
+			server.publish(t, tc.pkg, "evil")
+			m, files := newTestManager(t, server)
+			if err := m.Install(context.Background(), tc.pkg.Name); err == nil {
+				t.Fatal("Expected invalid path error")
+			}
+			if names := installedNames(t, m); len(names) != 0 {
+				t.Errorf("Expected nothing installed, got %v", names)
+			}
+			if _, err := files.Stat(m.binDir); !os.IsNotExist(err) {
+				t.Errorf("Expected no files written, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestInstallConflict(t *testing.T) {
+	server := testServer{}
+	server.publish(t, Package{Name: "sh", Version: "1"}, "sh v1")
+	server.publish(t, Package{Name: "busybox", Version: "1", Tools: []string{"cat", "sh"}}, "busybox v1")
+	m, files := newTestManager(t, server)
+	if err := m.Install(context.Background(), "sh"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Install(context.Background(), "busybox"); err == nil {
+		t.Fatal("Expected conflict error")
+	}
+	if contents := readTestFile(t, files, path.Join(m.binDir, "sh")); contents != "sh v1" {
+		t.Errorf("Expected sh to be untouched, got %q", contents)
+	}
+	if _, err := files.Stat(path.Join(m.binDir, "cat")); !os.IsNotExist(err) {
+		t.Errorf("Expected no tool entries installed, got: %v", err)
+	}
+}
+
+func TestInstallRecordsPackagesBeforeFailure(t *testing.T) {
+	server := testServer{}
+	server.publish(t, Package{Name: "editor", Version: "1"}, "editor v1")
+	server.publish(t, Package{Name: "sh", Version: "1"}, "sh v1")
+	server["wasm/sh.wasm"] = []byte("tampered")
+	m, files := newTestManager(t, server)
+	if err := m.Install(context.Background(), "editor", "sh"); err == nil {
+		t.Fatal("Expected checksum error")
+	}
+	if names := installedNames(t, m); !reflect.DeepEqual(names, []string{"editor"}) {
+		t.Errorf("Expected editor to be recorded, got %v", names)
+	}
+	if err := m.Uninstall("editor"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := files.Stat(path.Join(m.binDir, "editor")); !os.IsNotExist(err) {
+		t.Errorf("Expected editor to be removed, got: %v", err)
+	}
+}
+
+func TestInstallUnownedFile(t *testing.T) {
+	server := testServer{}
+	server.publish(t, Package{Name: "sh", Version: "1"}, "sh v1")
+	server.publish(t, Package{Name: "coreutils", Version: "1", Tools: []string{"cat"}}, "coreutils v1")
+	m, files := newTestManager(t, server)
+	if err := m.Install(context.Background(), "sh"); err != nil {
+		t.Fatal(err)
+	}
+	catPath := path.Join(m.binDir, "cat")
+	if err := m.writeFile(catPath, blob.NewBytes([]byte("my cat")), 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Install(context.Background(), "coreutils"); err == nil {
+		t.Fatal("Expected error replacing a file no package installed")
+	}
+	if contents := readTestFile(t, files, catPath); contents != "my cat" {
+		t.Errorf("Expected cat to be untouched, got %q", contents)
+	}
+	if err := m.Install(WithForce(context.Background()), "coreutils"); err != nil {
+		t.Fatal(err)
+	}
+	if contents := readTestFile(t, files, catPath); contents == "my cat" {
+		t.Error("Expected forced install to replace cat")
+	}
+}
+
+func TestInstallUntrackedFiles(t *testing.T) {
+	server := testServer{}
+	server.publish(t, Package{Name: "sh", Version: "1"}, "sh v1")
+	m, files := newTestManager(t, server)
+	// installed before packages were tracked
+	shPath := path.Join(m.binDir, "sh")
+	if err := files.MkdirAll(m.binDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.writeFile(shPath, blob.NewBytes([]byte("old sh")), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Install(context.Background(), "sh"); err != nil {
+		t.Fatal(err)
+	}
+	if contents := readTestFile(t, files, shPath); contents != "sh v1" {
+		t.Errorf("Expected sh to be replaced, got %q", contents)
+	}
+}
@@ -0,0 +1,71 @@
+// Package packages installs programs into hackpad from a JSON index served next to their Wasm binaries.
+package packages
+
+import "strings"
+
+const (
+	// IndexFile is the name of the package index, relative to the base URL
+	IndexFile = "index.json"
+	// StatePath records installed packages
+	StatePath = "/var/lib/hackpad/packages.json"
+	binDir    = "/bin"
+	// dataDir holds each package's extra files, in a directory named after the package
+	dataDir = "/usr/share"
+)
+
+// Index lists the packages available to install
+type Index struct {
+	Packages []Package `json:"packages"`
+}
+
+// Package is an installable program and its extra files
+type Package struct {
+	Name         string   `json:"name"`
+	Version      string   `json:"version"`
+	SHA256       string   `json:"sha256"`        // hex checksum of the binary
+	URL          string   `json:"url,omitempty"` // binary location, defaults to <name>.wasm next to the index
+	Dependencies []string `json:"dependencies,omitempty"`
+	// Tools are commands provided by a multi-call binary, like BusyBox. Each gets an entry in /bin that runs the binary.
+	Tools []string `json:"tools,omitempty"`
+	Files []File   `json:"files,omitempty"`
+}
+
+// File is an extra file installed with a package
+type File struct {
+	Path   string `json:"path"` // slash-separated path, relative to the package's directory in /usr/share
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+	Mode   uint32 `json:"mode,omitempty"` // permission bits, defaults to 0644
+}
+
+// Installed is a package recorded as installed
+type Installed struct {
+	Name    string   `json:"name"`
+	Version string   `json:"version"`
+	SHA256  string   `json:"sha256"` // empty if an install failed part way, so it's installed again
+	Files   []string `json:"files"`
+	// Dependency is set if the package was only installed as another package's dependency
+	Dependency   bool     `json:"dependency,omitempty"`
+	Dependencies []string `json:"dependencies,omitempty"`
+}
+
+// find returns the package named 'name'
+func (i Index) find(name string) (Package, bool) {
+	for _, pkg := range i.Packages {
+		if pkg.Name == name {
+			return pkg, true
+		}
+	}
+	return Package{}, false
+}
+
+// resolveURL returns 'url' relative to 'baseURL', unless it's absolute
+func resolveURL(baseURL, url string) string {
+	if strings.Contains(url, "://") || strings.HasPrefix(url, "/") {
+		return url
+	}
+	if baseURL == "" {
+		return url
+	}
+	return strings.TrimSuffix(baseURL, "/") + "/" + url
+}
@@ -2,12 +2,51 @@ package process
 
 import (
 	"github.com/hack-pad/hackpad/internal/fs"
+	"go.uber.org/atomic"
 )
 
 // ProcAttr is functionally identical to os.ProcAttr.
 // Env is structured as a map (instead of key=value pairs), and files is purely a list of nil-able file descriptor IDs. nil FIDs are to be effectively closed to the new process.
+// Limits bounds the new process's resource usage. Each one set becomes both its soft and hard limit, and may not exceed the parent's hard limit. Unset limits are inherited from the parent.
+// Backend selects where the process image runs.
 type ProcAttr struct {
-	Dir   string
-	Env   map[string]string
-	Files []fs.Attr
+	Dir     string
+	Env     map[string]string
+	Files   []fs.Attr
+	Limits  ResourceLimits
+	Backend Backend
+}
+
+// Backend is where a process image runs
+type Backend int
+
+const (
+	// BackendDefault uses the kernel's default backend, set with SetDefaultBackend
+	BackendDefault Backend = iota
+	// BackendMain runs the process image on the kernel's thread
+	BackendMain
+	// BackendWorker runs Go js/wasm process images in a dedicated Worker, proxying syscalls to the kernel.
+	// Falls back to BackendMain if Workers are unsupported.
+	BackendWorker
+)
+
+var defaultBackend = atomic.NewInt64(int64(BackendMain))
+
+// SetDefaultBackend sets the backend for processes spawned without one
+func SetDefaultBackend(backend Backend) {
+	if backend == BackendDefault {
+		backend = BackendMain
+	}
+	defaultBackend.Store(int64(backend))
+}
+
+func (b Backend) String() string {
+	switch b {
+	case BackendMain:
+		return "main"
+	case BackendWorker:
+		return "worker"
+	default:
+		return "default"
+	}
 }
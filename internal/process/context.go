@@ -2,27 +2,26 @@ package process
 
 import (
 	"strings"
+	"sync"
 	"syscall"
 
 	"github.com/hack-pad/hackpad/internal/fs"
-	"github.com/hack-pad/hackpad/internal/log"
 )
 
 const initialDirectory = "/home/me"
 
 var (
-	currentPID PID
-
-	switchedContextListener func(newPID, parentPID PID)
+	pidsMu sync.RWMutex
+	pids   = make(map[PID]*process)
 )
 
-func Init(switchedContext func(PID, PID)) {
+func Init() {
 	// create 'init' process
 	fileDescriptors, err := fs.NewStdFileDescriptors(minPID, initialDirectory)
 	if err != nil {
 		panic(err)
 	}
-	p, err := newWithCurrent(
+	p, err := newWithParent(
 		&process{fileDescriptors: fileDescriptors},
 		minPID,
 		"",
@@ -33,34 +32,45 @@ func Init(switchedContext func(PID, PID)) {
 		panic(err)
 	}
 	p.state = stateRunning
-	pids[minPID] = p
-
-	switchedContextListener = switchedContext
-	switchContext(minPID)
-}
-
-func switchContext(pid PID) (prev PID) {
-	prev = currentPID
-	log.Debug("Switching context from PID ", prev, " to ", pid)
-	if pid == prev {
-		return
-	}
-	newProcess := pids[pid]
-	currentPID = pid
-	switchedContextListener(pid, newProcess.parentPID)
-	return
+	setPID(p)
 }
 
+// Current returns the 'init' process, which owns hackpad's own runtime.
+// Syscalls from other processes are bound to their owning process instead, so they never resolve to Current.
 func Current() Process {
-	process, _ := Get(currentPID)
+	process, _ := Get(minPID)
 	return process
 }
 
 func Get(pid PID) (process Process, ok bool) {
+	p, ok := getPID(pid)
+	return p, ok
+}
+
+func getPID(pid PID) (*process, bool) {
+	pidsMu.RLock()
+	defer pidsMu.RUnlock()
 	p, ok := pids[pid]
 	return p, ok
 }
 
+func setPID(p *process) {
+	pidsMu.Lock()
+	pids[p.pid] = p
+	pidsMu.Unlock()
+}
+
+// allProcesses returns a snapshot of every process
+func allProcesses() []*process {
+	pidsMu.RLock()
+	defer pidsMu.RUnlock()
+	processes := make([]*process, 0, len(pids))
+	for _, p := range pids {
+		processes = append(processes, p)
+	}
+	return processes
+}
+
 func splitEnvPairs(pairs []string) map[string]string {
 	env := make(map[string]string)
 	for _, pair := range pairs {
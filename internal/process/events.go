@@ -0,0 +1,88 @@
+package process
+
+import (
+	"time"
+)
+
+// EventType is a stage in a process's lifecycle
+type EventType string
+
+const (
+	EventSpawn        EventType = "spawn"
+	EventCompileStart EventType = "compileStart"
+	EventCompileEnd   EventType = "compileEnd"
+	EventRunning      EventType = "running"
+	EventExit         EventType = "exit"
+	EventError        EventType = "error"
+	EventSignal       EventType = "signal" // an ignored signal was sent, like SIGWINCH when its terminal is resized
+)
+
+// Event describes a process's lifecycle change
+type Event struct {
+	Type      EventType
+	PID       PID
+	ParentPID PID
+	Args      []string
+	Time      time.Time
+	ExitCode  int           // set for EventExit
+	Duration  time.Duration // time spent compiling for EventCompileEnd, or running for EventExit
+	Err       error         // set for EventError, and EventCompileEnd if compiling failed
+	Signal    int           // set for EventSignal
+}
+
+func (p *process) newEvent(eventType EventType) Event {
+	p.mu.Lock()
+	args := p.args
+	p.mu.Unlock()
+	return Event{
+		Type:      eventType,
+		PID:       p.pid,
+		ParentPID: p.parentPID,
+		Args:      args,
+		Time:      time.Now(),
+	}
+}
+
+// setState moves the process to 'state', emitting compile and running events
+func (p *process) setState(state processState) {
+	p.mu.Lock()
+	previous := p.state
+	p.state = state
+	p.mu.Unlock()
+	switch state {
+	case stateCompiling:
+		p.compileStart = time.Now()
+		emitEvent(p.newEvent(EventCompileStart))
+	case stateRunning:
+		if previous == stateCompiling {
+			p.emitCompileEnd(nil)
+		}
+		emitEvent(p.newEvent(EventRunning))
+	}
+}
+
+func (p *process) emitCompileEnd(err error) {
+	event := p.newEvent(EventCompileEnd)
+	event.Duration = event.Time.Sub(p.compileStart)
+	event.Err = err
+	emitEvent(event)
+}
+
+// emitExit emits the process's final events. Must be called before the process's state changes to done or error.
+func (p *process) emitExit(err error) {
+	if p.getState() == stateCompiling {
+		p.emitCompileEnd(err)
+	}
+	if err != nil {
+		event := p.newEvent(EventError)
+		event.Err = err
+		emitEvent(event)
+	}
+	event := p.newEvent(EventExit)
+	event.ExitCode, _ = p.exitStatus()
+	p.limitsMu.Lock()
+	startTime := p.startTime
+	p.limitsMu.Unlock()
+	event.Duration = event.Time.Sub(startTime)
+	emitEvent(event)
+}
@@ -0,0 +1,49 @@
+//go:build js
+// +build js
+
+package process
+
+import (
+	"syscall/js"
+
+	"github.com/hack-pad/hackpad/internal/interop"
+)
+
+// ProcessEvent is the name of lifecycle events emitted on Events
+const ProcessEvent = "process"
+
+var events = interop.NewEventTarget()
+
+// Events returns the target for process lifecycle events. Each ProcessEvent's only arg is the Event as a JS object.
+func Events() interop.EventTarget {
+	return events
+}
+
+func emitEvent(event Event) {
+	events.Emit(interop.Event{Target: js.Null(), Type: ProcessEvent}, event.JSValue())
+}
+
+// JSValue returns the event as a JS object: {type, pid, ppid, argv, time, exitCode, duration, error, signal}
+// Times are milliseconds since the Unix epoch, and durations are milliseconds.
+func (e Event) JSValue() js.Value {
+	value := map[string]interface{}{
+		"type": string(e.Type),
+		"pid":  e.PID.JSValue(),
+		"ppid": e.ParentPID.JSValue(),
+		"argv": interop.SliceFromStrings(e.Args),
+		"time": float64(e.Time.UnixNano()) / 1e6,
+	}
+	switch e.Type {
+	case EventExit:
+		value["exitCode"] = e.ExitCode
+		value["duration"] = e.Duration.Seconds() * 1000
+	case EventCompileEnd:
+		value["duration"] = e.Duration.Seconds() * 1000
+	case EventSignal:
+		value["signal"] = e.Signal
+	}
+	if e.Err != nil {
+		value["error"] = interop.WrapAsJSError(e.Err, string(e.Type))
+	}
+	return js.ValueOf(value)
+}
@@ -0,0 +1,6 @@
+//go:build !js
+// +build !js
+
+package process
+
+func emitEvent(Event) {}
@@ -0,0 +1,57 @@
+package process
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Exec replaces the process image of 'pid' with the program at 'path', like execve(2).
+// The PID, working directory, and file descriptors are retained, except for descriptors marked close-on-exec.
+// Waiters on the process continue waiting until the new image exits.
+// If env is nil, the current environment is kept.
+func Exec(pid PID, path string, argv []string, env map[string]string) error {
+	p, ok := getPID(pid)
+	if !ok {
+		return errors.Errorf("Unknown process: %d", pid)
+	}
+	return p.exec(path, argv, env)
+}
+
+func (p *process) exec(path string, argv []string, env map[string]string) error {
+	if state := p.getState(); state != stateRunning {
+		return errors.Errorf("Cannot exec process %d in state %q", p.pid, state)
+	}
+	// resolve the new image before committing, so a failed exec leaves the current image running
+	command, argv, format, err := p.prepExecutable(path, argv)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	if p.state != stateRunning { // exited or replaced while resolving the new image
+		state := p.state
+		p.mu.Unlock()
+		return errors.Errorf("Cannot exec process %d in state %q", p.pid, state)
+	}
+	p.command = path
+	p.args = argv
+	p.format = format
+	if env != nil {
+		p.attr.Env = env
+	}
+	p.state = statePending
+	p.mu.Unlock()
+
+	p.fileDescriptors.CloseOnExec()
+	p.execs <- command
+	return nil
+}
+
+// pendingExec returns the path of the next process image, if Exec was called before the current image exited
+func (p *process) pendingExec() (path string, ok bool) {
+	select {
+	case path := <-p.execs:
+		return path, true
+	default:
+		return "", false
+	}
+}
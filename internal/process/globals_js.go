@@ -0,0 +1,75 @@
+//go:build js
+// +build js
+
+package process
+
+import (
+	"sort"
+	"syscall/js"
+)
+
+// GlobalBuilder creates a process's own copy of a JS global, like 'fs'. Its syscalls must act on 'p' alone.
+// The returned release func is called once the process image exits.
+type GlobalBuilder func(p Process) (value js.Value, release func())
+
+var globalBuilders = make(map[string]GlobalBuilder)
+
+// RegisterGlobal binds the JS global 'name' per process. Must be called before any process starts.
+//
+// Go's syscall package captures globals like 'fs' and 'process' when its instance starts,
+// so each Go instance is bound to its owning process's globals for its entire life.
+func RegisterGlobal(name string, builder GlobalBuilder) {
+	globalBuilders[name] = builder
+}
+
+// processGlobals are the JS globals bound to a single process
+type processGlobals struct {
+	names    []string
+	values   []js.Value
+	releases []func()
+}
+
+func (p *process) newGlobals() *processGlobals {
+	g := &processGlobals{}
+	for name := range globalBuilders {
+		g.names = append(g.names, name)
+	}
+	sort.Strings(g.names)
+	for _, name := range g.names {
+		value, release := globalBuilders[name](p)
+		g.values = append(g.values, value)
+		g.releases = append(g.releases, release)
+	}
+	return g
+}
+
+// enter installs the process's globals while its instance runs synchronously, then restores the previous globals on exit.
+// Calls into a Go instance never overlap in JS's single thread, so the swap is only visible to the instance and wasm_exec.js.
+func (g *processGlobals) enter() (exit func()) {
+	global := js.Global()
+	prev := make([]js.Value, len(g.names))
+	for i, name := range g.names {
+		prev[i] = global.Get(name)
+		global.Set(name, g.values[i])
+	}
+	return func() {
+		for i, name := range g.names {
+			global.Set(name, prev[i])
+		}
+	}
+}
+
+// object returns the globals by name
+func (g *processGlobals) object() map[string]js.Value {
+	object := make(map[string]js.Value, len(g.names))
+	for i, name := range g.names {
+		object[name] = g.values[i]
+	}
+	return object
+}
+
+func (g *processGlobals) release() {
+	for _, release := range g.releases {
+		release()
+	}
+}
@@ -0,0 +1,125 @@
+package process
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/hack-pad/hackpad/internal/fs"
+)
+
+var executables = newPathCache()
+
+func init() {
+	fs.OnChange(executables.invalidate)
+}
+
+// pathCache remembers where commands were found in PATH, like a shell's 'hash' builtin.
+// Entries are forgotten when a file in one of their PATH directories changes.
+type pathCache struct {
+	mu         sync.Mutex
+	entries    map[pathCacheKey]string
+	generation uint64 // incremented on every invalidation, so lookups racing with a change aren't cached
+}
+
+type pathCacheKey struct {
+	pathVar, file string
+}
+
+func newPathCache() *pathCache {
+	return &pathCache{entries: make(map[pathCacheKey]string)}
+}
+
+// lookPath is lookPath with caching. Commands found in relative PATH directories are not cached, since they depend on the working directory.
+func (c *pathCache) lookPath(stat stater, pathVar, file string) (string, error) {
+	if strings.Contains(file, "/") {
+		return lookPath(stat, pathVar, file)
+	}
+	key := pathCacheKey{pathVar: pathVar, file: file}
+	c.mu.Lock()
+	path, ok := c.entries[key]
+	generation := c.generation
+	c.mu.Unlock()
+	if ok {
+		return path, nil
+	}
+
+	path, err := lookPath(stat, pathVar, file)
+	if err != nil || !filepath.IsAbs(path) {
+		return path, err
+	}
+	c.mu.Lock()
+	if c.generation == generation {
+		c.entries[key] = path
+	}
+	c.mu.Unlock()
+	return path, nil
+}
+
+// invalidate forgets commands whose PATH includes the parent directory of 'changedPath', or a directory beneath it
+func (c *pathCache) invalidate(changedPath string) {
+	changedPath = filepath.Clean(changedPath)
+	parent := filepath.Dir(changedPath)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	for key := range c.entries {
+		for _, dir := range filepath.SplitList(key.pathVar) {
+			dir = filepath.Clean(dir)
+			if dir == parent || isWithin(dir, changedPath) {
+				delete(c.entries, key)
+				break
+			}
+		}
+	}
+}
+
+func isWithin(path, dir string) bool {
+	return path == dir || dir == "/" || strings.HasPrefix(path, dir+"/")
+}
+
+// reset forgets all commands, like 'hash -r'
+func (c *pathCache) reset() {
+	c.mu.Lock()
+	c.generation++
+	c.entries = make(map[pathCacheKey]string)
+	c.mu.Unlock()
+}
+
+// commands returns each remembered command's path, for the current PATH
+func (c *pathCache) commands(pathVar string) map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	commands := make(map[string]string)
+	for key, path := range c.entries {
+		if key.pathVar == pathVar {
+			commands[key.file] = path
+		}
+	}
+	return commands
+}
+
+// Hash remembers and returns the locations of 'commands' in PATH, like a shell's 'hash' builtin. Relative PATH directories are resolved from p's working directory.
+// Without commands, returns all remembered commands.
+func Hash(p Process, commands ...string) (map[string]string, error) {
+	pathVar := os.Getenv("PATH")
+	if len(commands) == 0 {
+		return executables.commands(pathVar), nil
+	}
+	files := p.Files()
+	found := make(map[string]string, len(commands))
+	for _, command := range commands {
+		path, err := executables.lookPath(files.Stat, pathVar, command)
+		if err != nil {
+			return nil, err
+		}
+		found[command] = path
+	}
+	return found, nil
+}
+
+// ResetHash forgets all remembered commands, like 'hash -r'
+func ResetHash() {
+	executables.reset()
+}
@@ -0,0 +1,64 @@
+package process
+
+import (
+	"os"
+	"testing"
+)
+
+type testFileInfo struct {
+	os.FileInfo
+	mode os.FileMode
+}
+
+func (i testFileInfo) Mode() os.FileMode { return i.mode }
+func (i testFileInfo) IsDir() bool       { return i.mode.IsDir() }
+
+func TestPathCache(t *testing.T) {
+	files := map[string]os.FileMode{
+		"/usr/bin/go": 0755,
+	}
+	stats := 0
+	stat := func(path string) (os.FileInfo, error) {
+		stats++
+		mode, ok := files[path]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return testFileInfo{mode: mode}, nil
+	}
+	const pathVar = "/bin:/usr/bin"
+	cache := newPathCache()
+	lookup := func(expectPath string, expectStats int) {
+		t.Helper()
+		stats = 0
+		path, err := cache.lookPath(stat, pathVar, "go")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path != expectPath {
+			t.Errorf("Expected path %q, got %q", expectPath, path)
+		}
+		if stats != expectStats {
+			t.Errorf("Expected %d stats, got %d", expectStats, stats)
+		}
+	}
+
+	lookup("/usr/bin/go", 2)
+	lookup("/usr/bin/go", 0)
+
+	cache.invalidate("/tmp/go")
+	lookup("/usr/bin/go", 0)
+
+	files["/bin/go"] = 0755
+	cache.invalidate("/bin/go")
+	lookup("/bin/go", 1)
+
+	cache.invalidate("/") // i.e. a new mount at root
+	lookup("/bin/go", 1)
+
+	cache.reset()
+	lookup("/bin/go", 1)
+	if commands := cache.commands(pathVar); commands["go"] != "/bin/go" || len(commands) != 1 {
+		t.Errorf("Expected only go in the cache, got: %v", commands)
+	}
+}
@@ -0,0 +1,76 @@
+package process
+
+import (
+	"sort"
+	"time"
+
+	"github.com/hack-pad/hackpad/internal/fs"
+)
+
+// Info is a snapshot of a process, with the same fields as Dump
+type Info struct {
+	PID              PID           `json:"pid"`
+	ParentPID        PID           `json:"ppid"`
+	Args             []string      `json:"argv"`
+	State            string        `json:"state"`
+	WorkingDirectory string        `json:"cwd"`
+	Backend          string        `json:"backend"`
+	StartTime        time.Time     `json:"startTime"`
+	Elapsed          time.Duration `json:"elapsed"` // running time so far, or total running time once exited
+	ExitCode         int           `json:"exitCode"`
+	Err              string        `json:"error,omitempty"`
+	Files            []fs.OpenFile `json:"files"`
+}
+
+// Done returns true if the process has exited
+func (i Info) Done() bool {
+	return i.State == string(stateDone) || i.State == string(stateError)
+}
+
+// Snapshot returns every process in the table, sorted by PID
+func Snapshot() []Info {
+	processes := allProcesses()
+	sort.Slice(processes, func(a, b int) bool {
+		return processes[a].pid < processes[b].pid
+	})
+	infos := make([]Info, 0, len(processes))
+	for _, p := range processes {
+		infos = append(infos, p.info())
+	}
+	return infos
+}
+
+func (p *process) info() Info {
+	p.limitsMu.Lock()
+	startTime, endTime := p.startTime, p.endTime
+	p.limitsMu.Unlock()
+	p.mu.Lock()
+	args, state, exitCode, err := p.args, p.state, p.exitCode, p.err
+	p.mu.Unlock()
+	var elapsed time.Duration
+	switch {
+	case startTime.IsZero():
+	case endTime.IsZero():
+		elapsed = time.Since(startTime)
+	default:
+		elapsed = endTime.Sub(startTime)
+	}
+	info := Info{
+		PID:       p.pid,
+		ParentPID: p.parentPID,
+		Args:      args,
+		State:     string(state),
+		Backend:   p.attr.Backend.String(),
+		StartTime: startTime,
+		Elapsed:   elapsed,
+		ExitCode:  exitCode,
+	}
+	if err != nil {
+		info.Err = err.Error()
+	}
+	if p.fileDescriptors != nil {
+		info.WorkingDirectory = p.WorkingDirectory()
+		info.Files = p.fileDescriptors.OpenFiles()
+	}
+	return info
+}
@@ -0,0 +1,256 @@
+package process
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hack-pad/hackpad/internal/interop"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrTooManyProcesses = interop.NewError("resource temporarily unavailable: too many child processes", "EAGAIN")
+)
+
+// ResourceLimits holds a value for each resource limit, in the units of its field. Zero values are unlimited.
+type ResourceLimits struct {
+	WallTime  time.Duration // maximum time since the process started before it is killed
+	Memory    int64         // maximum size of the process's Wasm linear memory, in bytes
+	OpenFiles int           // maximum number of open file descriptors
+	Processes int           // maximum number of running child processes
+}
+
+// Limits bounds the resources a process may use, similar to setrlimit(2).
+// Soft limits are enforced. Hard limits cap the soft limits: a process may move its own soft limits up to the hard limits, but nothing may raise a hard limit, and only init or the process's parent may lower one.
+//
+// Limits are checked whenever the kernel regains control from the process. A process on the main thread can't be preempted, so a busy loop overruns its wall time until it yields, and killing it only stops it from resuming.
+// Worker processes are terminated as soon as they exceed their wall time, but their memory isn't visible to the kernel.
+type Limits struct {
+	Soft ResourceLimits
+	Hard ResourceLimits
+}
+
+// Resource names a limit for getrlimit and setrlimit
+type Resource string
+
+const (
+	// ResourceCPU is the wall time limit in seconds. Wasm CPU time can't be measured, so wall time stands in for it.
+	ResourceCPU       Resource = "cpu"
+	ResourceMemory    Resource = "as"
+	ResourceOpenFiles Resource = "nofile"
+	ResourceProcesses Resource = "nproc"
+)
+
+var (
+	errLimitPermission = interop.NewError("operation not permitted: only init or the parent process may lower a hard limit, and hard limits can't be raised", "EPERM")
+)
+
+func unknownResource(resource Resource) error {
+	return interop.NewError(fmt.Sprintf("Unknown resource limit: %q", resource), "EINVAL")
+}
+
+// Get returns the value of 'resource' in setrlimit units
+func (l ResourceLimits) Get(resource Resource) (int64, error) {
+	switch resource {
+	case ResourceCPU:
+		return int64(l.WallTime / time.Second), nil
+	case ResourceMemory:
+		return l.Memory, nil
+	case ResourceOpenFiles:
+		return int64(l.OpenFiles), nil
+	case ResourceProcesses:
+		return int64(l.Processes), nil
+	default:
+		return 0, unknownResource(resource)
+	}
+}
+
+// Set updates 'resource' to 'value', in setrlimit units
+func (l *ResourceLimits) Set(resource Resource, value int64) error {
+	if value < 0 {
+		return interop.NewError(fmt.Sprintf("Invalid resource limit for %q: %d", resource, value), "EINVAL")
+	}
+	switch resource {
+	case ResourceCPU:
+		l.WallTime = time.Duration(value) * time.Second
+	case ResourceMemory:
+		l.Memory = value
+	case ResourceOpenFiles:
+		l.OpenFiles = int(value)
+	case ResourceProcesses:
+		l.Processes = int(value)
+	default:
+		return unknownResource(resource)
+	}
+	return nil
+}
+
+var resources = []Resource{ResourceCPU, ResourceMemory, ResourceOpenFiles, ResourceProcesses}
+
+// Get returns the soft and hard limits of 'resource' in setrlimit units
+func (l Limits) Get(resource Resource) (soft, hard int64, err error) {
+	soft, err = l.Soft.Get(resource)
+	if err != nil {
+		return 0, 0, err
+	}
+	hard, err = l.Hard.Get(resource)
+	return soft, hard, err
+}
+
+// Set updates the soft and hard limits of 'resource', in setrlimit units.
+// The soft limit may not exceed the hard limit, and the hard limit may not be raised. Only a 'privileged' caller may lower the hard limit.
+func (l *Limits) Set(resource Resource, soft, hard int64, privileged bool) error {
+	_, currentHard, err := l.Get(resource)
+	if err != nil {
+		return err
+	}
+	if soft < 0 || hard < 0 {
+		return interop.NewError(fmt.Sprintf("Invalid resource limit for %q: %d, %d", resource, soft, hard), "EINVAL")
+	}
+	if exceeds(soft, hard) {
+		return interop.NewError(fmt.Sprintf("Invalid resource limit for %q: soft limit %d exceeds hard limit %d", resource, soft, hard), "EINVAL")
+	}
+	if exceeds(hard, currentHard) || (hard != currentHard && !privileged) {
+		return errLimitPermission
+	}
+	if err := l.Soft.Set(resource, soft); err != nil {
+		return err
+	}
+	return l.Hard.Set(resource, hard)
+}
+
+// exceeds returns true if 'value' is beyond 'limit', where zero is unlimited
+func exceeds(value, limit int64) bool {
+	return limit != 0 && (value == 0 || value > limit)
+}
+
+// inherit returns the parent's limits, replacing any 'requested' limits as both soft and hard limits.
+// Requested limits may not exceed the parent's hard limits.
+func (requested ResourceLimits) inherit(parent Limits) (Limits, error) {
+	limits := parent
+	for _, resource := range resources {
+		value, err := requested.Get(resource)
+		if err != nil {
+			return Limits{}, err
+		}
+		if value == 0 {
+			continue
+		}
+		if err := limits.Set(resource, value, value, true); err != nil {
+			return Limits{}, err
+		}
+	}
+	return limits, nil
+}
+
+// killedExitCode returns the shell-style exit code for a process killed by 'signal'
+func killedExitCode(signal int) int {
+	return 128 + signal
+}
+
+type killRequest struct {
+	exitCode int
+	err      error
+}
+
+func (p *process) Limits() Limits {
+	p.limitsMu.Lock()
+	defer p.limitsMu.Unlock()
+	return p.limits
+}
+
+// SetLimit updates the soft and hard limits of 'resource' on behalf of 'caller'. Init and the parent process are privileged to lower hard limits.
+func (p *process) SetLimit(caller Process, resource Resource, soft, hard int64) error {
+	privileged := caller.PID() == minPID || caller.PID() == p.parentPID
+	p.limitsMu.Lock()
+	limits := p.limits
+	err := limits.Set(resource, soft, hard, privileged)
+	if err == nil {
+		p.limits = limits
+	}
+	p.limitsMu.Unlock()
+	if err != nil {
+		return err
+	}
+	if p.fileDescriptors != nil {
+		p.fileDescriptors.SetMaxFiles(limits.Soft.OpenFiles)
+	}
+	p.resetWallTimer()
+	return nil
+}
+
+// resetWallTimer (re)schedules the process to be killed once it exceeds its wall time limit
+func (p *process) resetWallTimer() {
+	p.limitsMu.Lock()
+	defer p.limitsMu.Unlock()
+	if p.wallTimer != nil {
+		p.wallTimer.Stop()
+		p.wallTimer = nil
+	}
+	if p.startTime.IsZero() || p.limits.Soft.WallTime == 0 {
+		return
+	}
+	remaining := p.limits.Soft.WallTime - time.Since(p.startTime)
+	p.wallTimer = time.AfterFunc(remaining, func() {
+		if kill := p.checkLimits(0); kill != nil {
+			p.kill(*kill)
+		}
+	})
+}
+
+func (p *process) stopWallTimer() {
+	p.limitsMu.Lock()
+	if p.wallTimer != nil {
+		p.wallTimer.Stop()
+	}
+	p.limitsMu.Unlock()
+}
+
+// checkLimits returns a kill request if the process has exceeded its limits. A memorySize of 0 skips the memory check.
+func (p *process) checkLimits(memorySize int64) *killRequest {
+	p.limitsMu.Lock()
+	limits, startTime := p.limits.Soft, p.startTime
+	p.limitsMu.Unlock()
+
+	if limits.WallTime > 0 && !startTime.IsZero() && time.Since(startTime) >= limits.WallTime {
+		return &killRequest{
+			exitCode: killedExitCode(signalXCPU),
+			err:      errors.Errorf("Process %d killed: exceeded wall time limit of %s", p.pid, limits.WallTime),
+		}
+	}
+	if limits.Memory > 0 && memorySize > limits.Memory {
+		return &killRequest{
+			exitCode: killedExitCode(SignalKill),
+			err:      errors.Errorf("Process %d killed: Wasm memory size %d exceeded limit of %d bytes", p.pid, memorySize, limits.Memory),
+		}
+	}
+	return nil
+}
+
+// kill stops the current process image. Only the first kill request is honored.
+func (p *process) kill(request killRequest) {
+	select {
+	case p.kills <- request:
+	default:
+	}
+}
+
+func checkProcessLimit(parent Process) error {
+	limit := parent.Limits().Soft.Processes
+	if limit == 0 {
+		return nil
+	}
+	running := 0
+	for _, p := range allProcesses() {
+		if p.parentPID != parent.PID() {
+			continue
+		}
+		if state := p.getState(); state != stateDone && state != stateError {
+			running++
+		}
+	}
+	if running >= limit {
+		return ErrTooManyProcesses
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package process
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpad/internal/interop"
+)
+
+func TestResourceLimitsGetSet(t *testing.T) {
+	for _, tc := range []struct {
+		resource Resource
+		value    int64
+		expect   ResourceLimits
+	}{
+		{ResourceCPU, 5, ResourceLimits{WallTime: 5 * time.Second}},
+		{ResourceMemory, 1 << 20, ResourceLimits{Memory: 1 << 20}},
+		{ResourceOpenFiles, 10, ResourceLimits{OpenFiles: 10}},
+		{ResourceProcesses, 3, ResourceLimits{Processes: 3}},
+	} {
+		t.Run(string(tc.resource), func(t *testing.T) {
+			var limits ResourceLimits
+			if err := limits.Set(tc.resource, tc.value); err != nil {
+				t.Fatal(err)
+			}
+			if limits != tc.expect {
+				t.Errorf("Expected %+v, got %+v", tc.expect, limits)
+			}
+			value, err := limits.Get(tc.resource)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value != tc.value {
+				t.Errorf("Expected %d, got %d", tc.value, value)
+			}
+		})
+	}
+
+	var limits ResourceLimits
+	if err := limits.Set(ResourceOpenFiles, -1); interop.ErrorCode(err) != "EINVAL" {
+		t.Errorf("Expected EINVAL for a negative limit, got %v", err)
+	}
+	if err := limits.Set("bogus", 1); interop.ErrorCode(err) != "EINVAL" {
+		t.Errorf("Expected EINVAL for an unknown resource, got %v", err)
+	}
+	if _, err := limits.Get("bogus"); interop.ErrorCode(err) != "EINVAL" {
+		t.Errorf("Expected EINVAL for an unknown resource, got %v", err)
+	}
+}
+
+func TestLimitsSet(t *testing.T) {
+	initial := Limits{
+		Soft: ResourceLimits{OpenFiles: 10},
+		Hard: ResourceLimits{OpenFiles: 20},
+	}
+	for _, tc := range []struct {
+		description string
+		soft, hard  int64
+		privileged  bool
+		expectCode  string
+		expect      Limits
+	}{
+		{
+			description: "raise soft up to hard",
+			soft:        20, hard: 20,
+			expect: Limits{Soft: ResourceLimits{OpenFiles: 20}, Hard: ResourceLimits{OpenFiles: 20}},
+		},
+		{
+			description: "lower soft",
+			soft:        5, hard: 20,
+			expect: Limits{Soft: ResourceLimits{OpenFiles: 5}, Hard: ResourceLimits{OpenFiles: 20}},
+		},
+		{
+			description: "soft above hard",
+			soft:        21, hard: 20,
+			expectCode: "EINVAL",
+		},
+		{
+			description: "unlimited soft under a hard limit",
+			soft:        0, hard: 20,
+			expectCode: "EINVAL",
+		},
+		{
+			description: "negative",
+			soft:        -1, hard: 20,
+			expectCode: "EINVAL",
+		},
+		{
+			description: "raise hard",
+			soft:        10, hard: 30,
+			privileged: true,
+			expectCode: "EPERM",
+		},
+		{
+			description: "remove hard",
+			soft:        0, hard: 0,
+			privileged: true,
+			expectCode: "EPERM",
+		},
+		{
+			description: "unprivileged lower hard",
+			soft:        10, hard: 15,
+			expectCode: "EPERM",
+		},
+		{
+			description: "privileged lower hard",
+			soft:        10, hard: 15,
+			privileged: true,
+			expect:     Limits{Soft: ResourceLimits{OpenFiles: 10}, Hard: ResourceLimits{OpenFiles: 15}},
+		},
+	} {
+		t.Run(tc.description, func(t *testing.T) {
+			limits := initial
+			err := limits.Set(ResourceOpenFiles, tc.soft, tc.hard, tc.privileged)
+			if tc.expectCode != "" {
+				if code := interop.ErrorCode(err); code != tc.expectCode {
+					t.Errorf("Expected %s, got %v", tc.expectCode, err)
+				}
+				if limits != initial {
+					t.Errorf("Expected limits to be unchanged, got %+v", limits)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if limits != tc.expect {
+				t.Errorf("Expected %+v, got %+v", tc.expect, limits)
+			}
+		})
+	}
+}
+
+func TestLimitsSetUnlimited(t *testing.T) {
+	var limits Limits
+	if err := limits.Set(ResourceProcesses, 4, 8, false); interop.ErrorCode(err) != "EPERM" {
+		t.Errorf("Expected EPERM lowering an unlimited hard limit unprivileged, got %v", err)
+	}
+	if err := limits.Set(ResourceProcesses, 4, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	soft, hard, err := limits.Get(ResourceProcesses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if soft != 4 || hard != 0 {
+		t.Errorf("Expected soft 4 and hard 0, got %d and %d", soft, hard)
+	}
+}
+
+func TestResourceLimitsInherit(t *testing.T) {
+	parent := Limits{
+		Soft: ResourceLimits{OpenFiles: 10, Processes: 2},
+		Hard: ResourceLimits{OpenFiles: 20, Processes: 4},
+	}
+
+	limits, err := ResourceLimits{}.inherit(parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits != parent {
+		t.Errorf("Expected parent's limits %+v, got %+v", parent, limits)
+	}
+
+	limits, err = ResourceLimits{OpenFiles: 15, Memory: 1 << 20}.inherit(parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := Limits{
+		Soft: ResourceLimits{OpenFiles: 15, Processes: 2, Memory: 1 << 20},
+		Hard: ResourceLimits{OpenFiles: 15, Processes: 4, Memory: 1 << 20},
+	}
+	if limits != expect {
+		t.Errorf("Expected %+v, got %+v", expect, limits)
+	}
+
+	_, err = ResourceLimits{Processes: 5}.inherit(parent)
+	if interop.ErrorCode(err) != "EPERM" {
+		t.Errorf("Expected EPERM exceeding the parent's hard limit, got %v", err)
+	}
+}
+
+func TestExceeds(t *testing.T) {
+	for _, tc := range []struct {
+		value, limit int64
+		expect       bool
+	}{
+		{0, 0, false},
+		{5, 0, false},
+		{0, 5, true},
+		{5, 5, false},
+		{4, 5, false},
+		{6, 5, true},
+	} {
+		if actual := exceeds(tc.value, tc.limit); actual != tc.expect {
+			t.Errorf("exceeds(%d, %d): expected %t, got %t", tc.value, tc.limit, tc.expect, actual)
+		}
+	}
+}
@@ -1,16 +1,18 @@
 package process
 
 import (
+	"bufio"
 	"context"
 	"fmt"
 	"os"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/hack-pad/hackpad/internal/common"
 	"github.com/hack-pad/hackpad/internal/fs"
 	"github.com/hack-pad/hackpad/internal/log"
-	"github.com/hack-pad/hackpadfs/keyvalue/blob"
 	"github.com/pkg/errors"
 	"go.uber.org/atomic"
 )
@@ -32,7 +34,6 @@ const (
 )
 
 var (
-	pids    = make(map[PID]*process)
 	lastPID = atomic.NewUint64(minPID)
 )
 
@@ -42,38 +43,67 @@ type Process interface {
 
 	Start() error
 	Wait() (exitCode int, err error)
+	Kill(signal int) error
 	Files() *fs.FileDescriptors
 	WorkingDirectory() string
 	SetWorkingDirectory(wd string) error
+	Limits() Limits
+	SetLimit(caller Process, resource Resource, soft, hard int64) error
 }
 
 type process struct {
-	pid, parentPID  PID
-	command         string
-	args            []string
-	state           processState
+	pid, parentPID PID
+
+	mu       sync.Mutex // guards the current image's command, args, format, and environment, and the process state, exit code, and error
+	command  string
+	args     []string
+	state    processState
+	format   wasmFormat
+	exitCode int
+	err      error
+
 	attr            *ProcAttr
 	ctx             context.Context
 	ctxDone         context.CancelFunc
-	exitCode        int
-	err             error
 	fileDescriptors *fs.FileDescriptors
 	setFilesWD      func(wd string) error
+	execs           chan string
+	kills           chan killRequest
+
+	limitsMu  sync.Mutex
+	limits    Limits
+	startTime time.Time
+	endTime   time.Time
+	wallTimer *time.Timer
+
+	compileStart time.Time
 }
 
-func New(command string, args []string, attr *ProcAttr) (Process, error) {
-	return newWithCurrent(Current(), PID(lastPID.Inc()), command, args, attr)
+// New creates a child process of 'parent'
+func New(parent Process, command string, args []string, attr *ProcAttr) (Process, error) {
+	if err := checkProcessLimit(parent); err != nil {
+		return nil, err
+	}
+	return newWithParent(parent, PID(lastPID.Inc()), command, args, attr)
 }
 
-func newWithCurrent(current Process, newPID PID, command string, args []string, attr *ProcAttr) (*process, error) {
-	wd := current.WorkingDirectory()
+func newWithParent(parent Process, newPID PID, command string, args []string, attr *ProcAttr) (*process, error) {
+	wd := parent.WorkingDirectory()
 	if attr.Dir != "" {
 		wd = attr.Dir
 	}
-	files, setFilesWD, err := fs.NewFileDescriptors(newPID, wd, current.Files(), attr.Files)
+	limits, err := attr.Limits.inherit(parent.Limits())
+	if err != nil {
+		return nil, err
+	}
+	files, setFilesWD, err := fs.NewFileDescriptors(newPID, wd, parent.Files(), attr.Files)
+	if files != nil {
+		files.SetMaxFiles(limits.Soft.OpenFiles)
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 	return &process{
 		pid:             newPID,
+		parentPID:       parent.PID(),
 		command:         command,
 		args:            args,
 		state:           statePending,
@@ -83,6 +113,9 @@ func newWithCurrent(current Process, newPID PID, command string, args []string,
 		err:             err,
 		fileDescriptors: files,
 		setFilesWD:      setFilesWD,
+		execs:           make(chan string, 1),
+		kills:           make(chan killRequest, 1),
+		limits:          limits,
 	}, err
 }
 
@@ -98,8 +131,39 @@ func (p *process) Files() *fs.FileDescriptors {
 	return p.fileDescriptors
 }
 
+// image returns the current image's args and format, and its environment, which defaults to the kernel's own
+func (p *process) image() (args []string, format wasmFormat, env map[string]string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.attr.Env == nil {
+		p.attr.Env = splitEnvPairs(os.Environ())
+	}
+	return p.args, p.format, p.attr.Env
+}
+
+func (p *process) getState() processState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state
+}
+
+// exitStatus returns the exit code and error, which are only final once the process is done
+func (p *process) exitStatus() (exitCode int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.exitCode, p.err
+}
+
+func (p *process) setExitCode(exitCode int) {
+	p.mu.Lock()
+	p.exitCode = exitCode
+	p.mu.Unlock()
+}
+
 func (p *process) Start() error {
 	err := p.start()
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	if p.err == nil {
 		p.err = err
 	}
@@ -107,61 +171,113 @@ func (p *process) Start() error {
 }
 
 func (p *process) start() error {
-	pids[p.pid] = p
+	setPID(p)
 	log.Debugf("Spawning process: %v", p)
+	p.limitsMu.Lock()
+	p.startTime = time.Now()
+	p.limitsMu.Unlock()
+	emitEvent(p.newEvent(EventSpawn))
+	p.resetWallTimer()
 	go func() {
-		command, err := p.prepExecutable()
+		p.mu.Lock()
+		command, args := p.command, p.args
+		p.mu.Unlock()
+		command, args, format, err := p.prepExecutable(command, args)
 		if err != nil {
 			p.handleErr(err)
 			return
 		}
+		p.mu.Lock()
+		p.args = args
+		p.format = format
+		p.mu.Unlock()
 		p.run(command)
 	}()
 	return nil
 }
 
-func (p *process) prepExecutable() (command string, err error) {
+// prepExecutable finds the executable for 'name' and its format. Scripts starting with "#!" run their interpreter instead, so the returned argv may differ from 'args'.
+func (p *process) prepExecutable(name string, args []string) (command string, argv []string, format wasmFormat, err error) {
 	fs := p.Files()
-	command, err = lookPath(fs.Stat, os.Getenv("PATH"), p.command)
+	command, err = executables.lookPath(fs.Stat, os.Getenv("PATH"), name)
+	if err != nil {
+		return "", nil, "", err
+	}
+	interpreter, arg, format, err := p.readExecutable(command)
+	if err != nil || interpreter == "" {
+		return command, args, format, err
+	}
+
+	script := command
+	command, err = executables.lookPath(fs.Stat, os.Getenv("PATH"), interpreter)
 	if err != nil {
-		return "", err
+		return "", nil, "", err
 	}
-	fid, err := fs.Open(command, 0, 0)
+	nestedInterpreter, _, format, err := p.readExecutable(command)
 	if err != nil {
-		return "", err
+		return "", nil, "", err
+	}
+	if nestedInterpreter != "" {
+		return "", nil, "", errors.Errorf("Format error. Interpreter %q is a script", interpreter)
+	}
+	return command, interpreterArgs(interpreter, arg, script, args), format, nil
+}
+
+// readExecutable reads the "#!" interpreter of a script, or the format of a Wasm binary
+func (p *process) readExecutable(path string) (interpreter, arg string, format wasmFormat, err error) {
+	fs := p.Files()
+	fid, err := fs.Open(path, 0, 0)
+	if err != nil {
+		return "", "", "", err
 	}
 	defer fs.Close(fid)
-	buf := blob.NewBytesLength(4)
-	_, err = fs.Read(fid, buf, 0, buf.Len(), nil)
+	info, err := fs.Fstat(fid)
+	if err != nil {
+		return "", "", "", err
+	}
+	raw, err := fs.RawFID(fid)
 	if err != nil {
-		return "", err
+		return "", "", "", err
 	}
-	magicNumber := string(buf.Bytes())
-	if magicNumber != "\x00asm" {
-		return "", errors.Errorf("Format error. Expected Wasm file header but found: %q", magicNumber)
+	defer raw.Close()
+	r := bufio.NewReader(raw)
+	interpreter, arg, err = readInterpreter(r)
+	if err != nil || interpreter != "" {
+		return interpreter, arg, "", err
 	}
-	return command, nil
+	format, err = readWasmFormat(r, info.Size())
+	return "", "", format, err
 }
 
 func (p *process) Done() {
 	log.Debug("PID ", p.pid, " is done.\n", p.fileDescriptors)
+	p.stopWallTimer()
 	p.fileDescriptors.CloseAll()
 	p.ctxDone()
 }
 
 func (p *process) handleErr(err error) {
-	p.state = stateDone
+	p.limitsMu.Lock()
+	p.endTime = time.Now()
+	p.limitsMu.Unlock()
+	p.emitExit(err)
+	state := stateDone
 	if err != nil {
 		log.Errorf("Failed to start process: %s", err.Error())
+		state = stateError
+	}
+	p.mu.Lock()
+	if err != nil {
 		p.err = err
-		p.state = stateError
 	}
+	p.state = state
+	p.mu.Unlock()
 	p.Done()
 }
 
 func (p *process) Wait() (exitCode int, err error) {
 	<-p.ctx.Done()
-	return p.exitCode, p.err
+	return p.exitStatus()
 }
 
 func (p *process) WorkingDirectory() string {
@@ -173,20 +289,20 @@ func (p *process) SetWorkingDirectory(wd string) error {
 }
 
 func (p *process) String() string {
-	return fmt.Sprintf("PID=%s, Command=%v, State=%s, WD=%s, Attr=%+v, Err=%+v, Files:\n%v", p.pid, p.args, p.state, p.WorkingDirectory(), p.attr, p.err, p.fileDescriptors)
+	p.mu.Lock()
+	args, state, attr, err := p.args, p.state, *p.attr, p.err
+	p.mu.Unlock()
+	return fmt.Sprintf("PID=%s, Command=%v, State=%s, WD=%s, Attr=%+v, Err=%+v, Files:\n%v", p.pid, args, state, p.WorkingDirectory(), &attr, err, p.fileDescriptors)
 }
 
 func Dump() interface{} {
 	var s strings.Builder
-	var pidSlice []PID
-	for pid := range pids {
-		pidSlice = append(pidSlice, pid)
-	}
-	sort.Slice(pidSlice, func(a, b int) bool {
-		return pidSlice[a] < pidSlice[b]
+	processes := allProcesses()
+	sort.Slice(processes, func(a, b int) bool {
+		return processes[a].pid < processes[b].pid
 	})
-	for _, pid := range pidSlice {
-		s.WriteString(pids[pid].String() + "\n")
+	for _, p := range processes {
+		s.WriteString(p.String() + "\n")
 	}
 	return s.String()
 }
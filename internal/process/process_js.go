@@ -14,10 +14,11 @@ var (
 )
 
 func (p *process) JSValue() js.Value {
+	_, err := p.exitStatus()
 	return js.ValueOf(map[string]interface{}{
 		"pid":   p.pid.JSValue(),
 		"ppid":  p.parentPID.JSValue(),
-		"error": interop.WrapAsJSError(p.err, "spawn"),
+		"error": interop.WrapAsJSError(err, "spawn"),
 	})
 }
 
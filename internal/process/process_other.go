@@ -5,25 +5,30 @@ package process
 
 import (
 	"fmt"
-	"os"
 	"os/exec"
 )
 
 func (p *process) run(path string) {
-	cmd := exec.Command(path, p.args...)
-	if p.attr.Env == nil {
-		cmd.Env = os.Environ()
-		p.attr.Env = splitEnvPairs(cmd.Env)
-	} else {
-		for k, v := range p.attr.Env {
-			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+	for {
+		exitCode, err := p.runCmd(path)
+		execPath, replaced := p.pendingExec()
+		if !replaced {
+			p.setExitCode(exitCode)
+			p.handleErr(err)
+			return
 		}
+		path = execPath
+	}
+}
+
+func (p *process) runCmd(path string) (exitCode int, err error) {
+	args, _, env := p.image()
+	cmd := exec.Command(path, args...)
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
 	}
 
-	p.state = stateRunning
-	prev := switchContext(p.pid)
-	err := cmd.Run()
-	switchContext(prev)
-	p.exitCode = cmd.ProcessState.ExitCode()
-	p.handleErr(err)
+	p.setState(stateRunning)
+	err = cmd.Run()
+	return cmd.ProcessState.ExitCode(), err
 }
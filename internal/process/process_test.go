@@ -0,0 +1,150 @@
+//go:build !js
+// +build !js
+
+package process
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpad/internal/interop"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+var initOnce sync.Once
+
+// TestSnapshotWhileExiting reads the process table while children exit, so the race detector checks exit status is guarded
+func TestSnapshotWhileExiting(t *testing.T) {
+	initOnce.Do(Init)
+	files := Current().Files()
+	dir := fmt.Sprintf("/%s-%d", t.Name(), time.Now().UnixNano())
+	if err := files.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	// a valid Wasm module, which fails to run natively since it only exists in hackpad's file system
+	prog := path.Join(dir, "prog")
+	fid, err := files.Open(prog, os.O_CREATE|os.O_WRONLY, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	module := []byte(wasmModule())
+	if _, err := files.Write(fid, blob.NewBytes(module), 0, len(module), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := files.Close(fid); err != nil {
+		t.Fatal(err)
+	}
+
+	var children []Process
+	for i := 0; i < 10; i++ {
+		command := prog
+		if i%2 == 1 {
+			command = path.Join(dir, "missing")
+		}
+		child, err := New(Current(), command, []string{command}, &ProcAttr{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		children = append(children, child)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, child := range children {
+			if err := child.Start(); err != nil {
+				t.Error(err)
+			}
+		}
+		for _, child := range children {
+			if _, err := child.Wait(); err == nil {
+				t.Errorf("Expected %v to fail", child)
+			}
+		}
+	}()
+	for {
+		for _, info := range Snapshot() {
+			_ = info.Done()
+		}
+		_ = Dump()
+		select {
+		case <-done:
+			for _, child := range children {
+				info := child.(*process).info()
+				if !info.Done() || info.Err == "" {
+					t.Errorf("Expected an exited process with an error, got %+v", info)
+				}
+			}
+			return
+		default:
+		}
+	}
+}
+
+func TestWaitNotFound(t *testing.T) {
+	initOnce.Do(Init)
+	child, err := New(Current(), "/does-not-exist", []string{"/does-not-exist"}, &ProcAttr{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := child.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := child.Wait(); interop.ErrorCode(err) != "ENOENT" {
+		t.Errorf("Expected ENOENT, got: %v", err)
+	}
+}
+
+func TestCanSignal(t *testing.T) {
+	initOnce.Do(Init)
+	newProcess := func(parent Process) Process {
+		t.Helper()
+		p, err := New(parent, "sh", []string{"sh"}, &ProcAttr{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		setPID(p.(*process))
+		t.Cleanup(func() {
+			pidsMu.Lock()
+			delete(pids, p.PID())
+			pidsMu.Unlock()
+		})
+		return p
+	}
+	shell := newProcess(Current())
+	killTool := newProcess(shell)
+	job := newProcess(shell)
+	jobChild := newProcess(job)
+	otherShell := newProcess(Current())
+
+	for _, tc := range []struct {
+		description    string
+		caller, target Process
+		expectAllowed  bool
+	}{
+		{"init", Current(), otherShell, true},
+		{"self", killTool, killTool, true},
+		{"child", shell, job, true},
+		{"grandchild", shell, jobChild, true},
+		{"sibling job", killTool, job, true},
+		{"sibling's child", killTool, jobChild, true},
+		{"parent", killTool, shell, false},
+		{"another shell", killTool, otherShell, false},
+		{"top-level sibling", otherShell, job, false},
+	} {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			err := CanSignal(tc.caller, tc.target.PID())
+			if tc.expectAllowed && err != nil {
+				t.Errorf("Expected signal to be allowed, got: %v", err)
+			}
+			if !tc.expectAllowed && interop.ErrorCode(err) != "EPERM" {
+				t.Errorf("Expected EPERM, got: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package process
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	interpreterPrefix = "#!"
+	// maxInterpreterLine is the longest "#!" line read, like Linux's BINPRM_BUF_SIZE
+	maxInterpreterLine = 256
+)
+
+// readInterpreter parses an executable's "#!" line, returning the interpreter path and its optional argument.
+// Returns an empty interpreter if the file isn't a script.
+// Peeks at 'r' so a binary can still be read from the start.
+func readInterpreter(r *bufio.Reader) (interpreter, arg string, err error) {
+	line, err := r.Peek(maxInterpreterLine)
+	isEOF := err == io.EOF
+	if err != nil && !isEOF {
+		return "", "", err
+	}
+	if !strings.HasPrefix(string(line), interpreterPrefix) {
+		return "", "", nil
+	}
+	line = line[len(interpreterPrefix):]
+	end := strings.IndexByte(string(line), '\n')
+	if end == -1 && isEOF {
+		end = len(line)
+	}
+	if end == -1 {
+		return "", "", errors.New("Format error. Interpreter line is too long")
+	}
+	interpreter, arg, _ = strings.Cut(strings.TrimSpace(string(line[:end])), " ")
+	if interpreter == "" {
+		return "", "", errors.New("Format error. Missing interpreter")
+	}
+	return interpreter, strings.TrimSpace(arg), nil
+}
+
+// interpreterArgs returns the argv for running 'script' with 'interpreter', like execve(2): the interpreter, its optional argument, the script path, then the script's arguments
+func interpreterArgs(interpreter, arg, script string, args []string) []string {
+	argv := []string{interpreter}
+	if arg != "" {
+		argv = append(argv, arg)
+	}
+	argv = append(argv, script)
+	if len(args) > 1 {
+		argv = append(argv, args[1:]...)
+	}
+	return argv
+}
@@ -0,0 +1,91 @@
+package process
+
+import (
+	"bufio"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadInterpreter(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		description       string
+		contents          string
+		expectInterpreter string
+		expectArg         string
+		expectErr         bool
+	}{
+		{
+			description: "wasm binary",
+			contents:    wasmMagicNumber + "\x01\x00\x00\x00",
+		},
+		{
+			description:       "interpreter",
+			contents:          "#!/bin/coreutils\n",
+			expectInterpreter: "/bin/coreutils",
+		},
+		{
+			description:       "interpreter with argument",
+			contents:          "#! /bin/sh -c  echo hi \nignored\n",
+			expectInterpreter: "/bin/sh",
+			expectArg:         "-c  echo hi",
+		},
+		{
+			description:       "no trailing newline",
+			contents:          "#!/bin/sh",
+			expectInterpreter: "/bin/sh",
+		},
+		{
+			description: "missing interpreter",
+			contents:    "#!\n",
+			expectErr:   true,
+		},
+		{
+			description: "line too long",
+			contents:    "#!/bin/" + strings.Repeat("a", maxInterpreterLine) + "\n",
+			expectErr:   true,
+		},
+	} {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			r := bufio.NewReader(strings.NewReader(tc.contents))
+			interpreter, arg, err := readInterpreter(r)
+			if tc.expectErr {
+				if err == nil {
+					t.Fatal("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if interpreter != tc.expectInterpreter || arg != tc.expectArg {
+				t.Errorf("Expected interpreter %q and arg %q, got %q and %q", tc.expectInterpreter, tc.expectArg, interpreter, arg)
+			}
+			rest, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(rest) != tc.contents {
+				t.Errorf("Expected reader to be unconsumed, got %q", rest)
+			}
+		})
+	}
+}
+
+func TestInterpreterArgs(t *testing.T) {
+	t.Parallel()
+	argv := interpreterArgs("/bin/coreutils", "", "/bin/cat", []string{"cat", "-n", "file"})
+	expect := []string{"/bin/coreutils", "/bin/cat", "-n", "file"}
+	if !reflect.DeepEqual(argv, expect) {
+		t.Errorf("Expected %v, got %v", expect, argv)
+	}
+	argv = interpreterArgs("/bin/sh", "-x", "/home/me/script", []string{"script"})
+	expect = []string{"/bin/sh", "-x", "/home/me/script"}
+	if !reflect.DeepEqual(argv, expect) {
+		t.Errorf("Expected %v, got %v", expect, argv)
+	}
+}
@@ -0,0 +1,72 @@
+package process
+
+import (
+	"fmt"
+
+	"github.com/hack-pad/hackpad/internal/interop"
+)
+
+// Signal numbers, as on Linux
+const (
+	SignalHangUp    = 1
+	SignalInterrupt = 2
+	SignalQuit      = 3
+	SignalKill      = 9
+	SignalTerminate = 15
+	// SignalTerminalStop is sent by a terminal's suspend character. Without job control, processes can't be stopped, so it's ignored.
+	SignalTerminalStop = 20
+	signalXCPU         = 24
+	// SignalWindowChange is sent when a terminal is resized. Like Linux, it's ignored by default.
+	SignalWindowChange = 28
+)
+
+// SignalIgnored returns true if 'signal' doesn't end the process
+func SignalIgnored(signal int) bool {
+	return signal == SignalTerminalStop || signal == SignalWindowChange
+}
+
+var (
+	ErrNoSuchProcess    = interop.NewError("no such process", "ESRCH")
+	errSignalPermission = interop.NewError("operation not permitted: processes may only signal themselves and their descendants", "EPERM")
+)
+
+// CanSignal returns an error unless 'caller' may signal process 'target'. Init may signal any process, and others may signal themselves and their descendants.
+// Tools like kill and killall run as children of the shell, so they may also signal their parent's descendants: the shell's other jobs.
+func CanSignal(caller Process, target PID) error {
+	if caller.PID() == minPID || target == caller.PID() {
+		return nil
+	}
+	parent := caller.ParentPID()
+	for pid := target; pid != minPID; {
+		p, ok := getPID(pid)
+		if !ok {
+			break
+		}
+		pid = p.parentPID
+		if pid == caller.PID() || (pid == parent && parent != minPID) {
+			return nil
+		}
+	}
+	return errSignalPermission
+}
+
+// Kill stops the process as if by 'signal', exiting with code 128+signal. Signal 0 only checks the process is still running.
+// Signals can't be caught, so any signal ends the process, except ignored signals which only emit an EventSignal.
+func (p *process) Kill(signal int) error {
+	if signal < 0 || signal > 64 {
+		return interop.NewError(fmt.Sprintf("Invalid signal: %d", signal), "EINVAL")
+	}
+	if p.ctx.Err() != nil {
+		return ErrNoSuchProcess
+	}
+	switch {
+	case signal == 0:
+	case SignalIgnored(signal):
+		event := p.newEvent(EventSignal)
+		event.Signal = signal
+		emitEvent(event)
+	default:
+		p.kill(killRequest{exitCode: killedExitCode(signal)})
+	}
+	return nil
+}
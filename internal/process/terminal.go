@@ -0,0 +1,23 @@
+package process
+
+import "github.com/hack-pad/hackpad/internal/fs"
+
+func init() {
+	fs.SetTerminalSignaler(signalTerminal)
+}
+
+// signalTerminal sends 'signal' from terminal 't' to its foreground processes, or to every attached process when it hangs up
+func signalTerminal(t *fs.PTY, signal int) {
+	for _, p := range allProcesses() {
+		if p.pid == minPID || p.fileDescriptors == nil || p.ctx.Err() != nil {
+			continue
+		}
+		attached := t.Foreground
+		if signal == SignalHangUp {
+			attached = t.Attached
+		}
+		if attached(p.fileDescriptors) {
+			_ = p.Kill(signal)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+//go:build js
+// +build js
+
+package process
+
+import (
+	"github.com/hack-pad/hackpad/internal/wasi"
+	"github.com/hack-pad/hackpad/internal/worker"
+)
+
+// startWASI instantiates a WASI module on the main thread and runs it to completion.
+// Unlike Go's js/wasm, WASI syscalls are synchronous, so the module runs on this goroutine until it exits.
+// Its syscalls can't wait on the event loop, so reads that aren't ready fail with EAGAIN, and sleeps only wait where the main thread may sleep.
+// Modules run in Workers instead when they're supported.
+func (p *process) startWASI(path string) (exitCode int, err error) {
+	p.setState(stateCompiling)
+	args, _, env := p.image()
+	host, err := wasi.New(args, env, p.Files())
+	if err != nil {
+		return 0, err
+	}
+	defer host.Close()
+	host.SetSleep(worker.Sleep)
+	importObject, release := host.ImportObject()
+	defer release()
+
+	instance, err := p.newWasmInstance(path, importObject)
+	if err != nil {
+		return 0, err
+	}
+
+	// the module runs synchronously, so limits are enforced between syscalls rather than in a select
+	var killErr error
+	host.OnSyscall(func(memorySize int64) {
+		kill := p.checkLimits(memorySize)
+		if kill == nil {
+			select {
+			case request := <-p.kills:
+				kill = &request
+			default:
+			}
+		}
+		if kill != nil {
+			killErr = kill.err
+			host.Interrupt(kill.exitCode)
+		}
+	})
+
+	p.setState(stateRunning)
+	exitCode, err = host.Start(instance)
+	if err == nil {
+		err = killErr
+	}
+	return exitCode, err
+}
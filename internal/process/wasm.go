@@ -4,13 +4,14 @@
 package process
 
 import (
-	"os"
 	"runtime"
+	"sync"
 	"syscall/js"
 
 	"github.com/hack-pad/hackpad/internal/interop"
 	"github.com/hack-pad/hackpad/internal/log"
 	"github.com/hack-pad/hackpad/internal/promise"
+	"go.uber.org/atomic"
 )
 
 var (
@@ -26,35 +27,84 @@ func (p *process) run(path string) {
 		go runtime.GC()
 	}()
 
+	for {
+		execPath, exitCode, err := p.runImage(path)
+		if execPath == "" {
+			p.setExitCode(exitCode)
+			p.handleErr(err)
+			return
+		}
+		log.Debug("PID ", p.pid, " replaced its image with ", execPath)
+		path = execPath
+	}
+}
+
+// runImage runs the Wasm module at 'path' until it exits or is replaced by Exec. If replaced, returns the path to the new image.
+func (p *process) runImage(path string) (execPath string, exitCode int, err error) {
+	if p.useWorker() {
+		return p.runWorkerImage(path)
+	}
+	if _, format, _ := p.image(); format == formatWASI {
+		exitCode, err = p.startWASI(path)
+		execPath, _ = p.pendingExec()
+		return execPath, exitCode, err
+	}
+
 	exitChan := make(chan int, 1)
-	runPromise, err := p.startWasmPromise(path, exitChan)
+	runPromise, abandon, err := p.startWasmPromise(path, exitChan)
 	if err != nil {
-		p.handleErr(err)
-		return
+		return "", 0, err
+	}
+	runErrs := make(chan error, 1)
+	release := runPromise.Done(func(err error) {
+		runErrs <- err
+	})
+	select {
+	case err := <-runErrs:
+		if err != nil {
+			return "", 0, err // the instance failed before it could call exit
+		}
+		return "", <-exitChan, nil
+	case execPath := <-p.execs:
+		// abandoned images never resume, so their run promise never settles
+		abandon()
+		release()
+		return execPath, 0, nil
+	case kill := <-p.kills:
+		abandon()
+		release()
+		return "", kill.exitCode, kill.err
 	}
-	_, err = runPromise.Await()
-	p.exitCode = <-exitChan
-	p.handleErr(err)
 }
 
-func (p *process) startWasmPromise(path string, exitChan chan<- int) (promise.Promise, error) {
-	p.state = stateCompiling
+// startWasmPromise starts a Go js/wasm instance. Call abandon to stop resuming the instance, i.e. after it is replaced by Exec.
+func (p *process) startWasmPromise(path string, exitChan chan<- int) (_ promise.JS, abandon func(), _ error) {
+	p.setState(stateCompiling)
+	args, _, env := p.image()
 	goInstance := jsGo.New()
-	goInstance.Set("argv", interop.SliceFromStrings(p.args))
-	if p.attr.Env == nil {
-		p.attr.Env = splitEnvPairs(os.Environ())
-	}
-	goInstance.Set("env", interop.StringMap(p.attr.Env))
+	goInstance.Set("argv", interop.SliceFromStrings(args))
+	goInstance.Set("env", interop.StringMap(env))
+	globals := p.newGlobals()
 	var resumeFuncPtr *js.Func
-	goInstance.Set("exit", interop.SingleUseFunc(func(this js.Value, args []js.Value) interface{} {
-		defer func() {
+	var abandoned atomic.Bool
+	var releaseOnce sync.Once
+	release := func() {
+		releaseOnce.Do(func() {
 			if resumeFuncPtr != nil {
 				resumeFuncPtr.Release()
 			}
+			globals.release()
 			// TODO free the whole goInstance to fix garbage issues entirely. Freeing individual properties appears to work for now, but is ultimately a bad long-term solution because memory still accumulates.
 			goInstance.Set("mem", js.Null())
 			goInstance.Set("importObject", js.Null())
-		}()
+		})
+	}
+	abandon = func() {
+		abandoned.Store(true)
+		release()
+	}
+	goInstance.Set("exit", interop.SingleUseFunc(func(this js.Value, args []js.Value) interface{} {
+		defer release()
 		if len(args) == 0 {
 			exitChan <- -1
 			return nil
@@ -70,25 +120,31 @@ func (p *process) startWasmPromise(path string, exitChan chan<- int) (promise.Pr
 
 	instance, err := p.newWasmInstance(path, importObject)
 	if err != nil {
-		return nil, err
+		release()
+		return promise.JS{}, nil, err
 	}
 
 	exports := instance.Get("exports")
 
 	resumeFunc := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
 		defer interop.PanicLogger()
-		prev := switchContext(p.pid)
+		if abandoned.Load() {
+			return nil // replaced images are blocked in exec and must never run again
+		}
+		exit := globals.enter()
+		defer exit()
 		ret := exports.Call("resume", interop.SliceFromJSValues(args)...)
-		switchContext(prev)
+		p.checkMemory(exports)
 		return ret
 	})
 	resumeFuncPtr = &resumeFunc
 	wrapperExports := map[string]interface{}{
 		"run": interop.SingleUseFunc(func(this js.Value, args []js.Value) interface{} {
 			defer interop.PanicLogger()
-			prev := switchContext(p.pid)
+			exit := globals.enter()
+			defer exit()
 			ret := exports.Call("run", interop.SliceFromJSValues(args)...)
-			switchContext(prev)
+			p.checkMemory(exports)
 			return ret
 		}),
 		"resume": resumeFunc,
@@ -107,6 +163,16 @@ func (p *process) startWasmPromise(path string, exitChan chan<- int) (promise.Pr
 		},
 	)
 
-	p.state = stateRunning
-	return promise.From(goInstance.Call("run", wrapperInstance)), nil
+	p.setState(stateRunning)
+	return promise.From(goInstance.Call("run", wrapperInstance)), abandon, nil
+}
+
+// checkMemory kills the process if its Wasm memory exceeds its limit. Called each time the instance yields to the kernel.
+func (p *process) checkMemory(exports js.Value) {
+	if p.Limits().Soft.Memory == 0 {
+		return
+	}
+	if kill := p.checkLimits(int64(exports.Get("mem").Get("buffer").Get("byteLength").Int())); kill != nil {
+		p.kill(*kill)
+	}
 }
@@ -0,0 +1,237 @@
+package process
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type wasmFormat string
+
+const (
+	formatGoJS wasmFormat = "js"
+	formatWASI wasmFormat = "wasi"
+)
+
+const (
+	wasmMagicNumber      = "\x00asm"
+	wasmCustomSectionID  = 0
+	wasmImportSectionID  = 2
+	wasiModulePrefix     = "wasi_"
+	wasmImportKindFunc   = 0x00
+	wasmImportKindTable  = 0x01
+	wasmImportKindMemory = 0x02
+	wasmImportKindGlobal = 0x03
+	wasmImportKindTag    = 0x04
+)
+
+// readWasmFormat inspects a Wasm module's header and import section to determine how it should be run.
+// Modules importing from any "wasi_*" module (i.e. wasi_snapshot_preview1 or wasi_unstable) are WASI binaries, all others are assumed to be Go js/wasm binaries.
+// 'size' is the module's size in bytes, which bounds every section and name.
+func readWasmFormat(reader io.Reader, size int64) (wasmFormat, error) {
+	r := &wasmReader{r: bufio.NewReader(reader), remaining: size}
+	header, err := r.read(8) // magic number + version
+	if err != nil && err != errWasmTruncated {
+		return "", err
+	}
+	if magicNumber := string(header[:minInt(len(header), len(wasmMagicNumber))]); magicNumber != wasmMagicNumber {
+		return "", errors.Errorf("Format error. Expected Wasm file header but found: %q", magicNumber)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	for r.remaining > 0 {
+		sectionID, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		size, err := readULEB128(r)
+		if err != nil {
+			return "", err
+		}
+		section, err := r.section(size)
+		if err != nil {
+			return "", err
+		}
+		switch sectionID {
+		case wasmCustomSectionID:
+			if err := section.discard(size); err != nil {
+				return "", err
+			}
+		case wasmImportSectionID:
+			return readImportsFormat(section)
+		default:
+			if sectionID > wasmImportSectionID {
+				// sections are ordered, so the import section cannot appear after this point
+				return formatGoJS, nil
+			}
+			if err := section.discard(size); err != nil {
+				return "", err
+			}
+		}
+	}
+	return formatGoJS, nil
+}
+
+var errWasmTruncated = errors.New("Format error. Wasm module is truncated")
+
+// wasmReader reads at most 'remaining' bytes, the rest of a Wasm module or one of its sections
+type wasmReader struct {
+	r         *bufio.Reader
+	remaining int64
+}
+
+func (w *wasmReader) ReadByte() (byte, error) {
+	if w.remaining <= 0 {
+		return 0, errWasmTruncated
+	}
+	b, err := w.r.ReadByte()
+	if err == io.EOF {
+		err = errWasmTruncated
+	}
+	if err == nil {
+		w.remaining--
+	}
+	return b, err
+}
+
+// read returns the next 'n' bytes. If fewer remain, returns them with errWasmTruncated.
+func (w *wasmReader) read(n uint64) ([]byte, error) {
+	var err error
+	if n > uint64(w.remaining) {
+		n = uint64(w.remaining)
+		err = errWasmTruncated
+	}
+	buf := make([]byte, n)
+	read, readErr := io.ReadFull(w.r, buf)
+	w.remaining -= int64(read)
+	if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+		readErr = errWasmTruncated
+	}
+	if readErr != nil {
+		err = readErr
+	}
+	return buf[:read], err
+}
+
+func (w *wasmReader) discard(n uint64) error {
+	if n > uint64(w.remaining) {
+		return errWasmTruncated
+	}
+	discarded, err := w.r.Discard(int(n))
+	w.remaining -= int64(discarded)
+	if err == io.EOF {
+		err = errWasmTruncated
+	}
+	return err
+}
+
+// section returns a reader for the next 'size' bytes. Reading from it also consumes bytes from 'w'.
+func (w *wasmReader) section(size uint64) (*wasmReader, error) {
+	if size > uint64(w.remaining) {
+		return nil, errors.Errorf("Format error. Wasm section size %d exceeds the %d bytes remaining", size, w.remaining)
+	}
+	w.remaining -= int64(size)
+	return &wasmReader{r: w.r, remaining: int64(size)}, nil
+}
+
+func readImportsFormat(r *wasmReader) (wasmFormat, error) {
+	count, err := readULEB128(r)
+	if err != nil {
+		return "", err
+	}
+	for i := uint64(0); i < count; i++ {
+		module, err := readWasmName(r)
+		if err != nil {
+			return "", err
+		}
+		if strings.HasPrefix(module, wasiModulePrefix) {
+			return formatWASI, nil
+		}
+		if _, err := readWasmName(r); err != nil { // field name
+			return "", err
+		}
+		if err := skipImportDescriptor(r); err != nil {
+			return "", err
+		}
+	}
+	return formatGoJS, nil
+}
+
+func skipImportDescriptor(r *wasmReader) error {
+	kind, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	switch kind {
+	case wasmImportKindFunc:
+		_, err = readULEB128(r) // type index
+	case wasmImportKindTable:
+		if _, err = r.ReadByte(); err == nil { // reference type
+			err = skipWasmLimits(r)
+		}
+	case wasmImportKindMemory:
+		err = skipWasmLimits(r)
+	case wasmImportKindGlobal:
+		err = r.discard(2) // value type and mutability
+	case wasmImportKindTag:
+		if _, err = r.ReadByte(); err == nil { // attribute
+			_, err = readULEB128(r) // type index
+		}
+	default:
+		err = errors.Errorf("Format error. Unknown Wasm import kind: 0x%02x", kind)
+	}
+	return err
+}
+
+func skipWasmLimits(r *wasmReader) error {
+	const hasMaximum = 0x01
+	flags, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if _, err := readULEB128(r); err != nil {
+		return err
+	}
+	if flags&hasMaximum != 0 {
+		_, err = readULEB128(r)
+	}
+	return err
+}
+
+func readWasmName(r *wasmReader) (string, error) {
+	length, err := readULEB128(r)
+	if err != nil {
+		return "", err
+	}
+	if length > uint64(r.remaining) {
+		return "", errors.Errorf("Format error. Wasm name length %d exceeds the %d bytes remaining in its section", length, r.remaining)
+	}
+	name, err := r.read(length)
+	return string(name), err
+}
+
+func readULEB128(r io.ByteReader) (uint64, error) {
+	var result uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		result |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return result, nil
+		}
+	}
+	return 0, errors.New("Format error. Wasm LEB128 value overflows 64 bits")
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,131 @@
+package process
+
+import (
+	"strings"
+	"testing"
+)
+
+func wasmModule(sections ...string) string {
+	return wasmMagicNumber + "\x01\x00\x00\x00" + strings.Join(sections, "")
+}
+
+func wasmSection(id byte, contents string) string {
+	return string([]byte{id, byte(len(contents))}) + contents
+}
+
+func wasmName(name string) string {
+	return string([]byte{byte(len(name))}) + name
+}
+
+func TestReadWasmFormat(t *testing.T) {
+	t.Parallel()
+	const typeSectionID = 1
+	wasiImport := wasmSection(wasmImportSectionID, "\x01"+wasmName("wasi_snapshot_preview1")+wasmName("fd_write")+"\x00\x00")
+	goImports := wasmSection(wasmImportSectionID, "\x03"+
+		wasmName("go")+wasmName("debug")+"\x00\x00"+
+		wasmName("go")+wasmName("mem")+"\x02\x01\x01\x02"+
+		wasmName("go")+wasmName("g")+"\x03\x7f\x00")
+
+	for _, tc := range []struct {
+		description  string
+		contents     string
+		size         int // defaults to len(contents)
+		expectFormat wasmFormat
+		expectErr    string
+	}{
+		{
+			description:  "empty module",
+			contents:     wasmModule(),
+			expectFormat: formatGoJS,
+		},
+		{
+			description:  "wasi import",
+			contents:     wasmModule(wasmSection(typeSectionID, "\x00"), wasiImport),
+			expectFormat: formatWASI,
+		},
+		{
+			description:  "wasi import after custom section",
+			contents:     wasmModule(wasmSection(wasmCustomSectionID, wasmName("name")+"abc"), wasiImport),
+			expectFormat: formatWASI,
+		},
+		{
+			description:  "go imports",
+			contents:     wasmModule(goImports),
+			expectFormat: formatGoJS,
+		},
+		{
+			description:  "no import section",
+			contents:     wasmModule(wasmSection(3, "\x00"), wasiImport),
+			expectFormat: formatGoJS,
+		},
+		{
+			description: "not wasm",
+			contents:    "#!/bin/sh\n",
+			expectErr:   "Expected Wasm file header",
+		},
+		{
+			description: "truncated header",
+			contents:    wasmMagicNumber,
+			expectErr:   "truncated",
+		},
+		{
+			description: "section larger than file",
+			contents:    wasmModule("\x00\xff\xff\xff\xff\x0f"),
+			expectErr:   "section size",
+		},
+		{
+			description: "section larger than reported size",
+			contents:    wasmModule(wasmSection(wasmCustomSectionID, "abcd")),
+			size:        len(wasmModule()) + 3,
+			expectErr:   "section size",
+		},
+		{
+			description: "name longer than section",
+			contents:    wasmModule(wasmSection(wasmImportSectionID, "\x01\x7fwasi_")),
+			expectErr:   "name length",
+		},
+		{
+			description: "name length overflows",
+			contents:    wasmModule(wasmSection(wasmImportSectionID, "\x01\xff\xff\xff\xff\xff\xff\xff\xff\xff\x01")),
+			expectErr:   "name length",
+		},
+		{
+			description: "file shorter than its size",
+			contents:    wasmModule(wasmSection(wasmImportSectionID, "\x01"+wasmName("go"))),
+			size:        len(wasmModule()) + 100,
+			expectErr:   "truncated",
+		},
+		{
+			description: "unknown import kind",
+			contents:    wasmModule(wasmSection(wasmImportSectionID, "\x01"+wasmName("go")+wasmName("x")+"\x09")),
+			expectErr:   "Unknown Wasm import kind",
+		},
+		{
+			description: "LEB128 overflow",
+			contents:    wasmModule("\x00" + strings.Repeat("\x80", 10)),
+			expectErr:   "overflows",
+		},
+	} {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			size := tc.size
+			if size == 0 {
+				size = len(tc.contents)
+			}
+			format, err := readWasmFormat(strings.NewReader(tc.contents), int64(size))
+			if tc.expectErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectErr) {
+					t.Fatalf("Expected error containing %q, got %v", tc.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if format != tc.expectFormat {
+				t.Errorf("Expected format %q, got %q", tc.expectFormat, format)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+//go:build js
+// +build js
+
+package process
+
+import (
+	"github.com/hack-pad/hackpad/internal/wasi"
+	"github.com/hack-pad/hackpad/internal/worker"
+)
+
+// useWorker returns true if the current image should run in a Worker.
+// While the kernel is blocked, main thread images can't run, so default backend images run in Workers too.
+// WASI syscalls can only wait for input or timers outside the main thread, so default backend WASI images always run in Workers.
+func (p *process) useWorker() bool {
+	if worker.Supported() != nil {
+		return false
+	}
+	switch p.attr.Backend {
+	case BackendWorker:
+		return true
+	case BackendMain:
+		return false
+	default:
+		_, format, _ := p.image()
+		return format == formatWASI || Backend(defaultBackend.Load()) == BackendWorker || worker.Blocked()
+	}
+}
+
+// runWorkerImage runs the Go js/wasm or WASI module at 'path' in a Worker until it exits or is replaced by Exec.
+// The Worker's syscalls run against this process's globals, just like a main thread image.
+// Memory limits are only enforced for WASI modules, which report their memory size with each syscall. A Go js/wasm Worker's memory is not visible to the kernel.
+func (p *process) runWorkerImage(path string) (execPath string, exitCode int, err error) {
+	p.setState(stateCompiling)
+	args, format, env := p.image()
+	module, err := p.Files().WasmModule(path)
+	if err != nil {
+		return "", 0, err
+	}
+	globals := p.newGlobals()
+	defer globals.release()
+	image := worker.Image{
+		Module:  module,
+		Args:    args,
+		Env:     env,
+		Globals: globals.object(),
+	}
+	if format == formatWASI {
+		host, err := wasi.New(args, env, p.Files())
+		if err != nil {
+			return "", 0, err
+		}
+		defer host.Close()
+		wasiGlobal, release := host.WorkerGlobal()
+		defer release()
+		host.OnSyscall(func(memorySize int64) {
+			if kill := p.checkLimits(memorySize); kill != nil {
+				p.kill(*kill)
+			}
+		})
+		image.Globals["wasi"] = wasiGlobal
+		image.WASIModule = wasi.ModuleName
+		image.WASISyscalls = wasi.SyscallNames()
+	}
+
+	type exit struct {
+		code int
+		err  error
+	}
+	exits := make(chan exit, 1)
+	thread, err := worker.Start(image, func(exitCode int, err error) {
+		exits <- exit{exitCode, err}
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	p.setState(stateRunning)
+	select {
+	case exit := <-exits:
+		return "", exit.code, exit.err
+	case execPath := <-p.execs:
+		thread.Terminate()
+		return execPath, 0, nil
+	case kill := <-p.kills:
+		thread.Terminate()
+		return "", kill.exitCode, kill.err
+	}
+}
@@ -5,6 +5,7 @@ package promise
 
 import (
 	"runtime/debug"
+	"sync"
 	"syscall/js"
 
 	"github.com/hack-pad/hackpad/internal/interop"
@@ -87,3 +88,28 @@ func (p JS) Await() (interface{}, error) {
 func (p JS) JSValue() js.Value {
 	return p.value
 }
+
+// Done calls 'fn' once the promise settles, with the rejection as an error if it was rejected.
+// If the promise will never settle, call release to free its callbacks. The promise must not settle after release.
+func (p JS) Done(fn func(err error)) (release func()) {
+	var resolved, rejected js.Func
+	var releaseOnce sync.Once
+	release = func() {
+		releaseOnce.Do(func() {
+			resolved.Release()
+			rejected.Release()
+		})
+	}
+	resolved = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		release()
+		fn(nil)
+		return nil
+	})
+	rejected = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		release()
+		fn(js.Error{Value: args[0]})
+		return nil
+	})
+	p.value.Call("then", resolved, rejected)
+	return release
+}
@@ -0,0 +1,68 @@
+//go:build js
+// +build js
+
+package terminal
+
+import (
+	"os"
+
+	"github.com/hack-pad/hackpad/internal/asciicast"
+	"github.com/hack-pad/hackpad/internal/fs"
+	"github.com/hack-pad/hackpad/internal/log"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+// recording records a terminal session to an asciicast file. A nil recording records nothing.
+type recording struct {
+	files    *fs.FileDescriptors
+	fid      fs.FID
+	recorder *asciicast.Recorder
+}
+
+func startRecording(files *fs.FileDescriptors, path string, header asciicast.Header) (*recording, error) {
+	fid, err := files.Open(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	r := &recording{files: files, fid: fid}
+	r.recorder, err = asciicast.NewRecorder(r, header)
+	if err != nil {
+		_ = files.Close(fid)
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *recording) Write(p []byte) (int, error) {
+	return r.files.Write(r.fid, blob.NewBytes(p), 0, len(p), nil)
+}
+
+func (r *recording) Output(p []byte) {
+	if r != nil {
+		r.logErr(r.recorder.Output(p))
+	}
+}
+
+func (r *recording) Input(p []byte) {
+	if r != nil {
+		r.logErr(r.recorder.Input(p))
+	}
+}
+
+func (r *recording) Resize(size fs.WindowSize) {
+	if r != nil {
+		r.logErr(r.recorder.Resize(int(size.Cols), int(size.Rows)))
+	}
+}
+
+func (r *recording) logErr(err error) {
+	if err != nil {
+		log.Error("Failed to record terminal:", err)
+	}
+}
+
+func (r *recording) Close() {
+	if r != nil {
+		r.logErr(r.files.Close(r.fid))
+	}
+}
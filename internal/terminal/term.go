@@ -4,13 +4,17 @@
 package terminal
 
 import (
+	"strings"
 	"syscall/js"
 
+	"github.com/hack-pad/hackpad/internal/asciicast"
 	"github.com/hack-pad/hackpad/internal/fs"
 	"github.com/hack-pad/hackpad/internal/interop"
 	"github.com/hack-pad/hackpad/internal/log"
 	"github.com/hack-pad/hackpad/internal/process"
+	"github.com/hack-pad/hackpad/internal/xterm"
 	"github.com/hack-pad/hackpadfs/indexeddb/idbblob"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
 	"github.com/pkg/errors"
 )
 
@@ -51,24 +55,61 @@ func Open(args []js.Value) error {
 		workingDirectory = wd.String()
 	}
 
-	files := process.Current().Files()
-	stdinR, stdinW := pipe(files)
-	stdoutR, stdoutW := pipe(files)
-	stderrR, stderrW := pipe(files)
+	parent := process.Current()
+	files := parent.Files()
+	master, slave, err := openPTY(files)
+	if err != nil {
+		return err
+	}
+	pty, err := files.Terminal(master)
+	if err != nil {
+		return err
+	}
+	var rec *recording
+	resize := func(value js.Value) {
+		if value.Get("rows").Type() == js.TypeNumber && value.Get("cols").Type() == js.TypeNumber {
+			size := fs.WindowSize{
+				Rows: uint16(value.Get("rows").Int()),
+				Cols: uint16(value.Get("cols").Int()),
+			}
+			pty.SetWindowSize(size)
+			rec.Resize(size)
+		}
+	}
+	resize(term)
+	if path := options.Get("record"); path.Truthy() {
+		size := pty.WindowSize()
+		rec, err = startRecording(files, path.String(), asciicast.Header{
+			Width:   int(size.Cols),
+			Height:  int(size.Rows),
+			Command: strings.Join(procArgs, " "),
+		})
+		if err != nil {
+			_ = files.Close(master)
+			_ = files.Close(slave)
+			return err
+		}
+	}
 
-	proc, err := process.New(procArgs[0], procArgs, &process.ProcAttr{
+	proc, err := process.New(parent, procArgs[0], procArgs, &process.ProcAttr{
 		Dir: workingDirectory,
 		Files: []fs.Attr{
-			{FID: stdinR},
-			{FID: stdoutW},
-			{FID: stderrW},
+			{FID: slave},
+			{FID: slave},
+			{FID: slave},
 		},
 	})
+	// only the terminal's process keeps the slave open, so the master sees EOF once it and its children exit
+	_ = files.Close(slave)
 	if err != nil {
+		_ = files.Close(master)
+		rec.Close()
 		return err
 	}
 	err = proc.Start()
 	if err != nil {
+		_ = files.Close(master)
+		rec.Close()
 		return err
 	}
 
@@ -78,38 +119,64 @@ func Open(args []js.Value) error {
 			log.Error("blob: Failed to write to terminal:", err)
 			return nil
 		}
-		_, err = files.Write(stdinW, chunk, 0, chunk.Len(), nil)
+		rec.Input([]byte(args[0].String()))
+		_, err = files.Write(master, chunk, 0, chunk.Len(), nil)
 		if err != nil {
 			log.Error("write: Failed to write to terminal:", err)
 		}
 		return nil
 	})
+	resizeFunc := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		resize(args[0])
+		return nil
+	})
 	go func() {
 		_, _ = proc.Wait()
 		f.Release()
+		resizeFunc.Release()
 	}()
 	term.Call("onData", f)
-	go readOutputPipes(term, files, stdoutR)
-	go readOutputPipes(term, files, stderrR)
+	term.Call("onResize", resizeFunc)
+	go readOutputPipes(term, files, master, rec)
 	return nil
 }
 
-func pipe(files *fs.FileDescriptors) (r, w fs.FID) {
-	p := files.Pipe()
-	return p[0], p[1]
-}
-
-func readOutputPipes(term js.Value, files *fs.FileDescriptors, output fs.FID) {
-	buf, err := idbblob.NewLength(1)
+// openPTY opens a close-on-exec pseudo-terminal, so only the terminal's own process inherits it
+func openPTY(files *fs.FileDescriptors) (master, slave fs.FID, err error) {
+	p, err := files.OpenPTY()
 	if err != nil {
-		panic(err)
+		return 0, 0, err
+	}
+	for _, fid := range p {
+		if err := files.SetCloseOnExec(fid, true); err != nil {
+			return 0, 0, err
+		}
 	}
+	return p[0], p[1], nil
+}
+
+// readOutputPipes copies output to 'term' until the terminal's processes all exit.
+// Each read takes as much output as is available, and blocks while xterm catches up.
+func readOutputPipes(term js.Value, files *fs.FileDescriptors, output fs.FID, rec *recording) {
+	const maxRead = 32 << 10 // 32KiB, a full pipe or terminal buffer
+	buf := blob.NewBytesLength(maxRead)
+	w := xterm.NewWriter(term)
+	defer w.Close()
+	defer files.Close(output)
+	defer rec.Close()
 	for {
-		_, err := files.Read(output, buf, 0, buf.Len(), nil)
-		if err != nil {
+		n, err := files.Read(output, buf, 0, buf.Len(), nil)
+		switch {
+		case err != nil:
+			log.Error("Failed to write to terminal:", err)
+			return
+		case n == 0:
+			return // the terminal's processes have all exited
+		}
+		rec.Output(buf.Bytes()[:n])
+		if _, err := w.Write(buf.Bytes()[:n]); err != nil {
 			log.Error("Failed to write to terminal:", err)
-		} else {
-			term.Call("write", buf)
+			return
 		}
 	}
 }
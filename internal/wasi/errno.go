@@ -0,0 +1,61 @@
+package wasi
+
+import (
+	"github.com/hack-pad/hackpad/internal/interop"
+)
+
+// Errno is a wasi_snapshot_preview1 error number
+type Errno uint16
+
+const (
+	ErrnoSuccess     Errno = 0
+	ErrnoAcces       Errno = 2
+	ErrnoAgain       Errno = 6
+	ErrnoBadf        Errno = 8
+	ErrnoExist       Errno = 20
+	ErrnoFault       Errno = 21
+	ErrnoInval       Errno = 28
+	ErrnoIO          Errno = 29
+	ErrnoIsdir       Errno = 31
+	ErrnoMfile       Errno = 33
+	ErrnoNoent       Errno = 44
+	ErrnoNosys       Errno = 52
+	ErrnoNotdir      Errno = 54
+	ErrnoNotempty    Errno = 55
+	ErrnoNotsup      Errno = 58
+	ErrnoPerm        Errno = 63
+	ErrnoPipe        Errno = 64
+	ErrnoSpipe       Errno = 70
+	ErrnoNotcapable  Errno = 76
+	errnoUnspecified       = ErrnoIO
+)
+
+// errnoCodes maps errno names produced by interop.ErrorCode to their WASI counterparts
+var errnoCodes = map[string]Errno{
+	"EACCES":    ErrnoAcces,
+	"EAGAIN":    ErrnoAgain,
+	"EBADF":     ErrnoBadf,
+	"EEXIST":    ErrnoExist,
+	"EINVAL":    ErrnoInval,
+	"EIO":       ErrnoIO,
+	"EISDIR":    ErrnoIsdir,
+	"EMFILE":    ErrnoMfile,
+	"ENOENT":    ErrnoNoent,
+	"ENOSYS":    ErrnoNosys,
+	"ENOTDIR":   ErrnoNotdir,
+	"ENOTEMPTY": ErrnoNotempty,
+	"ENOTSUP":   ErrnoNotsup,
+	"EPERM":     ErrnoPerm,
+	"EPIPE":     ErrnoPipe,
+	"ESPIPE":    ErrnoSpipe,
+}
+
+func toErrno(err error) Errno {
+	if err == nil {
+		return ErrnoSuccess
+	}
+	if errno, ok := errnoCodes[interop.ErrorCode(err)]; ok {
+		return errno
+	}
+	return errnoUnspecified
+}
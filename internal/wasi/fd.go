@@ -0,0 +1,365 @@
+package wasi
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"time"
+
+	"github.com/hack-pad/hackpad/internal/common"
+	"github.com/hack-pad/hackpad/internal/fs"
+	"github.com/hack-pad/hackpad/internal/interop"
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+	"github.com/pkg/errors"
+)
+
+const (
+	fileTypeUnknown         = 0
+	fileTypeCharacterDevice = 2
+	fileTypeDirectory       = 3
+	fileTypeRegularFile     = 4
+	fileTypeSymbolicLink    = 7
+
+	fdstatSize       = 24
+	filestatSize     = 64
+	direntHeaderSize = 24
+	prestatSize      = 8
+	preopenTypeDir   = 0
+
+	// allRights grants every right from fd_read (bit 1) through sock_shutdown (bit 28)
+	allRights = 1<<29 - 1
+)
+
+func fileType(mode os.FileMode) byte {
+	switch {
+	case mode.IsDir():
+		return fileTypeDirectory
+	case mode&os.ModeSymlink != 0:
+		return fileTypeSymbolicLink
+	case mode&(os.ModeCharDevice|os.ModeNamedPipe) != 0:
+		return fileTypeCharacterDevice
+	case mode.IsRegular():
+		return fileTypeRegularFile
+	default:
+		return fileTypeUnknown
+	}
+}
+
+type iovec struct {
+	offset, length uint32
+}
+
+func (h *Host) readIOVecs(ptr, count uint32) ([]iovec, uint32, Errno) {
+	const iovecSize = 8
+	data, errno := h.read(ptr, count*iovecSize)
+	if errno != ErrnoSuccess {
+		return nil, 0, errno
+	}
+	var total uint32
+	iovecs := make([]iovec, count)
+	for i := range iovecs {
+		iovecs[i].offset = binary.LittleEndian.Uint32(data[i*iovecSize:])
+		iovecs[i].length = binary.LittleEndian.Uint32(data[i*iovecSize+4:])
+		total += iovecs[i].length
+	}
+	return iovecs, total, ErrnoSuccess
+}
+
+func (h *Host) doRead(fd, iovsPtr, iovsLen, nreadPtr uint32, position *int64) Errno {
+	iovecs, total, errno := h.readIOVecs(iovsPtr, iovsLen)
+	if errno != ErrnoSuccess {
+		return errno
+	}
+	if errno := h.wouldBlock(common.FID(fd), fs.PollIn); errno != ErrnoSuccess {
+		return errno
+	}
+	buf := blob.NewBytesLength(int(total))
+	n, err := h.files.Read(common.FID(fd), buf, 0, int(total), position)
+	if err != nil {
+		return toErrno(err)
+	}
+	data := buf.Bytes()[:n]
+	for _, vec := range iovecs {
+		if len(data) == 0 {
+			break
+		}
+		chunk := data
+		if uint32(len(chunk)) > vec.length {
+			chunk = chunk[:vec.length]
+		}
+		if errno := h.write(vec.offset, chunk); errno != ErrnoSuccess {
+			return errno
+		}
+		data = data[len(chunk):]
+	}
+	return h.writeUint32(nreadPtr, uint32(n))
+}
+
+func (h *Host) doWrite(fd, iovsPtr, iovsLen, nwrittenPtr uint32, position *int64) Errno {
+	iovecs, total, errno := h.readIOVecs(iovsPtr, iovsLen)
+	if errno != ErrnoSuccess {
+		return errno
+	}
+	data := make([]byte, 0, total)
+	for _, vec := range iovecs {
+		chunk, errno := h.read(vec.offset, vec.length)
+		if errno != ErrnoSuccess {
+			return errno
+		}
+		data = append(data, chunk...)
+	}
+	if errno := h.wouldBlock(common.FID(fd), fs.PollOut); errno != ErrnoSuccess {
+		return errno
+	}
+	n, err := h.files.Write(common.FID(fd), blob.NewBytes(data), 0, len(data), position)
+	if err != nil {
+		return toErrno(err)
+	}
+	return h.writeUint32(nwrittenPtr, uint32(n))
+}
+
+// wouldBlock returns ErrnoAgain if 'fd' isn't ready for 'events' and syscalls can't wait
+func (h *Host) wouldBlock(fd common.FID, events fs.PollEvents) Errno {
+	if h.canBlock() {
+		return ErrnoSuccess
+	}
+	revents, err := h.files.Poll([]common.FID{fd}, []fs.PollEvents{events}, 0)
+	if err != nil {
+		return toErrno(err)
+	}
+	if revents[0] == 0 {
+		return ErrnoAgain
+	}
+	return ErrnoSuccess
+}
+
+func (h *Host) fdRead(args []uint64) Errno {
+	// args: fd, iovs, iovs_len, nread pointer
+	return h.doRead(uint32(args[0]), uint32(args[1]), uint32(args[2]), uint32(args[3]), nil)
+}
+
+func (h *Host) fdPread(args []uint64) Errno {
+	// args: fd, iovs, iovs_len, offset, nread pointer
+	position := int64(args[3])
+	return h.doRead(uint32(args[0]), uint32(args[1]), uint32(args[2]), uint32(args[4]), &position)
+}
+
+func (h *Host) fdWrite(args []uint64) Errno {
+	// args: fd, iovs, iovs_len, nwritten pointer
+	return h.doWrite(uint32(args[0]), uint32(args[1]), uint32(args[2]), uint32(args[3]), nil)
+}
+
+func (h *Host) fdPwrite(args []uint64) Errno {
+	// args: fd, iovs, iovs_len, offset, nwritten pointer
+	position := int64(args[3])
+	return h.doWrite(uint32(args[0]), uint32(args[1]), uint32(args[2]), uint32(args[4]), &position)
+}
+
+func (h *Host) fdClose(args []uint64) Errno {
+	fid := common.FID(args[0])
+	if err := h.files.Close(fid); err != nil {
+		return toErrno(err)
+	}
+	h.mu.Lock()
+	delete(h.paths, fid)
+	delete(h.preopens, fid)
+	h.mu.Unlock()
+	return ErrnoSuccess
+}
+
+func (h *Host) fdSync(args []uint64) Errno {
+	return toErrno(h.files.Fsync(common.FID(args[0])))
+}
+
+func (h *Host) fdSeek(args []uint64) Errno {
+	// args: fd, offset, whence, new offset pointer
+	offset, err := h.files.Seek(common.FID(args[0]), int64(args[1]), int(args[2]))
+	if err != nil {
+		return toErrno(err)
+	}
+	return h.writeUint64(uint32(args[3]), uint64(offset))
+}
+
+func (h *Host) fdTell(args []uint64) Errno {
+	// args: fd, offset pointer
+	offset, err := h.files.Seek(common.FID(args[0]), 0, io.SeekCurrent)
+	if err != nil {
+		return toErrno(err)
+	}
+	return h.writeUint64(uint32(args[1]), uint64(offset))
+}
+
+// fstatType returns the WASI file type for 'fid'. Special files like stdout may not support stat, so they are reported as unknown.
+func (h *Host) fstatType(fid common.FID) (byte, Errno) {
+	info, err := h.files.Fstat(fid)
+	switch {
+	case errors.Is(err, interop.ErrNotImplemented), errors.Is(err, hackpadfs.ErrNotImplemented):
+		return fileTypeUnknown, ErrnoSuccess
+	case err != nil:
+		return 0, toErrno(err)
+	default:
+		return fileType(info.Mode()), ErrnoSuccess
+	}
+}
+
+func (h *Host) fdFdstatGet(args []uint64) Errno {
+	// args: fd, fdstat pointer
+	fileType, errno := h.fstatType(common.FID(args[0]))
+	if errno != ErrnoSuccess {
+		return errno
+	}
+	statusFlags, err := h.files.Fcntl(common.FID(args[0]), fs.FcntlGetFL, 0)
+	if err != nil {
+		return toErrno(err)
+	}
+	var fdflags uint16
+	if statusFlags&os.O_APPEND != 0 {
+		fdflags |= fdflagsAppend
+	}
+	if statusFlags&fs.OpenNonBlock != 0 {
+		fdflags |= fdflagsNonblock
+	}
+	fdstat := make([]byte, fdstatSize)
+	fdstat[0] = fileType
+	binary.LittleEndian.PutUint16(fdstat[2:], fdflags)
+	binary.LittleEndian.PutUint64(fdstat[8:], allRights)
+	binary.LittleEndian.PutUint64(fdstat[16:], allRights)
+	return h.write(uint32(args[1]), fdstat)
+}
+
+func (h *Host) fdFdstatSetFlags(args []uint64) Errno {
+	// args: fd, fdflags
+	var flags int
+	if args[1]&fdflagsAppend != 0 {
+		flags |= os.O_APPEND
+	}
+	if args[1]&fdflagsNonblock != 0 {
+		flags |= fs.OpenNonBlock
+	}
+	_, err := h.files.Fcntl(common.FID(args[0]), fs.FcntlSetFL, flags)
+	return toErrno(err)
+}
+
+func newFilestat(info os.FileInfo) []byte {
+	filestat := make([]byte, filestatSize)
+	modTime := uint64(info.ModTime().UnixNano())
+	filestat[16] = fileType(info.Mode())
+	binary.LittleEndian.PutUint64(filestat[24:], 1) // nlink
+	binary.LittleEndian.PutUint64(filestat[32:], uint64(info.Size()))
+	binary.LittleEndian.PutUint64(filestat[40:], modTime) // atim
+	binary.LittleEndian.PutUint64(filestat[48:], modTime) // mtim
+	binary.LittleEndian.PutUint64(filestat[56:], modTime) // ctim
+	return filestat
+}
+
+func (h *Host) fdFilestatGet(args []uint64) Errno {
+	// args: fd, filestat pointer
+	info, err := h.files.Fstat(common.FID(args[0]))
+	if err != nil {
+		return toErrno(err)
+	}
+	return h.write(uint32(args[1]), newFilestat(info))
+}
+
+func (h *Host) fdFilestatSetSize(args []uint64) Errno {
+	return toErrno(h.files.Truncate(common.FID(args[0]), int64(args[1])))
+}
+
+func (h *Host) fdFilestatSetTimes(args []uint64) Errno {
+	// args: fd, atim, mtim, fst_flags
+	p, ok := h.pathOf(common.FID(args[0]))
+	if !ok {
+		return ErrnoBadf
+	}
+	return h.setTimes(p, args[1], args[2], uint16(args[3]))
+}
+
+const (
+	fstflagsAtim = 1 << iota
+	fstflagsAtimNow
+	fstflagsMtim
+	fstflagsMtimNow
+)
+
+func (h *Host) setTimes(p string, atim, mtim uint64, flags uint16) Errno {
+	info, err := h.files.Stat(p)
+	if err != nil {
+		return toErrno(err)
+	}
+	now := time.Now()
+	chooseTime := func(timestamp uint64, setFlag, nowFlag uint16) time.Time {
+		switch {
+		case flags&nowFlag != 0:
+			return now
+		case flags&setFlag != 0:
+			return time.Unix(0, int64(timestamp))
+		default:
+			return info.ModTime()
+		}
+	}
+	atime := chooseTime(atim, fstflagsAtim, fstflagsAtimNow)
+	mtime := chooseTime(mtim, fstflagsMtim, fstflagsMtimNow)
+	return toErrno(h.files.Utimes(p, atime, mtime))
+}
+
+func (h *Host) fdPrestatGet(args []uint64) Errno {
+	// args: fd, prestat pointer
+	h.mu.Lock()
+	name, ok := h.preopens[common.FID(args[0])]
+	h.mu.Unlock()
+	if !ok {
+		return ErrnoBadf
+	}
+	prestat := make([]byte, prestatSize)
+	prestat[0] = preopenTypeDir
+	binary.LittleEndian.PutUint32(prestat[4:], uint32(len(name)))
+	return h.write(uint32(args[1]), prestat)
+}
+
+func (h *Host) fdPrestatDirName(args []uint64) Errno {
+	// args: fd, path pointer, path length
+	h.mu.Lock()
+	name, ok := h.preopens[common.FID(args[0])]
+	h.mu.Unlock()
+	if !ok {
+		return ErrnoBadf
+	}
+	if uint32(args[2]) < uint32(len(name)) {
+		return ErrnoInval
+	}
+	return h.write(uint32(args[1]), []byte(name))
+}
+
+func (h *Host) fdReaddir(args []uint64) Errno {
+	// args: fd, buf, buf_len, cookie, bufused pointer
+	bufPtr, bufLen, cookie, bufUsedPtr := uint32(args[1]), uint32(args[2]), args[3], uint32(args[4])
+	dir, ok := h.pathOf(common.FID(args[0]))
+	if !ok {
+		return ErrnoBadf
+	}
+	entries, err := h.files.ReadDir(dir)
+	if err != nil {
+		return toErrno(err)
+	}
+
+	var dirents []byte
+	for i := cookie; i < uint64(len(entries)) && uint32(len(dirents)) < bufLen; i++ {
+		entry := entries[i]
+		name := entry.Name()
+		header := make([]byte, direntHeaderSize)
+		binary.LittleEndian.PutUint64(header[0:], i+1) // d_next
+		binary.LittleEndian.PutUint32(header[16:], uint32(len(name)))
+		header[20] = fileType(entry.Type())
+		dirents = append(dirents, header...)
+		dirents = append(dirents, name...)
+	}
+	if uint32(len(dirents)) > bufLen {
+		// a truncated final entry signals the caller to grow its buffer and retry
+		dirents = dirents[:bufLen]
+	}
+	if errno := h.write(bufPtr, dirents); errno != ErrnoSuccess {
+		return errno
+	}
+	return h.writeUint32(bufUsedPtr, uint32(len(dirents)))
+}
@@ -0,0 +1,570 @@
+// Package wasi implements a wasi_snapshot_preview1 host on top of hackpad's file descriptors and process attributes.
+//
+// WASI imports are synchronous: the module is suspended inside each call until it returns.
+// On the main thread, the call also holds up the JS event loop, so a syscall can't wait for anything the event loop drives, like terminal input, a pipe written by a main thread process, or a timer.
+// Reads and writes on descriptors that aren't ready fail with EAGAIN instead of deadlocking.
+// poll_oneoff sleeps until its clock expires where the thread may sleep, as in Node.js. Otherwise, as on a browser's main thread, it reports the clock as expired right away instead of spinning, so sleeps end early.
+// Files on mounts that need the event loop, like IndexedDB, can't be used at all.
+// A module run in a Worker has its syscalls served asynchronously, so they may wait. See Host.SetBlocking.
+package wasi
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpad/internal/common"
+	"github.com/hack-pad/hackpad/internal/fs"
+)
+
+// ModuleName is the import module name WASI binaries link against
+const ModuleName = "wasi_snapshot_preview1"
+
+// maxSyscallArgs is the largest number of parameters taken by any wasi_snapshot_preview1 function (path_open)
+const maxSyscallArgs = 9
+
+// Memory provides access to a module's linear memory
+type Memory interface {
+	Read(offset, length uint32) ([]byte, error)
+	Write(offset uint32, data []byte) error
+	Size() int64
+}
+
+// Syscall is a WASI function implementation. Integer parameters of any width are passed as uint64s.
+type Syscall func(args []uint64) Errno
+
+// Host serves WASI syscalls for a single process
+type Host struct {
+	args  []string
+	env   []string
+	files *fs.FileDescriptors
+	start time.Time
+
+	mu        sync.Mutex
+	mem       Memory
+	paths     map[common.FID]string // absolute paths of preopens and files opened with path_open
+	preopens  map[common.FID]string // guest-visible names of preopened directories
+	exited    bool
+	exitCode  int
+	blocking  bool
+	sleep     func(time.Duration) bool
+	onSyscall func(memorySize int64)
+}
+
+// New creates a WASI host for a process with the given args, env, and file descriptors.
+// The root directory and working directory are preopened, so both absolute and relative paths resolve.
+func New(args []string, env map[string]string, files *fs.FileDescriptors) (*Host, error) {
+	h := &Host{
+		args:     args,
+		files:    files,
+		start:    time.Now(),
+		paths:    make(map[common.FID]string),
+		preopens: make(map[common.FID]string),
+	}
+	for key, value := range env {
+		h.env = append(h.env, key+"="+value)
+	}
+	sort.Strings(h.env)
+
+	for _, preopen := range []struct{ name, dir string }{
+		{"/", "/"},
+		{".", files.WorkingDirectory()},
+	} {
+		fid, err := files.Open(preopen.dir, os.O_RDONLY, 0)
+		if err != nil {
+			_ = h.Close()
+			return nil, err
+		}
+		h.paths[fid] = preopen.dir
+		h.preopens[fid] = preopen.name
+	}
+	return h, nil
+}
+
+// Close closes the preopened directories. Call it once the module exits or the process execs another image.
+func (h *Host) Close() error {
+	h.mu.Lock()
+	preopens := h.preopens
+	h.preopens = make(map[common.FID]string)
+	for fid := range preopens {
+		delete(h.paths, fid)
+	}
+	h.mu.Unlock()
+
+	var firstErr error
+	for fid := range preopens {
+		if err := h.files.Close(fid); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// SetMemory attaches the instantiated module's memory. Must be called before running the module.
+func (h *Host) SetMemory(mem Memory) {
+	h.mu.Lock()
+	h.mem = mem
+	h.mu.Unlock()
+}
+
+// SetBlocking sets whether syscalls may wait, i.e. for input or in poll_oneoff.
+// Only enable it when syscalls are served outside the module's call, like for a module running in a Worker. Otherwise, waiting on the event loop deadlocks.
+func (h *Host) SetBlocking(blocking bool) {
+	h.mu.Lock()
+	h.blocking = blocking
+	h.mu.Unlock()
+}
+
+func (h *Host) canBlock() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.blocking
+}
+
+// SetSleep sets how poll_oneoff waits for a clock when syscalls can't block.
+// 'sleep' pauses the thread for up to the given duration without returning to the event loop, and returns false if the thread isn't allowed to sleep.
+func (h *Host) SetSleep(sleep func(time.Duration) bool) {
+	h.mu.Lock()
+	h.sleep = sleep
+	h.mu.Unlock()
+}
+
+// sleepFor sleeps for up to 'd' without blocking on the event loop, returning false if it can't sleep
+func (h *Host) sleepFor(d time.Duration) bool {
+	h.mu.Lock()
+	sleep := h.sleep
+	h.mu.Unlock()
+	return sleep != nil && sleep(d)
+}
+
+// OnSyscall registers a hook to run before every syscall, i.e. to enforce resource limits
+func (h *Host) OnSyscall(fn func(memorySize int64)) {
+	h.mu.Lock()
+	h.onSyscall = fn
+	h.mu.Unlock()
+}
+
+// Interrupt stops the module at its next syscall, as if it called proc_exit with 'exitCode'
+func (h *Host) Interrupt(exitCode int) {
+	h.mu.Lock()
+	if !h.exited {
+		h.exited = true
+		h.exitCode = exitCode
+	}
+	h.mu.Unlock()
+}
+
+func (h *Host) beforeSyscall() {
+	h.mu.Lock()
+	onSyscall, mem := h.onSyscall, h.mem
+	h.mu.Unlock()
+	if onSyscall != nil && mem != nil {
+		onSyscall(mem.Size())
+	}
+}
+
+// Exited returns true and the exit code if the module called proc_exit
+func (h *Host) Exited() (exitCode int, exited bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.exitCode, h.exited
+}
+
+// call runs 'syscall' unless the module has exited or is interrupted. Returns true if the module must stop.
+func (h *Host) call(syscall Syscall, args []uint64) (_ Errno, exited bool) {
+	h.beforeSyscall()
+	if _, exited := h.Exited(); exited {
+		return 0, true
+	}
+	errno := syscall(args)
+	_, exited = h.Exited()
+	return errno, exited
+}
+
+// Syscalls returns every wasi_snapshot_preview1 function, keyed by import name
+func (h *Host) Syscalls() map[string]Syscall {
+	return map[string]Syscall{
+		"args_get":                h.argsGet,
+		"args_sizes_get":          h.argsSizesGet,
+		"environ_get":             h.environGet,
+		"environ_sizes_get":       h.environSizesGet,
+		"clock_res_get":           h.clockResGet,
+		"clock_time_get":          h.clockTimeGet,
+		"fd_advise":               noop,
+		"fd_allocate":             notSupported,
+		"fd_close":                h.fdClose,
+		"fd_datasync":             h.fdSync,
+		"fd_fdstat_get":           h.fdFdstatGet,
+		"fd_fdstat_set_flags":     h.fdFdstatSetFlags,
+		"fd_fdstat_set_rights":    noop,
+		"fd_filestat_get":         h.fdFilestatGet,
+		"fd_filestat_set_size":    h.fdFilestatSetSize,
+		"fd_filestat_set_times":   h.fdFilestatSetTimes,
+		"fd_pread":                h.fdPread,
+		"fd_prestat_get":          h.fdPrestatGet,
+		"fd_prestat_dir_name":     h.fdPrestatDirName,
+		"fd_pwrite":               h.fdPwrite,
+		"fd_read":                 h.fdRead,
+		"fd_readdir":              h.fdReaddir,
+		"fd_renumber":             notSupported,
+		"fd_seek":                 h.fdSeek,
+		"fd_sync":                 h.fdSync,
+		"fd_tell":                 h.fdTell,
+		"fd_write":                h.fdWrite,
+		"path_create_directory":   h.pathCreateDirectory,
+		"path_filestat_get":       h.pathFilestatGet,
+		"path_filestat_set_times": h.pathFilestatSetTimes,
+		"path_link":               notSupported,
+		"path_open":               h.pathOpen,
+		"path_readlink":           notSupported,
+		"path_remove_directory":   h.pathRemoveDirectory,
+		"path_rename":             h.pathRename,
+		"path_symlink":            notSupported,
+		"path_unlink_file":        h.pathUnlinkFile,
+		"poll_oneoff":             h.pollOneoff,
+		"proc_exit":               h.procExit,
+		"proc_raise":              notSupported,
+		"sched_yield":             noop,
+		"random_get":              h.randomGet,
+		"sock_accept":             notSupported,
+		"sock_recv":               notSupported,
+		"sock_send":               notSupported,
+		"sock_shutdown":           notSupported,
+	}
+}
+
+// SyscallNames returns the import names of every wasi_snapshot_preview1 function, sorted
+func SyscallNames() []string {
+	var names []string
+	for name := range (&Host{}).Syscalls() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func noop([]uint64) Errno {
+	return ErrnoSuccess
+}
+
+func notSupported([]uint64) Errno {
+	return ErrnoNosys
+}
+
+func (h *Host) memory() Memory {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.mem
+}
+
+func (h *Host) read(offset, length uint32) ([]byte, Errno) {
+	data, err := h.memory().Read(offset, length)
+	if err != nil {
+		return nil, ErrnoFault
+	}
+	return data, ErrnoSuccess
+}
+
+func (h *Host) write(offset uint32, data []byte) Errno {
+	if err := h.memory().Write(offset, data); err != nil {
+		return ErrnoFault
+	}
+	return ErrnoSuccess
+}
+
+func (h *Host) writeUint32(offset uint32, value uint32) Errno {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], value)
+	return h.write(offset, buf[:])
+}
+
+func (h *Host) writeUint64(offset uint32, value uint64) Errno {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], value)
+	return h.write(offset, buf[:])
+}
+
+func (h *Host) readString(offset, length uint32) (string, Errno) {
+	data, errno := h.read(offset, length)
+	return string(data), errno
+}
+
+// writeStrings writes a list of null-terminated strings into 'buf' and stores a pointer to each one in 'ptrs'
+func (h *Host) writeStrings(strs []string, ptrs, buf uint32) Errno {
+	for _, s := range strs {
+		if errno := h.writeUint32(ptrs, buf); errno != ErrnoSuccess {
+			return errno
+		}
+		if errno := h.write(buf, append([]byte(s), 0)); errno != ErrnoSuccess {
+			return errno
+		}
+		ptrs += 4
+		buf += uint32(len(s)) + 1
+	}
+	return ErrnoSuccess
+}
+
+func (h *Host) writeStringSizes(strs []string, countPtr, sizePtr uint32) Errno {
+	size := 0
+	for _, s := range strs {
+		size += len(s) + 1
+	}
+	if errno := h.writeUint32(countPtr, uint32(len(strs))); errno != ErrnoSuccess {
+		return errno
+	}
+	return h.writeUint32(sizePtr, uint32(size))
+}
+
+func (h *Host) argsGet(args []uint64) Errno {
+	return h.writeStrings(h.args, uint32(args[0]), uint32(args[1]))
+}
+
+func (h *Host) argsSizesGet(args []uint64) Errno {
+	return h.writeStringSizes(h.args, uint32(args[0]), uint32(args[1]))
+}
+
+func (h *Host) environGet(args []uint64) Errno {
+	return h.writeStrings(h.env, uint32(args[0]), uint32(args[1]))
+}
+
+func (h *Host) environSizesGet(args []uint64) Errno {
+	return h.writeStringSizes(h.env, uint32(args[0]), uint32(args[1]))
+}
+
+const (
+	clockRealtime = iota
+	clockMonotonic
+	clockProcessCPUTime
+	clockThreadCPUTime
+)
+
+func (h *Host) now(clockID uint32) (uint64, Errno) {
+	switch clockID {
+	case clockRealtime:
+		return uint64(time.Now().UnixNano()), ErrnoSuccess
+	case clockMonotonic, clockProcessCPUTime, clockThreadCPUTime:
+		return uint64(time.Since(h.start).Nanoseconds()), ErrnoSuccess
+	default:
+		return 0, ErrnoInval
+	}
+}
+
+func (h *Host) clockResGet(args []uint64) Errno {
+	if _, errno := h.now(uint32(args[0])); errno != ErrnoSuccess {
+		return errno
+	}
+	return h.writeUint64(uint32(args[1]), uint64(time.Microsecond))
+}
+
+func (h *Host) clockTimeGet(args []uint64) Errno {
+	// args: id, precision, time pointer
+	now, errno := h.now(uint32(args[0]))
+	if errno != ErrnoSuccess {
+		return errno
+	}
+	return h.writeUint64(uint32(args[2]), now)
+}
+
+func (h *Host) randomGet(args []uint64) Errno {
+	bufPtr, length := uint32(args[0]), uint32(args[1])
+	// check bounds before allocating, so a guest can't make the host allocate more than its own memory
+	if uint64(bufPtr)+uint64(length) > uint64(h.memory().Size()) {
+		return ErrnoFault
+	}
+	buf := make([]byte, length)
+	if _, err := rand.Read(buf); err != nil {
+		return ErrnoIO
+	}
+	return h.write(bufPtr, buf)
+}
+
+func (h *Host) procExit(args []uint64) Errno {
+	h.Interrupt(int(int32(args[0])))
+	return ErrnoSuccess
+}
+
+const (
+	subscriptionSize    = 48
+	eventSize           = 32
+	eventTypeClock      = 0
+	eventTypeFDRead     = 1
+	eventTypeFDWrite    = 2
+	subscriptionAbstime = 1
+	// pollInterval is how often poll_oneoff checks descriptors while sleeping until a clock expires
+	pollInterval = time.Millisecond
+)
+
+// pollOneoff supports clock and file descriptor subscriptions. It waits on the descriptors' readiness and the earliest clock's timer.
+// If syscalls can't block, descriptors can't be waited on: if none are ready and there is no clock to wait on, they are reported as ready.
+// A clock is waited on by sleeping, checking descriptors every pollInterval. If the thread can't sleep either, the earliest clock is reported as expired right away.
+func (h *Host) pollOneoff(args []uint64) Errno {
+	// args: subscriptions pointer, events pointer, subscription count, events written pointer
+	inPtr, outPtr, count, countPtr := uint32(args[0]), uint32(args[1]), uint32(args[2]), uint32(args[3])
+	if count == 0 {
+		return ErrnoInval
+	}
+	subscriptions, errno := h.read(inPtr, count*subscriptionSize)
+	if errno != ErrnoSuccess {
+		return errno
+	}
+
+	type clockSubscription struct {
+		userData []byte
+		deadline time.Time
+	}
+	type fdSubscription struct {
+		userData  []byte
+		eventType byte
+	}
+	var clocks []clockSubscription
+	var fdSubs []fdSubscription
+	var fds []common.FID
+	var fdEvents []fs.PollEvents
+	for i := uint32(0); i < count; i++ {
+		sub := subscriptions[i*subscriptionSize : (i+1)*subscriptionSize]
+		userData, eventType := sub[0:8], sub[8]
+		switch eventType {
+		case eventTypeClock:
+			timeout := time.Duration(binary.LittleEndian.Uint64(sub[24:32]))
+			flags := binary.LittleEndian.Uint16(sub[40:42])
+			deadline := time.Now().Add(timeout)
+			if flags&subscriptionAbstime != 0 {
+				now, errno := h.now(binary.LittleEndian.Uint32(sub[16:20]))
+				if errno != ErrnoSuccess {
+					return errno
+				}
+				deadline = time.Now().Add(timeout - time.Duration(now))
+			}
+			clocks = append(clocks, clockSubscription{userData: userData, deadline: deadline})
+		case eventTypeFDRead, eventTypeFDWrite:
+			fdSubs = append(fdSubs, fdSubscription{userData: userData, eventType: eventType})
+			fds = append(fds, common.FID(binary.LittleEndian.Uint32(sub[16:20])))
+			if eventType == eventTypeFDRead {
+				fdEvents = append(fdEvents, fs.PollIn)
+			} else {
+				fdEvents = append(fdEvents, fs.PollOut)
+			}
+		default:
+			return ErrnoInval
+		}
+	}
+
+	pollFDs := func(timeout time.Duration) ([]byte, Errno) {
+		if len(fds) == 0 {
+			if timeout > 0 {
+				time.Sleep(timeout)
+			}
+			return nil, ErrnoSuccess
+		}
+		revents, err := h.files.Poll(fds, fdEvents, timeout)
+		if err != nil {
+			return nil, toErrno(err)
+		}
+		var events []byte
+		for i, revent := range revents {
+			switch {
+			case revent&fs.PollNval != 0:
+				events = append(events, newEvent(fdSubs[i].userData, ErrnoBadf, fdSubs[i].eventType)...)
+			case revent != 0:
+				events = append(events, newEvent(fdSubs[i].userData, ErrnoSuccess, fdSubs[i].eventType)...)
+			}
+		}
+		return events, ErrnoSuccess
+	}
+
+	var earliest time.Time
+	for i, c := range clocks {
+		if i == 0 || c.deadline.Before(earliest) {
+			earliest = c.deadline
+		}
+	}
+	timeout := time.Duration(-1)
+	if len(clocks) > 0 {
+		timeout = time.Until(earliest)
+		if timeout < 0 {
+			timeout = 0
+		}
+	}
+	blocking := h.canBlock()
+	if !blocking {
+		timeout = 0
+	}
+
+	events, errno := pollFDs(timeout)
+	if errno != ErrnoSuccess {
+		return errno
+	}
+	switch {
+	case len(events) > 0:
+	case len(clocks) > 0:
+		expireEarliest := false
+		for !blocking && len(events) == 0 && time.Now().Before(earliest) {
+			wait := time.Until(earliest)
+			if len(fds) > 0 && wait > pollInterval {
+				wait = pollInterval
+			}
+			if !h.sleepFor(wait) {
+				expireEarliest = true
+				break
+			}
+			events, errno = pollFDs(0)
+			if errno != ErrnoSuccess {
+				return errno
+			}
+		}
+		for _, c := range clocks {
+			if !time.Now().Before(c.deadline) || (expireEarliest && c.deadline.Equal(earliest)) {
+				events = append(events, newEvent(c.userData, ErrnoSuccess, eventTypeClock)...)
+			}
+		}
+	default:
+		for _, sub := range fdSubs {
+			events = append(events, newEvent(sub.userData, ErrnoSuccess, sub.eventType)...)
+		}
+	}
+	if errno := h.write(outPtr, events); errno != ErrnoSuccess {
+		return errno
+	}
+	return h.writeUint32(countPtr, uint32(len(events)/eventSize))
+}
+
+func newEvent(userData []byte, errno Errno, eventType byte) []byte {
+	event := make([]byte, eventSize)
+	copy(event[0:8], userData)
+	binary.LittleEndian.PutUint16(event[8:10], uint16(errno))
+	event[10] = eventType
+	return event
+}
+
+func (h *Host) pathOf(fid common.FID) (string, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	p, ok := h.paths[fid]
+	return p, ok
+}
+
+// resolve returns the absolute path for 'name' relative to directory descriptor 'dirFD'.
+// Like absolute paths, '..' segments that would leave the directory fail with ENOTCAPABLE, since a descriptor only grants access to what's beneath it.
+func (h *Host) resolve(dirFD uint32, pathPtr, pathLen uint32) (string, Errno) {
+	name, errno := h.readString(pathPtr, pathLen)
+	if errno != ErrnoSuccess {
+		return "", errno
+	}
+	dir, ok := h.pathOf(common.FID(dirFD))
+	if !ok {
+		return "", ErrnoBadf
+	}
+	if path.IsAbs(name) {
+		return "", ErrnoNotcapable
+	}
+	p := path.Join(dir, name)
+	if p != dir && !strings.HasPrefix(p, strings.TrimSuffix(dir, "/")+"/") {
+		return "", ErrnoNotcapable
+	}
+	return p, ErrnoSuccess
+}
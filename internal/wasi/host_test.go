@@ -0,0 +1,612 @@
+package wasi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpad/internal/common"
+	"github.com/hack-pad/hackpad/internal/fs"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+	"github.com/pkg/errors"
+)
+
+const (
+	testMemorySize = 1 << 16
+	// preopened descriptors, after stdin, stdout, and stderr
+	testRootFD = 3
+	testDirFD  = 4
+)
+
+type testMemory []byte
+
+func (m testMemory) Read(offset, length uint32) ([]byte, error) {
+	if uint64(offset)+uint64(length) > uint64(len(m)) {
+		return nil, errors.Errorf("memory access out of bounds: %d+%d", offset, length)
+	}
+	return append([]byte(nil), m[offset:offset+length]...), nil
+}
+
+func (m testMemory) Write(offset uint32, data []byte) error {
+	if uint64(offset)+uint64(len(data)) > uint64(len(m)) {
+		return errors.Errorf("memory access out of bounds: %d+%d", offset, len(data))
+	}
+	copy(m[offset:], data)
+	return nil
+}
+
+func (m testMemory) Size() int64 {
+	return int64(len(m))
+}
+
+func (m testMemory) uint32(offset uint32) uint32 {
+	return binary.LittleEndian.Uint32(m[offset:])
+}
+
+func (m testMemory) uint64(offset uint32) uint64 {
+	return binary.LittleEndian.Uint64(m[offset:])
+}
+
+// iovecs writes 'chunks' at 'offset' followed by an iovec for each, returning the iovec pointer
+func (m testMemory) iovecs(offset uint32, chunks ...string) uint32 {
+	iovs := offset
+	for _, chunk := range chunks {
+		iovs += uint32(len(chunk))
+	}
+	dataOffset := offset
+	for i, chunk := range chunks {
+		copy(m[dataOffset:], chunk)
+		binary.LittleEndian.PutUint32(m[iovs+uint32(i)*8:], dataOffset)
+		binary.LittleEndian.PutUint32(m[iovs+uint32(i)*8+4:], uint32(len(chunk)))
+		dataOffset += uint32(len(chunk))
+	}
+	return iovs
+}
+
+// newTestHost returns a host whose working directory is a new, empty directory
+func newTestHost(t *testing.T) (*Host, testMemory, *fs.FileDescriptors) {
+	t.Helper()
+	dir := fmt.Sprintf("/%s-%d", t.Name(), time.Now().UnixNano())
+	setup, err := fs.NewStdFileDescriptors(1, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = setup.MkdirAll(dir, 0700)
+	setup.CloseAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := fs.NewStdFileDescriptors(1, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(files.CloseAll)
+	h, err := New([]string{"prog", "arg"}, map[string]string{"HOME": "/home/me"}, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mem := make(testMemory, testMemorySize)
+	h.SetMemory(mem)
+	return h, mem, files
+}
+
+func syscall(t *testing.T, h *Host, name string, args ...uint64) Errno {
+	t.Helper()
+	fn, ok := h.Syscalls()[name]
+	if !ok {
+		t.Fatalf("Unknown syscall: %s", name)
+	}
+	return fn(append(args, make([]uint64, maxSyscallArgs-len(args))...))
+}
+
+func newTestPipe(t *testing.T, files *fs.FileDescriptors) (r, w common.FID) {
+	t.Helper()
+	fds, err := files.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fds[0], fds[1]
+}
+
+func TestFDWriteRead(t *testing.T) {
+	h, mem, files := newTestHost(t)
+	r, w := newTestPipe(t, files)
+
+	const nPtr = 1000
+	iovs := mem.iovecs(100, "hel", "lo")
+	if errno := syscall(t, h, "fd_write", uint64(w), uint64(iovs), 2, nPtr); errno != ErrnoSuccess {
+		t.Fatal("fd_write:", errno)
+	}
+	if n := mem.uint32(nPtr); n != 5 {
+		t.Errorf("Expected 5 bytes written, got %d", n)
+	}
+
+	iovs = mem.iovecs(200, "xx", "xxxxxx")
+	if errno := syscall(t, h, "fd_read", uint64(r), uint64(iovs), 2, nPtr); errno != ErrnoSuccess {
+		t.Fatal("fd_read:", errno)
+	}
+	if n := mem.uint32(nPtr); n != 5 {
+		t.Errorf("Expected 5 bytes read, got %d", n)
+	}
+	if data := string(mem[200:208]); data != "helloxxx" {
+		t.Errorf("Expected reads to fill each iovec in order, got %q", data)
+	}
+}
+
+func TestFDWriteReadErrors(t *testing.T) {
+	for _, tc := range []struct {
+		description string
+		syscall     string
+		args        func(r, w common.FID, mem testMemory) []uint64
+		expect      Errno
+	}{
+		{
+			description: "write bad descriptor",
+			syscall:     "fd_write",
+			args: func(r, w common.FID, mem testMemory) []uint64 {
+				return []uint64{99, uint64(mem.iovecs(0, "a")), 1, 100}
+			},
+			expect: ErrnoBadf,
+		},
+		{
+			description: "write iovecs out of bounds",
+			syscall:     "fd_write",
+			args: func(r, w common.FID, mem testMemory) []uint64 {
+				return []uint64{uint64(w), testMemorySize - 4, 1, 100}
+			},
+			expect: ErrnoFault,
+		},
+		{
+			description: "write data out of bounds",
+			syscall:     "fd_write",
+			args: func(r, w common.FID, mem testMemory) []uint64 {
+				iovs := mem.iovecs(0, "a")
+				binary.LittleEndian.PutUint32(mem[iovs:], testMemorySize)
+				return []uint64{uint64(w), uint64(iovs), 1, 100}
+			},
+			expect: ErrnoFault,
+		},
+		{
+			description: "read bad descriptor",
+			syscall:     "fd_read",
+			args: func(r, w common.FID, mem testMemory) []uint64 {
+				return []uint64{99, uint64(mem.iovecs(0, "a")), 1, 100}
+			},
+			expect: ErrnoBadf,
+		},
+		{
+			description: "read empty pipe without blocking",
+			syscall:     "fd_read",
+			args: func(r, w common.FID, mem testMemory) []uint64 {
+				return []uint64{uint64(r), uint64(mem.iovecs(0, "a")), 1, 100}
+			},
+			expect: ErrnoAgain,
+		},
+	} {
+		t.Run(tc.description, func(t *testing.T) {
+			h, mem, files := newTestHost(t)
+			r, w := newTestPipe(t, files)
+			if errno := syscall(t, h, tc.syscall, tc.args(r, w, mem)...); errno != tc.expect {
+				t.Errorf("Expected errno %d, got %d", tc.expect, errno)
+			}
+		})
+	}
+}
+
+func TestFDReadBlocking(t *testing.T) {
+	h, mem, files := newTestHost(t)
+	h.SetBlocking(true)
+	r, w := newTestPipe(t, files)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_, _ = files.Write(w, blob.NewBytes([]byte("hi")), 0, 2, nil)
+	}()
+	const nPtr = 1000
+	iovs := mem.iovecs(0, "xxxx")
+	if errno := syscall(t, h, "fd_read", uint64(r), uint64(iovs), 1, nPtr); errno != ErrnoSuccess {
+		t.Fatal("fd_read:", errno)
+	}
+	if data := string(mem[0:mem.uint32(nPtr)]); data != "hi" {
+		t.Errorf("Expected read to wait for %q, got %q", "hi", data)
+	}
+}
+
+func TestPathOpen(t *testing.T) {
+	const (
+		rights     = rightFDRead | rightFDWrite
+		pathOffset = 100
+		fdPtr      = 1000
+	)
+	for _, tc := range []struct {
+		description string
+		dirFD       uint64
+		path        string
+		oflags      uint64
+		expect      Errno
+	}{
+		{description: "create", dirFD: testDirFD, path: "new", oflags: oflagsCreat},
+		{description: "existing", dirFD: testDirFD, path: "file"},
+		{description: "existing from root", dirFD: testRootFD, path: "file"},
+		{description: "missing", dirFD: testDirFD, path: "missing", expect: ErrnoNoent},
+		{description: "exclusive", dirFD: testDirFD, path: "file", oflags: oflagsCreat | oflagsExcl, expect: ErrnoExist},
+		{description: "directory", dirFD: testDirFD, path: "dir", oflags: oflagsDirectory},
+		{description: "not a directory", dirFD: testDirFD, path: "file", oflags: oflagsDirectory, expect: ErrnoNotdir},
+		{description: "absolute path", dirFD: testDirFD, path: "/file", expect: ErrnoNotcapable},
+		{description: "parent directory", dirFD: testDirFD, path: "../file", expect: ErrnoNotcapable},
+		{description: "parent directory after subdirectory", dirFD: testDirFD, path: "dir/../../file", expect: ErrnoNotcapable},
+		{description: "dot dot within directory", dirFD: testDirFD, path: "dir/../file"},
+		{description: "parent of root", dirFD: testRootFD, path: "../file", expect: ErrnoNoent},
+		{description: "bad directory descriptor", dirFD: 99, path: "file", expect: ErrnoBadf},
+	} {
+		t.Run(tc.description, func(t *testing.T) {
+			h, mem, files := newTestHost(t)
+			dir := files.WorkingDirectory()
+			fid, err := files.Open(path.Join(dir, "file"), os.O_CREATE|os.O_WRONLY, 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := files.Close(fid); err != nil {
+				t.Fatal(err)
+			}
+			if err := files.Mkdir(path.Join(dir, "dir"), 0700); err != nil {
+				t.Fatal(err)
+			}
+
+			name := tc.path
+			if tc.dirFD == testRootFD {
+				name = path.Join(strings.TrimPrefix(dir, "/"), name)
+			}
+			copy(mem[pathOffset:], name)
+			errno := syscall(t, h, "path_open", tc.dirFD, 0, pathOffset, uint64(len(name)), tc.oflags, rights, rights, 0, fdPtr)
+			if errno != tc.expect {
+				t.Fatalf("Expected errno %d, got %d", tc.expect, errno)
+			}
+			if tc.expect != ErrnoSuccess {
+				return
+			}
+			fd := uint64(mem.uint32(fdPtr))
+			const statPtr = 2000
+			if errno := syscall(t, h, "fd_filestat_get", fd, statPtr); errno != ErrnoSuccess {
+				t.Fatal("fd_filestat_get:", errno)
+			}
+			expectType := byte(fileTypeRegularFile)
+			if tc.oflags&oflagsDirectory != 0 {
+				expectType = fileTypeDirectory
+			}
+			if fileType := mem[statPtr+16]; fileType != expectType {
+				t.Errorf("Expected file type %d, got %d", expectType, fileType)
+			}
+			if errno := syscall(t, h, "fd_close", fd); errno != ErrnoSuccess {
+				t.Error("fd_close:", errno)
+			}
+		})
+	}
+}
+
+func TestClock(t *testing.T) {
+	h, mem, _ := newTestHost(t)
+	const timePtr = 100
+
+	before := time.Now()
+	if errno := syscall(t, h, "clock_time_get", clockRealtime, 0, timePtr); errno != ErrnoSuccess {
+		t.Fatal("clock_time_get:", errno)
+	}
+	if now := time.Unix(0, int64(mem.uint64(timePtr))); now.Before(before.Truncate(time.Microsecond)) || now.After(time.Now()) {
+		t.Errorf("Expected realtime clock near %s, got %s", before, now)
+	}
+
+	var last uint64
+	for i := 0; i < 3; i++ {
+		if errno := syscall(t, h, "clock_time_get", clockMonotonic, 0, timePtr); errno != ErrnoSuccess {
+			t.Fatal("clock_time_get:", errno)
+		}
+		if now := mem.uint64(timePtr); now < last {
+			t.Errorf("Expected monotonic clock, got %d after %d", now, last)
+		} else {
+			last = now
+		}
+	}
+
+	if errno := syscall(t, h, "clock_res_get", clockMonotonic, timePtr); errno != ErrnoSuccess {
+		t.Fatal("clock_res_get:", errno)
+	}
+	if res := mem.uint64(timePtr); res != uint64(time.Microsecond) {
+		t.Errorf("Expected resolution %d, got %d", time.Microsecond, res)
+	}
+	for _, name := range []string{"clock_time_get", "clock_res_get"} {
+		if errno := syscall(t, h, name, 99, 0, timePtr); errno != ErrnoInval {
+			t.Errorf("%s: Expected EINVAL for an unknown clock, got %d", name, errno)
+		}
+	}
+}
+
+type testSubscription struct {
+	userData  uint64
+	eventType byte
+	fd        uint32        // for fd subscriptions
+	timeout   time.Duration // for clock subscriptions
+}
+
+func (s testSubscription) bytes() []byte {
+	b := make([]byte, subscriptionSize)
+	binary.LittleEndian.PutUint64(b[0:], s.userData)
+	b[8] = s.eventType
+	if s.eventType == eventTypeClock {
+		binary.LittleEndian.PutUint32(b[16:], clockMonotonic)
+		binary.LittleEndian.PutUint64(b[24:], uint64(s.timeout))
+	} else {
+		binary.LittleEndian.PutUint32(b[16:], s.fd)
+	}
+	return b
+}
+
+type testEvent struct {
+	userData  uint64
+	errno     Errno
+	eventType byte
+}
+
+func TestPollOneoff(t *testing.T) {
+	const (
+		readyFD = 100 // replaced with a pipe holding data
+		emptyFD = 101 // replaced with an empty pipe
+		badFD   = 99
+		timeout = 20 * time.Millisecond
+	)
+	for _, tc := range []struct {
+		description   string
+		blocking      bool
+		sleep         bool
+		subscriptions []testSubscription
+		expectEvents  []testEvent
+		expectWait    bool
+	}{
+		{
+			description:   "clock",
+			blocking:      true,
+			subscriptions: []testSubscription{{userData: 1, eventType: eventTypeClock, timeout: timeout}},
+			expectEvents:  []testEvent{{userData: 1, eventType: eventTypeClock}},
+			expectWait:    true,
+		},
+		{
+			description:   "clock without blocking",
+			sleep:         true,
+			subscriptions: []testSubscription{{userData: 1, eventType: eventTypeClock, timeout: timeout}},
+			expectEvents:  []testEvent{{userData: 1, eventType: eventTypeClock}},
+			expectWait:    true,
+		},
+		{
+			description: "clock without blocking or sleeping",
+			subscriptions: []testSubscription{
+				{userData: 1, eventType: eventTypeClock, timeout: timeout},
+				{userData: 2, eventType: eventTypeClock, timeout: time.Hour},
+			},
+			expectEvents: []testEvent{{userData: 1, eventType: eventTypeClock}},
+		},
+		{
+			description: "descriptor not ready before clock without blocking",
+			sleep:       true,
+			subscriptions: []testSubscription{
+				{userData: 1, eventType: eventTypeFDRead, fd: emptyFD},
+				{userData: 2, eventType: eventTypeClock, timeout: timeout},
+			},
+			expectEvents: []testEvent{{userData: 2, eventType: eventTypeClock}},
+			expectWait:   true,
+		},
+		{
+			description: "ready descriptor",
+			blocking:    true,
+			subscriptions: []testSubscription{
+				{userData: 1, eventType: eventTypeFDRead, fd: readyFD},
+				{userData: 2, eventType: eventTypeClock, timeout: time.Hour},
+			},
+			expectEvents: []testEvent{{userData: 1, eventType: eventTypeFDRead}},
+		},
+		{
+			description: "descriptor not ready before clock",
+			blocking:    true,
+			subscriptions: []testSubscription{
+				{userData: 1, eventType: eventTypeFDRead, fd: emptyFD},
+				{userData: 2, eventType: eventTypeClock, timeout: timeout},
+			},
+			expectEvents: []testEvent{{userData: 2, eventType: eventTypeClock}},
+			expectWait:   true,
+		},
+		{
+			description:   "descriptor not ready without blocking",
+			subscriptions: []testSubscription{{userData: 1, eventType: eventTypeFDRead, fd: emptyFD}},
+			expectEvents:  []testEvent{{userData: 1, eventType: eventTypeFDRead}},
+		},
+		{
+			description:   "bad descriptor",
+			blocking:      true,
+			subscriptions: []testSubscription{{userData: 1, eventType: eventTypeFDWrite, fd: badFD}},
+			expectEvents:  []testEvent{{userData: 1, errno: ErrnoBadf, eventType: eventTypeFDWrite}},
+		},
+	} {
+		t.Run(tc.description, func(t *testing.T) {
+			h, mem, files := newTestHost(t)
+			h.SetBlocking(tc.blocking)
+			if tc.sleep {
+				h.SetSleep(func(d time.Duration) bool {
+					time.Sleep(d)
+					return true
+				})
+			}
+			readyR, readyW := newTestPipe(t, files)
+			if _, err := files.Write(readyW, blob.NewBytes([]byte("x")), 0, 1, nil); err != nil {
+				t.Fatal(err)
+			}
+			emptyR, _ := newTestPipe(t, files)
+
+			const (
+				inPtr    = 0
+				outPtr   = 1000
+				countPtr = 2000
+			)
+			for i, sub := range tc.subscriptions {
+				switch sub.fd {
+				case readyFD:
+					sub.fd = uint32(readyR)
+				case emptyFD:
+					sub.fd = uint32(emptyR)
+				}
+				copy(mem[inPtr+i*subscriptionSize:], sub.bytes())
+			}
+
+			start := time.Now()
+			errno := syscall(t, h, "poll_oneoff", inPtr, outPtr, uint64(len(tc.subscriptions)), countPtr)
+			elapsed := time.Since(start)
+			if errno != ErrnoSuccess {
+				t.Fatal("poll_oneoff:", errno)
+			}
+			if tc.expectWait && elapsed < timeout {
+				t.Errorf("Expected to wait at least %s, waited %s", timeout, elapsed)
+			}
+			if !tc.expectWait && elapsed >= timeout {
+				t.Errorf("Expected no wait, waited %s", elapsed)
+			}
+
+			var events []testEvent
+			for i := uint32(0); i < mem.uint32(countPtr); i++ {
+				event := mem[outPtr+i*eventSize:]
+				events = append(events, testEvent{
+					userData:  binary.LittleEndian.Uint64(event[0:]),
+					errno:     Errno(binary.LittleEndian.Uint16(event[8:])),
+					eventType: event[10],
+				})
+			}
+			if len(events) != len(tc.expectEvents) {
+				t.Fatalf("Expected events %+v, got %+v", tc.expectEvents, events)
+			}
+			for i := range events {
+				if events[i] != tc.expectEvents[i] {
+					t.Errorf("Expected events %+v, got %+v", tc.expectEvents, events)
+					break
+				}
+			}
+		})
+	}
+
+	h, _, _ := newTestHost(t)
+	if errno := syscall(t, h, "poll_oneoff", 0, 1000, 0, 2000); errno != ErrnoInval {
+		t.Errorf("Expected EINVAL with no subscriptions, got %d", errno)
+	}
+}
+
+func TestArgsEnviron(t *testing.T) {
+	h, mem, _ := newTestHost(t)
+	const (
+		countPtr = 0
+		sizePtr  = 4
+		ptrs     = 100
+		buf      = 200
+	)
+	if errno := syscall(t, h, "args_sizes_get", countPtr, sizePtr); errno != ErrnoSuccess {
+		t.Fatal("args_sizes_get:", errno)
+	}
+	if count, size := mem.uint32(countPtr), mem.uint32(sizePtr); count != 2 || size != uint32(len("prog\x00arg\x00")) {
+		t.Errorf("Expected 2 args of %d bytes, got %d of %d", len("prog\x00arg\x00"), count, size)
+	}
+	if errno := syscall(t, h, "args_get", ptrs, buf); errno != ErrnoSuccess {
+		t.Fatal("args_get:", errno)
+	}
+	if data := string(mem[buf : buf+9]); data != "prog\x00arg\x00" {
+		t.Errorf("Expected null-terminated args, got %q", data)
+	}
+	if ptr := mem.uint32(ptrs + 4); ptr != buf+5 {
+		t.Errorf("Expected second arg at %d, got %d", buf+5, ptr)
+	}
+	if errno := syscall(t, h, "environ_get", ptrs, buf); errno != ErrnoSuccess {
+		t.Fatal("environ_get:", errno)
+	}
+	if data := string(mem[buf : buf+len("HOME=/home/me\x00")]); data != "HOME=/home/me\x00" {
+		t.Errorf("Expected environment, got %q", data)
+	}
+}
+
+func TestClose(t *testing.T) {
+	h, _, files := newTestHost(t)
+	openFiles := len(files.OpenFiles())
+	if errno := syscall(t, h, "fd_close", testRootFD); errno != ErrnoSuccess {
+		t.Fatal("fd_close:", errno)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if remaining := len(files.OpenFiles()); remaining != openFiles-2 {
+		t.Errorf("Expected both preopens to be closed, leaving %d files open, got %d: %v", openFiles-2, remaining, files)
+	}
+	if errno := syscall(t, h, "fd_prestat_get", testDirFD, 0); errno != ErrnoBadf {
+		t.Errorf("Expected closed preopen to be a bad descriptor, got errno %d", errno)
+	}
+	if err := h.Close(); err != nil {
+		t.Error("Close should be idempotent:", err)
+	}
+}
+
+func TestRandomGet(t *testing.T) {
+	h, mem, _ := newTestHost(t)
+	const bufPtr, length = 100, 32
+	if errno := syscall(t, h, "random_get", bufPtr, length); errno != ErrnoSuccess {
+		t.Fatal("random_get:", errno)
+	}
+	if data := mem[bufPtr : bufPtr+length]; string(data) == string(make([]byte, length)) {
+		t.Error("Expected random bytes, got all zeros")
+	}
+
+	for _, tc := range []struct {
+		description    string
+		bufPtr, length uint64
+	}{
+		{"past end of memory", testMemorySize - 8, 16},
+		{"4 GiB", 0, 1<<32 - 1},
+		{"pointer overflows", 1<<32 - 1, 2},
+	} {
+		if errno := syscall(t, h, "random_get", tc.bufPtr, tc.length); errno != ErrnoFault {
+			t.Errorf("%s: Expected errno %d, got %d", tc.description, ErrnoFault, errno)
+		}
+	}
+}
+
+func TestPollOneoffSleepChecksDescriptors(t *testing.T) {
+	h, mem, files := newTestHost(t)
+	r, w := newTestPipe(t, files)
+	sleeps := 0
+	h.SetSleep(func(d time.Duration) bool {
+		if d > pollInterval {
+			t.Errorf("Expected sleeps of at most %s while waiting on a descriptor, got %s", pollInterval, d)
+		}
+		sleeps++
+		if sleeps == 3 {
+			if _, err := files.Write(w, blob.NewBytes([]byte("x")), 0, 1, nil); err != nil {
+				t.Error(err)
+			}
+		}
+		return true
+	})
+	const (
+		inPtr    = 0
+		outPtr   = 1000
+		countPtr = 2000
+	)
+	copy(mem[inPtr:], testSubscription{userData: 1, eventType: eventTypeFDRead, fd: uint32(r)}.bytes())
+	copy(mem[inPtr+subscriptionSize:], testSubscription{userData: 2, eventType: eventTypeClock, timeout: time.Hour}.bytes())
+	if errno := syscall(t, h, "poll_oneoff", inPtr, outPtr, 2, countPtr); errno != ErrnoSuccess {
+		t.Fatal("poll_oneoff:", errno)
+	}
+	if count := mem.uint32(countPtr); count != 1 {
+		t.Fatalf("Expected 1 event, got %d", count)
+	}
+	if userData := mem.uint64(outPtr); userData != 1 {
+		t.Errorf("Expected the descriptor's event, got user data %d", userData)
+	}
+	if sleeps != 3 {
+		t.Errorf("Expected 3 sleeps, got %d", sleeps)
+	}
+}
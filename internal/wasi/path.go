@@ -0,0 +1,141 @@
+package wasi
+
+import (
+	"os"
+
+	"github.com/hack-pad/hackpad/internal/fs"
+)
+
+const (
+	lookupSymlinkFollow = 1
+
+	oflagsCreat     = 1 << 0
+	oflagsDirectory = 1 << 1
+	oflagsExcl      = 1 << 2
+	oflagsTrunc     = 1 << 3
+
+	fdflagsAppend   = 1 << 0
+	fdflagsNonblock = 1 << 2
+
+	rightFDRead  = 1 << 1
+	rightFDWrite = 1 << 6
+)
+
+func (h *Host) pathOpen(args []uint64) Errno {
+	// args: dirfd, dirflags, path, path_len, oflags, fs_rights_base, fs_rights_inheriting, fdflags, fd pointer
+	p, errno := h.resolve(uint32(args[0]), uint32(args[2]), uint32(args[3]))
+	if errno != ErrnoSuccess {
+		return errno
+	}
+	oflags, rights, fdflags, fdPtr := uint16(args[4]), args[5], uint16(args[7]), uint32(args[8])
+
+	var flags int
+	switch {
+	case rights&rightFDRead != 0 && rights&rightFDWrite != 0:
+		flags = os.O_RDWR
+	case rights&rightFDWrite != 0:
+		flags = os.O_WRONLY
+	default:
+		flags = os.O_RDONLY
+	}
+	if oflags&oflagsCreat != 0 {
+		flags |= os.O_CREATE
+	}
+	if oflags&oflagsExcl != 0 {
+		flags |= os.O_EXCL
+	}
+	if oflags&oflagsTrunc != 0 {
+		flags |= os.O_TRUNC
+	}
+	if fdflags&fdflagsAppend != 0 {
+		flags |= os.O_APPEND
+	}
+	if fdflags&fdflagsNonblock != 0 {
+		flags |= fs.OpenNonBlock
+	}
+
+	if oflags&oflagsDirectory != 0 {
+		info, err := h.files.Stat(p)
+		if err != nil {
+			return toErrno(err)
+		}
+		if !info.IsDir() {
+			return ErrnoNotdir
+		}
+	}
+
+	fid, err := h.files.Open(p, flags, 0666)
+	if err != nil {
+		return toErrno(err)
+	}
+	h.mu.Lock()
+	h.paths[fid] = p
+	h.mu.Unlock()
+	return h.writeUint32(fdPtr, uint32(fid))
+}
+
+func (h *Host) pathCreateDirectory(args []uint64) Errno {
+	// args: dirfd, path, path_len
+	p, errno := h.resolve(uint32(args[0]), uint32(args[1]), uint32(args[2]))
+	if errno != ErrnoSuccess {
+		return errno
+	}
+	return toErrno(h.files.Mkdir(p, 0777))
+}
+
+func (h *Host) pathFilestatGet(args []uint64) Errno {
+	// args: dirfd, lookup flags, path, path_len, filestat pointer
+	p, errno := h.resolve(uint32(args[0]), uint32(args[2]), uint32(args[3]))
+	if errno != ErrnoSuccess {
+		return errno
+	}
+	stat := h.files.Lstat
+	if args[1]&lookupSymlinkFollow != 0 {
+		stat = h.files.Stat
+	}
+	info, err := stat(p)
+	if err != nil {
+		return toErrno(err)
+	}
+	return h.write(uint32(args[4]), newFilestat(info))
+}
+
+func (h *Host) pathFilestatSetTimes(args []uint64) Errno {
+	// args: dirfd, lookup flags, path, path_len, atim, mtim, fst_flags
+	p, errno := h.resolve(uint32(args[0]), uint32(args[2]), uint32(args[3]))
+	if errno != ErrnoSuccess {
+		return errno
+	}
+	return h.setTimes(p, args[4], args[5], uint16(args[6]))
+}
+
+func (h *Host) pathRemoveDirectory(args []uint64) Errno {
+	// args: dirfd, path, path_len
+	p, errno := h.resolve(uint32(args[0]), uint32(args[1]), uint32(args[2]))
+	if errno != ErrnoSuccess {
+		return errno
+	}
+	return toErrno(h.files.RemoveDir(p))
+}
+
+func (h *Host) pathUnlinkFile(args []uint64) Errno {
+	// args: dirfd, path, path_len
+	p, errno := h.resolve(uint32(args[0]), uint32(args[1]), uint32(args[2]))
+	if errno != ErrnoSuccess {
+		return errno
+	}
+	return toErrno(h.files.Unlink(p))
+}
+
+func (h *Host) pathRename(args []uint64) Errno {
+	// args: old dirfd, old path, old path_len, new dirfd, new path, new path_len
+	oldPath, errno := h.resolve(uint32(args[0]), uint32(args[1]), uint32(args[2]))
+	if errno != ErrnoSuccess {
+		return errno
+	}
+	newPath, errno := h.resolve(uint32(args[3]), uint32(args[4]), uint32(args[5]))
+	if errno != ErrnoSuccess {
+		return errno
+	}
+	return toErrno(h.files.Rename(oldPath, newPath))
+}
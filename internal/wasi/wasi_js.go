@@ -0,0 +1,142 @@
+//go:build js
+// +build js
+
+package wasi
+
+import (
+	"strconv"
+	"syscall/js"
+
+	"github.com/hack-pad/hackpad/internal/common"
+	"github.com/pkg/errors"
+)
+
+var (
+	uint8Array = js.Global().Get("Uint8Array")
+
+	// exitSignal is thrown from proc_exit to unwind the module's stack back to Start
+	exitSignal = js.Global().Get("Symbol").Invoke("wasi proc_exit")
+	// wrapSyscall adapts a Go syscall into a Wasm import, throwing exitSignal when the Go func returns it.
+	// Go funcs cannot throw JS exceptions themselves, so this small shim is defined in JS.
+	// i64 args arrive as BigInts, which are passed on as decimal strings so no bits are lost.
+	wrapSyscall = js.Global().Get("Function").New("fn", "exitSignal", `
+return function(...args) {
+	const errno = fn(...args.map(arg => typeof arg === "bigint" ? BigInt.asUintN(64, arg).toString() : arg));
+	if (errno === exitSignal) {
+		throw exitSignal;
+	}
+	return errno;
+}`)
+)
+
+type jsMemory struct {
+	memory js.Value
+}
+
+func (m *jsMemory) view(offset, length uint32) (view js.Value, err error) {
+	defer common.CatchException(&err)
+	buffer := m.memory.Get("buffer") // buffer is replaced when memory grows, so always fetch the latest one
+	if uint64(offset)+uint64(length) > uint64(buffer.Get("byteLength").Int()) {
+		return js.Value{}, errors.Errorf("memory access out of bounds: %d+%d", offset, length)
+	}
+	return uint8Array.New(buffer, offset, length), nil
+}
+
+func (m *jsMemory) Size() int64 {
+	return int64(m.memory.Get("buffer").Get("byteLength").Int())
+}
+
+func (m *jsMemory) Read(offset, length uint32) ([]byte, error) {
+	view, err := m.view(offset, length)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, length)
+	js.CopyBytesToGo(data, view)
+	return data, nil
+}
+
+func (m *jsMemory) Write(offset uint32, data []byte) error {
+	view, err := m.view(offset, uint32(len(data)))
+	if err != nil {
+		return err
+	}
+	js.CopyBytesToJS(view, data)
+	return nil
+}
+
+// ImportObject returns a WebAssembly import object serving this host's syscalls.
+// Call release once the module has exited.
+func (h *Host) ImportObject() (importObject js.Value, release func()) {
+	var funcs []js.Func
+	imports := make(map[string]interface{})
+	for name, syscall := range h.Syscalls() {
+		fn := js.FuncOf(h.wrap(syscall))
+		funcs = append(funcs, fn)
+		imports[name] = wrapSyscall.Invoke(fn, exitSignal)
+	}
+	importObject = js.ValueOf(map[string]interface{}{
+		ModuleName: imports,
+	})
+	return importObject, func() {
+		for _, fn := range funcs {
+			fn.Release()
+		}
+	}
+}
+
+func (h *Host) wrap(syscall Syscall) func(js.Value, []js.Value) interface{} {
+	return func(_ js.Value, args []js.Value) interface{} {
+		uintArgs := make([]uint64, maxSyscallArgs)
+		for i, arg := range args {
+			if i < len(uintArgs) {
+				uintArgs[i] = toUint64(arg)
+			}
+		}
+		errno, exited := h.call(syscall, uintArgs)
+		if exited {
+			return exitSignal
+		}
+		return int(errno)
+	}
+}
+
+// toUint64 converts a Wasm i32 argument, or an i64 argument as a decimal string, preserving the bits of negative numbers
+func toUint64(value js.Value) uint64 {
+	if value.Type() == js.TypeString {
+		n, _ := strconv.ParseUint(value.String(), 10, 64)
+		return n
+	}
+	return uint64(int64(value.Int()))
+}
+
+// Start runs the instance's _start export to completion, returning the code passed to proc_exit if it was called
+func (h *Host) Start(instance js.Value) (exitCode int, err error) {
+	exports := instance.Get("exports")
+	h.SetMemory(&jsMemory{memory: exports.Get("memory")})
+	start := exports.Get("_start")
+	if start.Type() != js.TypeFunction {
+		return 0, errors.New("WASI module does not export a _start function")
+	}
+
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		if jsErr, ok := r.(js.Error); ok && jsErr.Value.Equal(exitSignal) {
+			exitCode, _ = h.Exited()
+			return
+		}
+		const trapExitCode = 1
+		exitCode = trapExitCode
+		switch r := r.(type) {
+		case error:
+			err = r
+		default:
+			err = errors.Errorf("%+v", r)
+		}
+	}()
+	start.Invoke()
+	return 0, nil
+}
@@ -0,0 +1,125 @@
+//go:build js
+// +build js
+
+package wasi
+
+import (
+	"syscall/js"
+
+	"github.com/pkg/errors"
+	"go.uber.org/atomic"
+)
+
+var errWorkerExited = errors.New("Worker exited")
+
+// workerCall is the Worker's reply to a memory request, along with the callback answering its next request
+type workerCall struct {
+	value    js.Value
+	callback js.Value
+}
+
+// workerMemory is the memory of a module running in a Worker. The Worker waits while the host serves one of its syscalls, so each access is a request answered by the Worker.
+type workerMemory struct {
+	size     atomic.Int64
+	callback js.Value // answers the Worker's pending call, only used by the goroutine serving it
+	calls    chan workerCall
+	done     chan struct{}
+}
+
+// request sends 'op' to the Worker and waits for its reply
+func (m *workerMemory) request(op map[string]interface{}) (js.Value, error) {
+	m.callback.Invoke(js.Null(), op)
+	select {
+	case call := <-m.calls:
+		m.callback = call.callback
+		return call.value, nil
+	case <-m.done:
+		return js.Value{}, errWorkerExited
+	}
+}
+
+func (m *workerMemory) Read(offset, length uint32) ([]byte, error) {
+	reply, err := m.request(map[string]interface{}{"op": "read", "offset": offset, "length": length})
+	if err != nil {
+		return nil, err
+	}
+	if reply.IsNull() {
+		return nil, errors.Errorf("memory access out of bounds: %d+%d", offset, length)
+	}
+	data := make([]byte, length)
+	js.CopyBytesToGo(data, reply)
+	return data, nil
+}
+
+func (m *workerMemory) Write(offset uint32, data []byte) error {
+	buf := uint8Array.New(len(data))
+	js.CopyBytesToJS(buf, data)
+	reply, err := m.request(map[string]interface{}{"op": "write", "offset": offset, "data": buf})
+	if err != nil {
+		return err
+	}
+	if !reply.Truthy() {
+		return errors.Errorf("memory access out of bounds: %d+%d", offset, len(data))
+	}
+	return nil
+}
+
+// Size returns the memory size the Worker reported with its latest syscall
+func (m *workerMemory) Size() int64 {
+	return m.size.Load()
+}
+
+// WorkerGlobal returns a "wasi" global serving the syscalls of this host's module while it runs in a Worker, and lets syscalls block.
+// The Worker calls syscall(name, args, memorySize, callback) for each import call, then answers the host's memory requests with memory(reply, callback) until the call returns.
+// Call release once the module has exited.
+func (h *Host) WorkerGlobal() (global js.Value, release func()) {
+	mem := &workerMemory{
+		calls: make(chan workerCall, 1),
+		done:  make(chan struct{}),
+	}
+	h.SetMemory(mem)
+	h.SetBlocking(true)
+	syscalls := h.Syscalls()
+
+	syscallFunc := js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+		name, callArgs, memorySize, callback := args[0].String(), args[1], args[2], args[3]
+		syscall, ok := syscalls[name]
+		if !ok {
+			syscall = notSupported
+		}
+		uintArgs := make([]uint64, maxSyscallArgs)
+		for i := 0; i < callArgs.Length() && i < len(uintArgs); i++ {
+			uintArgs[i] = toUint64(callArgs.Index(i))
+		}
+		mem.size.Store(int64(memorySize.Int()))
+		go func() {
+			mem.callback = callback
+			errno, exited := h.call(syscall, uintArgs)
+			select {
+			case <-mem.done:
+				return
+			default:
+			}
+			if exited {
+				exitCode, _ := h.Exited()
+				mem.callback.Invoke(js.Null(), map[string]interface{}{"op": "exit", "code": exitCode})
+				return
+			}
+			mem.callback.Invoke(js.Null(), map[string]interface{}{"op": "return", "errno": int(errno)})
+		}()
+		return nil
+	})
+	memoryFunc := js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+		mem.calls <- workerCall{value: args[0], callback: args[1]}
+		return nil
+	})
+	global = js.ValueOf(map[string]interface{}{
+		"syscall": syscallFunc,
+		"memory":  memoryFunc,
+	})
+	return global, func() {
+		close(mem.done)
+		syscallFunc.Release()
+		memoryFunc.Release()
+	}
+}
@@ -0,0 +1,205 @@
+// Channel carries synchronous syscalls from a process's Worker to the kernel over a SharedArrayBuffer.
+// The Worker blocks in call() with Atomics.wait, while the kernel polls for requests and responds when they complete.
+// Messages are chunked, so they may be larger than the shared buffer.
+
+const STATE_IDLE = 0;        // no transfer in progress, or the kernel is handling a request
+const STATE_TO_KERNEL = 1;   // a request chunk is ready for the kernel
+const STATE_TO_WORKER = 2;   // a response chunk is ready for the Worker
+const STATE_ACK_KERNEL = 3;  // the kernel read a request chunk and wants the next one
+const STATE_ACK_WORKER = 4;  // the Worker read a response chunk and wants the next one
+
+const HEADER_BYTES = 16; // Int32 state, chunk length, more chunks flag
+const CHUNK_BYTES = 1 << 20;
+
+function newChannelBuffer() {
+  return new SharedArrayBuffer(HEADER_BYTES + CHUNK_BYTES);
+}
+
+class Channel {
+  // signalKernel wakes the kernel, which may be waiting for a message event instead of polling
+  constructor(buffer, signalKernel) {
+    this.header = new Int32Array(buffer, 0, 3);
+    this.data = new Uint8Array(buffer, HEADER_BYTES);
+    this.signalKernel = signalKernel || (() => {});
+    this.incoming = [];
+    this.outgoing = null;
+    this.outgoingOffset = 0;
+  }
+
+  // call sends 'message' to the kernel and blocks until it responds. Worker side only.
+  call(message) {
+    const bytes = encodeMessage(message);
+    let offset = 0;
+    for (;;) {
+      const more = this.writeChunk(bytes, offset);
+      offset += this.header[1];
+      Atomics.store(this.header, 0, STATE_TO_KERNEL);
+      this.signalKernel();
+      if (!more) {
+        break;
+      }
+      this.waitFor(STATE_ACK_KERNEL);
+    }
+
+    const chunks = [];
+    for (;;) {
+      this.waitFor(STATE_TO_WORKER);
+      chunks.push(this.data.slice(0, this.header[1]));
+      if (this.header[2] === 0) {
+        Atomics.store(this.header, 0, STATE_IDLE);
+        break;
+      }
+      Atomics.store(this.header, 0, STATE_ACK_WORKER);
+      this.signalKernel();
+    }
+    return decodeMessage(concatBytes(chunks));
+  }
+
+  waitFor(state) {
+    for (let current = Atomics.load(this.header, 0); current !== state; current = Atomics.load(this.header, 0)) {
+      Atomics.wait(this.header, 0, current);
+    }
+  }
+
+  // poll advances the kernel side of a transfer. Returns a request once all of its chunks arrive. Kernel side only.
+  poll() {
+    switch (Atomics.load(this.header, 0)) {
+    case STATE_TO_KERNEL: {
+      this.incoming.push(this.data.slice(0, this.header[1]));
+      if (this.header[2] === 1) {
+        this.setState(STATE_ACK_KERNEL);
+        return undefined;
+      }
+      Atomics.store(this.header, 0, STATE_IDLE);
+      const request = decodeMessage(concatBytes(this.incoming));
+      this.incoming = [];
+      return request;
+    }
+    case STATE_ACK_WORKER:
+      this.sendNextChunk();
+      return undefined;
+    default:
+      return undefined;
+    }
+  }
+
+  // respond answers the Worker's pending call. Kernel side only.
+  respond(message) {
+    this.outgoing = encodeMessage(message);
+    this.outgoingOffset = 0;
+    this.sendNextChunk();
+  }
+
+  sendNextChunk() {
+    const more = this.writeChunk(this.outgoing, this.outgoingOffset);
+    this.outgoingOffset += this.header[1];
+    if (!more) {
+      this.outgoing = null;
+    }
+    this.setState(STATE_TO_WORKER);
+  }
+
+  writeChunk(bytes, offset) {
+    const chunk = bytes.subarray(offset, offset + CHUNK_BYTES);
+    this.data.set(chunk);
+    this.header[1] = chunk.length;
+    const more = offset + chunk.length < bytes.length;
+    this.header[2] = more ? 1 : 0;
+    return more;
+  }
+
+  setState(state) {
+    Atomics.store(this.header, 0, state);
+    Atomics.notify(this.header, 0);
+  }
+}
+
+// encodeMessage serializes JSON-like values, Uint8Arrays, and undefined. Functions are dropped.
+function encodeMessage(value) {
+  const buffers = [];
+  const json = JSON.stringify(value, function (key, v) {
+    const raw = this[key];
+    if (raw instanceof Uint8Array) {
+      buffers.push(raw);
+      return { $bytes: buffers.length - 1 };
+    }
+    if (raw === undefined) {
+      return { $undefined: true };
+    }
+    if (typeof raw === "bigint") {
+      return { $bigint: raw.toString() };
+    }
+    return v;
+  });
+  const jsonBytes = new TextEncoder().encode(json);
+  let size = 4 + jsonBytes.length + 4;
+  for (const buffer of buffers) {
+    size += 4 + buffer.length;
+  }
+  const bytes = new Uint8Array(size);
+  const view = new DataView(bytes.buffer);
+  let offset = 0;
+  view.setUint32(offset, jsonBytes.length, true);
+  offset += 4;
+  bytes.set(jsonBytes, offset);
+  offset += jsonBytes.length;
+  view.setUint32(offset, buffers.length, true);
+  offset += 4;
+  for (const buffer of buffers) {
+    view.setUint32(offset, buffer.length, true);
+    offset += 4;
+    bytes.set(buffer, offset);
+    offset += buffer.length;
+  }
+  return bytes;
+}
+
+function decodeMessage(bytes) {
+  const view = new DataView(bytes.buffer, bytes.byteOffset, bytes.byteLength);
+  let offset = 0;
+  const jsonLength = view.getUint32(offset, true);
+  offset += 4;
+  const json = new TextDecoder().decode(bytes.subarray(offset, offset + jsonLength));
+  offset += jsonLength;
+  const count = view.getUint32(offset, true);
+  offset += 4;
+  const buffers = [];
+  for (let i = 0; i < count; i++) {
+    const length = view.getUint32(offset, true);
+    offset += 4;
+    buffers.push(bytes.slice(offset, offset + length));
+    offset += length;
+  }
+  return JSON.parse(json, (key, value) => {
+    if (value === null || typeof value !== "object") {
+      return value;
+    }
+    if ("$bytes" in value) {
+      return buffers[value.$bytes];
+    }
+    if ("$undefined" in value) {
+      return undefined;
+    }
+    if ("$bigint" in value) {
+      return BigInt(value.$bigint);
+    }
+    return value;
+  });
+}
+
+function concatBytes(chunks) {
+  if (chunks.length === 1) {
+    return chunks[0];
+  }
+  let size = 0;
+  for (const chunk of chunks) {
+    size += chunk.length;
+  }
+  const bytes = new Uint8Array(size);
+  let offset = 0;
+  for (const chunk of chunks) {
+    bytes.set(chunk, offset);
+    offset += chunk.length;
+  }
+  return bytes;
+}
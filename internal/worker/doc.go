@@ -0,0 +1,3 @@
+// Package worker runs Go js/wasm process images in Web Workers, or Node.js worker_threads.
+// A Worker's syscalls are proxied to the kernel over a SharedArrayBuffer, so they still run against the kernel's file descriptors.
+package worker
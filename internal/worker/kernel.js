@@ -0,0 +1,280 @@
+// The kernel's side of running processes in Workers: a pool of idle Worker threads, and the syscall server for each running process.
+
+const isNode = typeof require === "function" && typeof process === "object" && process.versions && process.versions.node;
+
+// supported returns an error message if Workers can't share memory with the kernel, or null if they can
+function supported() {
+  if (typeof SharedArrayBuffer === "undefined" || typeof Atomics === "undefined") {
+    return "SharedArrayBuffer is unavailable";
+  }
+  if (!isNode && !globalThis.crossOriginIsolated) {
+    return "the page is not cross-origin isolated. Serve it with the headers 'Cross-Origin-Opener-Policy: same-origin' and 'Cross-Origin-Embedder-Policy: require-corp'";
+  }
+  if (!wasmExecLocation()) {
+    return "wasm_exec.js location is unknown, configure it with hackpad.startWorkers({wasmExec})";
+  }
+  return null;
+}
+
+let wasmExec = null;
+let poolSize = 2;
+const idle = [];
+let starting = 0;
+const running = new Set();
+
+// doorbell counts signals from every Worker's channel, so a blocked kernel can sleep until a syscall arrives instead of polling
+const doorbell = typeof SharedArrayBuffer === "undefined" ? null : new Int32Array(new SharedArrayBuffer(4));
+// sleepCell is waited on but never notified, so sleep always waits out its timeout
+const sleepCell = typeof SharedArrayBuffer === "undefined" ? null : new Int32Array(new SharedArrayBuffer(4));
+let doorbellSeen = 0;
+let canWaitBlocked = true;
+
+function wasmExecLocation() {
+  if (wasmExec) {
+    return wasmExec;
+  }
+  if (typeof document !== "undefined") {
+    const script = document.querySelector('script[src*="wasm_exec"]');
+    if (script) {
+      return script.src;
+    }
+  }
+  return null;
+}
+
+// configure sets the Worker pool's options, then fills the pool
+function configure(options) {
+  if (options.wasmExec) {
+    wasmExec = isNode ? options.wasmExec : new URL(options.wasmExec, location.href).href;
+  }
+  if (typeof options.poolSize === "number") {
+    poolSize = options.poolSize;
+  }
+  fillPool();
+}
+
+// fillPool starts idle Workers in the background. Browsers only start Workers while the main thread is idle,
+// so a blocked kernel can only use Workers started ahead of time.
+function fillPool() {
+  if (supported() !== null) {
+    return;
+  }
+  while (idle.length + starting < poolSize) {
+    starting++;
+    newThread(thread => {
+      starting--;
+      idle.push(thread);
+    });
+  }
+}
+
+function newThread(onReady) {
+  const thread = {
+    onMessage: () => {},
+    onError: () => {},
+    ready: false,
+    ref: () => {},
+  };
+  const handleMessage = message => {
+    if (message.type === "ready" && !thread.ready) {
+      thread.ready = true;
+      onReady(thread);
+      return;
+    }
+    thread.onMessage(message);
+  };
+  if (isNode) {
+    const { Worker } = require("worker_threads");
+    const worker = new Worker(threadSource, { eval: true });
+    worker.on("message", handleMessage);
+    worker.on("error", error => thread.onError(error));
+    worker.unref(); // idle Workers shouldn't keep Node.js running
+    thread.ref = () => worker.ref();
+    thread.post = message => worker.postMessage(message);
+    thread.terminate = () => worker.terminate();
+  } else {
+    const url = URL.createObjectURL(new Blob([threadSource], { type: "text/javascript" }));
+    const worker = new Worker(url);
+    URL.revokeObjectURL(url);
+    worker.onmessage = event => handleMessage(event.data);
+    worker.onerror = event => thread.onError(event.message);
+    thread.post = message => worker.postMessage(message);
+    thread.terminate = () => worker.terminate();
+  }
+  thread.post({ type: "init", wasmExec: wasmExecLocation() });
+  return thread;
+}
+
+// takeThread returns an idle Worker, or a new one if 'canWait' and the pool is empty.
+// A Worker started on demand runs once the main thread is idle, so a blocked kernel must not wait for one.
+function takeThread(canWait, onThread) {
+  const thread = idle.shift();
+  fillPool();
+  if (thread) {
+    onThread(thread);
+    return true;
+  }
+  if (!canWait) {
+    return false;
+  }
+  newThread(onThread).ref();
+  return true;
+}
+
+// spawn runs a process image in a Worker. 'globals' are the kernel's fs, process, and child_process objects for the process.
+// If 'wasi' is set, the image is a WASI module importing wasi.syscalls from wasi.module, served by the "wasi" global.
+// Calls onExit(code, error) when the image exits. Returns a handle to terminate it, or null if no Worker is available.
+function spawn({ image, wasi, argv, env, globals, canWait, onExit }) {
+  const server = {
+    channel: new Channel(newChannelBuffer()),
+    globals,
+    onExit,
+    thread: null,
+    exited: false,
+  };
+  const ok = takeThread(canWait, thread => {
+    if (server.exited) {
+      thread.terminate();
+      return;
+    }
+    server.thread = thread;
+    thread.ref();
+    thread.onMessage = message => {
+      if (message.type === "syscall") {
+        serve(server);
+      }
+    };
+    thread.onError = error => {
+      if (!server.exited) {
+        terminate(server);
+        server.onExit(1, String(error && error.stack || error));
+      }
+    };
+    running.add(server);
+    thread.post({
+      type: "start",
+      buffer: server.channel.header.buffer,
+      doorbell: doorbell.buffer,
+      image,
+      wasi: wasi || null,
+      argv,
+      env,
+      globals: describeGlobals(globals),
+    });
+  });
+  if (!ok) {
+    return null;
+  }
+  return {
+    terminate: () => terminate(server),
+  };
+}
+
+function terminate(server) {
+  server.exited = true;
+  running.delete(server);
+  if (server.thread) {
+    server.thread.terminate();
+  }
+}
+
+// describeGlobals lists the functions and plain properties of each global, so the Worker can build proxies for them
+function describeGlobals(globals) {
+  const descriptions = {};
+  for (const [name, value] of Object.entries(globals)) {
+    const funcs = [];
+    const props = {};
+    for (const key in value) {
+      const prop = value[key];
+      if (typeof prop === "function") {
+        funcs.push(key);
+      } else if (prop === null || ["string", "number", "boolean"].includes(typeof prop)) {
+        props[key] = prop;
+      } else if (key === "constants") {
+        props[key] = Object.assign({}, prop);
+      }
+    }
+    descriptions[name] = { funcs, props };
+  }
+  return descriptions;
+}
+
+// readMethods fill in their buffer args, so the Worker needs a copy of the result
+const readMethods = new Set(["read", "readSync"]);
+
+// serve handles a pending syscall from a process's Worker, if any
+function serve(server) {
+  const request = server.channel.poll();
+  if (request === undefined || server.exited) {
+    return;
+  }
+  if ("exit" in request) {
+    server.channel.respond({});
+    terminate(server);
+    server.onExit(request.exit, request.error || null);
+    return;
+  }
+
+  const { target, method, args } = request;
+  const object = server.globals[target];
+  const fn = object && object[method];
+  if (typeof fn !== "function") {
+    server.channel.respond({ error: { message: `${target}.${method} is not implemented`, code: "ENOSYS" } });
+    return;
+  }
+  const buffers = () => readMethods.has(method) ? args.map(arg => arg instanceof Uint8Array ? arg : null) : undefined;
+  try {
+    if (request.callback) {
+      fn.call(object, ...args, (error, ...results) => {
+        if (!server.exited) {
+          server.channel.respond({ error, results, buffers: buffers() });
+        }
+      });
+    } else {
+      const result = fn.apply(object, args);
+      server.channel.respond({ result, buffers: buffers() });
+    }
+  } catch (error) {
+    server.channel.respond({ error: { message: String(error && error.message || error), code: error && error.code || "EIO" } });
+  }
+}
+
+// serveAll polls every running Worker. A blocked kernel calls this in a loop, since it can't receive message events.
+// Returns true if any Worker signaled since the last call.
+function serveAll() {
+  const rung = doorbell === null ? 0 : Atomics.load(doorbell, 0);
+  const signaled = rung !== doorbellSeen;
+  doorbellSeen = rung;
+  for (const server of running) {
+    serve(server);
+  }
+  return signaled;
+}
+
+// waitBlocked sleeps until a Worker signals after the last serveAll, or until 'timeoutMillis' passes.
+// Browsers don't allow Atomics.wait on the main thread, so there it returns immediately and the kernel keeps polling.
+function waitBlocked(timeoutMillis) {
+  if (doorbell === null || !canWaitBlocked) {
+    return;
+  }
+  try {
+    Atomics.wait(doorbell, 0, doorbellSeen, timeoutMillis);
+  } catch (error) {
+    canWaitBlocked = false;
+  }
+}
+
+// sleep pauses the main thread for 'timeoutMillis' without returning to the event loop.
+// Returns false without waiting where the main thread can't sleep, like in browsers.
+function sleep(timeoutMillis) {
+  if (sleepCell === null || !canWaitBlocked) {
+    return false;
+  }
+  try {
+    Atomics.wait(sleepCell, 0, 0, timeoutMillis);
+    return true;
+  } catch (error) {
+    canWaitBlocked = false;
+    return false;
+  }
+}
@@ -0,0 +1,20 @@
+// Command echo prints its args, env, and stdin, then exits with code 3
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+func main() {
+	fmt.Println("args:", os.Args[1:])
+	fmt.Println("env:", os.Getenv("GREETING"))
+	input, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("stdin: %q\n", input)
+	os.Exit(3)
+}
@@ -0,0 +1,103 @@
+// Runs a Go js/wasm or WASI image with the Worker kernel in Node.js worker_threads, then prints the results as JSON.
+// Usage: node harness.js <worker dir> <wasm_exec.js> <image.wasm> <async|blocked> [wasi]
+const fs = require("fs");
+const path = require("path");
+
+const [workerDir, wasmExec, imagePath, mode, kind] = process.argv.slice(2);
+const source = name => fs.readFileSync(path.join(workerDir, name), "utf8");
+// mirrors newKernel in worker.go
+const kernel = new Function("require", source("channel.js") +
+  "\nconst threadSource = " + JSON.stringify(source("channel.js") + source("thread.js")) + ";\n" +
+  source("kernel.js") + "\nreturn { supported, configure, fillPool, spawn, serveAll, waitBlocked, sleep };")(require);
+
+// kernel-side globals, standing in for a process's file descriptors
+const stdin = Buffer.from("line 1\nline 2\n");
+let stdinOffset = 0;
+const output = { 1: "", 2: "" };
+const processFS = Object.create(fs);
+processFS.read = (fd, buffer, offset, length, position, callback) => {
+  if (fd !== 0) {
+    return fs.read(fd, buffer, offset, length, position, callback);
+  }
+  const n = Math.min(length, stdin.length - stdinOffset);
+  buffer.set(stdin.subarray(stdinOffset, stdinOffset + n), offset);
+  stdinOffset += n;
+  callback(null, n);
+};
+processFS.write = (fd, buffer, offset, length, position, callback) => {
+  if (fd !== 1 && fd !== 2) {
+    return fs.write(fd, buffer, offset, length, position, callback);
+  }
+  output[fd] += Buffer.from(buffer.subarray(offset, offset + length)).toString();
+  callback(null, length);
+};
+
+// a kernel-side "wasi" global, standing in for the Go WASI host. Each syscall is a generator yielding memory requests.
+const wasiSyscalls = {
+  fd_write: function* (fd, iovs, iovsLen, nwrittenPtr) {
+    const iovec = yield { op: "read", offset: iovs, length: 8 };
+    const view = new DataView(iovec.buffer, iovec.byteOffset, iovec.byteLength);
+    const data = yield { op: "read", offset: view.getUint32(0, true), length: view.getUint32(4, true) };
+    output[fd] += Buffer.from(data).toString();
+    yield { op: "write", offset: nwrittenPtr, data: new Uint8Array(new Uint32Array([data.length]).buffer) };
+    return { op: "return", errno: 0 };
+  },
+  proc_exit: function* (code) {
+    return { op: "exit", code };
+  },
+};
+let pendingSyscall = null;
+const stepSyscall = (reply, callback) => callback(null, pendingSyscall.next(reply).value);
+const wasiGlobal = {
+  syscall: (name, args, memorySize, callback) => {
+    pendingSyscall = wasiSyscalls[name](...args);
+    stepSyscall(undefined, callback);
+  },
+  memory: stepSyscall,
+};
+
+kernel.configure({ wasmExec, poolSize: 1 });
+const image = new Uint8Array(fs.readFileSync(imagePath));
+
+function run() {
+  let result = null;
+  const thread = kernel.spawn({
+    image,
+    argv: ["echo", "a", "b"],
+    env: { GREETING: "hello" },
+    wasi: kind === "wasi" ? { module: "wasi_snapshot_preview1", syscalls: Object.keys(wasiSyscalls) } : null,
+    globals: kind === "wasi" ? { wasi: wasiGlobal } : { fs: processFS, process: Object.create(process) },
+    canWait: mode === "async",
+    onExit: (code, error) => {
+      result = { code, error, stdout: output[1], stderr: output[2] };
+      if (mode === "async") {
+        console.log(JSON.stringify(result));
+      }
+    },
+  });
+  if (thread === null) {
+    console.log(JSON.stringify({ error: "no idle Worker" }));
+    return;
+  }
+  if (mode === "blocked") {
+    // like spawnSync, never return to the event loop until the image exits
+    const deadline = Date.now() + 30000;
+    while (result === null && Date.now() < deadline) {
+      if (!kernel.serveAll()) {
+        kernel.waitBlocked(10);
+      }
+    }
+    // Node.js lets the main thread sleep, unlike browsers
+    const sleepStart = Date.now();
+    const slept = kernel.sleep(20) && Date.now() - sleepStart >= 20;
+    console.log(JSON.stringify(Object.assign({ slept }, result || { error: "timed out" })));
+  }
+}
+
+if (mode === "blocked") {
+  // a blocked kernel can only use Workers started ahead of time
+  const waitForPool = () => setTimeout(run, 500);
+  waitForPool();
+} else {
+  run();
+}
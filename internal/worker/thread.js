@@ -0,0 +1,174 @@
+// Runs inside a Worker: loads wasm_exec.js, then runs one Go js/wasm or WASI process image.
+// The process's fs, process, and child_process globals are proxies, so every syscall runs in the kernel.
+
+const isNode = typeof require === "function" && typeof process === "object" && process.versions && process.versions.node;
+const parentPort = isNode ? require("worker_threads").parentPort : null;
+
+function postToKernel(message) {
+  if (parentPort) {
+    parentPort.postMessage(message);
+  } else {
+    self.postMessage(message);
+  }
+}
+
+function onKernelMessage(handler) {
+  if (parentPort) {
+    parentPort.on("message", handler);
+  } else {
+    self.onmessage = event => handler(event.data);
+  }
+}
+
+let channel;
+
+// newChannel connects to the kernel. Each signal also rings the kernel's doorbell, which wakes a blocked kernel sleeping in waitBlocked.
+function newChannel(buffer, doorbellBuffer) {
+  const doorbell = new Int32Array(doorbellBuffer);
+  return new Channel(buffer, () => {
+    Atomics.add(doorbell, 0, 1);
+    Atomics.notify(doorbell, 0);
+    postToKernel({ type: "syscall" });
+  });
+}
+
+// syscall forwards a call on a global, like fs.read(...), to the kernel. A trailing function arg is a Node.js-style callback.
+function syscall(target, method, args) {
+  let callback;
+  if (typeof args[args.length - 1] === "function") {
+    callback = args.pop();
+  }
+  const response = channel.call({ target, method, args, callback: !!callback });
+  if (response.buffers) {
+    // copy back buffers the kernel filled in, i.e. reads into Wasm memory
+    response.buffers.forEach((bytes, i) => {
+      if (bytes) {
+        args[i].set(bytes);
+      }
+    });
+  }
+  if (callback) {
+    queueMicrotask(() => callback(response.error || null, ...(response.results || [])));
+    return undefined;
+  }
+  if (response.error) {
+    throw response.error;
+  }
+  return response.result;
+}
+
+function installGlobals(descriptions) {
+  for (const [name, description] of Object.entries(descriptions)) {
+    const value = name === "process" && typeof process === "object" ? Object.create(process) : {};
+    for (const [key, prop] of Object.entries(description.props)) {
+      // defined rather than assigned, since Node.js's process has read-only properties
+      Object.defineProperty(value, key, { value: prop, writable: true, enumerable: true, configurable: true });
+    }
+    for (const method of description.funcs) {
+      value[method] = (...args) => syscall(name, method, args);
+    }
+    globalThis[name] = value;
+  }
+}
+
+async function start({ buffer, doorbell, image, argv, env, globals }) {
+  channel = newChannel(buffer, doorbell);
+  installGlobals(globals);
+  const go = new Go();
+  go.argv = argv;
+  go.env = env;
+  let exitCode = 0;
+  go.exit = code => {
+    exitCode = code;
+  };
+  try {
+    const result = await WebAssembly.instantiate(image, go.importObject);
+    await go.run(result instanceof WebAssembly.Instance ? result : result.instance);
+    channel.call({ exit: exitCode });
+  } catch (error) {
+    channel.call({ exit: 1, error: String(error && error.stack || error) });
+  }
+}
+
+// startWASI runs a WASI module. Each import call is forwarded to the kernel's "wasi" global with the module's memory size.
+// While the kernel serves it, the import answers the kernel's requests to read and write the module's memory, until the kernel returns an errno or exits.
+async function startWASI({ buffer, doorbell, image, wasi }) {
+  channel = newChannel(buffer, doorbell);
+  const exitSignal = Symbol("wasi proc_exit");
+  let memory = null;
+  let exitCode = 0;
+  const call = (method, args) => {
+    const response = channel.call({ target: "wasi", method, args, callback: true });
+    if (response.error) {
+      throw new Error(response.error.message || String(response.error));
+    }
+    return response.results[0];
+  };
+  const inBounds = (offset, length) => memory && offset + length <= memory.buffer.byteLength;
+
+  const imports = {};
+  for (const name of wasi.syscalls) {
+    imports[name] = (...args) => {
+      // i64 args are BigInts, sent as decimal strings so no bits are lost
+      const callArgs = args.map(arg => typeof arg === "bigint" ? BigInt.asUintN(64, arg).toString() : arg);
+      let op = call("syscall", [name, callArgs, memory ? memory.buffer.byteLength : 0]);
+      for (;;) {
+        switch (op.op) {
+        case "read":
+          op = call("memory", [inBounds(op.offset, op.length) ? new Uint8Array(memory.buffer, op.offset, op.length).slice() : null]);
+          break;
+        case "write": {
+          const ok = inBounds(op.offset, op.data.length);
+          if (ok) {
+            new Uint8Array(memory.buffer).set(op.data, op.offset);
+          }
+          op = call("memory", [ok]);
+          break;
+        }
+        case "exit":
+          exitCode = op.code;
+          throw exitSignal;
+        default:
+          return op.errno;
+        }
+      }
+    };
+  }
+
+  try {
+    const result = await WebAssembly.instantiate(image, { [wasi.module]: imports });
+    const instance = result instanceof WebAssembly.Instance ? result : result.instance;
+    memory = instance.exports.memory;
+    if (typeof instance.exports._start !== "function") {
+      throw new Error("WASI module does not export a _start function");
+    }
+    instance.exports._start();
+    channel.call({ exit: 0 });
+  } catch (error) {
+    if (error === exitSignal) {
+      channel.call({ exit: exitCode });
+    } else {
+      channel.call({ exit: 1, error: String(error && error.stack || error) });
+    }
+  }
+}
+
+onKernelMessage(message => {
+  switch (message.type) {
+  case "init":
+    if (isNode) {
+      require(message.wasmExec);
+    } else {
+      importScripts(message.wasmExec);
+    }
+    postToKernel({ type: "ready" });
+    break;
+  case "start":
+    if (message.wasi) {
+      startWASI(message);
+    } else {
+      start(message);
+    }
+    break;
+  }
+});
@@ -0,0 +1,182 @@
+//go:build js
+// +build js
+
+package worker
+
+import (
+	_ "embed"
+	"encoding/json"
+	"sync"
+	"syscall/js"
+	"time"
+
+	"github.com/hack-pad/hackpad/internal/interop"
+	"github.com/pkg/errors"
+	"go.uber.org/atomic"
+)
+
+var (
+	//go:embed channel.js
+	channelSource string
+	//go:embed thread.js
+	threadSource string
+	//go:embed kernel.js
+	kernelSource string
+
+	kernel   = newKernel()
+	blockers = atomic.NewInt64(0)
+)
+
+func newKernel() js.Value {
+	threadSourceJSON, err := json.Marshal(channelSource + threadSource)
+	if err != nil {
+		panic(err)
+	}
+	source := channelSource + "\nconst threadSource = " + string(threadSourceJSON) + ";\n" + kernelSource + `
+return { supported, configure, fillPool, spawn, serveAll, waitBlocked, sleep };`
+	return js.Global().Get("Function").New(source).Invoke()
+}
+
+// ErrUnsupported is returned when Workers can't share memory with the kernel
+var ErrUnsupported = interop.NewError("Workers are unsupported", "ENOTSUP")
+
+// ErrNoIdleWorker is returned when a blocked kernel needs a Worker, but none were started ahead of time
+var ErrNoIdleWorker = interop.NewError("no idle Worker available: browsers only start Workers while the main thread is idle, so increase the Worker pool size", "EAGAIN")
+
+// Supported returns an error if this environment can't run processes in Workers
+func Supported() error {
+	if reason := kernel.Call("supported"); reason.Truthy() {
+		return errors.Wrap(ErrUnsupported, reason.String())
+	}
+	return nil
+}
+
+// Options configure the Worker pool
+type Options struct {
+	// WasmExec is the URL, or path in Node.js, of the wasm_exec.js each Worker loads. Detected from the page's script tags by default.
+	WasmExec string
+	// PoolSize is the number of idle Workers to keep started
+	PoolSize int
+}
+
+// Configure sets pool options, then starts idle Workers in the background
+func Configure(options Options) {
+	jsOptions := map[string]interface{}{}
+	if options.WasmExec != "" {
+		jsOptions["wasmExec"] = options.WasmExec
+	}
+	if options.PoolSize > 0 {
+		jsOptions["poolSize"] = options.PoolSize
+	}
+	kernel.Call("configure", jsOptions)
+}
+
+// FillPool starts idle Workers in the background, if Workers are supported
+func FillPool() {
+	kernel.Call("fillPool")
+}
+
+// Image is a process image to run in a Worker
+type Image struct {
+	Module  js.Value // compiled WebAssembly.Module or the module's bytes
+	Args    []string
+	Env     map[string]string
+	Globals map[string]js.Value // the process's fs, process, and child_process objects, which serve the Worker's syscalls
+	// WASIModule and WASISyscalls describe a WASI module's imports. If set, the image is a WASI module rather than Go js/wasm, and the "wasi" global serves its syscalls.
+	WASIModule   string
+	WASISyscalls []string
+}
+
+// Thread is a process image running in a Worker
+type Thread struct {
+	handle      js.Value
+	onExit      js.Func
+	releaseOnce sync.Once
+}
+
+// Block marks the kernel as unable to return to the event loop, i.e. during spawnSync, until unblock is called.
+// While blocked, Workers only start from the idle pool, and the blocker must call ServeBlocked to handle their syscalls.
+func Block() (unblock func()) {
+	blockers.Inc()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			blockers.Dec()
+		})
+	}
+}
+
+// Blocked returns true if the kernel can't return to the event loop
+func Blocked() bool {
+	return blockers.Load() > 0
+}
+
+// Start runs 'image' in a Worker, calling exited once the image exits
+func Start(image Image, exited func(exitCode int, err error)) (*Thread, error) {
+	if err := Supported(); err != nil {
+		return nil, err
+	}
+	globals := make(map[string]interface{}, len(image.Globals))
+	for name, value := range image.Globals {
+		globals[name] = value
+	}
+	t := &Thread{}
+	t.onExit = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		t.release()
+		var err error
+		if args[1].Truthy() {
+			err = errors.New(args[1].String())
+		}
+		go exited(args[0].Int(), err)
+		return nil
+	})
+	var wasi interface{}
+	if image.WASIModule != "" {
+		wasi = map[string]interface{}{
+			"module":   image.WASIModule,
+			"syscalls": interop.SliceFromStrings(image.WASISyscalls),
+		}
+	}
+	t.handle = kernel.Call("spawn", map[string]interface{}{
+		"image":   image.Module,
+		"wasi":    wasi,
+		"argv":    interop.SliceFromStrings(image.Args),
+		"env":     interop.StringMap(image.Env),
+		"globals": globals,
+		"canWait": !Blocked(),
+		"onExit":  t.onExit,
+	})
+	if t.handle.IsNull() {
+		t.release()
+		return nil, ErrNoIdleWorker
+	}
+	return t, nil
+}
+
+func (t *Thread) release() {
+	t.releaseOnce.Do(t.onExit.Release)
+}
+
+// Terminate stops the Worker. The exited callback is not called.
+func (t *Thread) Terminate() {
+	t.handle.Call("terminate")
+	t.release()
+}
+
+// ServeBlocked handles pending syscalls from all running Workers, returning true if any Worker signaled since the last call.
+// A kernel blocked on a Worker calls this in a loop, since it can't receive the message events that normally wake it.
+func ServeBlocked() bool {
+	return kernel.Call("serveAll").Bool()
+}
+
+// WaitBlocked sleeps until a Worker signals after the last ServeBlocked, or until 'timeout' passes.
+// Where the main thread can't sleep, like in browsers, it returns immediately.
+func WaitBlocked(timeout time.Duration) {
+	kernel.Call("waitBlocked", float64(timeout)/float64(time.Millisecond))
+}
+
+// Sleep pauses the main thread for 'd' without returning to the event loop, i.e. in a synchronous syscall.
+// Returns false without waiting where the main thread can't sleep, like in browsers.
+func Sleep(d time.Duration) bool {
+	return kernel.Call("sleep", float64(d)/float64(time.Millisecond)).Bool()
+}
@@ -0,0 +1,175 @@
+//go:build !js
+// +build !js
+
+package worker
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func wasmExecPath() string {
+	wasmExec := filepath.Join(runtime.GOROOT(), "lib", "wasm", "wasm_exec.js")
+	if _, err := os.Stat(wasmExec); err != nil {
+		wasmExec = filepath.Join(runtime.GOROOT(), "misc", "wasm", "wasm_exec.js")
+	}
+	return wasmExec
+}
+
+// TestWorkerThreads runs a Go js/wasm image with the Worker kernel in Node.js worker_threads
+func TestWorkerThreads(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is not installed")
+	}
+	wasmExec := wasmExecPath()
+	image := filepath.Join(t.TempDir(), "echo.wasm")
+	build := exec.Command("go", "build", "-o", image, "./testdata/echo")
+	build.Env = append(os.Environ(), "GOOS=js", "GOARCH=wasm")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatal(err, string(out))
+	}
+	workerDir, err := filepath.Abs(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mode := range []string{"async", "blocked"} {
+		mode := mode
+		t.Run(mode, func(t *testing.T) {
+			t.Parallel()
+			cmd := exec.Command(node, "testdata/harness.js", workerDir, wasmExec, image, mode)
+			cmd.Stderr = os.Stderr
+			out, err := cmd.Output()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var result struct {
+				Code   int
+				Error  string
+				Stdout string
+				Stderr string
+				Slept  bool
+			}
+			if err := json.Unmarshal(out, &result); err != nil {
+				t.Fatal(err, string(out))
+			}
+			if result.Error != "" {
+				t.Fatal("Unexpected error: ", result.Error)
+			}
+			if mode == "blocked" && !result.Slept {
+				t.Error("Expected a blocked kernel to sleep in Node.js")
+			}
+			if result.Code != 3 {
+				t.Errorf("Expected exit code 3, got %d", result.Code)
+			}
+			const expectStdout = "args: [a b]\nenv: hello\nstdin: \"line 1\\nline 2\\n\"\n"
+			if result.Stdout != expectStdout {
+				t.Errorf("Expected stdout %q, got %q", expectStdout, result.Stdout)
+			}
+			if result.Stderr != "" {
+				t.Errorf("Unexpected stderr: %q", result.Stderr)
+			}
+		})
+	}
+}
+
+// wasiModule is a WASI module which writes "hi\n" to stdout with fd_write, then calls proc_exit(3)
+func wasiModule() []byte {
+	section := func(id byte, contents ...byte) []byte {
+		return append([]byte{id, byte(len(contents))}, contents...)
+	}
+	name := func(s string) []byte {
+		return append([]byte{byte(len(s))}, s...)
+	}
+	concat := func(parts ...[]byte) []byte {
+		var b []byte
+		for _, part := range parts {
+			b = append(b, part...)
+		}
+		return b
+	}
+	const (
+		i32      = 0x7f
+		i32Const = 0x41
+		call     = 0x10
+		drop     = 0x1a
+		end      = 0x0b
+	)
+	code := []byte{0x00, // no locals
+		i32Const, 1, i32Const, 16, i32Const, 1, i32Const, 24, call, 0, drop, // fd_write(stdout, iovec at 16, 1 iovec, nwritten at 24)
+		i32Const, 3, call, 1, // proc_exit(3)
+		end,
+	}
+	data := concat([]byte("hi\n"), make([]byte, 13), []byte{0, 0, 0, 0, 3, 0, 0, 0}) // "hi\n", then its iovec at 16
+	return concat(
+		[]byte("\x00asm\x01\x00\x00\x00"),
+		section(1, 3, // types
+			0x60, 4, i32, i32, i32, i32, 1, i32, // fd_write
+			0x60, 1, i32, 0, // proc_exit
+			0x60, 0, 0, // _start
+		),
+		section(2, concat([]byte{2},
+			name("wasi_snapshot_preview1"), name("fd_write"), []byte{0x00, 0},
+			name("wasi_snapshot_preview1"), name("proc_exit"), []byte{0x00, 1},
+		)...),
+		section(3, 1, 2),       // functions
+		section(5, 1, 0x00, 1), // memory of 1 page
+		section(7, concat([]byte{2}, name("_start"), []byte{0x00, 2}, name("memory"), []byte{0x02, 0})...),
+		section(10, concat([]byte{1, byte(len(code))}, code)...),
+		section(11, concat([]byte{1, 0x00, i32Const, 0, end, byte(len(data))}, data)...),
+	)
+}
+
+// TestWorkerThreadsWASI runs a WASI module with the Worker kernel, serving its syscalls and memory requests from the kernel
+func TestWorkerThreadsWASI(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is not installed")
+	}
+	image := filepath.Join(t.TempDir(), "hi.wasm")
+	if err := os.WriteFile(image, wasiModule(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	workerDir, err := filepath.Abs(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mode := range []string{"async", "blocked"} {
+		mode := mode
+		t.Run(mode, func(t *testing.T) {
+			t.Parallel()
+			// the thread loads wasm_exec.js before any image, but WASI images don't use it
+			cmd := exec.Command(node, "testdata/harness.js", workerDir, wasmExecPath(), image, mode, "wasi")
+			cmd.Stderr = os.Stderr
+			out, err := cmd.Output()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var result struct {
+				Code   int
+				Error  string
+				Stdout string
+			}
+			if err := json.Unmarshal(out, &result); err != nil {
+				t.Fatal(err, string(out))
+			}
+			if result.Error != "" {
+				t.Fatal("Unexpected error: ", result.Error)
+			}
+			if result.Code != 3 {
+				t.Errorf("Expected exit code 3, got %d", result.Code)
+			}
+			if result.Stdout != "hi\n" {
+				t.Errorf("Expected stdout %q, got %q", "hi\n", result.Stdout)
+			}
+		})
+	}
+}
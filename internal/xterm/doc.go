@@ -0,0 +1,2 @@
+// Package xterm writes output to xterm.js terminals
+package xterm
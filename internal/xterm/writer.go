@@ -0,0 +1,110 @@
+//go:build js
+// +build js
+
+package xterm
+
+import (
+	"os"
+	"sync"
+	"syscall/js"
+
+	"github.com/hack-pad/hackpad/internal/common"
+	"github.com/hack-pad/hackpad/internal/interop"
+)
+
+const (
+	// highWaterMark is the most output buffered or queued in xterm before writers block
+	highWaterMark = 256 << 10 // 256KiB
+	// flushInterval is the fallback delay between flushes where requestAnimationFrame is unavailable, like in Workers
+	flushInterval = 16 // milliseconds
+)
+
+// Writer batches writes to an xterm.js Terminal. Output is coalesced and written once per animation frame, split only on UTF-8 sequence boundaries.
+// Write blocks while xterm has too much output queued to render, so fast writers are slowed to the terminal's pace.
+type Writer struct {
+	term js.Value
+
+	mu        sync.Mutex
+	drained   *sync.Cond // broadcast when queued output shrinks
+	pending   []byte     // output not yet written to xterm
+	queued    int        // bytes written to xterm it hasn't processed yet
+	scheduled bool
+	closed    bool
+}
+
+// NewWriter returns a Writer for xterm.js Terminal 'term'. Close it to flush remaining output.
+func NewWriter(term js.Value) *Writer {
+	w := &Writer{term: term}
+	w.drained = sync.NewCond(&w.mu)
+	return w
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for !w.closed && len(w.pending)+w.queued >= highWaterMark {
+		w.drained.Wait()
+	}
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	w.pending = append(w.pending, p...)
+	w.schedule()
+	return len(p), nil
+}
+
+// schedule arranges a flush on the next animation frame. Must be called with w.mu held.
+func (w *Writer) schedule() {
+	if w.scheduled {
+		return
+	}
+	w.scheduled = true
+	flush := interop.SingleUseFunc(func(js.Value, []js.Value) interface{} {
+		w.flush(false)
+		return nil
+	})
+	if js.Global().Get("requestAnimationFrame").Type() == js.TypeFunction {
+		js.Global().Call("requestAnimationFrame", flush)
+	} else {
+		js.Global().Call("setTimeout", flush, flushInterval)
+	}
+}
+
+// flush writes pending output to xterm. Unless 'all' is set, a trailing incomplete UTF-8 sequence is held for the next flush.
+func (w *Writer) flush(all bool) {
+	w.mu.Lock()
+	w.scheduled = false
+	n := len(w.pending)
+	if !all {
+		n = common.CompleteUTF8(w.pending)
+	}
+	if n == 0 {
+		w.mu.Unlock()
+		return
+	}
+	data := string(w.pending[:n])
+	w.pending = append([]byte(nil), w.pending[n:]...)
+	w.queued += n
+	w.mu.Unlock()
+
+	w.term.Call("write", data, interop.SingleUseFunc(func(js.Value, []js.Value) interface{} {
+		w.mu.Lock()
+		w.queued -= n
+		w.drained.Broadcast()
+		w.mu.Unlock()
+		return nil
+	}))
+}
+
+// Close flushes all pending output. Further writes fail.
+func (w *Writer) Close() error {
+	w.flush(true)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	w.drained.Broadcast()
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/hack-pad/hackpad/internal/global"
 	"github.com/hack-pad/hackpad/internal/interop"
 	"github.com/hack-pad/hackpad/internal/js/fs"
+	"github.com/hack-pad/hackpad/internal/js/packages"
 	"github.com/hack-pad/hackpad/internal/js/process"
 	"github.com/hack-pad/hackpad/internal/log"
 	libProcess "github.com/hack-pad/hackpad/internal/process"
@@ -40,7 +41,7 @@ func main() {
 		return nil
 	}))
 	global.Set("profile", js.FuncOf(interop.ProfileJS))
-	global.Set("install", js.FuncOf(installFunc))
+	packages.Init(newPackageManager())
 	interop.SetInitialized()
 	select {}
 }
@@ -6,7 +6,7 @@ import "@fontsource/roboto";
 import '@fortawesome/fontawesome-free/css/all.css';
 import Compat from './Compat';
 import Loading from './Loading';
-import { install, run, observeGoDownloadProgress } from './Hackpad';
+import { install, spawn, observeGoDownloadProgress } from './Hackpad';
 import { newEditor } from './Editor';
 import { newTerminal } from './Terminal';
 
@@ -20,9 +20,10 @@ function App() {
       newTerminal,
       newEditor,
     }
-    Promise.all([ install('editor'), install('sh') ])
+    Promise.all([ install('editor'), install('sh'), install('procps'), install('coreutils'), install('pkg') ])
       .then(() => {
-        run('editor', '--editor=editor')
+        // the editor drives the DOM, so it must stay on the main thread
+        spawn({ name: 'editor', args: ['--editor=editor'], backend: 'main' })
         setLoading(false)
       })
   }, [setLoading, setPercentage])
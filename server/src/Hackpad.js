@@ -20,6 +20,10 @@ async function init() {
   go.run(cmd.instance)
   const { hackpad, fs } = window
   console.debug(`hackpad status: ${hackpad.ready ? 'ready' : 'not ready'}`)
+  const workersErr = hackpad.startWorkers({ backend: 'worker' })
+  if (workersErr) {
+    console.debug(`Running processes on the main thread: ${workersErr}`)
+  }
 
   const mkdir = promisify(fs.mkdir)
   await mkdir("/bin", {mode: 0o700})
@@ -29,7 +33,8 @@ async function init() {
   await hackpad.overlayIndexedDB('/home/me/.cache', {cache: true})
 
   await mkdir("/usr/local/go", {recursive: true, mode: 0o700})
-  await hackpad.overlayTarGzip('/usr/local/go', 'wasm/go.tar.gz', {
+  // fetches each package's files as they're first opened, rather than all of wasm/go.tar.gz up front
+  await hackpad.overlayLazy('/usr/local/go', 'wasm/go/index.json', {
     persist: true,
     skipCacheDirs: [
       '/usr/local/go/bin/js_wasm',
@@ -52,8 +57,11 @@ export async function install(name) {
 }
 
 export async function run(name, ...args) {
-  const process = await spawn({ name, args })
-  return await wait(process.pid)
+  const subprocess = await spawn({ name, args })
+  return await new Promise((resolve, reject) => {
+    subprocess.once('error', reject)
+    subprocess.once('exit', exitCode => resolve({ pid: subprocess.pid, exitCode }))
+  })
 }
 
 export async function wait(pid) {